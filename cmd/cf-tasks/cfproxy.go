@@ -2,7 +2,9 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"github.com/dgruber/go-cfclient"
+	"github.com/dgruber/ubercluster/pkg/proxy"
 	"github.com/dgruber/ubercluster/pkg/types"
 	"log"
 )
@@ -35,10 +37,8 @@ func (cp *CFProxy) RunJob(template types.JobTemplate) (jobid string, err error)
 
 func (cp *CFProxy) JobOperation(jobsessionname, operation, jobid string) (out string, err error) {
 	switch operation {
-	case "suspend":
-		err = errors.New("Unsupported operation: \"suspend\"")
-	case "resume":
-		err = errors.New("Unsupported operation: \"resume\"")
+	case "suspend", "resume", "hold", "release":
+		err = fmt.Errorf("%w: %q", proxy.ErrUnsupportedOperation, operation)
 	case "terminate":
 		err = cp.client.TerminateTask(jobid)
 		if err != nil {
@@ -124,7 +124,18 @@ func (dp *drmaa1Proxy) JobOperation(jobsessionname, operation, jobid string) (ou
 		} else {
 			out = "Terminated Job"
 		}
-		// TODO adding hold and resume
+	case "hold":
+		if opErr := dp.Session.HoldJob(jobid); opErr != nil {
+			err = opErr
+		} else {
+			out = "Held Job"
+		}
+	case "release":
+		if opErr := dp.Session.ReleaseJob(jobid); opErr != nil {
+			err = opErr
+		} else {
+			out = "Released Job"
+		}
 	default:
 		log.Println("JobOperation unknown operation ", operation)
 		err = errors.New("Unknown operation: " + operation)
@@ -197,6 +197,8 @@ func ConvertD2Machine(il []drmaa2.Machine) (ol []types.Machine) {
 		o.Architecture = (types.CPU)(i.Architecture)
 		o.OSVersion = (types.Version)(i.OSVersion)
 		o.OS = (types.OS)(i.OS)
+		o.UnavailableReason = i.UnavailableReason
+		o.Draining = i.Draining
 		ol = append(ol, o)
 	}
 	return ol
@@ -207,6 +209,9 @@ func ConvertD2Queue(il []drmaa2.Queue) (ol []types.Queue) {
 	for _, i := range il {
 		var o types.Queue
 		o.Name = i.Name
+		o.State = i.State
+		o.UsedSlots = i.UsedSlots
+		o.TotalSlots = i.TotalSlots
 		ol = append(ol, o)
 	}
 	return ol
@@ -242,12 +247,12 @@ func ConvertUCJobTemplate(u types.JobTemplate) (jt drmaa2.JobTemplate) {
 	jt.JoinFiles = u.JoinFiles
 	jt.ReservationId = u.ReservationId
 	jt.QueueName = u.QueueName
-	jt.MaxSlots = u.MaxSlots
-	jt.MinSlots = u.MinSlots
-	jt.Priority = u.Priority
+	jt.MaxSlots = types.UnsetNumToZero(u.MaxSlots)
+	jt.MinSlots = types.UnsetNumToZero(u.MinSlots)
+	jt.Priority = types.UnsetNumToZero(u.Priority)
 	jt.CandidateMachines = make([]string, len(u.CandidateMachines), len(u.CandidateMachines))
 	copy(jt.CandidateMachines, u.CandidateMachines)
-	jt.MinPhysMemory = u.MinPhysMemory
+	jt.MinPhysMemory = types.UnsetNumToZero(u.MinPhysMemory)
 	jt.MachineOs = u.MachineOs
 	jt.MachineArch = u.MachineArch
 	jt.StartTime = u.StartTime
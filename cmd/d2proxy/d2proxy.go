@@ -17,7 +17,6 @@
 package main
 
 import (
-	"errors"
 	"fmt"
 	"github.com/dgruber/drmaa2"
 	"github.com/dgruber/ubercluster/pkg/persistency"
@@ -39,15 +38,26 @@ func init() {
 }
 
 var (
-	app            = kingpin.New("d2proxy", "A proxy server for DRMAA2 compatible cluster schedulers (like Univa Grid Engine).")
-	cliVerbose     = app.Flag("verbose", "Enables enhanced logging for debugging.").Bool()
-	cliPort        = app.Flag("port", "Sets address and port on which proxy is listening.").Default(":8888").String()
-	certFile       = app.Flag("certFile", "Path to certification file for secure connections (TLS).").Default("").String()
-	keyFile        = app.Flag("keyFile", "Path to key file for secure connections (TLS).").Default("").String()
-	otp            = app.Flag("otp", "One time password settings (\"yubikey\") or a fixed shared secret.").Default("").String()
-	yubiID         = app.Flag("yubiID", "Yubi client ID if otp is set to yubikey.").Default("").String()
-	yubiSecret     = app.Flag("yubiSecret", "Yubi secret key if otp is set to yubikey").Default("").String()
-	yubiAllowedIds = app.Flag("yubiAllowedIds", "A list of IDs of yubikeys which are accepted as source for OTPs.").Default("").Strings()
+	app             = kingpin.New("d2proxy", "A proxy server for DRMAA2 compatible cluster schedulers (like Univa Grid Engine).")
+	cliVerbose      = app.Flag("verbose", "Enables enhanced logging for debugging.").Bool()
+	cliPort         = app.Flag("port", "Sets address and port on which proxy is listening.").Default(":8888").String()
+	certFile        = app.Flag("certFile", "Path to certification file for secure connections (TLS).").Default("").String()
+	keyFile         = app.Flag("keyFile", "Path to key file for secure connections (TLS).").Default("").String()
+	otp             = app.Flag("otp", "One time password settings (\"yubikey\") or a fixed shared secret.").Default("").String()
+	yubiID          = app.Flag("yubiID", "Yubi client ID if otp is set to yubikey.").Default("").String()
+	yubiSecret      = app.Flag("yubiSecret", "Yubi secret key if otp is set to yubikey").Default("").String()
+	yubiAllowedIds  = app.Flag("yubiAllowedIds", "A list of IDs of yubikeys which are accepted as source for OTPs.").Default("").Strings()
+	clientCAFile    = app.Flag("clientCAFile", "PEM file with CA certificates for verifying client certificates (mutual TLS).").Default("").String()
+	noClientCerts   = app.Flag("noClientCerts", "Serves TLS without requiring client certificates when no clientCAFile is given.").Bool()
+	rateLimit       = app.Flag("rateLimit", "Allowed requests per second and client (0 means unlimited).").Default("0").Float()
+	rateBurst       = app.Flag("rateBurst", "Amount of requests a client can send at once when rate limiting.").Default("10").Int()
+	corsOrigins     = app.Flag("corsOrigin", "Origin of a browser based client which is allowed to access the proxy (CORS), can be repeated (\"*\" allows all).").Strings()
+	validateSubmits = app.Flag("validateSubmissions", "Rejects job submissions which request unknown queues or job categories.").Bool()
+	bearerToken     = app.Flag("bearerToken", "Static token accepted in an \"Authorization: Bearer\" header besides the one time password.").Default("").String()
+	jwksURL         = app.Flag("jwksURL", "URL of a JSON Web Key Set for accepting JWT bearer tokens signed by its keys.").Default("").String()
+	jwtAudience     = app.Flag("jwtAudience", "Audience JWT bearer tokens must be issued for (required with jwksURL).").Default("").String()
+	jwtIssuer       = app.Flag("jwtIssuer", "Issuer JWT bearer tokens must be issued by (not checked when empty).").Default("").String()
+	shutdownTimeout = app.Flag("shutdownTimeout", "Time in-flight requests get for finishing when the proxy is stopped by SIGINT or SIGTERM.").Default("30s").Duration()
 )
 
 type drmaa2proxy struct {
@@ -111,6 +121,21 @@ func (d2p *drmaa2proxy) GetAllCategories() ([]string, error) {
 	return d2p.js.GetJobCategories()
 }
 
+// GetJobCategory looks up a single job category in the job session.
+func (d2p *drmaa2proxy) GetJobCategory(name string) (string, error) {
+	return d2p.js.GetJobCategory(name)
+}
+
+// CloseSessions closes the job session and the monitoring session
+// when the proxy shuts down.
+func (d2p *drmaa2proxy) CloseSessions() error {
+	jsErr := d2p.js.Close()
+	if err := d2p.ms.CloseMonitoringSession(); err != nil {
+		return err
+	}
+	return jsErr
+}
+
 func (d2p *drmaa2proxy) GetAllSessions(sessions []string) ([]string, error) {
 	log.Println("GetAllSesssions")
 	snl, err := d2p.sm.GetJobSessionNames()
@@ -146,12 +171,10 @@ func (d2p *drmaa2proxy) DRMSLoad() float64 {
 	return 0.5
 }
 
-// RunJob submits a job through the DRMAA2 API into a Univa Grid Engine
-// cluster. If the file to run is found in the file staging area then
-// the absolut path to this file is set. This removes the burden to deal
-// with the PATH. In case it is not found the file is expected to be in the
-// path.
-func (d2p *drmaa2proxy) RunJob(template types.JobTemplate) (string, error) {
+// convertStagedJobTemplate converts the job template into a DRMAA2
+// job template. If the remote command is in the staging area it is
+// executed otherwise the one in the standard path.
+func convertStagedJobTemplate(template types.JobTemplate) drmaa2.JobTemplate {
 	jt := ConvertUCJobTemplate(template)
 	// workaround: if file is in staging area exexcute it otherwise
 	// the one in standard path
@@ -163,13 +186,30 @@ func (d2p *drmaa2proxy) RunJob(template types.JobTemplate) (string, error) {
 			jt.RemoteCommand = localFile
 		}
 	}
-	if job, err := d2p.js.RunJob(jt); err != nil {
+	return jt
+}
+
+// RunJob submits a job through the DRMAA2 API into a Univa Grid Engine
+// cluster. If the file to run is found in the file staging area then
+// the absolut path to this file is set. This removes the burden to deal
+// with the PATH. In case it is not found the file is expected to be in the
+// path.
+func (d2p *drmaa2proxy) RunJob(template types.JobTemplate) (string, error) {
+	if job, err := d2p.js.RunJob(convertStagedJobTemplate(template)); err != nil {
 		return "", err
 	} else {
 		return job.GetId(), nil
 	}
 }
 
+func (d2p *drmaa2proxy) RunArrayJob(template types.JobTemplate, begin, end, step, maxParallel int) (string, error) {
+	aj, err := d2p.js.RunBulkJobs(convertStagedJobTemplate(template), begin, end, step, maxParallel)
+	if err != nil {
+		return "", err
+	}
+	return aj.GetID(), nil
+}
+
 func (d2p *drmaa2proxy) JobOperation(jobsessionname, operation, jobid string) (string, error) {
 	// The filter is missing in GetJobs() hence until this is
 	// fixed in Go DRMAA2 we use a non-scaling method and do
@@ -203,11 +243,11 @@ func (d2p *drmaa2proxy) JobOperation(jobsessionname, operation, jobid string) (s
 					return "success", nil
 				}
 			default:
-				return "", errors.New("unsupported operation")
+				return "", fmt.Errorf("%w: %s", proxy.ErrUnsupportedOperation, operation)
 			}
 		}
 	}
-	return "", errors.New("job not found")
+	return "", proxy.ErrJobNotFound
 }
 
 func main() {
@@ -252,15 +292,25 @@ func main() {
 
 	// Open MonitoringSession and create a JobSession with the given name
 	var p drmaa2proxy
+	// the sessions are closed by CloseSessions when the proxy shuts down
 	p.initializeDRMAA2(JobSessionName)
-	defer p.js.Close()
-	defer p.ms.CloseMonitoringSession()
 
 	var sc proxy.SecConfig
 	sc.OTP = *otp
 	sc.YubiID = *yubiID
 	sc.YubiSecret = *yubiSecret
 	sc.YubiAllowedIDs = *yubiAllowedIds
+	sc.ClientCAFile = *clientCAFile
+	sc.NoClientCerts = *noClientCerts
+	sc.RateLimit = *rateLimit
+	sc.RateBurst = *rateBurst
+	sc.CORSAllowedOrigins = *corsOrigins
+	sc.ValidateSubmissions = *validateSubmits
+	sc.BearerToken = *bearerToken
+	sc.JWKSURL = *jwksURL
+	sc.JWTAudience = *jwtAudience
+	sc.JWTIssuer = *jwtIssuer
+	sc.ShutdownTimeout = *shutdownTimeout
 
 	var pi persistency.DummyPersistency
 
@@ -36,15 +36,25 @@ func init() {
 
 // Standard set of CLI parameters.
 var (
-	app            = kingpin.New("dockerproxy", "A proxy server for Docker")
-	cliVerbose     = app.Flag("verbose", "Enables enhanced logging for debugging.").Bool()
-	cliPort        = app.Flag("port", "Sets address and port on which proxy is listening.").Default(":8080").String()
-	certFile       = app.Flag("certFile", "Path to certification file for secure connections (TLS).").Default("").String()
-	keyFile        = app.Flag("keyFile", "Path to key file for secure connections (TLS).").Default("").String()
-	otp            = app.Flag("otp", "One time password settings (\"yubikey\") or a fixed shared secret.").Default("").String()
-	yubiID         = app.Flag("yubiID", "Yubi client ID if otp is set to yubikey.").Default("").String()
-	yubiSecret     = app.Flag("yubiSecret", "Yubi secret key if otp is set to yubikey").Default("").String()
-	yubiAllowedIds = app.Flag("yubiAllowedIds", "A list of IDs of yubikeys which are accepted as source for OTPs.").Default("").Strings()
+	app             = kingpin.New("dockerproxy", "A proxy server for Docker")
+	cliVerbose      = app.Flag("verbose", "Enables enhanced logging for debugging.").Bool()
+	cliPort         = app.Flag("port", "Sets address and port on which proxy is listening.").Default(":8080").String()
+	certFile        = app.Flag("certFile", "Path to certification file for secure connections (TLS).").Default("").String()
+	keyFile         = app.Flag("keyFile", "Path to key file for secure connections (TLS).").Default("").String()
+	otp             = app.Flag("otp", "One time password settings (\"yubikey\") or a fixed shared secret.").Default("").String()
+	yubiID          = app.Flag("yubiID", "Yubi client ID if otp is set to yubikey.").Default("").String()
+	yubiSecret      = app.Flag("yubiSecret", "Yubi secret key if otp is set to yubikey").Default("").String()
+	yubiAllowedIds  = app.Flag("yubiAllowedIds", "A list of IDs of yubikeys which are accepted as source for OTPs.").Default("").Strings()
+	clientCAFile    = app.Flag("clientCAFile", "PEM file with CA certificates for verifying client certificates (mutual TLS).").Default("").String()
+	noClientCerts   = app.Flag("noClientCerts", "Serves TLS without requiring client certificates when no clientCAFile is given.").Bool()
+	rateLimit       = app.Flag("rateLimit", "Allowed requests per second and client (0 means unlimited).").Default("0").Float()
+	rateBurst       = app.Flag("rateBurst", "Amount of requests a client can send at once when rate limiting.").Default("10").Int()
+	corsOrigins     = app.Flag("corsOrigin", "Origin of a browser based client which is allowed to access the proxy (CORS), can be repeated (\"*\" allows all).").Strings()
+	validateSubmits = app.Flag("validateSubmissions", "Rejects job submissions which request unknown queues or job categories.").Bool()
+	bearerToken     = app.Flag("bearerToken", "Static token accepted in an \"Authorization: Bearer\" header besides the one time password.").Default("").String()
+	jwksURL         = app.Flag("jwksURL", "URL of a JSON Web Key Set for accepting JWT bearer tokens signed by its keys.").Default("").String()
+	jwtAudience     = app.Flag("jwtAudience", "Audience JWT bearer tokens must be issued for (required with jwksURL).").Default("").String()
+	jwtIssuer       = app.Flag("jwtIssuer", "Issuer JWT bearer tokens must be issued by (not checked when empty).").Default("").String()
 )
 
 func main() {
@@ -65,6 +75,16 @@ func main() {
 	sc.YubiID = *yubiID
 	sc.YubiSecret = *yubiSecret
 	sc.YubiAllowedIDs = *yubiAllowedIds
+	sc.ClientCAFile = *clientCAFile
+	sc.NoClientCerts = *noClientCerts
+	sc.RateLimit = *rateLimit
+	sc.RateBurst = *rateBurst
+	sc.CORSAllowedOrigins = *corsOrigins
+	sc.ValidateSubmissions = *validateSubmits
+	sc.BearerToken = *bearerToken
+	sc.JWKSURL = *jwksURL
+	sc.JWTAudience = *jwtAudience
+	sc.JWTIssuer = *jwtIssuer
 
 	var ps persistency.DummyPersistency
 
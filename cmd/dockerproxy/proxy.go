@@ -2,6 +2,8 @@ package main
 
 import (
 	"errors"
+	"fmt"
+	"github.com/dgruber/ubercluster/pkg/proxy"
 	"github.com/dgruber/ubercluster/pkg/types"
 	"golang.org/x/net/context"
 	"log"
@@ -61,6 +63,8 @@ func (p *Proxy) JobOperation(jobsessionname, operation, jobid string) (out strin
 			return out, err
 		}
 		return "Terminated job", nil
+	case "hold", "release":
+		err = fmt.Errorf("%w: %s is not supported for containers", proxy.ErrUnsupportedOperation, operation)
 	default:
 		log.Printf("JobOperation unknown operation: %s", operation)
 		err = errors.New("Unknown operation: " + operation)
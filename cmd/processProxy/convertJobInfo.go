@@ -3,8 +3,23 @@ package main
 import (
 	"github.com/dgruber/drmaa2interface"
 	"github.com/dgruber/ubercluster/pkg/types"
+	"os/user"
+	"strconv"
 )
 
+// jobOwnerName returns the user name of a job owner which is given
+// as numeric user id by the process tracker so that jobs can be
+// filtered by the name of their owner.
+func jobOwnerName(owner string) string {
+	if _, err := strconv.Atoi(owner); err != nil {
+		return owner
+	}
+	if u, err := user.LookupId(owner); err == nil {
+		return u.Username
+	}
+	return owner
+}
+
 func ConvertJobInfo(d drmaa2interface.JobInfo) *types.JobInfo {
 	var t types.JobInfo
 	t.Id = d.ID
@@ -16,7 +31,7 @@ func ConvertJobInfo(d drmaa2interface.JobInfo) *types.JobInfo {
 	t.AllocatedMachines = make([]string, len(d.AllocatedMachines))
 	copy(t.AllocatedMachines, d.AllocatedMachines)
 	t.SubmissionMachine = d.SubmissionMachine
-	t.JobOwner = d.JobOwner
+	t.JobOwner = jobOwnerName(d.JobOwner)
 	t.Slots = d.Slots
 	t.QueueName = d.QueueName
 	t.WallclockTime = d.WallclockTime
@@ -24,5 +39,41 @@ func ConvertJobInfo(d drmaa2interface.JobInfo) *types.JobInfo {
 	t.SubmissionTime = d.SubmissionTime
 	t.DispatchTime = d.DispatchTime
 	t.FinishTime = d.FinishTime
+	if d.ExtensionList != nil {
+		t.ExtensionList = make(map[string]string, len(d.ExtensionList))
+		for k, v := range d.ExtensionList {
+			t.ExtensionList[k] = v
+		}
+	}
 	return &t
 }
+
+// ConvertToDRMAA2JobInfo converts the job info back into the
+// DRMAA2 job info of the process tracker.
+func ConvertToDRMAA2JobInfo(t types.JobInfo) drmaa2interface.JobInfo {
+	var d drmaa2interface.JobInfo
+	d.ID = t.Id
+	d.ExitStatus = t.ExitStatus
+	d.TerminatingSignal = t.TerminatingSignal
+	d.Annotation = t.Annotation
+	d.State = (drmaa2interface.JobState)(t.State)
+	d.SubState = t.SubState
+	d.AllocatedMachines = make([]string, len(t.AllocatedMachines))
+	copy(d.AllocatedMachines, t.AllocatedMachines)
+	d.SubmissionMachine = t.SubmissionMachine
+	d.JobOwner = t.JobOwner
+	d.Slots = t.Slots
+	d.QueueName = t.QueueName
+	d.WallclockTime = t.WallclockTime
+	d.CPUTime = t.CPUTime
+	d.SubmissionTime = t.SubmissionTime
+	d.DispatchTime = t.DispatchTime
+	d.FinishTime = t.FinishTime
+	if t.ExtensionList != nil {
+		d.ExtensionList = make(map[string]string, len(t.ExtensionList))
+		for k, v := range t.ExtensionList {
+			d.ExtensionList[k] = v
+		}
+	}
+	return d
+}
@@ -6,6 +6,7 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"os/user"
 	"time"
 
 	"github.com/dgruber/drmaa2interface"
@@ -79,6 +80,14 @@ var _ = Describe("ConvertJobInfo", func() {
 			Ω(output.FinishTime).Should(Equal(expected.FinishTime))
 		})
 
+		It("must convert a numeric job owner into the user name", func() {
+			current, err := user.Current()
+			Ω(err).Should(BeNil())
+			input.JobOwner = current.Uid
+			output := ConvertJobInfo(input)
+			Ω(output.JobOwner).Should(Equal(current.Username))
+		})
+
 	})
 
 })
@@ -33,12 +33,12 @@ func ConvertJobTemplate(u types.JobTemplate) (jt drmaa2interface.JobTemplate) {
 	jt.JoinFiles = u.JoinFiles
 	jt.ReservationID = u.ReservationId
 	jt.QueueName = u.QueueName
-	jt.MaxSlots = u.MaxSlots
-	jt.MinSlots = u.MinSlots
-	jt.Priority = u.Priority
+	jt.MaxSlots = types.UnsetNumToZero(u.MaxSlots)
+	jt.MinSlots = types.UnsetNumToZero(u.MinSlots)
+	jt.Priority = types.UnsetNumToZero(u.Priority)
 	jt.CandidateMachines = make([]string, len(u.CandidateMachines), len(u.CandidateMachines))
 	copy(jt.CandidateMachines, u.CandidateMachines)
-	jt.MinPhysMemory = u.MinPhysMemory
+	jt.MinPhysMemory = types.UnsetNumToZero(u.MinPhysMemory)
 	jt.MachineOs = u.MachineOs
 	jt.MachineArch = u.MachineArch
 	jt.StartTime = u.StartTime
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"github.com/dgruber/drmaa2interface"
+	"github.com/dgruber/ubercluster/pkg/persistency"
+)
+
+// TrackerPersistency saves the jobs of the process tracker in the
+// persistency of the proxy so that running jobs are taken over after
+// the proxy is restarted.
+type TrackerPersistency struct {
+	store persistency.JobInfoStore
+}
+
+// NewTrackerPersistency creates the persister of the process tracker.
+func NewTrackerPersistency(store persistency.JobInfoStore) TrackerPersistency {
+	return TrackerPersistency{store: store}
+}
+
+func (tp TrackerPersistency) SaveJobInfo(jobid string, ji drmaa2interface.JobInfo) error {
+	return tp.store.SaveJobInfo(jobid, *ConvertJobInfo(ji))
+}
+
+func (tp TrackerPersistency) LoadJobInfos() (map[string]drmaa2interface.JobInfo, error) {
+	jobinfos, err := tp.store.LoadJobInfos()
+	if err != nil {
+		return nil, err
+	}
+	converted := make(map[string]drmaa2interface.JobInfo, len(jobinfos))
+	for jobid, ji := range jobinfos {
+		converted[jobid] = ConvertToDRMAA2JobInfo(ji)
+	}
+	return converted, nil
+}
@@ -0,0 +1,119 @@
+package main_test
+
+import (
+	. "github.com/dgruber/ubercluster/cmd/processProxy"
+
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/dgruber/drmaa2interface"
+	"github.com/dgruber/drmaa2os/pkg/jobtracker/simpletracker"
+	"github.com/dgruber/ubercluster/pkg/persistency"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Persistency", func() {
+
+	var (
+		dir string
+		bp  *persistency.BoltPersistency
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "processProxyState")
+		Ω(err).Should(BeNil())
+		bp, err = persistency.NewBoltPersistency(filepath.Join(dir, "state.db"))
+		Ω(err).Should(BeNil())
+	})
+
+	AfterEach(func() {
+		bp.Close()
+		os.RemoveAll(dir)
+		// the job ids are global, other tests expect to start with 1
+		simpletracker.SetJobID(0)
+	})
+
+	It("should take over running jobs after a restart", func() {
+
+		before := simpletracker.New("before")
+		defer before.Destroy()
+		Ω(before.SetPersister(NewTrackerPersistency(bp))).Should(BeNil())
+		running, err := before.AddJob(drmaa2interface.JobTemplate{RemoteCommand: "sleep", Args: []string{"30"}})
+		Ω(err).Should(BeNil())
+		finished, err := before.AddJob(drmaa2interface.JobTemplate{RemoteCommand: "true"})
+		Ω(err).Should(BeNil())
+		Ω(before.Wait(finished, 5*time.Second, drmaa2interface.Done, drmaa2interface.Failed)).Should(BeNil())
+		Eventually(func() int {
+			jobinfos, _ := bp.LoadJobInfos()
+			return int(jobinfos[finished].State)
+		}).Should(Equal(int(drmaa2interface.Done)))
+
+		after := simpletracker.New("after")
+		defer after.Destroy()
+		Ω(after.SetPersister(NewTrackerPersistency(bp))).Should(BeNil())
+		Ω(after.JobState(running)).Should(Equal(drmaa2interface.Running))
+		Ω(after.JobState(finished)).Should(Equal(drmaa2interface.Done))
+
+		next, err := after.AddJob(drmaa2interface.JobTemplate{RemoteCommand: "true"})
+		Ω(err).Should(BeNil())
+		nextID, _ := strconv.Atoi(next)
+		runningID, _ := strconv.Atoi(running)
+		Ω(nextID).Should(BeNumerically(">", runningID))
+
+		Ω(after.JobControl(running, "terminate_forced")).Should(BeNil())
+		Eventually(func() string {
+			ji, _ := after.JobInfo(running)
+			return ji.SubState
+		}, 5*time.Second).Should(Equal("exit status unknown after restart"))
+		Ω(after.JobState(running)).Should(Equal(drmaa2interface.Failed))
+	})
+
+	It("should fail jobs whose process is gone", func() {
+		tp := NewTrackerPersistency(bp)
+		ji := drmaa2interface.JobInfo{ID: "4711", State: drmaa2interface.Running,
+			ExtensionList: map[string]string{simpletracker.PIDExtension: "0"}}
+		Ω(tp.SaveJobInfo("4711", ji)).Should(BeNil())
+
+		tracker := simpletracker.New("lost")
+		defer tracker.Destroy()
+		Ω(tracker.SetPersister(tp)).Should(BeNil())
+		Ω(tracker.JobState("4711")).Should(Equal(drmaa2interface.Failed))
+		Ω(tracker.Wait("4711", time.Second, drmaa2interface.Failed)).Should(BeNil())
+	})
+
+	It("should not control restored jobs which are finished", func() {
+		tp := NewTrackerPersistency(bp)
+		ji := drmaa2interface.JobInfo{ID: "4712", State: drmaa2interface.Done}
+		Ω(tp.SaveJobInfo("4712", ji)).Should(BeNil())
+
+		tracker := simpletracker.New("finished")
+		defer tracker.Destroy()
+		Ω(tracker.SetPersister(tp)).Should(BeNil())
+		Ω(tracker.JobState("4712")).Should(Equal(drmaa2interface.Done))
+		Ω(tracker.JobControl("4712", "terminate_forced")).ShouldNot(BeNil())
+		Ω(tracker.JobControl("4712", "suspend")).ShouldNot(BeNil())
+		Ω(tracker.JobControl("4712", "resume")).ShouldNot(BeNil())
+	})
+
+	It("should not take over a process which was started after the job", func() {
+		tp := NewTrackerPersistency(bp)
+		// the pid of the test process belongs to another "job" now
+		ji := drmaa2interface.JobInfo{ID: "4713", State: drmaa2interface.Running,
+			DispatchTime:  time.Now().Add(-24 * time.Hour),
+			ExtensionList: map[string]string{simpletracker.PIDExtension: strconv.Itoa(os.Getpid())}}
+		Ω(tp.SaveJobInfo("4713", ji)).Should(BeNil())
+
+		tracker := simpletracker.New("reused")
+		defer tracker.Destroy()
+		Ω(tracker.SetPersister(tp)).Should(BeNil())
+		Ω(tracker.JobState("4713")).Should(Equal(drmaa2interface.Failed))
+		Ω(tracker.JobControl("4713", "terminate_forced")).ShouldNot(BeNil())
+	})
+
+})
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
@@ -29,6 +30,20 @@ var (
 	keyFile            = app.Flag("key", "Path to key file for secure connections (TLS).").Default("").String()
 	otp                = app.Flag("otp", "One time password settings (\"yubikey\") or a fixed shared secret.").Default("").String()
 	trustedClientCerts = app.Flag("clientCerts", "Path to directory where trusted client certificates are stored.").Default("").String()
+	rateLimit          = app.Flag("rateLimit", "Allowed requests per second and client (0 means unlimited).").Default("0").Float()
+	rateBurst          = app.Flag("rateBurst", "Amount of requests a client can send at once when rate limiting.").Default("10").Int()
+	corsOrigins        = app.Flag("corsOrigin", "Origin of a browser based client which is allowed to access the proxy (CORS), can be repeated (\"*\" allows all).").Strings()
+	clientCAFile       = app.Flag("clientCAFile", "PEM file with CA certificates for verifying client certificates (mutual TLS).").Default("").String()
+	noClientCerts      = app.Flag("noClientCerts", "Serves TLS without requiring client certificates when no clientCerts or clientCAFile are given.").Bool()
+	maxRunningJobs     = app.Flag("maxRunningJobs", "Maximum amount of jobs running at the same time, further jobs are queued (0 means unlimited).").Default("0").Int()
+	validateSubmits    = app.Flag("validateSubmissions", "Rejects job submissions which request unknown queues or job categories.").Bool()
+	bearerToken        = app.Flag("bearerToken", "Static token accepted in an \"Authorization: Bearer\" header besides the one time password.").Default("").String()
+	jwksURL            = app.Flag("jwksURL", "URL of a JSON Web Key Set for accepting JWT bearer tokens signed by its keys.").Default("").String()
+	jwtAudience        = app.Flag("jwtAudience", "Audience JWT bearer tokens must be issued for (required with jwksURL).").Default("").String()
+	jwtIssuer          = app.Flag("jwtIssuer", "Issuer JWT bearer tokens must be issued by (not checked when empty).").Default("").String()
+	stateDB            = app.Flag("stateDB", "BoltDB file where the jobs are saved so that running jobs are taken over after a restart (their exit status is unknown then, they are reported as Failed when they end).").Default("").String()
+	outputRetention    = app.Flag("outputRetention", "Time the output of a finished job can be requested, temporary output files are removed afterwards (0 keeps them).").Default("24h").Duration()
+	shutdownTimeout    = app.Flag("shutdownTimeout", "Time in-flight requests get for finishing when the proxy is stopped by SIGINT or SIGTERM.").Default("30s").Duration()
 )
 
 func main() {
@@ -38,12 +53,33 @@ func main() {
 		log.SetOutput(os.Stdout)
 	}
 
-	processProxy := NewProxy()
+	var store persistency.JobInfoStore
+	if *stateDB != "" {
+		bp, err := persistency.NewBoltPersistency(*stateDB)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not use state database (%s).\n", err.Error())
+			os.Exit(1)
+		}
+		defer bp.Close()
+		store = bp
+	}
+
+	processProxy := NewProxy(*maxRunningJobs, store)
+	processProxy.SetOutputRetention(*outputRetention)
 	sc := proxy.SecConfig{
 		OTP:                  *otp,
 		TrustedClientCertDir: *trustedClientCerts,
+		ClientCAFile:         *clientCAFile,
+		NoClientCerts:        *noClientCerts,
+		RateLimit:            *rateLimit,
+		RateBurst:            *rateBurst,
+		CORSAllowedOrigins:   *corsOrigins,
+		ValidateSubmissions:  *validateSubmits,
+		BearerToken:          *bearerToken,
+		JWKSURL:              *jwksURL,
+		JWTAudience:          *jwtAudience,
+		JWTIssuer:            *jwtIssuer,
+		ShutdownTimeout:      *shutdownTimeout,
 	}
-	var ps persistency.DummyPersistency
-
-	proxy.ProxyListenAndServe(*cliPort, *certFile, *keyFile, sc, &ps, &processProxy)
+	proxy.ProxyListenAndServe(*cliPort, *certFile, *keyFile, sc, processProxy.Persistency, &processProxy)
 }
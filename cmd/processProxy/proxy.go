@@ -3,25 +3,45 @@ package main
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
 
 	"github.com/dgruber/drmaa2interface"
 	"github.com/dgruber/drmaa2os"
+	"github.com/dgruber/ubercluster/pkg/persistency"
+	"github.com/dgruber/ubercluster/pkg/proxy"
 	"github.com/dgruber/ubercluster/pkg/types"
 )
 
 type Proxy struct {
 	SessionManager *drmaa2os.SessionManager
 	JobSession     drmaa2interface.JobSession
+	Persistency    persistency.PersistencyImplementer
+	outputs        *outputFiles
+	maxRunningJobs int
 }
 
-func NewProxy() Proxy {
+// NewProxy creates the process proxy. With maxRunningJobs > 0 only
+// that amount of jobs runs at the same time, further jobs are Queued.
+// When a store is given the jobs are saved in it and running jobs are
+// taken over when the proxy is started again.
+func NewProxy(maxRunningJobs int, store persistency.JobInfoStore) Proxy {
 	sm, err := drmaa2os.NewDefaultSessionManager("ucProxy.db")
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Could not create SessionManager for processes (%s).\n", err.Error())
 		os.Exit(1)
 	}
+	sm.SetMaxRunningJobs(maxRunningJobs)
+	var pi persistency.PersistencyImplementer = &persistency.DummyPersistency{}
+	if store != nil {
+		sm.SetJobPersister(NewTrackerPersistency(store))
+		pi = store
+	}
 	js, errCreate := sm.CreateJobSession(SESSION_NAME, "")
 	if errCreate != nil {
 		var errOpen error
@@ -34,12 +54,15 @@ func NewProxy() Proxy {
 	return Proxy{
 		SessionManager: sm,
 		JobSession:     js,
+		Persistency:    pi,
+		outputs:        &outputFiles{files: make(map[string]outputFile), retention: DefaultOutputRetention},
+		maxRunningJobs: maxRunningJobs,
 	}
 }
 
-// RunJob creates a process.
-func (p *Proxy) RunJob(template types.JobTemplate) (string, error) {
-	// file path fix when the app is uploaded
+// stagedCommand fixes the file path of the remote command when
+// the app is uploaded to the staging area.
+func stagedCommand(template types.JobTemplate) types.JobTemplate {
 	localFile := template.WorkingDirectory + "/" + template.RemoteCommand
 	log.Println("Local file: ", localFile)
 	if fi, statErr := os.Stat(localFile); statErr == nil {
@@ -49,15 +72,38 @@ func (p *Proxy) RunJob(template types.JobTemplate) (string, error) {
 			template.RemoteCommand = localFile
 		}
 	}
+	return template
+}
 
+// RunJob creates a process.
+func (p *Proxy) RunJob(template types.JobTemplate) (string, error) {
+	p.purgeOutputs()
+	temporary := template.OutputPath == ""
+	template, err := withOutputFile(stagedCommand(template))
+	if err != nil {
+		return "", err
+	}
 	job, err := p.JobSession.RunJob(ConvertJobTemplate(template))
 	if err != nil {
+		if temporary {
+			os.Remove(template.OutputPath)
+		}
 		return "", err
 	}
-
+	p.outputs.set(job.GetID(), template.OutputPath, temporary)
 	return job.GetID(), nil
 }
 
+// RunArrayJob creates a process for each task of the array job.
+func (p *Proxy) RunArrayJob(template types.JobTemplate, begin, end, step, maxParallel int) (string, error) {
+	aj, err := p.JobSession.RunBulkJobs(ConvertJobTemplate(stagedCommand(template)), begin, end, step, maxParallel)
+	if err != nil {
+		return "", err
+	}
+
+	return aj.GetID(), nil
+}
+
 func jobByID(p *Proxy, jobid string) (drmaa2interface.Job, error) {
 	filter := drmaa2interface.CreateJobInfo()
 	filter.ID = jobid
@@ -66,7 +112,7 @@ func jobByID(p *Proxy, jobid string) (drmaa2interface.Job, error) {
 		return nil, err
 	}
 	if len(jobs) < 1 {
-		return nil, errors.New("couldn't fetch job (job list length 0)")
+		return nil, proxy.ErrJobNotFound
 	}
 	return jobs[0], nil
 }
@@ -97,7 +143,8 @@ func (p *Proxy) JobOperation(jobsessionname, operation, jobid string) (out strin
 		} else {
 			out = "Terminated Job"
 		}
-		// hold and resume not supported for processes
+	case "hold", "release":
+		err = fmt.Errorf("%w: %s is not supported for processes", proxy.ErrUnsupportedOperation, operation)
 	default:
 		log.Println("JobOperation unknown operation ", operation)
 		err = errors.New("Unknown operation: " + operation)
@@ -107,23 +154,19 @@ func (p *Proxy) JobOperation(jobsessionname, operation, jobid string) (out strin
 
 // GetJobInfosByFilter
 func (p *Proxy) GetJobInfosByFilter(filtered bool, filter types.JobInfo) []types.JobInfo {
-	if filtered == false {
-		jobs, err := p.JobSession.GetJobs(drmaa2interface.CreateJobInfo())
-		if err != nil {
-			fmt.Printf("GetJobInfosByFilter(): %s\n", err.Error())
-			return nil
-		}
-		jobInfos := make([]types.JobInfo, len(jobs))
-		for _, job := range jobs {
-			j := p.GetJobInfo(job.GetID())
-			if j != nil {
-				jobInfos = append(jobInfos, *j)
-			}
+	jobs, err := p.JobSession.GetJobs(drmaa2interface.CreateJobInfo())
+	if err != nil {
+		fmt.Printf("GetJobInfosByFilter(): %s\n", err.Error())
+		return nil
+	}
+	jobInfos := make([]types.JobInfo, 0, len(jobs))
+	for _, job := range jobs {
+		j := p.GetJobInfo(job.GetID())
+		if j != nil && (filtered == false || j.Matches(filter)) {
+			jobInfos = append(jobInfos, *j)
 		}
-		return jobInfos
 	}
-	fmt.Println("GetJobInfosByFilter() with filter not implemented")
-	return nil
+	return jobInfos
 }
 
 // GetJobInfo returns information about a job.
@@ -159,10 +202,20 @@ func (p *Proxy) GetAllMachines(machines []string) ([]types.Machine, error) {
 	return []types.Machine{}, nil
 }
 
-// GetAllQueues
+// GetAllQueues returns the "os" queue. It has as many slots as jobs
+// are allowed to run at the same time (one for each CPU of the machine
+// when the amount is unlimited), each running process occupies one slot.
 func (p *Proxy) GetAllQueues(queues []string) ([]types.Queue, error) {
+	running := types.CreateJobInfo()
+	running.State = types.Running
 	q := types.Queue{
-		Name: "os",
+		Name:       "os",
+		State:      "enabled",
+		UsedSlots:  int64(len(p.GetJobInfosByFilter(true, running))),
+		TotalSlots: int64(runtime.NumCPU()),
+	}
+	if p.maxRunningJobs > 0 {
+		q.TotalSlots = int64(p.maxRunningJobs)
 	}
 	if queues == nil {
 		return []types.Queue{q}, nil
@@ -215,3 +268,145 @@ func (p *Proxy) DRMSName() string {
 func (p *Proxy) DRMSLoad() float64 {
 	return 0.5
 }
+
+// DefaultOutputRetention is the time the output of a finished job
+// can be requested.
+const DefaultOutputRetention = 24 * time.Hour
+
+// outputFiles remembers the output file of each job since the job
+// template is not available anymore when the job is fetched later.
+// The files are forgotten after the retention period once the job
+// is finished.
+type outputFiles struct {
+	sync.Mutex
+	files     map[string]outputFile
+	retention time.Duration
+	lastPurge time.Time
+}
+
+type outputFile struct {
+	path      string
+	temporary bool      // created by the proxy and removed when forgotten
+	finished  time.Time // when the job was found to be finished
+}
+
+func (of *outputFiles) set(jobid, path string, temporary bool) {
+	of.Lock()
+	defer of.Unlock()
+	of.files[jobid] = outputFile{path: path, temporary: temporary}
+}
+
+func (of *outputFiles) get(jobid string) (string, bool) {
+	of.Lock()
+	defer of.Unlock()
+	file, exists := of.files[jobid]
+	return file.path, exists
+}
+
+// purgeDue returns true when the output files should be checked
+// for jobs which finished before the retention period. They are
+// checked at most once a minute.
+func (of *outputFiles) purgeDue(now time.Time) bool {
+	of.Lock()
+	defer of.Unlock()
+	if of.retention <= 0 {
+		return false
+	}
+	interval := of.retention
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+	if now.Sub(of.lastPurge) < interval {
+		return false
+	}
+	of.lastPurge = now
+	return true
+}
+
+// purge forgets the output files of the jobs which are finished for
+// longer than the retention period and removes the temporary ones.
+// The retention period starts when a job is found to be finished.
+func (of *outputFiles) purge(now time.Time, unfinished map[string]bool) {
+	of.Lock()
+	defer of.Unlock()
+	for jobid, file := range of.files {
+		if unfinished[jobid] {
+			continue
+		}
+		if file.finished.IsZero() {
+			file.finished = now
+			of.files[jobid] = file
+			continue
+		}
+		if now.Sub(file.finished) < of.retention {
+			continue
+		}
+		if file.temporary {
+			if err := os.Remove(file.path); err != nil && !os.IsNotExist(err) {
+				log.Printf("Could not remove output file of job %s: %s\n", jobid, err)
+			}
+		}
+		delete(of.files, jobid)
+	}
+}
+
+// SetOutputRetention sets the time the output of a finished job can
+// be requested. With 0 the output is kept as long as the proxy runs.
+func (p *Proxy) SetOutputRetention(retention time.Duration) {
+	p.outputs.Lock()
+	defer p.outputs.Unlock()
+	p.outputs.retention = retention
+}
+
+// purgeOutputs forgets the output files of jobs which finished before
+// the retention period so that they don't pile up.
+func (p *Proxy) purgeOutputs() {
+	if !p.outputs.purgeDue(time.Now()) {
+		return
+	}
+	jobs, err := p.JobSession.GetJobs(drmaa2interface.CreateJobInfo())
+	if err != nil {
+		log.Printf("Could not get jobs for removing output files: %s\n", err)
+		return
+	}
+	unfinished := make(map[string]bool, len(jobs))
+	for _, job := range jobs {
+		if state := job.GetState(); state != drmaa2interface.Done && state != drmaa2interface.Failed {
+			unfinished[job.GetID()] = true
+		}
+	}
+	p.outputs.purge(time.Now(), unfinished)
+}
+
+// withOutputFile sets an output file for jobs which don't specify
+// one so that the output of the job can be requested by the client.
+func withOutputFile(template types.JobTemplate) (types.JobTemplate, error) {
+	if template.OutputPath != "" {
+		if !filepath.IsAbs(template.OutputPath) && template.WorkingDirectory != "" {
+			template.OutputPath = filepath.Join(template.WorkingDirectory, template.OutputPath)
+		}
+		return template, nil
+	}
+	file, err := ioutil.TempFile(template.WorkingDirectory, "ucjob-")
+	if err != nil {
+		return template, err
+	}
+	file.Close()
+	template.OutputPath = file.Name()
+	return template, nil
+}
+
+// JobOutputPath returns the path to the output file of a job.
+func (p *Proxy) JobOutputPath(jobsessionname, jobid string) (string, error) {
+	p.purgeOutputs()
+	if path, exists := p.outputs.get(jobid); exists {
+		return path, nil
+	}
+	return "", fmt.Errorf("output file of job %s is not known", jobid)
+}
+
+// CloseSessions closes the job session when the proxy shuts down.
+// Running processes are not affected.
+func (p *Proxy) CloseSessions() error {
+	return p.JobSession.Close()
+}
@@ -3,6 +3,14 @@ package main_test
 import (
 	. "github.com/dgruber/ubercluster/cmd/processProxy"
 
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dgruber/drmaa2interface"
+	"github.com/dgruber/drmaa2os/pkg/jobtracker/simpletracker"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
@@ -14,7 +22,7 @@ var _ = Describe("Proxy", func() {
 	jtemplate := types.JobTemplate{RemoteCommand: "sleep", Args: []string{"0"}}
 
 	Context("basic operations", func() {
-		proxy := NewProxy()
+		proxy := NewProxy(0, nil)
 
 		It("should be possible to create a NewProxy()", func() {
 			Ω(proxy.SessionManager).ShouldNot(BeNil())
@@ -27,6 +35,74 @@ var _ = Describe("Proxy", func() {
 			Ω(jobid).Should(Equal("1"))
 		})
 
+		It("should be possible to run an array job", func() {
+			jobid, err := proxy.RunArrayJob(jtemplate, 1, 3, 1, 0)
+			Ω(err).Should(BeNil())
+			Ω(jobid).ShouldNot(Equal(""))
+		})
+
+		It("should finish a job which reads its input file until EOF", func() {
+			in, err := ioutil.TempFile("", "processProxyInput")
+			Ω(err).Should(BeNil())
+			defer os.Remove(in.Name())
+			in.WriteString("input\n")
+			in.Close()
+
+			jobid, err := proxy.RunJob(types.JobTemplate{RemoteCommand: "cat", InputPath: in.Name()})
+			Ω(err).Should(BeNil())
+			filter := drmaa2interface.CreateJobInfo()
+			filter.ID = jobid
+			jobs, err := proxy.JobSession.GetJobs(filter)
+			Ω(err).Should(BeNil())
+			Ω(jobs).Should(HaveLen(1))
+			Ω(jobs[0].WaitTerminated(5 * time.Second)).Should(BeNil())
+			Ω(jobs[0].GetState()).Should(Equal(drmaa2interface.Done))
+		})
+
+		It("should reject a job whose input file does not exist", func() {
+			_, err := proxy.RunJob(types.JobTemplate{RemoteCommand: "cat", InputPath: "/nonexistent/processProxyInput"})
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Error()).Should(ContainSubstring("InputPath"))
+		})
+
+		It("should write the output of a job into a file", func() {
+			jobid, err := proxy.RunJob(types.JobTemplate{RemoteCommand: "echo", Args: []string{"hello"}})
+			Ω(err).Should(BeNil())
+			path, err := proxy.JobOutputPath(SESSION_NAME, jobid)
+			Ω(err).Should(BeNil())
+			defer os.Remove(path)
+			Eventually(func() string {
+				out, _ := ioutil.ReadFile(path)
+				return string(out)
+			}, 5*time.Second).Should(Equal("hello\n"))
+			_, err = proxy.JobOutputPath(SESSION_NAME, "unknown")
+			Ω(err).ShouldNot(BeNil())
+		})
+
+		It("should write stdout and stderr into one file when JoinFiles is set", func() {
+			out, err := ioutil.TempFile("", "processProxyJoined")
+			Ω(err).Should(BeNil())
+			out.Close()
+			defer os.Remove(out.Name())
+
+			jobid, err := proxy.RunJob(types.JobTemplate{
+				RemoteCommand: "sh",
+				Args:          []string{"-c", "echo out1; echo err1 1>&2; echo out2"},
+				OutputPath:    out.Name(),
+				JoinFiles:     true,
+			})
+			Ω(err).Should(BeNil())
+			filter := drmaa2interface.CreateJobInfo()
+			filter.ID = jobid
+			jobs, err := proxy.JobSession.GetJobs(filter)
+			Ω(err).Should(BeNil())
+			Ω(jobs).Should(HaveLen(1))
+			Ω(jobs[0].WaitTerminated(5 * time.Second)).Should(BeNil())
+			content, err := ioutil.ReadFile(out.Name())
+			Ω(err).Should(BeNil())
+			Ω(string(content)).Should(Equal("out1\nerr1\nout2\n"))
+		})
+
 		It("should be possible to do a JobOperation()", func() {
 			jobid, err := proxy.RunJob(jtemplate)
 			Ω(err).Should(BeNil())
@@ -41,6 +117,19 @@ var _ = Describe("Proxy", func() {
 			Ω(jis).ShouldNot(BeNil())
 		})
 
+		It("should be possible to filter GetJobInfosByFilter()", func() {
+			jobid, err := proxy.RunJob(jtemplate)
+			Ω(err).Should(BeNil())
+			filter := types.CreateJobInfo()
+			filter.Id = jobid
+			jis := proxy.GetJobInfosByFilter(true, filter)
+			Ω(jis).Should(HaveLen(1))
+			Ω(jis[0].Id).Should(Equal(jobid))
+			filter.Id = "unknown"
+			jis = proxy.GetJobInfosByFilter(true, filter)
+			Ω(jis).Should(BeEmpty())
+		})
+
 		It("should be possible to GetJobInfo()", func() {
 			jobid, err := proxy.RunJob(jtemplate)
 			Ω(err).Should(BeNil())
@@ -48,6 +137,19 @@ var _ = Describe("Proxy", func() {
 			Ω(ji).ShouldNot(BeNil())
 		})
 
+		It("should report the dispatch time and process id of a running job", func() {
+			jobid, err := proxy.RunJob(types.JobTemplate{RemoteCommand: "sleep", Args: []string{"10"}})
+			Ω(err).Should(BeNil())
+			ji := proxy.GetJobInfo(jobid)
+			Ω(ji).ShouldNot(BeNil())
+			Ω(ji.State).Should(Equal(types.Running))
+			Ω(ji.DispatchTime).Should(BeTemporally("~", time.Now(), 5*time.Second))
+			Ω(ji.ExtensionList).Should(HaveKey("pid"))
+			Ω(ji.ExtensionList["pid"]).ShouldNot(Equal("0"))
+			_, errOp := proxy.JobOperation(SESSION_NAME, "terminate", jobid)
+			Ω(errOp).Should(BeNil())
+		})
+
 		It("should be possible to GetAllMaschines()", func() {
 			hostnames, err := proxy.GetAllMachines(nil)
 			Ω(err).Should(BeNil())
@@ -68,6 +170,8 @@ var _ = Describe("Proxy", func() {
 			Ω(queues).ShouldNot(BeNil())
 			Ω(len(queues)).Should(BeNumerically("==", 1))
 			Ω(queues[0].Name).Should(Equal("os"))
+			Ω(queues[0].State).Should(Equal("enabled"))
+			Ω(queues[0].TotalSlots).Should(BeNumerically(">", 0))
 		})
 
 		It("should be possible to filter GetAllQueues()", func() {
@@ -116,4 +220,151 @@ var _ = Describe("Proxy", func() {
 
 	})
 
+	Context("limited amount of running jobs", func() {
+		var wd, dir string
+
+		// the session manager of another proxy keeps ucProxy.db locked
+		BeforeEach(func() {
+			var err error
+			wd, err = os.Getwd()
+			Ω(err).Should(BeNil())
+			dir, err = ioutil.TempDir("", "processProxyLimited")
+			Ω(err).Should(BeNil())
+			Ω(os.Chdir(dir)).Should(BeNil())
+		})
+
+		AfterEach(func() {
+			os.Chdir(wd)
+			os.RemoveAll(dir)
+		})
+
+		It("should queue jobs and terminate queued jobs only once", func() {
+			proxy := NewProxy(1, nil)
+			running, err := proxy.RunJob(types.JobTemplate{RemoteCommand: "sleep", Args: []string{"30"}})
+			Ω(err).Should(BeNil())
+			queued, err := proxy.RunJob(types.JobTemplate{RemoteCommand: "sleep", Args: []string{"30"}})
+			Ω(err).Should(BeNil())
+			Ω(proxy.GetJobInfo(queued).State).Should(Equal(types.Queued))
+
+			// the queue has a slot for each job which is allowed to run
+			queues, err := proxy.GetAllQueues(nil)
+			Ω(err).Should(BeNil())
+			Ω(queues[0].UsedSlots).Should(BeNumerically("==", 1))
+			Ω(queues[0].TotalSlots).Should(BeNumerically("==", 1))
+
+			_, errOp := proxy.JobOperation(SESSION_NAME, "terminate", queued)
+			Ω(errOp).Should(BeNil())
+			Ω(proxy.GetJobInfo(queued).State).Should(Equal(types.Failed))
+
+			// a finished job has no process which could be signalled
+			_, errOp = proxy.JobOperation(SESSION_NAME, "terminate", queued)
+			Ω(errOp).ShouldNot(BeNil())
+			_, errOp = proxy.JobOperation(SESSION_NAME, "suspend", queued)
+			Ω(errOp).ShouldNot(BeNil())
+			_, errOp = proxy.JobOperation(SESSION_NAME, "resume", queued)
+			Ω(errOp).ShouldNot(BeNil())
+
+			_, errOp = proxy.JobOperation(SESSION_NAME, "terminate", running)
+			Ω(errOp).Should(BeNil())
+		})
+
+	})
+
+	Context("output retention", func() {
+		var wd, dir string
+
+		// the session manager of another proxy keeps ucProxy.db locked
+		BeforeEach(func() {
+			var err error
+			wd, err = os.Getwd()
+			Ω(err).Should(BeNil())
+			dir, err = ioutil.TempDir("", "processProxyRetention")
+			Ω(err).Should(BeNil())
+			Ω(os.Chdir(dir)).Should(BeNil())
+		})
+
+		AfterEach(func() {
+			os.Chdir(wd)
+			os.RemoveAll(dir)
+		})
+
+		It("should forget the output of finished jobs after the retention period", func() {
+			proxy := NewProxy(0, nil)
+			proxy.SetOutputRetention(100 * time.Millisecond)
+			temporary, err := proxy.RunJob(types.JobTemplate{RemoteCommand: "echo", Args: []string{"hello"}})
+			Ω(err).Should(BeNil())
+			temporaryPath, err := proxy.JobOutputPath(SESSION_NAME, temporary)
+			Ω(err).Should(BeNil())
+			given, err := proxy.RunJob(types.JobTemplate{RemoteCommand: "echo", Args: []string{"hello"},
+				OutputPath: "given.out", WorkingDirectory: dir})
+			Ω(err).Should(BeNil())
+
+			Eventually(func() error {
+				_, err := proxy.JobOutputPath(SESSION_NAME, given)
+				return err
+			}, 5*time.Second, 50*time.Millisecond).ShouldNot(BeNil())
+			_, err = proxy.JobOutputPath(SESSION_NAME, temporary)
+			Ω(err).ShouldNot(BeNil())
+
+			// only the output files created by the proxy are removed
+			_, err = os.Stat(temporaryPath)
+			Ω(os.IsNotExist(err)).Should(BeTrue())
+			_, err = os.Stat(filepath.Join(dir, "given.out"))
+			Ω(err).Should(BeNil())
+		})
+
+	})
+
+	Context("terminating jobs", func() {
+
+		var tracker *simpletracker.JobTracker
+
+		BeforeEach(func() {
+			tracker = simpletracker.New("terminate")
+		})
+
+		AfterEach(func() {
+			tracker.Destroy()
+			// the job ids are global, other tests expect to start with 1
+			simpletracker.SetJobID(0)
+		})
+
+		It("should not fail a job before its process exited", func() {
+			tracker.SetTerminateGracePeriod(10 * time.Second)
+			jobid, err := tracker.AddJob(drmaa2interface.JobTemplate{RemoteCommand: "sh",
+				Args: []string{"-c", "trap 'sleep 1; exit 1' TERM; sleep 30 & wait"}})
+			Ω(err).Should(BeNil())
+			Eventually(func() drmaa2interface.JobState {
+				return tracker.JobState(jobid)
+			}, 5*time.Second).Should(Equal(drmaa2interface.Running))
+			// give the shell time to set up the trap
+			time.Sleep(200 * time.Millisecond)
+
+			Ω(tracker.JobControl(jobid, "terminate")).Should(BeNil())
+			Ω(tracker.JobState(jobid)).Should(Equal(drmaa2interface.Running))
+			Consistently(func() drmaa2interface.JobState {
+				return tracker.JobState(jobid)
+			}, 500*time.Millisecond).Should(Equal(drmaa2interface.Running))
+			Ω(tracker.Wait(jobid, 5*time.Second, drmaa2interface.Failed)).Should(BeNil())
+		})
+
+		It("should kill a job which ignores SIGTERM after the grace period", func() {
+			tracker.SetTerminateGracePeriod(500 * time.Millisecond)
+			jobid, err := tracker.AddJob(drmaa2interface.JobTemplate{RemoteCommand: "sh",
+				Args: []string{"-c", "trap '' TERM; sleep 30"}})
+			Ω(err).Should(BeNil())
+			Eventually(func() drmaa2interface.JobState {
+				return tracker.JobState(jobid)
+			}, 5*time.Second).Should(Equal(drmaa2interface.Running))
+			time.Sleep(200 * time.Millisecond)
+
+			Ω(tracker.JobControl(jobid, "terminate")).Should(BeNil())
+			Ω(tracker.Wait(jobid, 5*time.Second, drmaa2interface.Failed)).Should(BeNil())
+			ji, err := tracker.JobInfo(jobid)
+			Ω(err).Should(BeNil())
+			Ω(ji.TerminatingSignal).Should(Equal("killed"))
+		})
+
+	})
+
 })
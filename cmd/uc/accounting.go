@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/dgruber/ubercluster/pkg/output"
+	"github.com/dgruber/ubercluster/pkg/types"
+	"log"
+	"net/url"
+	"time"
+)
+
+// GetAccounting requests the CPU time and wallclock time of the finished
+// jobs summed up per user from the proxy. An empty user or "all" returns
+// the summaries of all users, a since of 0 takes all finished jobs into
+// account.
+func (r *Request) GetAccounting(clusteraddress, user string, since time.Duration) ([]types.AccountingSummary, error) {
+	query := url.Values{}
+	if user != "" {
+		query.Set("user", user)
+	}
+	if since > 0 {
+		query.Set("since", time.Now().Add(-since).Format(time.RFC3339))
+	}
+	request := fmt.Sprintf("%s/msession/accounting?%s", clusteraddress, query.Encode())
+	log.Println("Requesting:" + request)
+	resp, err := r.get(request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := responseError(resp); err != nil {
+		return nil, err
+	}
+	var summaries []types.AccountingSummary
+	if err := json.NewDecoder(resp.Body).Decode(&summaries); err != nil {
+		return nil, err
+	}
+	return summaries, nil
+}
+
+// ShowAccounting prints the accounting summaries of the cluster.
+func (r *Request) ShowAccounting(clusteraddress, user string, since time.Duration, of output.OutputFormater) error {
+	summaries, err := r.GetAccounting(clusteraddress, user, since)
+	if err != nil {
+		return err
+	}
+	of.PrintAccounting(summaries)
+	return nil
+}
@@ -0,0 +1,47 @@
+package main_test
+
+import (
+	. "github.com/dgruber/ubercluster/cmd/uc"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+var _ = Describe("Accounting", func() {
+
+	var otp string
+
+	It("should request the accounting of the user since the given time", func() {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Ω(r.URL.Path).Should(Equal("/v1/msession/accounting"))
+			Ω(r.FormValue("user")).Should(Equal("alice"))
+			since, err := time.Parse(time.RFC3339, r.FormValue("since"))
+			Ω(err).Should(BeNil())
+			Ω(time.Since(since)).Should(BeNumerically("~", 24*time.Hour, time.Minute))
+			w.Write([]byte(`[{"jobOwner":"alice","jobs":2,"failed":1,"cpuTime":30,"wallclockTime":60000000000}]`))
+		}))
+		defer ts.Close()
+
+		r := NewRequest("", "", &otp)
+		summaries, err := r.GetAccounting(ts.URL+"/v1", "alice", 24*time.Hour)
+		Ω(err).Should(BeNil())
+		Ω(summaries).Should(HaveLen(1))
+		Ω(summaries[0].Jobs).Should(Equal(int64(2)))
+		Ω(summaries[0].CPUTime).Should(Equal(int64(30)))
+		Ω(summaries[0].WallclockTime).Should(Equal(time.Minute))
+	})
+
+	It("should return the error of the proxy", func() {
+		ts := httptest.NewServer(http.NotFoundHandler())
+		defer ts.Close()
+
+		r := NewRequest("", "", &otp)
+		_, err := r.GetAccounting(ts.URL+"/v1", "all", 0)
+		Ω(err).ShouldNot(BeNil())
+	})
+
+})
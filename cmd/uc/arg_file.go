@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// LoadArgFile reads the job arguments from a file. See ParseArgs for
+// the format.
+func LoadArgFile(filename string) ([]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	args, err := ParseArgs(file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid argument file %s: %s", filename, err)
+	}
+	return args, nil
+}
+
+// ParseArgs returns one argument per line. Leading and trailing spaces
+// are removed, empty lines and lines starting with # are skipped.
+// Arguments which are empty, have surrounding spaces, or start with #
+// can be quoted: double quoted arguments support Go escape sequences
+// (like \n or \"), single quoted arguments are taken literally.
+func ParseArgs(r io.Reader) ([]string, error) {
+	var args []string
+	scanner := bufio.NewScanner(r)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		arg, err := unquoteArg(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", lineno, err)
+		}
+		args = append(args, arg)
+	}
+	return args, scanner.Err()
+}
+
+// unquoteArg removes the quotes of a quoted argument.
+func unquoteArg(line string) (string, error) {
+	switch line[0] {
+	case '"':
+		arg, err := strconv.Unquote(line)
+		if err != nil {
+			return "", fmt.Errorf("invalid quoted argument %s", line)
+		}
+		return arg, nil
+	case '\'':
+		if len(line) < 2 || !strings.HasSuffix(line, "'") {
+			return "", fmt.Errorf("invalid quoted argument %s", line)
+		}
+		return line[1 : len(line)-1], nil
+	}
+	return line, nil
+}
@@ -0,0 +1,72 @@
+package main_test
+
+import (
+	. "github.com/dgruber/ubercluster/cmd/uc"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var _ = Describe("ArgFile", func() {
+
+	Context("basic functions", func() {
+
+		It("should return one argument per line", func() {
+			args, err := ParseArgs(strings.NewReader("-v\n  input.dat  \n\nresult file.dat\n"))
+			Ω(err).Should(BeNil())
+			Ω(args).Should(Equal([]string{"-v", "input.dat", "result file.dat"}))
+		})
+
+		It("should skip comments", func() {
+			args, err := ParseArgs(strings.NewReader("# input\ninput.dat\n  # output\noutput.dat"))
+			Ω(err).Should(BeNil())
+			Ω(args).Should(Equal([]string{"input.dat", "output.dat"}))
+		})
+
+		It("should remove the quotes of quoted arguments", func() {
+			args, err := ParseArgs(strings.NewReader(`"  spaces  "
+""
+"#nocomment"
+"a\"b\tc"
+'a\"b'
+`))
+			Ω(err).Should(BeNil())
+			Ω(args).Should(Equal([]string{"  spaces  ", "", "#nocomment", "a\"b\tc", `a\"b`}))
+		})
+
+		It("should load the arguments of a file", func() {
+			dir, err := ioutil.TempDir("", "argfile")
+			Ω(err).Should(BeNil())
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "args.txt")
+			Ω(ioutil.WriteFile(path, []byte("# args\n10\n"), 0600)).Should(BeNil())
+			args, err := LoadArgFile(path)
+			Ω(err).Should(BeNil())
+			Ω(args).Should(Equal([]string{"10"}))
+		})
+
+	})
+
+	Context("error cases", func() {
+
+		It("should reject unterminated quotes", func() {
+			_, err := ParseArgs(strings.NewReader("ok\n\"missing"))
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Error()).Should(ContainSubstring("line 2"))
+			_, err = ParseArgs(strings.NewReader("'"))
+			Ω(err).ShouldNot(BeNil())
+		})
+
+		It("should fail for a missing file", func() {
+			_, err := LoadArgFile(filepath.Join(os.TempDir(), "uc-missing-args.txt"))
+			Ω(err).ShouldNot(BeNil())
+		})
+
+	})
+
+})
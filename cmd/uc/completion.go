@@ -0,0 +1,226 @@
+package main
+
+// Shell completion for uc. The completion scripts call "uc __complete"
+// with the words of the command line and offer its output as candidates.
+// Both commands are handled before the command line is parsed so that
+// they don't show up in the help.
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/viper"
+	"io"
+	"sort"
+	"strings"
+)
+
+// completionNode contains the subcommands and flags of a uc command.
+// Flags are mapped to true when they take a value.
+type completionNode struct {
+	commands []string
+	flags    map[string]bool
+}
+
+// globalFlags are the flags which are accepted by all commands.
+var globalFlags = map[string]bool{
+	"--help": false, "--verbose": false, "--cluster": true, "--otp": true, "--otp-file": true,
+	"--format": true, "--no-color": false, "--timeout": true, "--retries": true, "--retry-delay": true,
+	"--cert": true, "--key": true,
+}
+
+// completionTree maps the uc commands (like "show job") to their
+// subcommands and flags. It needs to be kept in sync with the
+// commands defined in uc.go.
+var completionTree = map[string]completionNode{
+	"": {commands: []string{"show", "run", "logs", "events", "top", "report", "runlocal", "terminate", "suspend", "resume", "fs", "config", "version", "inception"}},
+
+	"show": {commands: []string{"job", "machine", "queue", "category", "session"}},
+	"show job": {flags: map[string]bool{
+		"--state": true, "--user": true, "--watch": false, "--interval": true, "--full": false,
+		"--count": false, "--since": true, "--until": true}},
+	"show machine": {flags: map[string]bool{
+		"--sort-by": true, "--max-load": true, "--by-cluster": false}},
+	"show queue":    {},
+	"show category": {},
+	"show session":  {},
+
+	"run": {flags: map[string]bool{
+		"--arg": true, "--arg-file": true, "--name": true, "--queue": true, "--category": true, "--alg": true, "--affinity-key": true,
+		"--upload": true, "--array": true, "--template-file": true, "--dry-run": false,
+		"--max-parallel": true, "--interactive": false}},
+	"logs":     {flags: map[string]bool{"--follow": false}},
+	"events":   {flags: map[string]bool{"--job": true, "--user": true}},
+	"top":      {flags: map[string]bool{"--all": false, "--interval": true, "--count": true}},
+	"runlocal": {flags: map[string]bool{"--arg": true}},
+
+	"report":            {commands: []string{"accounting"}},
+	"report accounting": {flags: map[string]bool{"--user": true, "--since": true}},
+
+	"terminate": {commands: []string{"job"}},
+	"terminate job": {flags: map[string]bool{
+		"--all": false, "--state": true, "--user": true, "--yes": false}},
+	"suspend":     {commands: []string{"job"}},
+	"suspend job": {},
+	"resume":      {commands: []string{"job"}},
+	"resume job":  {},
+
+	"fs":      {commands: []string{"ls", "up", "down"}},
+	"fs ls":   {},
+	"fs up":   {},
+	"fs down": {},
+
+	"config":      {commands: []string{"list", "add", "remove", "test"}},
+	"config list": {},
+	"config add": {flags: map[string]bool{
+		"--name": true, "--address": true, "--protocol": true, "--no-check": false}},
+	"config remove": {flags: map[string]bool{"--name": true}},
+	"config test":   {},
+
+	"version": {flags: map[string]bool{"--all": false}},
+
+	"inception": {flags: map[string]bool{"--alg": true}},
+}
+
+// takesValue returns true if the flag of the command expects a value.
+func takesValue(command, flag string) bool {
+	if globalFlags[flag] {
+		return true
+	}
+	return completionTree[command].flags[flag]
+}
+
+// Complete returns the completion candidates for the last word of the
+// given command line (without the leading "uc"). The names of the
+// configured clusters are offered as values of the --cluster flag.
+func Complete(words []string, clusters []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	command := ""
+	current := words[len(words)-1]
+	previous := ""
+	for i, word := range words[:len(words)-1] {
+		previous = word
+		if strings.HasPrefix(word, "-") {
+			continue
+		}
+		if i > 0 && takesValue(command, words[i-1]) {
+			// value of a flag
+			continue
+		}
+		for _, sub := range completionTree[command].commands {
+			if sub == word {
+				command = strings.TrimSpace(command + " " + word)
+				break
+			}
+		}
+	}
+
+	var candidates []string
+	switch {
+	case previous == "--cluster":
+		candidates = clusters
+	case takesValue(command, previous):
+		// no suggestions for other values
+		return nil
+	case strings.HasPrefix(current, "-"):
+		for flag := range globalFlags {
+			candidates = append(candidates, flag)
+		}
+		for flag := range completionTree[command].flags {
+			candidates = append(candidates, flag)
+		}
+		sort.Strings(candidates)
+	default:
+		candidates = completionTree[command].commands
+	}
+
+	matches := make([]string, 0, len(candidates))
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, current) {
+			matches = append(matches, candidate)
+		}
+	}
+	return matches
+}
+
+const bashCompletion = `# bash completion for uc
+# source it or add "source <(uc completion bash)" to ~/.bashrc
+_uc_completion() {
+	COMPREPLY=( $(uc __complete -- "${COMP_WORDS[@]:1:$COMP_CWORD}") )
+}
+complete -F _uc_completion uc
+`
+
+const zshCompletion = `#compdef uc
+# zsh completion for uc
+# source it or add "source <(uc completion zsh)" to ~/.zshrc
+_uc() {
+	local -a candidates
+	candidates=(${(f)"$(uc __complete -- "${(@)words[2,CURRENT]}")"})
+	compadd -a candidates
+}
+compdef _uc uc
+`
+
+// PrintCompletionScript writes the completion script for the given
+// shell ("bash" or "zsh").
+func PrintCompletionScript(shell string, w io.Writer) error {
+	switch shell {
+	case "bash":
+		fmt.Fprint(w, bashCompletion)
+	case "zsh":
+		fmt.Fprint(w, zshCompletion)
+	default:
+		return fmt.Errorf("Unsupported shell for completion: %s (expected bash or zsh)", shell)
+	}
+	return nil
+}
+
+// completionClusters returns the names of the configured clusters and
+// cluster groups (with GroupPrefix). Since it is called while completing
+// a command line errors are ignored.
+func completionClusters() []string {
+	var c Config
+	setConfigPaths()
+	if err := viper.ReadInConfig(); err != nil {
+		return nil
+	}
+	if err := viper.Unmarshal(&c); err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(c.Cluster)+len(c.Group))
+	for _, cc := range c.Cluster {
+		names = append(names, cc.Name)
+	}
+	for _, gc := range c.Group {
+		names = append(names, GroupPrefix+gc.Name)
+	}
+	return names
+}
+
+// handleCompletion processes the hidden "completion" and "__complete"
+// commands. It returns false if the arguments are not a completion
+// request.
+func handleCompletion(arguments []string, w io.Writer) (bool, error) {
+	if len(arguments) == 0 {
+		return false, nil
+	}
+	switch arguments[0] {
+	case "completion":
+		if len(arguments) != 2 {
+			return true, errors.New("Usage: uc completion bash|zsh")
+		}
+		return true, PrintCompletionScript(arguments[1], w)
+	case "__complete":
+		words := arguments[1:]
+		if len(words) > 0 && words[0] == "--" {
+			words = words[1:]
+		}
+		for _, candidate := range Complete(words, completionClusters()) {
+			fmt.Fprintln(w, candidate)
+		}
+		return true, nil
+	}
+	return false, nil
+}
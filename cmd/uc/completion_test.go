@@ -0,0 +1,59 @@
+package main_test
+
+import (
+	. "github.com/dgruber/ubercluster/cmd/uc"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"bytes"
+)
+
+var _ = Describe("Completion", func() {
+
+	clusters := []string{"default", "gridengine", "docker"}
+
+	Context("when a command line is completed", func() {
+
+		It("should complete the commands and subcommands", func() {
+			Ω(Complete([]string{""}, clusters)).Should(ContainElement("show"))
+			Ω(Complete([]string{"sh"}, clusters)).Should(Equal([]string{"show"}))
+			Ω(Complete([]string{"show", "m"}, clusters)).Should(Equal([]string{"machine"}))
+			Ω(Complete(nil, clusters)).Should(ContainElement("config"))
+		})
+
+		It("should complete the flags of the command", func() {
+			flags := Complete([]string{"show", "machine", "--"}, clusters)
+			Ω(flags).Should(ContainElement("--by-cluster"))
+			Ω(flags).Should(ContainElement("--cluster"))
+			Ω(flags).ShouldNot(ContainElement("--dry-run"))
+			Ω(Complete([]string{"run", "--dry"}, clusters)).Should(Equal([]string{"--dry-run"}))
+		})
+
+		It("should complete the cluster names", func() {
+			Ω(Complete([]string{"--cluster", ""}, clusters)).Should(Equal(clusters))
+			Ω(Complete([]string{"show", "job", "--cluster", "d"}, clusters)).Should(Equal([]string{"default", "docker"}))
+			Ω(Complete([]string{"--cluster", "docker", "show", "q"}, clusters)).Should(Equal([]string{"queue"}))
+		})
+
+		It("should not suggest values of other flags", func() {
+			Ω(Complete([]string{"run", "--name", ""}, clusters)).Should(BeEmpty())
+		})
+
+	})
+
+	Context("when a completion script is requested", func() {
+
+		It("should print the script for bash and zsh", func() {
+			var out bytes.Buffer
+			Ω(PrintCompletionScript("bash", &out)).Should(BeNil())
+			Ω(out.String()).Should(ContainSubstring("complete -F _uc_completion uc"))
+			out.Reset()
+			Ω(PrintCompletionScript("zsh", &out)).Should(BeNil())
+			Ω(out.String()).Should(ContainSubstring("#compdef uc"))
+			Ω(PrintCompletionScript("fish", &out)).ShouldNot(BeNil())
+		})
+
+	})
+
+})
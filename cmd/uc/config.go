@@ -20,10 +20,18 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/dgruber/ubercluster/pkg/proxy"
+	"github.com/dgruber/ubercluster/pkg/types"
 	"github.com/spf13/viper"
+	"io"
+	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 // Config contains configuration for proxies of compute clusters which can be queried.
@@ -40,9 +48,29 @@ type ClusterConfig struct {
 	Name            string
 	Address         string // like http://localhost:8888
 	ProtocolVersion string // the protocol the proxy speaks "v1"
+	// NegotiatedVersion is the protocol version uc and the proxy
+	// agreed on (see NegotiateProtocolVersion). It is stored by uc
+	// and updated by "uc config test".
+	NegotiatedVersion string `json:",omitempty"`
+	// Weight biases the weighted cluster selection ("--alg weighted")
+	// towards the cluster. Clusters without weight have a weight of 1,
+	// clusters with weight 0 are never selected.
+	Weight *float64 `json:",omitempty"`
+}
+
+// SelectionWeight returns the configured weight of the cluster or 1
+// when no weight is configured.
+func (c ClusterConfig) SelectionWeight() float64 {
+	if c.Weight == nil {
+		return 1
+	}
+	return *c.Weight
 }
 
 func (c ClusterConfig) String() string {
+	if c.Weight != nil {
+		return fmt.Sprintf("Name: %s\nAddress: %s\nProtocolVersion: %s\nWeight: %g\n", c.Name, c.Address, c.ProtocolVersion, *c.Weight)
+	}
 	return fmt.Sprintf("Name: %s\nAddress: %s\nProtocolVersion: %s\n", c.Name, c.Address, c.ProtocolVersion)
 }
 
@@ -51,6 +79,9 @@ func (c ClusterConfig) String() string {
 type Config struct {
 	// Multiple endpoints of proxies can be defined
 	Cluster []ClusterConfig
+	// Groups of clusters which are addressed together with
+	// --cluster group:name
+	Group []GroupConfig `json:",omitempty"`
 }
 
 // GlobalConfig is the merged configuration containing the
@@ -84,13 +115,7 @@ func saveDummyConfig() {
 }
 
 func ReadConfig() Config {
-	viper.SetConfigName("config")
-	// check local directory first
-	viper.AddConfigPath("./")
-	// then home directory
-	viper.AddConfigPath("$HOME/.ubercluster/")
-	// finally /etc
-	viper.AddConfigPath("/etc/ubercluster/")
+	setConfigPaths()
 
 	if err := viper.ReadInConfig(); err != nil {
 		fmt.Printf("Error reading in config file: %s\n", err)
@@ -104,10 +129,235 @@ func ReadConfig() Config {
 	return config
 }
 
+// ReadConfigOrEmpty reads in the configuration like ReadConfig but
+// starts with an empty configuration when no configuration file
+// exists, so that "uc config add" can create the first one. It is
+// written to $HOME/.ubercluster/config.json.
+func ReadConfigOrEmpty() Config {
+	setConfigPaths()
+
+	if err := viper.ReadInConfig(); err != nil {
+		if _, notFound := err.(viper.ConfigFileNotFoundError); !notFound {
+			fmt.Printf("Error reading in config file: %s\n", err)
+			os.Exit(1)
+		}
+		viper.SetConfigFile(filepath.Join(os.Getenv("HOME"), ".ubercluster", "config.json"))
+		config = Config{}
+		return config
+	}
+
+	if err := viper.Unmarshal(&config); err != nil {
+		fmt.Printf("Internal error parsing config file: %s\n", err)
+		os.Exit(1)
+	}
+	return config
+}
+
+// setConfigPaths defines where the configuration file is searched.
+func setConfigPaths() {
+	viper.SetConfigName("config")
+	// check local directory first
+	viper.AddConfigPath("./")
+	// then home directory
+	viper.AddConfigPath("$HOME/.ubercluster/")
+	// finally /etc
+	viper.AddConfigPath("/etc/ubercluster/")
+}
+
+// WriteConfig stores the configuration in the configuration file
+// which was read in by ReadConfig. Only JSON configuration files can
+// be written. The file is replaced by a completely written new file,
+// so that it is never left truncated, and it is indented so that it
+// can still be edited by hand.
+func WriteConfig(c Config) error {
+	filename := viper.ConfigFileUsed()
+	if filename == "" {
+		return errors.New("No configuration file in use")
+	}
+	if !strings.EqualFold(filepath.Ext(filename), ".json") {
+		return fmt.Errorf("Can't change configuration file %s (only JSON files can be written)", filename)
+	}
+	mode := os.FileMode(0644)
+	if fi, err := os.Stat(filename); err == nil {
+		mode = fi.Mode().Perm()
+	} else if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	file, err := ioutil.TempFile(filepath.Dir(filename), ".config")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(append(content, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(file.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), filename)
+}
+
+// AddCluster adds the configuration of a new cluster proxy. The
+// cluster name must not be already in use.
+func (c *Config) AddCluster(cc ClusterConfig) error {
+	if cc.Name == "" || cc.Address == "" {
+		return errors.New("Cluster name and address must be set")
+	}
+	for i := range c.Cluster {
+		if c.Cluster[i].Name == cc.Name {
+			return fmt.Errorf("Cluster %s already exists in configuration", cc.Name)
+		}
+	}
+	// addresses are concatenated with the protocol version
+	if !strings.HasSuffix(cc.Address, "/") {
+		cc.Address = cc.Address + "/"
+	}
+	if cc.ProtocolVersion == "" {
+		cc.ProtocolVersion = "v1"
+	}
+	c.Cluster = append(c.Cluster, cc)
+	return nil
+}
+
+// RemoveCluster removes the configuration of the cluster with
+// the given name.
+func (c *Config) RemoveCluster(name string) error {
+	for i := range c.Cluster {
+		if c.Cluster[i].Name == name {
+			c.Cluster = append(c.Cluster[:i], c.Cluster[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("Cluster %s not found in configuration", name)
+}
+
 func listConfig(clusteraddress string) {
 	for _, cc := range config.Cluster {
 		fmt.Println(cc)
 	}
+	for _, gc := range config.Group {
+		fmt.Println(gc)
+	}
+}
+
+// addConfig adds a cluster to the configuration file. If check is
+// set the cluster proxy must be reachable.
+func addConfig(r *Request, cc ClusterConfig, check bool) error {
+	if err := config.AddCluster(cc); err != nil {
+		return err
+	}
+	if check {
+		added := config.Cluster[len(config.Cluster)-1]
+		address := fmt.Sprintf("%s%s", added.Address, added.ProtocolVersion)
+		if err := r.CheckCluster(address); err != nil {
+			return fmt.Errorf("Cluster %s is not reachable (use --no-check to add it anyway): %s", cc.Name, err)
+		}
+	}
+	return WriteConfig(config)
+}
+
+// removeConfig removes a cluster from the configuration file.
+func removeConfig(name string) error {
+	if err := config.RemoveCluster(name); err != nil {
+		return err
+	}
+	return WriteConfig(config)
+}
+
+// ClusterCheck is the result of testing the connection to the
+// proxy of a configured cluster.
+type ClusterCheck struct {
+	Name            string
+	Address         string
+	ProtocolVersion string        // negotiated protocol version
+	Latency         time.Duration // time until the proxy answered
+	Err             error         // set when the proxy is not reachable
+}
+
+// Reachable returns true when the proxy answered the request.
+func (c ClusterCheck) Reachable() bool {
+	return c.Err == nil
+}
+
+// CheckClusterConnection requests the supported protocol versions from
+// the proxy of the cluster. Older proxies without /versions endpoint
+// are checked with a request of the configured protocol version.
+func (r *Request) CheckClusterConnection(cc ClusterConfig) ClusterCheck {
+	check := ClusterCheck{Name: cc.Name, Address: cc.Address, ProtocolVersion: cc.ProtocolVersion}
+	if check.ProtocolVersion == "" {
+		check.ProtocolVersion = string(types.ProtocolV1)
+	}
+	start := time.Now()
+	supported, err := r.requestProtocolVersions(cc)
+	if err == nil {
+		if common, ok := types.HighestCommonProtocolVersion(proxy.SupportedProtocolVersions, supported); ok {
+			check.ProtocolVersion = string(common)
+			r.versions.remember(cc.Address, check.ProtocolVersion, true)
+		} else {
+			err = fmt.Errorf("No common protocol version (proxy supports %v)", supported)
+		}
+	} else {
+		cc.ProtocolVersion = check.ProtocolVersion
+		err = r.CheckCluster(versionedAddress(cc))
+	}
+	check.Latency = time.Since(start)
+	check.Err = err
+	return check
+}
+
+// CheckClusterConnections tests the connections to all given clusters
+// in parallel.
+func (r *Request) CheckClusterConnections(clusters []ClusterConfig) []ClusterCheck {
+	checks := make([]ClusterCheck, len(clusters))
+	var wg sync.WaitGroup
+	wg.Add(len(clusters))
+	for i := range clusters {
+		go func(i int) {
+			defer wg.Done()
+			checks[i] = r.CheckClusterConnection(clusters[i])
+		}(i)
+	}
+	wg.Wait()
+	return checks
+}
+
+// PrintClusterChecks writes the results of the connection tests as table.
+func PrintClusterChecks(w io.Writer, checks []ClusterCheck) {
+	fmt.Fprintf(w, "%-20s %-30s %-11s %10s %8s\n", "CLUSTER", "ADDRESS", "STATUS", "LATENCY", "PROTOCOL")
+	for _, c := range checks {
+		if !c.Reachable() {
+			fmt.Fprintf(w, "%-20s %-30s %-11s %10s %8s  %s\n", c.Name, c.Address, "unreachable",
+				c.Latency.Round(time.Millisecond), "-", c.Err)
+			continue
+		}
+		fmt.Fprintf(w, "%-20s %-30s %-11s %10s %8s\n", c.Name, c.Address, "reachable",
+			c.Latency.Round(time.Millisecond), c.ProtocolVersion)
+	}
+}
+
+// testConfig tests the connections to all configured clusters and
+// returns an error when one of them is not reachable.
+func testConfig(r *Request, w io.Writer) error {
+	checks := r.CheckClusterConnections(config.Cluster)
+	PrintClusterChecks(w, checks)
+	unreachable := 0
+	for _, c := range checks {
+		if !c.Reachable() {
+			unreachable++
+		}
+	}
+	if unreachable > 0 {
+		return fmt.Errorf("%d of %d clusters are not reachable", unreachable, len(checks))
+	}
+	return nil
 }
 
 // GetClusterAddress searches the address of the cluster to contact to
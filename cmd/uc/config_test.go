@@ -5,6 +5,10 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+
+	"bytes"
+	"net/http"
+	"net/http/httptest"
 )
 
 var _ = Describe("Config", func() {
@@ -31,4 +35,76 @@ var _ = Describe("Config", func() {
 			Ω(err2).NotTo(BeNil())
 		})
 	})
+	Context("When the configuration is changed", func() {
+		It("must add a new cluster", func() {
+			var config Config
+			err := config.AddCluster(ClusterConfig{Name: "new", Address: "http://localhost:7777"})
+			Ω(err).To(BeNil())
+			Ω(config.Cluster).To(HaveLen(1))
+			Ω(config.Cluster[0].Address).To(Equal("http://localhost:7777/"))
+			Ω(config.Cluster[0].ProtocolVersion).To(Equal("v1"))
+		})
+		It("must not add a cluster twice", func() {
+			var config Config
+			Ω(config.AddCluster(ClusterConfig{Name: "new", Address: "http://localhost:7777/"})).To(BeNil())
+			Ω(config.AddCluster(ClusterConfig{Name: "new", Address: "http://localhost:7778/"})).NotTo(BeNil())
+			Ω(config.Cluster).To(HaveLen(1))
+		})
+		It("must remove a cluster", func() {
+			var config Config
+			Ω(config.AddCluster(ClusterConfig{Name: "one", Address: "http://localhost:7777/"})).To(BeNil())
+			Ω(config.AddCluster(ClusterConfig{Name: "two", Address: "http://localhost:7778/"})).To(BeNil())
+			Ω(config.RemoveCluster("one")).To(BeNil())
+			Ω(config.Cluster).To(HaveLen(1))
+			Ω(config.Cluster[0].Name).To(Equal("two"))
+			Ω(config.RemoveCluster("one")).NotTo(BeNil())
+		})
+	})
+})
+
+var _ = Describe("Config test", func() {
+
+	var otp string
+
+	It("should report reachable and unreachable clusters", func() {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Ω(r.URL.Path).Should(Equal("/versions"))
+			w.Write([]byte(`{"versions":["v1"]}`))
+		}))
+		defer ts.Close()
+		down := httptest.NewServer(http.NotFoundHandler())
+		down.Close()
+
+		r := NewRequest("", "", &otp)
+		checks := r.CheckClusterConnections([]ClusterConfig{
+			{Name: "up", Address: ts.URL + "/", ProtocolVersion: "v1"},
+			{Name: "down", Address: down.URL + "/", ProtocolVersion: "v1"},
+		})
+		Ω(checks).Should(HaveLen(2))
+		Ω(checks[0].Reachable()).Should(BeTrue())
+		Ω(checks[0].ProtocolVersion).Should(Equal("v1"))
+		Ω(checks[1].Reachable()).Should(BeFalse())
+
+		var out bytes.Buffer
+		PrintClusterChecks(&out, checks)
+		Ω(out.String()).Should(ContainSubstring("reachable"))
+		Ω(out.String()).Should(ContainSubstring("unreachable"))
+	})
+
+	It("should check proxies without /versions endpoint with the configured version", func() {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/v1/msession/drmsname" {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write([]byte("simple"))
+		}))
+		defer ts.Close()
+
+		r := NewRequest("", "", &otp)
+		check := r.CheckClusterConnection(ClusterConfig{Name: "old", Address: ts.URL, ProtocolVersion: "v1"})
+		Ω(check.Err).Should(BeNil())
+		Ω(check.ProtocolVersion).Should(Equal("v1"))
+	})
+
 })
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestWriteConfigOnlyJSON(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ucconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	previous := viper.ConfigFileUsed()
+	defer viper.SetConfigFile(previous)
+
+	yamlFile := filepath.Join(dir, "config.yaml")
+	content := []byte("cluster:\n- name: default\n  address: http://localhost:8888/\n")
+	if err := ioutil.WriteFile(yamlFile, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	viper.SetConfigFile(yamlFile)
+	if err := WriteConfig(makeTestConfig(1)); err == nil {
+		t.Errorf("Expected an error when writing %s", yamlFile)
+	}
+	if written, _ := ioutil.ReadFile(yamlFile); string(written) != string(content) {
+		t.Errorf("Expected %s to be unchanged but it is: %s", yamlFile, written)
+	}
+
+	jsonFile := filepath.Join(dir, "config.JSON")
+	viper.SetConfigFile(jsonFile)
+	if err := WriteConfig(makeTestConfig(1)); err != nil {
+		t.Errorf("Unexpected error when writing %s: %s", jsonFile, err)
+	}
+}
+
+func TestWriteConfigReplacesFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ucconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	previous := viper.ConfigFileUsed()
+	defer viper.SetConfigFile(previous)
+
+	jsonFile := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(jsonFile, []byte(`{"Cluster":[]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	viper.SetConfigFile(jsonFile)
+	if err := WriteConfig(makeTestConfig(2)); err != nil {
+		t.Fatalf("Unexpected error when writing %s: %s", jsonFile, err)
+	}
+	written, _ := ioutil.ReadFile(jsonFile)
+	if !strings.Contains(string(written), "\n  \"Cluster\": [\n") {
+		t.Errorf("Expected an indented configuration but got: %s", written)
+	}
+	if fi, err := os.Stat(jsonFile); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("Expected the permissions of %s to be kept but got %v (%v)", jsonFile, fi.Mode(), err)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Errorf("Expected only the configuration file in %s but got %d files", dir, len(files))
+	}
+}
+
+func TestReadConfigOrEmptyCreatesFirstConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ucconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	previous := config
+	defer func() { config = previous }()
+	defer viper.Reset()
+	viper.Reset()
+	os.Chdir(dir)
+	os.Setenv("HOME", dir)
+
+	c := ReadConfigOrEmpty()
+	if len(c.Cluster) != 0 {
+		t.Errorf("Expected an empty configuration but got %v", c)
+	}
+	if err := c.AddCluster(ClusterConfig{Name: "default", Address: "http://localhost:8888"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteConfig(c); err != nil {
+		t.Fatalf("Unexpected error when writing the first configuration: %s", err)
+	}
+	viper.Reset()
+	if c = ReadConfigOrEmpty(); len(c.Cluster) != 1 || c.Cluster[0].Name != "default" {
+		t.Errorf("Expected the added cluster in %s but got %v", viper.ConfigFileUsed(), c)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/dgruber/ubercluster/pkg/http_helper"
+	"github.com/dgruber/ubercluster/pkg/types"
+	"io"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// FollowJobEvents subscribes to the job state change notifications of
+// the cluster and calls f for each of them until the proxy closes the
+// connection. An empty job id selects the events of all jobs, an empty
+// user or "all" the events of the jobs of all users.
+func (r *Request) FollowJobEvents(clusteraddress, jobid, user string, f func(types.Notification)) error {
+	query := url.Values{}
+	if jobid != "" {
+		query.Set("jobid", jobid)
+	}
+	if user != "" {
+		query.Set("user", user)
+	}
+	request := fmt.Sprintf("%s/jsession/default/events?%s", clusteraddress, query.Encode())
+	log.Println("Requesting:" + request)
+	// the event stream lasts until it is interrupted
+	client := *r.client
+	client.Timeout = 0
+	resp, err := http_helper.UberGetWithContext(r.context(), &client, *r.otp, request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := responseError(resp); err != nil {
+		return err
+	}
+	return readEvents(resp.Body, f)
+}
+
+// readEvents decodes the data of server-sent events as notifications.
+// Events are separated by empty lines, lines which don't carry data
+// (like the event name or comments) are skipped.
+func readEvents(stream io.Reader, f func(types.Notification)) error {
+	scanner := bufio.NewScanner(stream)
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "data:") {
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+			continue
+		}
+		if line != "" || data.Len() == 0 {
+			continue
+		}
+		var n types.Notification
+		if err := json.Unmarshal([]byte(data.String()), &n); err != nil {
+			return fmt.Errorf("invalid job event: %s", err)
+		}
+		data.Reset()
+		f(n)
+	}
+	return scanner.Err()
+}
+
+// ShowJobEvents prints a line for each job state change of the cluster
+// until it is interrupted.
+func (r *Request) ShowJobEvents(clusteraddress, jobid, user string, w io.Writer) error {
+	return r.FollowJobEvents(clusteraddress, jobid, user, func(n types.Notification) {
+		fmt.Fprintf(w, "%s %-16s %-12s %s\n", time.Now().Format("15:04:05"),
+			n.JobId, n.State, n.JobOwner)
+	})
+}
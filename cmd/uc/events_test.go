@@ -0,0 +1,62 @@
+package main_test
+
+import (
+	. "github.com/dgruber/ubercluster/cmd/uc"
+	"github.com/dgruber/ubercluster/pkg/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+)
+
+var _ = Describe("Events", func() {
+
+	var otp string
+
+	It("should decode the job state changes sent by the proxy", func() {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Ω(r.URL.Path).Should(Equal("/v1/jsession/default/events"))
+			Ω(r.FormValue("jobid")).Should(Equal("7"))
+			Ω(r.FormValue("user")).Should(Equal("alice"))
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte(": comment\n\nevent: notification\ndata: {\"jobId\":\"7\",\"jobState\":4,\"jobOwner\":\"alice\"}\n\n"))
+			w.Write([]byte("event: notification\ndata: {\"jobId\":\"7\",\"jobState\":8,\"jobOwner\":\"alice\"}\n\n"))
+		}))
+		defer ts.Close()
+
+		var notifications []types.Notification
+		err := NewRequest("", "", &otp).FollowJobEvents(ts.URL+"/v1", "7", "alice", func(n types.Notification) {
+			notifications = append(notifications, n)
+		})
+		Ω(err).Should(BeNil())
+		Ω(notifications).Should(HaveLen(2))
+		Ω(notifications[0].State).Should(Equal(types.Running))
+		Ω(notifications[1].State).Should(Equal(types.Done))
+	})
+
+	It("should print a line for each job state change", func() {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("data: {\"jobId\":\"7\",\"jobState\":4,\"jobOwner\":\"alice\"}\n\n"))
+		}))
+		defer ts.Close()
+
+		var out bytes.Buffer
+		Ω(NewRequest("", "", &otp).ShowJobEvents(ts.URL+"/v1", "", "all", &out)).Should(BeNil())
+		Ω(out.String()).Should(ContainSubstring("Running"))
+		Ω(out.String()).Should(ContainSubstring("alice"))
+	})
+
+	It("should reject invalid events", func() {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("data: {invalid\n\n"))
+		}))
+		defer ts.Close()
+
+		err := NewRequest("", "", &otp).FollowJobEvents(ts.URL+"/v1", "", "", func(types.Notification) {})
+		Ω(err).ShouldNot(BeNil())
+	})
+
+})
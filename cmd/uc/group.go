@@ -0,0 +1,132 @@
+package main
+
+// Cluster groups: "--cluster group:name" addresses all member clusters
+// of a group through a uc in inception mode which is started within
+// the uc process on a local port.
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/dgruber/ubercluster/pkg/persistency"
+	"github.com/dgruber/ubercluster/pkg/proxy"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// GroupPrefix marks a cluster name as the name of a cluster group.
+const GroupPrefix = "group:"
+
+// GroupConfig is a named group of configured clusters.
+type GroupConfig struct {
+	Name    string
+	Cluster []string // names of the member clusters
+}
+
+func (g GroupConfig) String() string {
+	return fmt.Sprintf("Group: %s\nCluster: %s\n", g.Name, strings.Join(g.Cluster, ", "))
+}
+
+// IsGroup returns true when the cluster name references a cluster group.
+func IsGroup(cluster string) bool {
+	return strings.HasPrefix(cluster, GroupPrefix)
+}
+
+// GroupConfig returns a configuration which contains only the member
+// clusters of the group. The group name can be given with or without
+// GroupPrefix. An error is returned when the group is not configured,
+// has no members, or a member is not a configured cluster.
+func (c Config) GroupConfig(group string) (Config, error) {
+	group = strings.TrimPrefix(group, GroupPrefix)
+	for _, g := range c.Group {
+		if g.Name != group {
+			continue
+		}
+		if len(g.Cluster) == 0 {
+			return Config{}, fmt.Errorf("Group %s has no clusters", group)
+		}
+		members := Config{Cluster: make([]ClusterConfig, 0, len(g.Cluster))}
+		for _, name := range g.Cluster {
+			found := false
+			for _, cc := range c.Cluster {
+				if cc.Name == name {
+					members.Cluster = append(members.Cluster, cc)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return Config{}, fmt.Errorf("Cluster %s of group %s not found in configuration", name, group)
+			}
+		}
+		return members, nil
+	}
+	return Config{}, fmt.Errorf("Group %s not found in configuration", group)
+}
+
+// GroupAddress returns the address of a proxy aggregating the member
+// clusters of the group (see ServeGroup) and the name of the group.
+func (r *Request) GroupAddress(group, alg string) (string, string, error) {
+	members, err := config.GroupConfig(group)
+	if err != nil {
+		fmt.Println(err)
+		return "", "", err
+	}
+	address, err := r.ServeGroup(members, alg)
+	if err != nil {
+		return "", "", err
+	}
+	name := GroupPrefix + strings.TrimPrefix(group, GroupPrefix)
+	log.Println("Chosen cluster group: ", name, address)
+	return address, name, nil
+}
+
+// ServeGroup starts a uc in inception mode for the given clusters which
+// serves on a local port until uc exits. It returns the address of that
+// proxy including the protocol version so that all commands aggregate
+// the clusters like inception does. Jobs are submitted to the cluster
+// selected by alg or, without alg, to a random cluster.
+//
+// The local proxy is protected by a random secret which replaces the
+// one time password of the request, so that other local users can't
+// use it. The member clusters are still requested with the original
+// one time password.
+func (r *Request) ServeGroup(members Config, alg string) (string, error) {
+	if alg == "" {
+		alg = "rand"
+	}
+	sched, err := MakeSchedulerByAlg(alg, members, r)
+	if err != nil {
+		return "", err
+	}
+	secret, err := groupSecret()
+	if err != nil {
+		return "", err
+	}
+	otp := *r.otp
+	forward := *r
+	forward.otp = &otp
+	incept := &Inception{config: members, request: &forward, scheduler: sched}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	var sc proxy.SecConfig
+	sc.OTP = secret
+	*r.otp = secret
+	var pi persistency.DummyPersistency
+	go http.Serve(listener, proxy.NewProxyRouter(incept, sc, &pi))
+	return fmt.Sprintf("http://%s/%s", listener.Addr(), proxy.ProtocolVersion), nil
+}
+
+// groupSecret returns a random secret for the local proxy of a group.
+func groupSecret() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("can't create secret for the cluster group: %s", err)
+	}
+	return hex.EncodeToString(b), nil
+}
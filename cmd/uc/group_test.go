@@ -0,0 +1,119 @@
+package main_test
+
+import (
+	. "github.com/dgruber/ubercluster/cmd/uc"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"net/http"
+	"net/http/httptest"
+	"sort"
+)
+
+var _ = Describe("Group", func() {
+
+	config := Config{
+		Cluster: []ClusterConfig{
+			{Name: "a", Address: "http://a:8888/", ProtocolVersion: "v1"},
+			{Name: "b", Address: "http://b:8888/", ProtocolVersion: "v1"},
+			{Name: "c", Address: "http://c:8888/", ProtocolVersion: "v1"},
+		},
+		Group: []GroupConfig{
+			{Name: "gpu", Cluster: []string{"a", "c"}},
+			{Name: "empty"},
+			{Name: "broken", Cluster: []string{"a", "d"}},
+		},
+	}
+
+	It("should recognize group names", func() {
+		Ω(IsGroup("group:gpu")).Should(BeTrue())
+		Ω(IsGroup("gpu")).Should(BeFalse())
+	})
+
+	It("should return the member clusters of a group", func() {
+		members, err := config.GroupConfig("group:gpu")
+		Ω(err).Should(BeNil())
+		Ω(members.Cluster).Should(HaveLen(2))
+		Ω(members.Cluster[0].Name).Should(Equal("a"))
+		Ω(members.Cluster[1].Name).Should(Equal("c"))
+		Ω(members.Group).Should(BeEmpty())
+
+		members, err = config.GroupConfig("gpu")
+		Ω(err).Should(BeNil())
+		Ω(members.Cluster).Should(HaveLen(2))
+	})
+
+	It("should reject unknown, empty, and broken groups", func() {
+		_, err := config.GroupConfig("group:cpu")
+		Ω(err).Should(MatchError("Group cpu not found in configuration"))
+		_, err = config.GroupConfig("group:empty")
+		Ω(err).Should(MatchError("Group empty has no clusters"))
+		_, err = config.GroupConfig("group:broken")
+		Ω(err).Should(MatchError("Cluster d of group broken not found in configuration"))
+	})
+
+	It("should aggregate the jobs of the member clusters", func() {
+		cluster := func(jobs string) *httptest.Server {
+			return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(jobs))
+			}))
+		}
+		a := cluster(`[{"id":"1","state":4},{"id":"2","state":2}]`)
+		defer a.Close()
+		c := cluster(`[{"id":"3","state":4}]`)
+		defer c.Close()
+
+		members := Config{Cluster: []ClusterConfig{
+			{Name: "a", Address: a.URL, ProtocolVersion: "v1"},
+			{Name: "c", Address: c.URL, ProtocolVersion: "v1"},
+		}}
+		var otp string
+		r := NewRequest("", "", &otp)
+		address, err := r.ServeGroup(members, "")
+		Ω(err).Should(BeNil())
+		Ω(address).Should(MatchRegexp(`^http://127\.0\.0\.1:\d+/v1$`))
+
+		jobs, err := r.GetJobs(address, "all", "")
+		Ω(err).Should(BeNil())
+		ids := make([]string, 0, len(jobs))
+		for _, job := range jobs {
+			ids = append(ids, job.Id)
+		}
+		sort.Strings(ids)
+		Ω(ids).Should(Equal([]string{"1", "2", "3"}))
+	})
+
+	It("should protect the local proxy by a secret", func() {
+		var received string
+		member := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received = r.FormValue("otp")
+			w.Write([]byte(`[]`))
+		}))
+		defer member.Close()
+
+		members := Config{Cluster: []ClusterConfig{{Name: "a", Address: member.URL, ProtocolVersion: "v1"}}}
+		otp := "membersecret"
+		r := NewRequest("", "", &otp)
+		address, err := r.ServeGroup(members, "")
+		Ω(err).Should(BeNil())
+		Ω(otp).ShouldNot(Equal("membersecret"))
+		Ω(otp).ShouldNot(BeEmpty())
+
+		resp, err := http.Get(address + "/msession/jobinfos")
+		Ω(err).Should(BeNil())
+		resp.Body.Close()
+		Ω(resp.StatusCode).Should(Equal(http.StatusUnauthorized))
+
+		_, err = r.GetJobs(address, "all", "")
+		Ω(err).Should(BeNil())
+		Ω(received).Should(Equal("membersecret"))
+	})
+
+	It("should reject unknown selection algorithms", func() {
+		var otp string
+		_, err := NewRequest("", "", &otp).ServeGroup(config, "best")
+		Ω(err).ShouldNot(BeNil())
+	})
+
+})
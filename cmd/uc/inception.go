@@ -19,12 +19,14 @@ package main
 // Run uc as proxy itself. Allows to stack clusters of cluster recursively.
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/dgruber/ubercluster/pkg/persistency"
 	"github.com/dgruber/ubercluster/pkg/proxy"
 	"github.com/dgruber/ubercluster/pkg/types"
 	"log"
+	"os"
 	"strings"
 	"sync"
 )
@@ -33,6 +35,7 @@ type Inception struct {
 	inceptionAddress string // address of uc itself
 	config           Config // uc configuration object
 	request          *Request
+	scheduler        *SchedulerImpl // selects the cluster for job submission
 }
 
 func NewInception(certFile, keyFile string, otp string, config Config) *Inception {
@@ -44,6 +47,15 @@ func NewInception(certFile, keyFile string, otp string, config Config) *Inceptio
 
 // Implements the ProxyImplementer interface
 
+// WithContext implements the ContextProxyImplementer interface. The
+// returned inception forwards the request id of the incoming request
+// to the clusters.
+func (i *Inception) WithContext(ctx context.Context) proxy.ProxyImplementer {
+	c := *i
+	c.request = i.request.WithContext(ctx)
+	return &c
+}
+
 // collects jobinfos from all clusters in parallel
 type jiProtected struct {
 	sync.Mutex
@@ -51,11 +63,14 @@ type jiProtected struct {
 	jobinfos []types.JobInfo
 }
 
-// requestJobInfos requests job infos of jobs in the
-// given state from a cluster given by the address
-func requestJobInfos(i *Inception, ji *jiProtected, state string, address string) {
+// requestJobInfos requests job infos of jobs in the given
+// state and of the given user from a cluster given by the address
+func requestJobInfos(i *Inception, ji *jiProtected, state, user string, address string) {
 	log.Println("Requesting from: ", address)
-	jis := i.request.GetJobs(address, state, "")
+	jis, err := i.request.GetJobs(address, state, user)
+	if err != nil {
+		log.Println("Error while requesting jobinfos from ", address, err)
+	}
 	log.Println("Got following jobinfos: ", jis)
 	if jis != nil {
 		ji.Lock()
@@ -65,7 +80,18 @@ func requestJobInfos(i *Inception, ji *jiProtected, state string, address string
 	ji.Done()
 }
 
+// GetJobInfosByFilter collects the job infos from all clusters. The state
+// and owner of the filter are passed to the clusters, the aggregated job
+// infos are filtered again for the remaining fields of the filter.
 func (i *Inception) GetJobInfosByFilter(filtered bool, filter types.JobInfo) []types.JobInfo {
+	state, user := "all", ""
+	if filtered {
+		if filter.State != types.Unset {
+			state = filter.State.ShortCode()
+		}
+		user = filter.JobOwner
+	}
+
 	var jip jiProtected
 	jip.jobinfos = make([]types.JobInfo, 0, 0)
 	jip.Add(len(i.config.Cluster))
@@ -76,37 +102,59 @@ func (i *Inception) GetJobInfosByFilter(filtered bool, filter types.JobInfo) []t
 			jip.Done()
 			continue
 		}
-		go requestJobInfos(i, &jip, "all", fmt.Sprintf("%s/v1", c.Address))
+		go func(c ClusterConfig) {
+			requestJobInfos(i, &jip, state, user, i.request.requestAddress(c))
+		}(c)
 	}
 	// wait until we got all job infos from all cluster
 	jip.Wait()
 
-	return jip.jobinfos
+	if !filtered {
+		return jip.jobinfos
+	}
+	jobinfos := make([]types.JobInfo, 0, len(jip.jobinfos))
+	for _, ji := range jip.jobinfos {
+		if ji.Matches(filter) {
+			jobinfos = append(jobinfos, ji)
+		}
+	}
+	return jobinfos
 }
 
-func getJobFromCluster(i *Inception, clustername string, jobid string) (*types.JobInfo, error) {
-	// check if cluster name is known
-	address := ""
-	version := "v1"
+// clusterRequestAddress returns the address of the proxy of the cluster
+// with the given name including the negotiated protocol version.
+func clusterRequestAddress(i *Inception, clustername string) (string, error) {
 	for _, c := range i.config.Cluster {
-		if c.Name == clustername {
-			address = c.Address
-			version = c.ProtocolVersion
-			break
+		if c.Name == clustername && c.Address != "" {
+			return i.request.requestAddress(c), nil
 		}
 	}
-	if address != "" {
-		request := fmt.Sprintf("%s%s", address, version)
-		log.Println("GetJobFromCluster request", request)
-		job, err := i.request.GetJob(request, jobid)
-		if err == nil {
-			return &job, nil
-		}
-		log.Println("error during requesting job: ", err)
-		return nil, err
+	return "", errors.New("Couldn't find clustername in config: " + clustername)
+}
 
+// splitJobID splits a job identifier of the form jobid@cluster
+// into the job id and the cluster name. The job id of a stacked
+// inception (like 1@inner@outer) is split at the last "@".
+func splitJobID(jobid string) (string, string, error) {
+	at := strings.LastIndex(jobid, "@")
+	if at <= 0 || at == len(jobid)-1 {
+		return "", "", fmt.Errorf("Wrong job identifier (expected jobid@cluster) but is %s", jobid)
 	}
-	return nil, errors.New("Couldn't find clustername in config: " + clustername)
+	return jobid[:at], jobid[at+1:], nil
+}
+
+func getJobFromCluster(i *Inception, clustername string, jobid string) (*types.JobInfo, error) {
+	request, err := clusterRequestAddress(i, clustername)
+	if err != nil {
+		return nil, err
+	}
+	log.Println("GetJobFromCluster request", request)
+	job, err := i.request.GetJob(request, jobid)
+	if err == nil {
+		return &job, nil
+	}
+	log.Println("error during requesting job: ", err)
+	return nil, err
 }
 
 func (i *Inception) GetJobInfo(jobid string) *types.JobInfo {
@@ -115,12 +163,12 @@ func (i *Inception) GetJobInfo(jobid string) *types.JobInfo {
 	// 1301@mybiggridenginecluster search 1301 in the given cluster
 	if strings.Contains(jobid, "@") {
 		// get cluster name
-		jobAtCluster := strings.Split(jobid, "@")
-		if len(jobAtCluster) == 2 {
-			job, _ := getJobFromCluster(i, jobAtCluster[1], jobAtCluster[0])
+		id, clustername, err := splitJobID(jobid)
+		if err == nil {
+			job, _ := getJobFromCluster(i, clustername, id)
 			return job
 		}
-		log.Println("Wrong job identifier (expected jobid@cluster or jobid) but is ", jobid)
+		log.Println(err)
 	} else {
 		// request default cluster for the given job identifier
 		job, _ := getJobFromCluster(i, "default", jobid)
@@ -129,6 +177,8 @@ func (i *Inception) GetJobInfo(jobid string) *types.JobInfo {
 	return nil
 }
 
+// GetAllMachines returns the machines of all clusters which are connected
+// to the uc tool. Each machine is tagged with the name of its cluster.
 func (i *Inception) GetAllMachines(machines []string) ([]types.Machine, error) {
 	allmachines := make([]types.Machine, 0, 0)
 	for _, c := range i.config.Cluster {
@@ -137,12 +187,18 @@ func (i *Inception) GetAllMachines(machines []string) ([]types.Machine, error) {
 		if addr := fmt.Sprintf("%s/", c.Address); addr == i.inceptionAddress {
 			continue
 		}
-		address, _, err := GetClusterAddress(c.Name)
+		address, err := clusterRequestAddress(i, c.Name)
 		if err != nil {
-			log.Panicln(err.Error())
 			return nil, err
 		}
 		if ms, err := i.request.GetMachines(address, "all"); err == nil {
+			// tag the machines with their origin (if not done by
+			// a stacked uc already)
+			for m := range ms {
+				if ms[m].Cluster == "" {
+					ms[m].Cluster = c.Name
+				}
+			}
 			allmachines = append(allmachines, ms...)
 			log.Println("Appending: ", allmachines)
 		} else {
@@ -165,7 +221,7 @@ func (i *Inception) GetAllQueues(queues []string) ([]types.Queue, error) {
 		if addr := fmt.Sprintf("%s/", c.Address); addr == i.inceptionAddress {
 			continue
 		}
-		address, _, err := GetClusterAddress(c.Name)
+		address, _, err := i.request.ClusterAddress(c.Name)
 		if err != nil {
 			log.Panicln(err.Error())
 			return nil, err
@@ -197,18 +253,24 @@ func (i *Inception) GetAllCategories() ([]string, error) {
 			log.Println("Skipping own address")
 			continue
 		}
-		address, _, err := GetClusterAddress(c.Name)
+		address, _, err := i.request.ClusterAddress(c.Name)
 		if err != nil {
 			log.Panicln(err.Error())
 			return nil, err
 		}
-		cat = append(cat, i.request.GetJobCategories(address, "ubercluster", "all")...)
+		if cs, err := i.request.GetJobCategories(address, "ubercluster", "all"); err == nil {
+			cat = append(cat, cs...)
+		} else {
+			log.Println("Error while requesting job categories from ", c.Name, err)
+		}
 	}
 	return cat, nil
 }
 
+// DRMSVersion returns the version of uc since the "DRMS" of an
+// inception proxy is uc itself.
 func (i *Inception) DRMSVersion() string {
-	return "0.1"
+	return Version
 }
 
 func (i *Inception) DRMSName() string {
@@ -219,17 +281,77 @@ func (i *Inception) DRMSLoad() float64 {
 	return 0.5
 }
 
+// selectCluster returns the name of the cluster a job is submitted to.
+// A cluster requested by the Cluster field of the job template is
+// preferred, otherwise the scheduler is asked. The affinity scheduler
+// uses the AffinityKey of the job template (the user name of the
+// submitting uc by default) when it is set. Without a scheduler the
+// default cluster is used.
+func (i *Inception) selectCluster(template types.JobTemplate) string {
+	if template.Cluster != "" {
+		log.Println("Cluster requested by job template: ", template.Cluster)
+		return template.Cluster
+	}
+	if i.scheduler != nil {
+		if qs, ok := i.scheduler.Impl.(QueueScheduler); ok {
+			name, _ := qs.SelectClusterForQueue(template.QueueName)
+			return name
+		}
+		if as, ok := i.scheduler.Impl.(AffinityScheduler); ok && template.AffinityKey != "" {
+			name, _ := as.SelectClusterForKey(template.AffinityKey)
+			return name
+		}
+		return i.scheduler.Impl.SelectCluster()
+	}
+	return "default"
+}
+
+// RunJob forwards the job submission to one of the connected clusters.
+// The returned job id has the form jobid@cluster so that subsequent
+// requests for the job can be routed to the right cluster.
 func (i *Inception) RunJob(template types.JobTemplate) (string, error) {
-	return "", nil
+	clustername := i.selectCluster(template)
+	address, err := clusterRequestAddress(i, clustername)
+	if err != nil {
+		return "", err
+	}
+	// the name is meaningless for the clusters of a stacked uc
+	template.Cluster = ""
+	log.Println("Forwarding job submission to: ", clustername, address)
+	jobid, err := i.request.SubmitJobTemplate(address, template)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s@%s", jobid, clustername), nil
 }
 
+// JobOperation forwards a job operation (suspend, resume, terminate) to
+// the cluster the job is running in. The job id must be in the form
+// jobid@cluster as returned by RunJob.
 func (i *Inception) JobOperation(jobsessionname, operation, jobid string) (string, error) {
-	return "", nil
+	id, clustername, err := splitJobID(jobid)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", proxy.ErrJobNotFound, err)
+	}
+	address, err := clusterRequestAddress(i, clustername)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", proxy.ErrJobNotFound, err)
+	}
+	log.Println("Forwarding job operation to: ", clustername, operation, id)
+	return i.request.JobOperation(address, jobsessionname, operation, id)
 }
 
 // start uc as proxy
-func inceptionMode(certFile, keyFile, otp, address string) {
+func inceptionMode(certFile, keyFile, otp, address, alg string) {
 	incept := NewInception(certFile, keyFile, otp, config)
+	if alg != "" {
+		sched, err := MakeSchedulerByAlg(alg, config, incept.request)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+		incept.scheduler = sched
+	}
 
 	fmt.Println("Starting uc in inception mode as proxy listening at address: ", address)
 	var sc proxy.SecConfig
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgruber/ubercluster/pkg/proxy"
+)
+
+func TestSplitJobID(t *testing.T) {
+	tests := []struct {
+		jobid   string
+		id      string
+		cluster string
+		valid   bool
+	}{
+		{"1@c1", "1", "c1", true},
+		{"1301@mybiggridenginecluster", "1301", "mybiggridenginecluster", true},
+		{"1", "", "", false},
+		{"", "", "", false},
+		{"1@", "", "", false},
+		{"@c1", "", "", false},
+		{"1@c1@c2", "1@c1", "c2", true},
+		{"13@inner@outer", "13@inner", "outer", true},
+	}
+	for _, test := range tests {
+		id, cluster, err := splitJobID(test.jobid)
+		if test.valid != (err == nil) {
+			t.Errorf("Expected %q to be valid: %t but got error %v", test.jobid, test.valid, err)
+		}
+		if id != test.id || cluster != test.cluster {
+			t.Errorf("Expected %q to be split into %q and %q but got %q and %q",
+				test.jobid, test.id, test.cluster, id, cluster)
+		}
+	}
+}
+
+func TestInceptionJobOperationRouting(t *testing.T) {
+	var requested []string
+	makeCluster := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/versions" {
+				http.NotFound(w, r)
+				return
+			}
+			requested = append(requested, name+" "+r.URL.Path)
+			json.NewEncoder(w).Encode("Terminated Job")
+		}))
+	}
+	c1 := makeCluster("c1")
+	defer c1.Close()
+	c2 := makeCluster("c2")
+	defer c2.Close()
+
+	config := Config{Cluster: []ClusterConfig{
+		{Name: "default", Address: c1.URL, ProtocolVersion: "v1"},
+		{Name: "c1", Address: c1.URL, ProtocolVersion: "v1"},
+		{Name: "c2", Address: c2.URL, ProtocolVersion: "v1"},
+	}}
+	incept := NewInception("", "", "", config)
+
+	tests := []struct {
+		jobid     string
+		requested string // empty when the job is not found
+	}{
+		{"1@c1", "c1 /v1/jsession/ubercluster/terminate/1"},
+		{"13@c2", "c2 /v1/jsession/ubercluster/terminate/13"},
+		{"1", ""},
+		{"1@c1@c2", "c2 /v1/jsession/ubercluster/terminate/1@c1"},
+		{"1@unknown", ""},
+		{"1@", ""},
+		{"@c2", ""},
+	}
+	for _, test := range tests {
+		requested = nil
+		out, err := incept.JobOperation("ubercluster", "terminate", test.jobid)
+		if test.requested == "" {
+			if !errors.Is(err, proxy.ErrJobNotFound) {
+				t.Errorf("Expected job %q to be not found but got %v", test.jobid, err)
+			}
+			if len(requested) != 0 {
+				t.Errorf("Expected no request for job %q but got %v", test.jobid, requested)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for job %q: %s", test.jobid, err)
+			continue
+		}
+		if out != "Terminated Job" {
+			t.Errorf("Expected the answer of the cluster for job %q but got %q", test.jobid, out)
+		}
+		if len(requested) != 1 || requested[0] != test.requested {
+			t.Errorf("Expected job %q to be forwarded as %q but got %v", test.jobid, test.requested, requested)
+		}
+	}
+}
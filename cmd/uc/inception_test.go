@@ -0,0 +1,186 @@
+package main_test
+
+import (
+	. "github.com/dgruber/ubercluster/cmd/uc"
+	"github.com/dgruber/ubercluster/pkg/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+var _ = Describe("Inception", func() {
+
+	Context("when job infos are requested", func() {
+
+		It("should pass the filter to the clusters and filter the results", func() {
+			var state, user string
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				state = r.FormValue("state")
+				user = r.FormValue("user")
+				w.Write([]byte(`[{"id":"1","state":4,"jobOwner":"alice","queueName":"a.q"},
+					{"id":"2","state":4,"jobOwner":"alice","queueName":"b.q"}]`))
+			}))
+			defer ts.Close()
+
+			config := Config{Cluster: []ClusterConfig{{Name: "c1", Address: ts.URL, ProtocolVersion: "v1"}}}
+			incept := NewInception("", "", "", config)
+
+			filter := types.CreateJobInfo()
+			filter.State = types.Running
+			filter.JobOwner = "alice"
+			filter.QueueName = "b.q"
+			jis := incept.GetJobInfosByFilter(true, filter)
+			Ω(state).Should(Equal("r"))
+			Ω(user).Should(Equal("alice"))
+			Ω(jis).Should(HaveLen(1))
+			Ω(jis[0].Id).Should(Equal("2"))
+
+			jis = incept.GetJobInfosByFilter(false, types.JobInfo{})
+			Ω(state).Should(Equal(""))
+			Ω(jis).Should(HaveLen(2))
+		})
+
+	})
+
+	Context("when jobs are submitted", func() {
+
+		// memberCluster returns a cluster which accepts jobs and counts
+		// the submissions.
+		memberCluster := func(submissions *int) *httptest.Server {
+			return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasSuffix(r.URL.Path, "/run") {
+					*submissions++
+				}
+				fmt.Fprintf(w, `{"jobid":"%d"}`, *submissions)
+			}))
+		}
+
+		It("should forward the job to the cluster requested by the job template", func() {
+			var submissionsA, submissionsB int
+			a := memberCluster(&submissionsA)
+			defer a.Close()
+			b := memberCluster(&submissionsB)
+			defer b.Close()
+
+			members := Config{Cluster: []ClusterConfig{
+				{Name: "a", Address: a.URL, ProtocolVersion: "v1"},
+				{Name: "b", Address: b.URL, ProtocolVersion: "v1"},
+			}}
+			var otp string
+			r := NewRequest("", "", &otp)
+			address, err := r.ServeGroup(members, "rand")
+			Ω(err).Should(BeNil())
+			for k := 0; k < 4; k++ {
+				jobid, err := r.SubmitJobTemplate(address, types.JobTemplate{RemoteCommand: "/bin/sleep", Cluster: "b"})
+				Ω(err).Should(BeNil())
+				Ω(jobid).Should(Equal(fmt.Sprintf("%d@b", k+1)))
+			}
+			Ω(submissionsA).Should(Equal(0))
+			Ω(submissionsB).Should(Equal(4))
+
+			_, err = r.SubmitJobTemplate(address, types.JobTemplate{RemoteCommand: "/bin/sleep", Cluster: "unknown"})
+			Ω(err).ShouldNot(BeNil())
+		})
+
+		It("should submit to the default cluster without scheduler", func() {
+			var submissionsDefault, submissionsOther int
+			d := memberCluster(&submissionsDefault)
+			defer d.Close()
+			o := memberCluster(&submissionsOther)
+			defer o.Close()
+
+			incept := NewInception("", "", "", Config{Cluster: []ClusterConfig{
+				{Name: "other", Address: o.URL, ProtocolVersion: "v1"},
+				{Name: "default", Address: d.URL, ProtocolVersion: "v1"},
+			}})
+			jobid, err := incept.RunJob(types.JobTemplate{RemoteCommand: "/bin/sleep"})
+			Ω(err).Should(BeNil())
+			Ω(jobid).Should(Equal("1@default"))
+			jobid, err = incept.RunJob(types.JobTemplate{RemoteCommand: "/bin/sleep", Cluster: "other"})
+			Ω(err).Should(BeNil())
+			Ω(jobid).Should(Equal("1@other"))
+			Ω(submissionsDefault).Should(Equal(1))
+			Ω(submissionsOther).Should(Equal(1))
+		})
+
+		It("should forward job operations to the cluster of the job id", func() {
+			var operation string
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				operation = r.URL.Path
+				w.Write([]byte(`"Suspended Job"`))
+			}))
+			defer ts.Close()
+
+			incept := NewInception("", "", "", Config{Cluster: []ClusterConfig{
+				{Name: "c1", Address: ts.URL, ProtocolVersion: "v1"},
+			}})
+			out, err := incept.JobOperation("ubercluster", "suspend", "13@c1")
+			Ω(err).Should(BeNil())
+			Ω(out).Should(Equal("Suspended Job"))
+			Ω(operation).Should(Equal("/v1/jsession/ubercluster/suspend/13"))
+
+			_, err = incept.JobOperation("ubercluster", "suspend", "13")
+			Ω(err).ShouldNot(BeNil())
+			_, err = incept.JobOperation("ubercluster", "suspend", "13@unknown")
+			Ω(err).ShouldNot(BeNil())
+		})
+
+		It("should select the cluster by the affinity key of the job template", func() {
+			var submissionsA, submissionsB int
+			a := memberCluster(&submissionsA)
+			defer a.Close()
+			b := memberCluster(&submissionsB)
+			defer b.Close()
+
+			members := Config{Cluster: []ClusterConfig{
+				{Name: "a", Address: a.URL, ProtocolVersion: "v1"},
+				{Name: "b", Address: b.URL, ProtocolVersion: "v1"},
+			}}
+			sched, err := MakeSchedulerByAlg("affinity", members, nil)
+			Ω(err).Should(BeNil())
+			var otp string
+			r := NewRequest("", "", &otp)
+			address, err := r.ServeGroup(members, "affinity")
+			Ω(err).Should(BeNil())
+
+			// the keys must be spread over both clusters
+			expected := map[string]int{}
+			for k := 0; k < 8; k++ {
+				key := fmt.Sprintf("user%d", k)
+				cluster, _ := sched.Impl.(AffinityScheduler).SelectClusterForKey(key)
+				expected[cluster]++
+				jobid, err := r.SubmitJobTemplate(address, types.JobTemplate{RemoteCommand: "/bin/sleep", AffinityKey: key})
+				Ω(err).Should(BeNil())
+				Ω(jobid).Should(HaveSuffix("@" + cluster))
+			}
+			Ω(expected).Should(HaveLen(2))
+			Ω(submissionsA).Should(Equal(expected["a"]))
+			Ω(submissionsB).Should(Equal(expected["b"]))
+		})
+
+	})
+
+	Context("when machines are requested", func() {
+
+		It("should tag the machines with the name of their cluster", func() {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`[{"name":"host1"},{"name":"host2","cluster":"inner"}]`))
+			}))
+			defer ts.Close()
+
+			config := Config{Cluster: []ClusterConfig{{Name: "c1", Address: ts.URL, ProtocolVersion: "/v1"}}}
+			machines, err := NewInception("", "", "", config).GetAllMachines(nil)
+			Ω(err).Should(BeNil())
+			Ω(machines).Should(HaveLen(2))
+			Ω(machines[0].Cluster).Should(Equal("c1"))
+			Ω(machines[1].Cluster).Should(Equal("inner"))
+		})
+
+	})
+
+})
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/dgruber/ubercluster/pkg/types"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// interactivePollInterval defines how often the state of an interactive
+// job is requested after its output is complete.
+var interactivePollInterval = time.Second
+
+// WaitJob requests the job info in the given interval until the job
+// reached an end state (Done / Failed) and returns it.
+func (r *Request) WaitJob(clusteraddress, jobid string, interval time.Duration) (types.JobInfo, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		jobinfo, err := r.GetJob(clusteraddress, jobid)
+		if err != nil {
+			return jobinfo, err
+		}
+		if jobinfo.State.IsTerminal() {
+			return jobinfo, nil
+		}
+		select {
+		case <-r.context().Done():
+			return jobinfo, r.context().Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// JobExitCode returns the exit code of a finished job which is used as
+// exit code of uc. Failed jobs without exit status return 1.
+func JobExitCode(jobinfo types.JobInfo) int {
+	if jobinfo.State == types.Failed && jobinfo.ExitStatus <= 0 {
+		return 1
+	}
+	if jobinfo.ExitStatus < 0 {
+		return 1
+	}
+	return jobinfo.ExitStatus
+}
+
+// RunInteractive submits the job, writes its output to w while it runs,
+// and returns the exit code of the job once it is finished. The job id
+// is printed to stderr so that w only gets the output of the job. When
+// the proxy can't send the job output only the end of the job is awaited.
+// On Ctrl-C the job is terminated.
+func (r *Request) RunInteractive(clusteraddress, clustername string, jt types.JobTemplate, autoSelected bool, w io.Writer) (int, error) {
+	jobid, err := r.SubmitJobTemplate(clusteraddress, jt)
+	if err != nil {
+		return 0, fmt.Errorf("job submission error: %s", err)
+	}
+	fmt.Fprintln(os.Stderr, "Job ID: ", printedJobID(jobid, clustername, autoSelected))
+
+	ctx, cancel := context.WithCancel(r.context())
+	defer cancel()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	interrupted := make(chan struct{})
+	go func() {
+		select {
+		case <-interrupt:
+			close(interrupted)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	jobinfo, err := r.WithContext(ctx).followJob(clusteraddress, jobid, w)
+	select {
+	case <-interrupted:
+		fmt.Fprintf(os.Stderr, "Terminating job %s\n", jobid)
+		if _, terr := r.JobOperation(clusteraddress, "ubercluster", "terminate", jobid); terr != nil {
+			return 0, terr
+		}
+		return 130, nil
+	default:
+	}
+	if err != nil {
+		return 0, err
+	}
+	return JobExitCode(jobinfo), nil
+}
+
+// followJob copies the output of the job to w until the job is
+// finished and returns the final job info.
+func (r *Request) followJob(clusteraddress, jobid string, w io.Writer) (types.JobInfo, error) {
+	if err := r.ShowJobOutput(clusteraddress, jobid, true, w); err != nil {
+		pe, ok := err.(*ProxyError)
+		if !ok || (pe.StatusCode != http.StatusNotImplemented && pe.StatusCode != http.StatusNotFound) {
+			return types.JobInfo{}, err
+		}
+		log.Printf("Job output is not available (%s), waiting for the end of the job\n", err)
+	}
+	return r.WaitJob(clusteraddress, jobid, interactivePollInterval)
+}
@@ -0,0 +1,89 @@
+package main_test
+
+import (
+	. "github.com/dgruber/ubercluster/cmd/uc"
+	"github.com/dgruber/ubercluster/pkg/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+var _ = Describe("Interactive", func() {
+
+	var otp string
+
+	// interactiveProxy answers the submission with job 7 and returns
+	// the given job states for the job info requests one after another.
+	interactiveProxy := func(output func(w http.ResponseWriter), states ...types.JobInfo) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/v1/jsession/default/run":
+				Ω(r.Method).Should(Equal("POST"))
+				w.Write([]byte(`{"jobid":"7"}`))
+			case "/v1/jsession/default/job/7/output":
+				Ω(r.FormValue("follow")).Should(Equal("true"))
+				output(w)
+			case "/v1/msession/jobinfo/7":
+				ji := states[0]
+				if len(states) > 1 {
+					states = states[1:]
+				}
+				json.NewEncoder(w).Encode(ji)
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+	}
+
+	It("should print the output of the job and return its exit code", func() {
+		ts := interactiveProxy(func(w http.ResponseWriter) {
+			w.Write([]byte("hello\n"))
+		}, types.JobInfo{Id: "7", State: types.Done, ExitStatus: 3})
+		defer ts.Close()
+
+		var out bytes.Buffer
+		exitCode, err := NewRequest("", "", &otp).RunInteractive(ts.URL+"/v1", "default", types.JobTemplate{RemoteCommand: "/bin/echo"}, false, &out)
+		Ω(err).Should(BeNil())
+		Ω(exitCode).Should(Equal(3))
+		Ω(out.String()).Should(Equal("hello\n"))
+	})
+
+	It("should wait for the end of the job when the output is not supported", func() {
+		ts := interactiveProxy(func(w http.ResponseWriter) {
+			http.Error(w, "job output is not supported by the proxy", http.StatusNotImplemented)
+		}, types.JobInfo{Id: "7", State: types.Failed, ExitStatus: -1})
+		defer ts.Close()
+
+		var out bytes.Buffer
+		exitCode, err := NewRequest("", "", &otp).RunInteractive(ts.URL+"/v1", "default", types.JobTemplate{RemoteCommand: "/bin/false"}, false, &out)
+		Ω(err).Should(BeNil())
+		Ω(exitCode).Should(Equal(1))
+		Ω(out.String()).Should(BeEmpty())
+	})
+
+	It("should wait until the job reached an end state", func() {
+		ts := interactiveProxy(nil,
+			types.JobInfo{Id: "7", State: types.Queued},
+			types.JobInfo{Id: "7", State: types.Running},
+			types.JobInfo{Id: "7", State: types.Done})
+		defer ts.Close()
+
+		ji, err := NewRequest("", "", &otp).WaitJob(ts.URL+"/v1", "7", 10*time.Millisecond)
+		Ω(err).Should(BeNil())
+		Ω(ji.State).Should(Equal(types.Done))
+	})
+
+	It("should return the exit code of a finished job", func() {
+		Ω(JobExitCode(types.JobInfo{State: types.Done, ExitStatus: 0})).Should(Equal(0))
+		Ω(JobExitCode(types.JobInfo{State: types.Done, ExitStatus: 2})).Should(Equal(2))
+		Ω(JobExitCode(types.JobInfo{State: types.Failed, ExitStatus: 0})).Should(Equal(1))
+		Ω(JobExitCode(types.JobInfo{State: types.Failed, ExitStatus: 137})).Should(Equal(137))
+	})
+
+})
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"github.com/dgruber/ubercluster/pkg/types"
+	"sort"
+)
+
+// MachineListOptions defines how the list of machines is
+// processed before it is printed.
+type MachineListOptions struct {
+	SortBy  string  // "load", "name", "cores" or "" for the order of the proxy
+	MaxLoad float64 // machines with a higher load are skipped (negative for no limit)
+	// ByCluster prints a summary of the machines of each cluster instead
+	// of the machines (useful when uc runs in inception mode)
+	ByCluster bool
+}
+
+// FilterMachinesByLoad returns all machines which have a load lower
+// or equal than maxLoad. A negative maxLoad does not filter.
+func FilterMachinesByLoad(machines []types.Machine, maxLoad float64) []types.Machine {
+	if maxLoad < 0 {
+		return machines
+	}
+	filtered := make([]types.Machine, 0, len(machines))
+	for i := range machines {
+		if machines[i].Load <= maxLoad {
+			filtered = append(filtered, machines[i])
+		}
+	}
+	return filtered
+}
+
+// SortMachines sorts the machines in place. Machines are sorted by
+// ascending load, by name, or by descending amount of cores.
+func SortMachines(machines []types.Machine, by string) error {
+	var less func(i, j int) bool
+	switch by {
+	case "":
+		return nil
+	case "load":
+		less = func(i, j int) bool { return machines[i].Load < machines[j].Load }
+	case "name":
+		less = func(i, j int) bool { return machines[i].Name < machines[j].Name }
+	case "cores":
+		less = func(i, j int) bool { return machines[i].Cores() > machines[j].Cores() }
+	default:
+		return fmt.Errorf("can not sort machines by %s (expected load, name, or cores)", by)
+	}
+	sort.SliceStable(machines, less)
+	return nil
+}
+
+// TagMachinesWithCluster sets the cluster of all machines which are not
+// tagged with their origin already.
+func TagMachinesWithCluster(machines []types.Machine, cluster string) {
+	for i := range machines {
+		if machines[i].Cluster == "" {
+			machines[i].Cluster = cluster
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main_test
+
+import (
+	. "github.com/dgruber/ubercluster/cmd/uc"
+	"github.com/dgruber/ubercluster/pkg/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Machines", func() {
+
+	var machines []types.Machine
+
+	BeforeEach(func() {
+		machines = []types.Machine{
+			{Name: "b", Load: 0.9, Sockets: 1, CoresPerSocket: 4},
+			{Name: "c", Load: 0.1, Sockets: 2, CoresPerSocket: 8},
+			{Name: "a", Load: 0.5, Sockets: 1, CoresPerSocket: 2},
+		}
+	})
+
+	names := func(machines []types.Machine) []string {
+		n := make([]string, 0, len(machines))
+		for _, m := range machines {
+			n = append(n, m.Name)
+		}
+		return n
+	}
+
+	Context("when the machine list is processed", func() {
+
+		It("should sort the machines", func() {
+			Ω(SortMachines(machines, "load")).Should(BeNil())
+			Ω(names(machines)).Should(Equal([]string{"c", "a", "b"}))
+			Ω(SortMachines(machines, "name")).Should(BeNil())
+			Ω(names(machines)).Should(Equal([]string{"a", "b", "c"}))
+			Ω(SortMachines(machines, "cores")).Should(BeNil())
+			Ω(names(machines)).Should(Equal([]string{"c", "b", "a"}))
+		})
+
+		It("should filter the machines by load", func() {
+			Ω(names(FilterMachinesByLoad(machines, 0.5))).Should(Equal([]string{"c", "a"}))
+			Ω(FilterMachinesByLoad(machines, -1)).Should(HaveLen(3))
+		})
+
+		It("should reject unknown sort keys", func() {
+			Ω(SortMachines(machines, "memory")).ShouldNot(BeNil())
+		})
+
+		It("should tag untagged machines with the cluster", func() {
+			machines[1].Cluster = "other"
+			TagMachinesWithCluster(machines, "default")
+			Ω(machines[0].Cluster).Should(Equal("default"))
+			Ω(machines[1].Cluster).Should(Equal("other"))
+		})
+
+	})
+
+})
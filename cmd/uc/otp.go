@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// OTPEnvironmentVariable can contain the one time password ("yubikey")
+// or shared secret instead of the --otp flag, which is visible for all
+// users in the process list.
+const OTPEnvironmentVariable = "UC_OTP"
+
+// ResolveOTP returns the one time password ("yubikey") or shared secret
+// given by the --otp flag, the first line of the --otp-file, or the
+// UC_OTP environment variable (in that order of precedence).
+func ResolveOTP(flag, file string) (string, error) {
+	if flag != "" {
+		return flag, nil
+	}
+	if file != "" {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("can't read one time password file: %s", err)
+		}
+		secret := strings.TrimSpace(strings.SplitN(string(content), "\n", 2)[0])
+		if secret == "" {
+			return "", fmt.Errorf("one time password file %s is empty", file)
+		}
+		return secret, nil
+	}
+	return os.Getenv(OTPEnvironmentVariable), nil
+}
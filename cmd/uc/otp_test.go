@@ -0,0 +1,53 @@
+package main_test
+
+import (
+	. "github.com/dgruber/ubercluster/cmd/uc"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+var _ = Describe("OTP", func() {
+
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "ucotp")
+		Ω(err).Should(BeNil())
+		os.Setenv(OTPEnvironmentVariable, "fromenv")
+	})
+
+	AfterEach(func() {
+		os.Unsetenv(OTPEnvironmentVariable)
+		os.RemoveAll(dir)
+	})
+
+	It("should prefer the flag over the file and the environment", func() {
+		file := filepath.Join(dir, "otp")
+		Ω(ioutil.WriteFile(file, []byte("fromfile\n"), 0600)).Should(BeNil())
+		otp, err := ResolveOTP("fromflag", file)
+		Ω(err).Should(BeNil())
+		Ω(otp).Should(Equal("fromflag"))
+		otp, err = ResolveOTP("", file)
+		Ω(err).Should(BeNil())
+		Ω(otp).Should(Equal("fromfile"))
+		otp, err = ResolveOTP("", "")
+		Ω(err).Should(BeNil())
+		Ω(otp).Should(Equal("fromenv"))
+	})
+
+	It("should reject missing or empty files", func() {
+		_, err := ResolveOTP("", filepath.Join(dir, "missing"))
+		Ω(err).ShouldNot(BeNil())
+		file := filepath.Join(dir, "empty")
+		Ω(ioutil.WriteFile(file, []byte("\n"), 0600)).Should(BeNil())
+		_, err = ResolveOTP("", file)
+		Ω(err).ShouldNot(BeNil())
+	})
+
+})
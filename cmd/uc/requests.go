@@ -18,8 +18,10 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/dgruber/ubercluster/pkg/http_helper"
 	"github.com/dgruber/ubercluster/pkg/output"
@@ -27,16 +29,26 @@ import (
 	"github.com/dgruber/ubercluster/pkg/types"
 
 	"crypto/x509"
+	"golang.org/x/crypto/ssh/terminal"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	osuser "os/user"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type Request struct {
-	otp    *string
-	client *http.Client
+	otp      *string
+	client   *http.Client
+	retry    retryPolicy
+	ctx      context.Context // carries the request id sent to the proxies
+	versions *versionCache   // negotiated protocol versions of the clusters
 }
 
 func NewRequest(certFile string, keyFile string, oneTimePassword *string) *Request {
@@ -75,113 +87,503 @@ func NewRequest(certFile string, keyFile string, oneTimePassword *string) *Reque
 
 	config.BuildNameToCertificate()
 
-	tr := &http.Transport{
-		MaxIdleConns:       10,
-		IdleConnTimeout:    30 * time.Second,
-		DisableCompression: false,
-		TLSClientConfig:    &config,
+	client := http_helper.NewClient(http_helper.ClientConfig{TLSConfig: &config})
+
+	// all requests of one uc call can be correlated by the same id
+	return &Request{
+		otp:      oneTimePassword,
+		client:   client,
+		ctx:      http_helper.WithRequestID(context.Background(), http_helper.NewRequestID()),
+		versions: &versionCache{versions: make(map[string]string), negotiated: make(map[string]string)},
 	}
+}
 
-	client := &http.Client{Transport: tr}
+// WithContext returns a copy of the request object which sends the
+// request id of the given context to the proxies.
+func (r *Request) WithContext(ctx context.Context) *Request {
+	c := *r
+	c.ctx = ctx
+	return &c
+}
 
-	return &Request{
-		otp:    oneTimePassword,
-		client: client,
+// context returns the context of the requests to the proxies.
+func (r *Request) context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
 	}
+	return r.ctx
 }
 
-func (r *Request) SelectClusterAddress(cluster, alg string) (string, string, error) {
+// SetTimeout limits the time of each request to a proxy including
+// reading the answer. A timeout of 0 disables the limit.
+func (r *Request) SetTimeout(timeout time.Duration) {
+	r.client.Timeout = timeout
+}
+
+// SelectClusterAddress returns the address and name of the cluster
+// given by its name or chosen by the selection algorithm. Schedulers
+// which consider queues select a cluster for the given queue, affinity
+// schedulers for the given affinity key (when set). Cluster groups
+// ("group:name") are addressed through a local proxy aggregating the
+// member clusters (see GroupAddress).
+func (r *Request) SelectClusterAddress(cluster, alg, queue, affinityKey string) (string, string, error) {
+	if IsGroup(cluster) {
+		return r.GroupAddress(cluster, alg)
+	}
+	if alg == "" {
+		return r.ClusterAddress(cluster)
+	}
 	// a cluster selection algorithm chooses the right cluster
-	switch alg {
-	case "rand": // random scheduling
-		return GetClusterAddress(MakeNewScheduler(RandomSchedulerType, config, r.client).Impl.SelectCluster())
-	case "prob": // probabilistic scheduling
-		return GetClusterAddress(MakeNewScheduler(ProbabilisticSchedulerType, config, r.client).Impl.SelectCluster())
-	case "load": // load based scheduling
-		return GetClusterAddress(MakeNewScheduler(LoadBasedSchedulerType, config, r.client).Impl.SelectCluster())
+	sched, err := MakeSchedulerByAlg(alg, config, r)
+	if err != nil {
+		return "", "", err
+	}
+	var name, reason string
+	if qs, ok := sched.Impl.(QueueScheduler); ok {
+		name, reason = qs.SelectClusterForQueue(queue)
+	} else if as, ok := sched.Impl.(AffinityScheduler); ok && affinityKey != "" {
+		name, reason = as.SelectClusterForKey(affinityKey)
+	} else {
+		name, reason = sched.Impl.SelectClusterWithReason()
+	}
+	log.Printf("Selected cluster %s: %s\n", name, reason)
+	return r.ClusterAddress(name)
+}
+
+// printedJobID returns the job id which is printed after a submission.
+// When the cluster was selected by a scheduling algorithm the job id
+// is qualified with the cluster name (jobid@cluster) so that later
+// commands find the job without specifying the cluster.
+func printedJobID(jobid, clustername string, autoSelected bool) string {
+	if !autoSelected {
+		return jobid
 	}
-	if alg != "" {
-		fmt.Println("Unkown scheduler selection algorithm: ", alg)
-		os.Exit(2)
+	return fmt.Sprintf("%s@%s", jobid, clustername)
+}
+
+// isConfiguredCluster returns true if a cluster with the given name
+// is part of the configuration.
+func isConfiguredCluster(name string) bool {
+	for _, cc := range config.Cluster {
+		if cc.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveJobCluster resolves job ids of the form jobid@cluster as they
+// are printed after submissions to automatically selected clusters. If
+// no cluster was requested (cluster is "default") and the part after the
+// last "@" is a configured cluster the job id without that suffix and
+// the address and name of the cluster are returned. Otherwise (like for
+// job ids of uc in inception mode or an explicit --cluster) the job id
+// and the given cluster are returned unchanged.
+func (r *Request) ResolveJobCluster(jobid, cluster, clusteraddress, clustername string) (string, string, string, error) {
+	at := strings.LastIndex(jobid, "@")
+	if cluster != "default" || at <= 0 || !isConfiguredCluster(jobid[at+1:]) {
+		return jobid, clusteraddress, clustername, nil
+	}
+	address, name, err := r.ClusterAddress(jobid[at+1:])
+	if err != nil {
+		return "", "", "", err
+	}
+	return jobid[:at], address, name, nil
+}
+
+// ProxyError is returned when the proxy answers a request with
+// a non-2xx http status code.
+type ProxyError struct {
+	StatusCode int    // http status code of the answer
+	Status     string // http status line of the answer
+	Message    string // body of the answer
+}
+
+func (pe *ProxyError) Error() string {
+	return fmt.Sprintf("request failed (%s): %s", pe.Status, pe.Message)
+}
+
+// responseError returns an error containing the answer of the proxy
+// in case the request was not successful.
+func responseError(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	return &ProxyError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Message:    strings.TrimSpace(string(body)),
+	}
+}
+
+// CheckCluster verifies that the proxy at the given address is
+// reachable and answers requests.
+func (r *Request) CheckCluster(clusteraddress string) error {
+	request := fmt.Sprintf("%s%s", clusteraddress, "/msession/drmsname")
+	log.Println("Requesting:" + request)
+	resp, err := r.get(request)
+	if err != nil {
+		return err
 	}
-	return GetClusterAddress(cluster)
+	defer resp.Body.Close()
+	return responseError(resp)
 }
 
 func (r *Request) GetJob(clusteraddress, jobid string) (types.JobInfo, error) {
+	var jobinfo types.JobInfo
 	request := fmt.Sprintf("%s%s%s", clusteraddress, "/msession/jobinfo/", jobid)
 	log.Println("Requesting:" + request)
 
-	resp, err := http_helper.UberGet(r.client, *otp, request)
+	resp, err := r.get(request)
 	if err != nil {
-		log.Fatal(err)
-		os.Exit(1)
+		return jobinfo, err
 	}
 	defer resp.Body.Close()
+	if err := responseError(resp); err != nil {
+		return jobinfo, err
+	}
 
 	decoder := json.NewDecoder(resp.Body)
-	var jobinfo types.JobInfo
 	if err := decoder.Decode(&jobinfo); err != nil {
+		if err == io.EOF {
+			return jobinfo, fmt.Errorf("job %s not found", jobid)
+		}
 		return jobinfo, err
 	}
 	return jobinfo, nil
 }
 
-func (r *Request) ShowJobDetails(clustername, jobid string, of output.OutputFormater) {
+// printJobSeparator prints the empty line between the jobs of a job
+// list. Table formats print one line per job without separator.
+func printJobSeparator(of output.OutputFormater) {
+	if !output.IsTableFormat(of) {
+		fmt.Println()
+	}
+}
+
+// ShowJobDetails prints the details of the job. When the job id
+// refers to an array job, the details of all its tasks are printed.
+// With full set all fields of the job info are printed.
+func (r *Request) ShowJobDetails(clustername, jobid string, full bool, of output.OutputFormater) error {
+	printJob := of.PrintJobDetails
+	if full {
+		printJob = of.PrintFullJobDetails
+	}
 	jobinfo, err := r.GetJob(clustername, jobid)
-	if err == nil {
+	if err != nil {
+		tasks, terr := r.GetArrayJobTasks(clustername, jobid)
+		if terr != nil || len(tasks) == 0 {
+			return err
+		}
+		for i := range tasks {
+			printJob(tasks[i])
+			printJobSeparator(of)
+		}
+		return nil
+	}
+	printJob(jobinfo)
+	return nil
+}
+
+// GetArrayJobTasks returns the job infos of all tasks of an array job.
+// Tasks have the job id "<arrayjobid>.<taskid>".
+func (r *Request) GetArrayJobTasks(clusteraddress, arrayjobid string) ([]types.JobInfo, error) {
+	joblist, err := r.GetJobs(clusteraddress, "all", "")
+	if err != nil {
+		return nil, err
+	}
+	tasks := make([]types.JobInfo, 0)
+	for i := range joblist {
+		if strings.HasPrefix(joblist[i].Id, arrayjobid+".") {
+			tasks = append(tasks, joblist[i])
+		}
+	}
+	return tasks, nil
+}
+
+// WatchJobDetails prints the job details repeatedly in the given
+// interval until the job reached an end state (Done / Failed) or
+// the user interrupts it (Ctrl-C). On a terminal the screen is
+// cleared before each refresh.
+func (r *Request) WatchJobDetails(clusteraddress, jobid string, interval time.Duration, of output.OutputFormater) error {
+	if interval <= 0 {
+		return fmt.Errorf("invalid refresh interval %s (must be positive)", interval)
+	}
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	tty := terminal.IsTerminal(int(os.Stdout.Fd()))
+	for {
+		jobinfo, err := r.GetJob(clusteraddress, jobid)
+		if err != nil {
+			return err
+		}
+		if tty {
+			// move cursor to top left and clear the screen
+			fmt.Print("\033[H\033[2J")
+		}
 		of.PrintJobDetails(jobinfo)
-	} else {
-		fmt.Println("Error: ", err)
+		fmt.Println()
+		if jobinfo.State.IsTerminal() {
+			return nil
+		}
+		select {
+		case <-interrupt:
+			return nil
+		case <-ticker.C:
+		}
 	}
 }
 
-func (r *Request) GetJobs(clusteraddress, state, user string) []types.JobInfo {
-	firstSet := false
-	request := fmt.Sprintf("%s%s", clusteraddress, "/msession/jobinfos")
+// jobsPageSize is the amount of job infos requested at once from a proxy.
+const jobsPageSize = 1000
+
+// jobsRequest creates the request for the job infos matching the
+// given state and user returning at most limit job infos at once.
+func jobsRequest(clusteraddress, state, user string, window SubmissionWindow, limit int) (string, error) {
+	query := url.Values{}
 	if state != "" && state != "all" {
-		firstSet = true
-		request = fmt.Sprintf("%s%s%s", request, "?state=", state)
+		js, err := types.ParseJobState(state)
+		if err != nil {
+			return "", err
+		}
+		query.Set("state", js.ShortCode())
 	}
 	if user != "" {
-		if firstSet == true {
-			request = fmt.Sprintf("%s%s", request, "&")
-		} else {
-			request = fmt.Sprintf("%s%s", request, "?")
+		query.Set("user", user)
+	}
+	if !window.Since.IsZero() {
+		query.Set("since", window.Since.Format(time.RFC3339))
+	}
+	if !window.Until.IsZero() {
+		query.Set("until", window.Until.Format(time.RFC3339))
+	}
+	query.Set("limit", strconv.Itoa(limit))
+	return fmt.Sprintf("%s/msession/jobinfos?%s", clusteraddress, query.Encode()), nil
+}
+
+// SubmissionWindow selects the jobs submitted within a time window.
+// A zero Since or Until leaves the window open at that side.
+type SubmissionWindow struct {
+	Since time.Time
+	Until time.Time
+}
+
+// ParseSubmissionWindow parses the --since and --until values which
+// are either RFC 3339 times or durations before now (like 30m, 2h, 1d).
+func ParseSubmissionWindow(since, until string) (SubmissionWindow, error) {
+	var window SubmissionWindow
+	now := time.Now()
+	if since != "" {
+		t, err := types.ParseTime(since, now)
+		if err != nil {
+			return window, fmt.Errorf("--since: %s", err)
 		}
-		request = fmt.Sprintf("%s%s%s", request, "user=", user)
+		window.Since = t
+	}
+	if until != "" {
+		t, err := types.ParseTime(until, now)
+		if err != nil {
+			return window, fmt.Errorf("--until: %s", err)
+		}
+		window.Until = t
+	}
+	if !window.Since.IsZero() && !window.Until.IsZero() && window.Until.Before(window.Since) {
+		return window, errors.New("--until is before --since")
+	}
+	return window, nil
+}
+
+// IsZero returns true when the window selects all jobs.
+func (w SubmissionWindow) IsZero() bool {
+	return w.Since.IsZero() && w.Until.IsZero()
+}
+
+// filter returns the jobs submitted within the window. The proxy
+// filters the jobs already, older proxies ignore the window though.
+func (w SubmissionWindow) filter(jobinfos []types.JobInfo) []types.JobInfo {
+	if w.IsZero() {
+		return jobinfos
+	}
+	matching := make([]types.JobInfo, 0, len(jobinfos))
+	for _, ji := range jobinfos {
+		if ji.SubmittedBetween(w.Since, w.Until) {
+			matching = append(matching, ji)
+		}
+	}
+	return matching
+}
+
+// jobOwnerFilter returns the user filter of job info requests for the
+// --user flag of "uc show job": no user selects the jobs of the current
+// user, "all" the jobs of all users, and any other name the jobs of
+// that owner.
+func jobOwnerFilter(user string) string {
+	if user != "" {
+		return user
+	}
+	if name := currentUser(); name != "" {
+		return name
 	}
+	// current user is unknown
+	return types.AllJobOwners
+}
+
+// currentUser returns the name of the user running uc or an empty
+// string when it is not known.
+func currentUser() string {
+	if u, err := osuser.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// getJobsPage requests the job infos starting at the given offset.
+func (r *Request) getJobsPage(request string, offset int) ([]types.JobInfo, error) {
+	request = fmt.Sprintf("%s&offset=%d", request, offset)
 	log.Println("Requesting:" + request)
-	resp, err := http_helper.UberGet(r.client, *otp, request)
+	resp, err := r.get(request)
 	if err != nil {
-		log.Fatal(err)
-		os.Exit(1)
+		return nil, err
 	}
 	defer resp.Body.Close()
+	if err := responseError(resp); err != nil {
+		return nil, err
+	}
 
 	decoder := json.NewDecoder(resp.Body)
 	var joblist []types.JobInfo
-	decoder.Decode(&joblist)
+	// the proxy sends an empty answer when there are no jobs
+	if err := decoder.Decode(&joblist); err != nil && err != io.EOF {
+		return nil, err
+	}
 	log.Println(joblist)
+	return joblist, nil
+}
+
+// forEachJobsPage requests the job infos matching the given state,
+// user, and submission window page by page so that large amounts of
+// jobs are not requested at once. The pages are passed to f.
+func (r *Request) forEachJobsPage(clusteraddress, state, user string, window SubmissionWindow, f func([]types.JobInfo)) error {
+	request, err := jobsRequest(clusteraddress, state, user, window, jobsPageSize)
+	if err != nil {
+		return err
+	}
+	firstID := ""
+	for offset := 0; ; offset += jobsPageSize {
+		joblist, err := r.getJobsPage(request, offset)
+		if err != nil {
+			return err
+		}
+		if len(joblist) > 0 {
+			if offset == 0 {
+				firstID = joblist[0].Id
+			} else if joblist[0].Id == firstID {
+				// an older proxy ignores the offset and returns the
+				// first page again
+				return nil
+			}
+		}
+		f(window.filter(joblist))
+		// a proxy without paging returns all jobs at once
+		if len(joblist) != jobsPageSize {
+			return nil
+		}
+	}
+}
 
-	return joblist
+func (r *Request) GetJobs(clusteraddress, state, user string) ([]types.JobInfo, error) {
+	var joblist []types.JobInfo
+	err := r.forEachJobsPage(clusteraddress, state, user, SubmissionWindow{}, func(page []types.JobInfo) {
+		joblist = append(joblist, page...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return joblist, nil
 }
 
-func (r *Request) ShowJobs(clusteraddress, state, user string, of output.OutputFormater) {
-	joblist := r.GetJobs(clusteraddress, state, user)
-	for index := range joblist {
-		of.PrintJobDetails(joblist[index])
-		fmt.Println()
+// CountJobs returns the amount of jobs matching the given state, user,
+// and submission window. Only one job info is requested since the proxy
+// returns the total amount of matching jobs in the X-Total-Count header.
+// For proxies without that header the returned job infos are counted.
+// Jobs of a submission window are always counted since older proxies
+// don't filter them.
+func (r *Request) CountJobs(clusteraddress, state, user string, window SubmissionWindow) (int, error) {
+	if !window.IsZero() {
+		count := 0
+		err := r.forEachJobsPage(clusteraddress, state, user, window, func(page []types.JobInfo) {
+			count += len(page)
+		})
+		return count, err
+	}
+	request, err := jobsRequest(clusteraddress, state, user, window, 1)
+	if err != nil {
+		return 0, err
+	}
+	log.Println("Requesting:" + request)
+	resp, err := r.get(request)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if err := responseError(resp); err != nil {
+		return 0, err
 	}
-	if len(joblist) == 0 {
+	if total := resp.Header.Get("X-Total-Count"); total != "" {
+		count, err := strconv.Atoi(total)
+		if err != nil {
+			return 0, fmt.Errorf("invalid job count %s", total)
+		}
+		return count, nil
+	}
+	// a proxy without paging returns all jobs at once
+	var joblist []types.JobInfo
+	if err := json.NewDecoder(resp.Body).Decode(&joblist); err != nil && err != io.EOF {
+		return 0, err
+	}
+	return len(joblist), nil
+}
+
+// ShowJobCount prints the amount of jobs matching the given state,
+// user, and submission window.
+func (r *Request) ShowJobCount(clusteraddress, state, user string, window SubmissionWindow) error {
+	count, err := r.CountJobs(clusteraddress, state, user, window)
+	if err != nil {
+		return err
+	}
+	fmt.Println(count)
+	return nil
+}
+
+func (r *Request) ShowJobs(clusteraddress, state, user string, window SubmissionWindow, of output.OutputFormater) error {
+	found := 0
+	err := r.forEachJobsPage(clusteraddress, state, user, window, func(page []types.JobInfo) {
+		for index := range page {
+			of.PrintJobDetails(page[index])
+			printJobSeparator(of)
+		}
+		found += len(page)
+	})
+	if err != nil {
+		return err
+	}
+	if found == 0 {
 		if state != "all" {
 			fmt.Printf("No job in state %s found.\n", state)
 		} else {
 			fmt.Printf("No job found.\n")
 		}
 	}
+	return nil
 }
 
-func (r *Request) RunLocalRequest(otp, clusteraddress, cmd, arg string) {
+func (r *Request) RunLocalRequest(otp, clusteraddress, cmd, arg string) error {
 	url := fmt.Sprintf("%s%s", clusteraddress, "/local/run")
 	log.Println("POST to URL:", url)
 	rlr := types.RunLocalRequest{
@@ -189,76 +591,178 @@ func (r *Request) RunLocalRequest(otp, clusteraddress, cmd, arg string) {
 		Arg:     arg,
 	}
 	body, _ := json.Marshal(rlr)
-	resp, err := http_helper.UberPost(r.client, otp, url, "application/json", bytes.NewBuffer(body))
+	resp, err := http_helper.UberPostWithContext(r.context(), r.client, otp, url, "application/json", bytes.NewBuffer(body))
 	if err != nil {
-		fmt.Println("Run local error: ", err)
-		return
+		return fmt.Errorf("run local error: %s", err)
 	}
 	defer resp.Body.Close()
+	if err := responseError(resp); err != nil {
+		return err
+	}
 
 	var answer string
 	respBody, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		fmt.Printf("Error during reading answer from proxy: %s\n", err.Error())
-		return
+		return fmt.Errorf("error during reading answer from proxy: %s", err)
 	}
 	json.Unmarshal(respBody, &answer)
 	fmt.Printf("%s\n", answer)
+	return nil
 }
 
-func (r *Request) CreateJobRequest(jobname, cmd, arg, queue, category string) []byte {
-	jt := types.JobTemplate{
-		RemoteCommand: cmd,
-		JobName:       jobname,
-		QueueName:     queue,
-		JobCategory:   category,
-	}
+func (r *Request) CreateJobTemplate(jobname, cmd, arg, queue, category string) types.JobTemplate {
+	jt := types.CreateJobTemplate()
+	jt.RemoteCommand = cmd
+	jt.JobName = jobname
+	jt.QueueName = queue
+	jt.JobCategory = category
 	if arg != "" {
 		jt.Args = []string{arg}
 	}
-	jtb, _ := json.Marshal(jt)
-	return jtb
+	return jt
 }
 
 // SubmitJob creates a new job in the given cluster
-func (r *Request) SubmitJob(clusteraddress, clustername, jobname, cmd, arg, queue, category, otp string) {
-	jtb := r.CreateJobRequest(jobname, cmd, arg, queue, category)
+func (r *Request) SubmitJob(clusteraddress, clustername string, jt types.JobTemplate, autoSelected bool) error {
+	jobid, err := r.SubmitJobTemplate(clusteraddress, jt)
+	if err != nil {
+		return fmt.Errorf("job submission error: %s", err)
+	}
+	fmt.Println("Job ID: ", printedJobID(jobid, clustername, autoSelected))
+	fmt.Println("Cluster: ", clustername)
+	return nil
+}
+
+// DryRunJob prints the job template which would be submitted in the
+// selected output format instead of submitting it. The target cluster
+// and the array job range are printed to stderr so that the output
+// can be reused as job template file.
+func (r *Request) DryRunJob(clusteraddress, clustername string, jt types.JobTemplate, array string, of output.OutputFormater) error {
+	fmt.Fprintf(os.Stderr, "Cluster: %s (%s)\n", clustername, clusteraddress)
+	if array != "" {
+		begin, end, step, err := ParseArrayRange(array)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Array job tasks: %d-%d:%d\n", begin, end, step)
+	}
+	of.PrintJobTemplate(jt)
+	return nil
+}
+
+// SubmitArrayJob submits an array job with tasks in the given range
+// ("begin:end[:step]") and prints out the id of the array job.
+func (r *Request) SubmitArrayJob(clusteraddress, clustername string, jt types.JobTemplate, array string, maxParallel int, autoSelected bool) error {
+	begin, end, step, err := ParseArrayRange(array)
+	if err != nil {
+		return err
+	}
+	ajr := types.ArrayJobRequest{
+		JobTemplate: jt,
+		Begin:       begin,
+		End:         end,
+		Step:        step,
+		MaxParallel: maxParallel,
+	}
+	jobid, err := r.SubmitArrayJobRequest(clusteraddress, ajr)
+	if err != nil {
+		return fmt.Errorf("job submission error: %s", err)
+	}
+	fmt.Println("Array job ID: ", printedJobID(jobid, clustername, autoSelected))
+	fmt.Println("Cluster: ", clustername)
+	return nil
+}
+
+// ParseArrayRange parses an array job range given as "begin:end:step".
+// The step is optional and defaults to 1.
+func ParseArrayRange(array string) (begin, end, step int, err error) {
+	parts := strings.Split(array, ":")
+	if len(parts) != 2 && len(parts) != 3 {
+		return 0, 0, 0, fmt.Errorf("invalid array range %s (expected begin:end:step)", array)
+	}
+	step = 1
+	values := []*int{&begin, &end, &step}
+	for i := range parts {
+		if *values[i], err = strconv.Atoi(parts[i]); err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid array range %s: %s", array, err)
+		}
+	}
+	if begin < 1 || end < begin || step < 1 {
+		return 0, 0, 0, fmt.Errorf("invalid array range %s (requires 1 <= begin <= end and step >= 1)", array)
+	}
+	return begin, end, step, nil
+}
+
+// SubmitJobTemplate sends the job template to the proxy of the given
+// cluster and returns the job id the cluster assigned to the job.
+func (r *Request) SubmitJobTemplate(clusteraddress string, jt types.JobTemplate) (string, error) {
+	return r.submit(fmt.Sprintf("%s%s", clusteraddress, "/jsession/default/run"), jt)
+}
+
+// SubmitArrayJobRequest sends the array job request to the proxy of the
+// given cluster and returns the id of the array job.
+func (r *Request) SubmitArrayJobRequest(clusteraddress string, ajr types.ArrayJobRequest) (string, error) {
+	return r.submit(fmt.Sprintf("%s%s", clusteraddress, "/jsession/default/runbulk"), ajr)
+}
+
+// submit posts the JSON encoded submission request to the proxy
+// and returns the job id of the answer.
+func (r *Request) submit(url string, submission interface{}) (string, error) {
+	jtb, err := json.Marshal(submission)
+	if err != nil {
+		return "", err
+	}
 
-	// create URL of cluster to send the job to
-	url := fmt.Sprintf("%s%s", clusteraddress, "/jsession/default/run")
 	log.Println("POST to URL:", url)
 	log.Println("Submit template: ", string(jtb))
 
-	resp, err := http_helper.UberPost(r.client, otp, url, "application/json", bytes.NewBuffer(jtb))
+	resp, err := r.post(url, "application/json", jtb, false)
 	if err != nil {
-		fmt.Printf("Job submission error: %s\n", err.Error())
-		return
+		return "", err
 	}
 	defer resp.Body.Close()
+	if err := responseError(resp); err != nil {
+		return "", err
+	}
 
-	// fmt.Println("Job submitted successfully: ", resp.Status)
-	var answer proxy.RunJobResult
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		fmt.Printf("Error during reading answer from proxy: %s\n", err.Error())
-		return
+		return "", err
 	}
 
-	err = json.Unmarshal(body, &answer)
-	if err != nil {
-		fmt.Printf("Error during decoding answer from POSTING to proxy during job submission: %s\n", string(body))
-	} else {
-		fmt.Println("Job ID: ", answer.JobId)
-		fmt.Println("Cluster: ", clustername)
+	var answer proxy.RunJobResult
+	if err := json.Unmarshal(body, &answer); err != nil {
+		return "", fmt.Errorf("can't decode answer of job submission: %s", string(body))
 	}
+	return answer.JobId, nil
 }
 
-func (r *Request) ShowQueues(clustername, queue string, of output.OutputFormater) {
-	r.ShowMachinesQueues(clustername, "queues", queue, of)
+func (r *Request) ShowQueues(clustername, queue string, of output.OutputFormater) error {
+	return r.ShowMachinesQueues(clustername, "queues", queue, of)
 }
 
-func (r *Request) ShowMachines(clustername, machine string, of output.OutputFormater) {
-	r.ShowMachinesQueues(clustername, "machines", machine, of)
+// ShowMachines prints the machines of the cluster after filtering
+// and sorting them as defined in the options. When requested it prints
+// a summary for each cluster the machines belong to instead. Machines
+// which are not tagged with a cluster belong to the given cluster.
+func (r *Request) ShowMachines(clusteraddress, clustername, machine string, opts MachineListOptions, of output.OutputFormater) error {
+	machinelist, err := r.GetMachines(clusteraddress, machine)
+	if err != nil {
+		return err
+	}
+	machinelist = FilterMachinesByLoad(machinelist, opts.MaxLoad)
+	if opts.ByCluster {
+		TagMachinesWithCluster(machinelist, clustername)
+		of.PrintMachineSummaries(types.SummarizeMachinesByCluster(machinelist))
+		return nil
+	}
+	if err := SortMachines(machinelist, opts.SortBy); err != nil {
+		return err
+	}
+	for index := range machinelist {
+		of.PrintMachine(machinelist[index])
+	}
+	return nil
 }
 
 func createRequestMachinesQueues(clusteraddress, req, filter string) string {
@@ -278,77 +782,107 @@ func createRequestMachinesQueues(clusteraddress, req, filter string) string {
 }
 
 func (r *Request) GetQueues(clusteraddress, filter string) ([]types.Queue, error) {
-	resp, err := http_helper.UberGet(r.client, *otp, createRequestMachinesQueues(clusteraddress, "queues", filter))
+	resp, err := r.get(createRequestMachinesQueues(clusteraddress, "queues", filter))
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return nil, err
 	}
 	defer resp.Body.Close()
+	if err := responseError(resp); err != nil {
+		return nil, err
+	}
 
 	decoder := json.NewDecoder(resp.Body)
 	var queuelist []types.Queue
 	if err := decoder.Decode(&queuelist); err != nil {
-		fmt.Println("Error during decoding: ", err)
-		return nil, err
+		return nil, fmt.Errorf("error during decoding queues: %s", err)
 	}
 	return queuelist, nil
 }
 
 func (r *Request) GetMachines(clusteraddress, filter string) ([]types.Machine, error) {
-	resp, err := http_helper.UberGet(r.client, *otp, createRequestMachinesQueues(clusteraddress, "machines", filter))
+	resp, err := r.get(createRequestMachinesQueues(clusteraddress, "machines", filter))
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return nil, err
 	}
 	defer resp.Body.Close()
+	if err := responseError(resp); err != nil {
+		return nil, err
+	}
 
 	decoder := json.NewDecoder(resp.Body)
 	var machinelist []types.Machine
 	if err := decoder.Decode(&machinelist); err != nil {
-		fmt.Println("Error during decoding: ", err)
-		return nil, err
+		return nil, fmt.Errorf("error during decoding machines: %s", err)
 	}
 	return machinelist, nil
 }
 
-func (r *Request) ShowMachinesQueues(clusteraddress, req, filter string, of output.OutputFormater) {
+func (r *Request) ShowMachinesQueues(clusteraddress, req, filter string, of output.OutputFormater) error {
 	log.Println("showMachineQueues: ", clusteraddress, req, filter)
 	if req == "machines" {
-		if machinelist, err := r.GetMachines(clusteraddress, filter); err == nil {
-			for index := range machinelist {
-				//emulateQhost(machinelist[index])
-				of.PrintMachine(machinelist[index])
-			}
+		machinelist, err := r.GetMachines(clusteraddress, filter)
+		if err != nil {
+			return err
+		}
+		for index := range machinelist {
+			//emulateQhost(machinelist[index])
+			of.PrintMachine(machinelist[index])
 		}
 	} else if req == "queues" {
-		if queuelist, err := r.GetQueues(clusteraddress, filter); err == nil {
-			log.Println("Queuelist: ", queuelist)
-			for index := range queuelist {
-				fmt.Println(queuelist[index].Name)
-				// TODO
-			}
+		queuelist, err := r.GetQueues(clusteraddress, filter)
+		if err != nil {
+			return err
+		}
+		log.Println("Queuelist: ", queuelist)
+		for index := range queuelist {
+			of.PrintQueue(queuelist[index])
 		}
 	}
+	return nil
 }
 
 // PerformOperation sends request to perform an operation on a particular
 // job to a connected cluster (to its proxy).
 // The request url is: jsession/<jobsessionname>/<operation>/jobnumber
-func (r *Request) PerformOperation(clusteraddress, jsession, operation, jobId string) {
+func (r *Request) PerformOperation(clusteraddress, jsession, operation, jobId string) error {
+	answer, err := r.JobOperation(clusteraddress, jsession, operation, jobId)
+	if err != nil {
+		return fmt.Errorf("%s of job %s failed: %s", operation, jobId, err)
+	}
+	fmt.Println(answer)
+	return nil
+}
+
+// JobOperation sends the operation request for a job to the proxy
+// and returns the answer of the proxy.
+func (r *Request) JobOperation(clusteraddress, jsession, operation, jobId string) (string, error) {
 	url := fmt.Sprintf("%s/jsession/%s/%s/%s", clusteraddress, jsession, operation, jobId)
 	log.Println("Requesting:" + url)
-	buffer := bytes.NewBuffer([]byte(""))
-	if resp, err := http_helper.UberPost(r.client, *otp, url, "application/json", buffer); err != nil {
-		fmt.Println("Error during post: ", err)
-	} else {
-		log.Println("Status of request:", resp.Status)
-		defer resp.Body.Close()
-		body, _ := ioutil.ReadAll(resp.Body)
-		fmt.Println(string(body))
+	resp, err := r.post(url, "application/json", []byte(""), true)
+	if err != nil {
+		return "", err
 	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("job operation %s failed (%s): %w", operation, resp.Status, proxy.ErrJobNotFound)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("job operation %s failed (%s): %s", operation, resp.Status, strings.TrimSpace(string(body)))
+	}
+	var answer string
+	if err := json.Unmarshal(body, &answer); err != nil {
+		// the proxy encodes errors as JSON objects
+		return "", fmt.Errorf("job operation %s failed: %s", operation, strings.TrimSpace(string(body)))
+	}
+	return answer, nil
 }
 
-func (r *Request) GetJobCategories(clusteraddress, jsession, category string) []string {
+func (r *Request) GetJobCategories(clusteraddress, jsession, category string) ([]string, error) {
 	var url string
 	if category == "all" || category == "" {
 		url = fmt.Sprintf("%s/jsession/%s/jobcategories", clusteraddress, jsession)
@@ -356,72 +890,107 @@ func (r *Request) GetJobCategories(clusteraddress, jsession, category string) []
 		url = fmt.Sprintf("%s/jsession/%s/jobcategory/%s", clusteraddress, jsession, category)
 	}
 	log.Println("Requesting:" + url)
-	if resp, err := http_helper.UberGet(r.client, *otp, url); err != nil {
-		log.Fatal(err)
-		os.Exit(1)
-	} else {
-		defer resp.Body.Close()
-		if category == "all" || category == "" {
-			var catList []string
-			json.NewDecoder(resp.Body).Decode(&catList)
-			return catList
-		} else {
-			var cat string
-			json.NewDecoder(resp.Body).Decode(&cat)
-			return []string{cat}
+	resp, err := r.get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := responseError(resp); err != nil {
+		return nil, err
+	}
+	if category == "all" || category == "" {
+		var catList []string
+		if err := json.NewDecoder(resp.Body).Decode(&catList); err != nil && err != io.EOF {
+			return nil, err
 		}
+		return catList, nil
 	}
-	return nil
+	var cat string
+	if err := json.NewDecoder(resp.Body).Decode(&cat); err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("job category %s does not exist", category)
+		}
+		return nil, err
+	}
+	return []string{cat}, nil
+}
+
+// ShowJobOutput copies the output of a job to w. When follow is set
+// the proxy keeps sending new output until the job is finished.
+func (r *Request) ShowJobOutput(clusteraddress, jobid string, follow bool, w io.Writer) error {
+	url := fmt.Sprintf("%s/jsession/default/job/%s/output?follow=%t", clusteraddress, jobid, follow)
+	log.Println("Requesting:" + url)
+	var resp *http.Response
+	var err error
+	if follow {
+		// following the output lasts as long as the job runs
+		client := *r.client
+		client.Timeout = 0
+		resp, err = http_helper.UberGetWithContext(r.context(), &client, *r.otp, url)
+	} else {
+		resp, err = r.get(url)
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := responseError(resp); err != nil {
+		return err
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
 }
 
-func (r *Request) ShowJobCategories(clusteraddress, jsession, category string) {
-	for _, cat := range r.GetJobCategories(clusteraddress, jsession, category) {
-		fmt.Println(cat)
+// ShowJobCategories requests the job categories of the given cluster
+// and prints them out in the selected output format.
+func (r *Request) ShowJobCategories(clusteraddress, jsession, category string, of output.OutputFormater) error {
+	categories, err := r.GetJobCategories(clusteraddress, jsession, category)
+	if err != nil {
+		return err
 	}
+	of.PrintJobCategories(categories)
+	return nil
 }
 
-func (r *Request) GetJobSessions(clusteraddress, jsession string) []string {
+func (r *Request) GetJobSessions(clusteraddress, jsession string) ([]string, error) {
 	url := fmt.Sprintf("%s/jsessions", clusteraddress)
 	log.Println("Requesting:" + url)
-	if resp, err := http_helper.UberGet(r.client, *otp, url); err != nil {
-		log.Fatal(err)
-		os.Exit(1)
-	} else {
-		defer resp.Body.Close()
-		var jsList []string
-		json.NewDecoder(resp.Body).Decode(&jsList)
-		found := false
-		if jsession != "all" {
-			for _, js := range jsList {
-				if js == jsession {
-					found = true
-				}
-			}
-			if found == true {
-				return []string{jsession}
-			} else {
-				return []string{}
+	resp, err := r.get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := responseError(resp); err != nil {
+		return nil, err
+	}
+	var jsList []string
+	if err := json.NewDecoder(resp.Body).Decode(&jsList); err != nil && err != io.EOF {
+		return nil, err
+	}
+	if jsession != "all" {
+		for _, js := range jsList {
+			if js == jsession {
+				return []string{jsession}, nil
 			}
 		}
-		return jsList
+		return []string{}, nil
 	}
-	return nil
+	return jsList, nil
 }
 
 // ShowJobSessions requests all job sessions available on the
-// given cluster and prints them out to the user.
-func (r *Request) ShowJobSessions(clusteraddress, jsession string) {
-	jSessions := r.GetJobSessions(clusteraddress, jsession)
-	if len(jSessions) >= 1 {
-		for _, js := range jSessions {
-			fmt.Println(js)
-		}
-	} else {
+// given cluster and prints them out in the selected output format.
+func (r *Request) ShowJobSessions(clusteraddress, jsession string, of output.OutputFormater) error {
+	jSessions, err := r.GetJobSessions(clusteraddress, jsession)
+	if err != nil {
+		return err
+	}
+	if len(jSessions) == 0 {
 		if jsession == "all" {
-			fmt.Println("No job session found.")
-			os.Exit(1)
+			return errors.New("No job session found.")
 		}
-		fmt.Printf("Job session %s does not exist.\n", jsession)
-		os.Exit(1)
+		return fmt.Errorf("Job session %s does not exist.", jsession)
 	}
+	of.PrintJobSessions(jSessions)
+	return nil
 }
@@ -0,0 +1,508 @@
+package main_test
+
+import (
+	. "github.com/dgruber/ubercluster/cmd/uc"
+	"github.com/dgruber/ubercluster/pkg/output"
+	"github.com/dgruber/ubercluster/pkg/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var _ = Describe("Requests", func() {
+
+	var otp string
+
+	formater := func(format string) output.OutputFormater {
+		of, err := output.MakeOutputFormater(format)
+		Ω(err).Should(BeNil())
+		return of
+	}
+
+	It("should reject unknown output formats", func() {
+		_, err := output.MakeOutputFormater("jsn")
+		Ω(err).ShouldNot(BeNil())
+		Ω(err.Error()).Should(Equal("unknown format: jsn (supported: default, json, xml, wide)"))
+	})
+
+	Context("when the proxy answers", func() {
+
+		It("should return the decoded job list", func() {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`[{"id":"1"},{"id":"2"}]`))
+			}))
+			defer ts.Close()
+
+			jobs, err := NewRequest("", "", &otp).GetJobs(ts.URL, "all", "")
+			Ω(err).Should(BeNil())
+			Ω(jobs).Should(HaveLen(2))
+			Ω(jobs[0].Id).Should(Equal("1"))
+		})
+
+		It("should print the job list in the wide format", func() {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`[{"id":"1","state":4},{"id":"2","state":2}]`))
+			}))
+			defer ts.Close()
+
+			of := formater("wide")
+			Ω(output.IsTableFormat(of)).Should(BeTrue())
+			Ω(output.IsTableFormat(formater("default"))).Should(BeFalse())
+			Ω(NewRequest("", "", &otp).ShowJobs(ts.URL, "all", "", SubmissionWindow{}, of)).Should(BeNil())
+		})
+
+		It("should request the job list page by page", func() {
+			calls := 0
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				limit, _ := strconv.Atoi(r.FormValue("limit"))
+				offset, _ := strconv.Atoi(r.FormValue("offset"))
+				Ω(r.FormValue("state")).Should(Equal("r"))
+				jobs := make([]types.JobInfo, 0, limit)
+				for i := offset; i < offset+limit && i < 2500; i++ {
+					jobs = append(jobs, types.JobInfo{Id: strconv.Itoa(i)})
+				}
+				json.NewEncoder(w).Encode(jobs)
+			}))
+			defer ts.Close()
+
+			jobs, err := NewRequest("", "", &otp).GetJobs(ts.URL, "r", "")
+			Ω(err).Should(BeNil())
+			Ω(jobs).Should(HaveLen(2500))
+			Ω(jobs[2499].Id).Should(Equal("2499"))
+			Ω(calls).Should(Equal(3))
+		})
+
+		It("should stop paging when the proxy ignores the offset", func() {
+			calls := 0
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				jobs := make([]types.JobInfo, 0, 1000)
+				for i := 0; i < 1000; i++ {
+					jobs = append(jobs, types.JobInfo{Id: strconv.Itoa(i)})
+				}
+				json.NewEncoder(w).Encode(jobs)
+			}))
+			defer ts.Close()
+
+			jobs, err := NewRequest("", "", &otp).GetJobs(ts.URL, "all", "")
+			Ω(err).Should(BeNil())
+			Ω(jobs).Should(HaveLen(1000))
+			Ω(calls).Should(Equal(2))
+		})
+
+		It("should return an empty job list when the proxy has no jobs", func() {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			defer ts.Close()
+
+			jobs, err := NewRequest("", "", &otp).GetJobs(ts.URL, "all", "")
+			Ω(err).Should(BeNil())
+			Ω(jobs).Should(BeEmpty())
+		})
+
+		It("should count the jobs with the total count of the proxy", func() {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Ω(r.FormValue("limit")).Should(Equal("1"))
+				Ω(r.FormValue("state")).Should(Equal("r"))
+				w.Header().Set("X-Total-Count", "4711")
+				w.Write([]byte(`[{"id":"1"}]`))
+			}))
+			defer ts.Close()
+
+			count, err := NewRequest("", "", &otp).CountJobs(ts.URL, "r", "", SubmissionWindow{})
+			Ω(err).Should(BeNil())
+			Ω(count).Should(Equal(4711))
+		})
+
+		It("should count the returned jobs when the proxy sends no total count", func() {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`[{"id":"1"},{"id":"2"},{"id":"3"}]`))
+			}))
+			defer ts.Close()
+
+			count, err := NewRequest("", "", &otp).CountJobs(ts.URL, "all", "", SubmissionWindow{})
+			Ω(err).Should(BeNil())
+			Ω(count).Should(Equal(3))
+		})
+
+		It("should request and filter the jobs of a submission window", func() {
+			now := time.Now()
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Ω(r.FormValue("since")).ShouldNot(BeEmpty())
+				Ω(r.FormValue("until")).ShouldNot(BeEmpty())
+				// a proxy which ignores the window
+				json.NewEncoder(w).Encode([]types.JobInfo{
+					{Id: "1", SubmissionTime: now.Add(-3 * time.Hour)},
+					{Id: "2", SubmissionTime: now.Add(-90 * time.Minute)},
+					{Id: "3", SubmissionTime: now.Add(-10 * time.Minute)},
+				})
+			}))
+			defer ts.Close()
+
+			window, err := ParseSubmissionWindow("2h", "30m")
+			Ω(err).Should(BeNil())
+			count, err := NewRequest("", "", &otp).CountJobs(ts.URL, "all", "", window)
+			Ω(err).Should(BeNil())
+			Ω(count).Should(Equal(1))
+		})
+
+		It("should reject invalid submission windows", func() {
+			_, err := ParseSubmissionWindow("yesterday", "")
+			Ω(err).ShouldNot(BeNil())
+			_, err = ParseSubmissionWindow("1h", "2h")
+			Ω(err).ShouldNot(BeNil())
+			window, err := ParseSubmissionWindow("", "")
+			Ω(err).Should(BeNil())
+			Ω(window.IsZero()).Should(BeTrue())
+		})
+
+		It("should submit an array job to the bulk run endpoint", func() {
+			var path string
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path = r.URL.Path
+				w.Write([]byte(`{"jobid":"7"}`))
+			}))
+			defer ts.Close()
+
+			r := NewRequest("", "", &otp)
+			ajr := types.ArrayJobRequest{
+				JobTemplate: r.CreateJobTemplate("", "sleep", "1", "", ""),
+				Begin:       1,
+				End:         10,
+				Step:        2,
+			}
+			jobid, err := r.SubmitArrayJobRequest(ts.URL, ajr)
+			Ω(err).Should(BeNil())
+			Ω(jobid).Should(Equal("7"))
+			Ω(path).Should(Equal("/jsession/default/runbulk"))
+		})
+
+		It("should return the tasks of an array job", func() {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`[{"id":"7.1"},{"id":"7.3"},{"id":"71"},{"id":"8.1"}]`))
+			}))
+			defer ts.Close()
+
+			tasks, err := NewRequest("", "", &otp).GetArrayJobTasks(ts.URL, "7")
+			Ω(err).Should(BeNil())
+			Ω(tasks).Should(HaveLen(2))
+			Ω(tasks[1].Id).Should(Equal("7.3"))
+		})
+
+		It("should print the job sessions and categories in the selected format", func() {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`["ubercluster","other"]`))
+			}))
+			defer ts.Close()
+
+			r := NewRequest("", "", &otp)
+			Ω(r.ShowJobSessions(ts.URL, "all", formater("json"))).Should(BeNil())
+			Ω(r.ShowJobSessions(ts.URL, "unknown", formater("json"))).ShouldNot(BeNil())
+			Ω(r.ShowJobCategories(ts.URL, "ubercluster", "all", formater("xml"))).Should(BeNil())
+		})
+
+		It("should print a summary of the machines of each cluster", func() {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`[{"name":"host1","sockets":1,"coresPerSocket":4,"cluster":"c1"},
+					{"name":"host2","sockets":1,"coresPerSocket":4}]`))
+			}))
+			defer ts.Close()
+
+			r := NewRequest("", "", &otp)
+			opts := MachineListOptions{MaxLoad: -1, ByCluster: true}
+			Ω(r.ShowMachines(ts.URL, "default", "all", opts, formater("json"))).Should(BeNil())
+			Ω(r.ShowMachines(ts.URL, "default", "all", opts, formater("default"))).Should(BeNil())
+		})
+
+		It("should decode the slots and state of the queues", func() {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`[{"name":"all.q","state":"enabled","usedSlots":2,"totalSlots":8}]`))
+			}))
+			defer ts.Close()
+
+			r := NewRequest("", "", &otp)
+			queues, err := r.GetQueues(ts.URL, "all")
+			Ω(err).Should(BeNil())
+			Ω(queues).Should(HaveLen(1))
+			Ω(queues[0].State).Should(Equal("enabled"))
+			Ω(queues[0].Utilization()).Should(Equal(0.25))
+			Ω(r.ShowQueues(ts.URL, "all", formater("default"))).Should(BeNil())
+		})
+
+		It("should copy the output of a job", func() {
+			var query string
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				query = r.URL.Path + "?" + r.URL.RawQuery
+				w.Write([]byte("hello\n"))
+			}))
+			defer ts.Close()
+
+			var out bytes.Buffer
+			err := NewRequest("", "", &otp).ShowJobOutput(ts.URL, "3", true, &out)
+			Ω(err).Should(BeNil())
+			Ω(out.String()).Should(Equal("hello\n"))
+			Ω(query).Should(Equal("/jsession/default/job/3/output?follow=true"))
+		})
+
+		It("should print the job template without submitting it in a dry run", func() {
+			calls := 0
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+			}))
+			defer ts.Close()
+
+			r := NewRequest("", "", &otp)
+			jt := r.CreateJobTemplate("name", "sleep", "1", "all.q", "")
+			jt.JobEnvironment = map[string]string{"KEY": "value"}
+			for _, format := range []string{"default", "json", "xml"} {
+				Ω(r.DryRunJob(ts.URL, "c1", jt, "", formater(format))).Should(BeNil())
+			}
+			Ω(r.DryRunJob(ts.URL, "c1", jt, "1:10", formater("json"))).Should(BeNil())
+			Ω(r.DryRunJob(ts.URL, "c1", jt, "10:1", formater("json"))).ShouldNot(BeNil())
+			Ω(calls).Should(Equal(0))
+		})
+
+		It("should print all details of a job", func() {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Ω(r.URL.Path).Should(Equal("/msession/jobinfo/1"))
+				w.Write([]byte(`{"id":"1","state":8,"allocatedMachines":["host1","host2"],"exitStatus":0}`))
+			}))
+			defer ts.Close()
+
+			r := NewRequest("", "", &otp)
+			Ω(r.ShowJobDetails(ts.URL, "1", true, formater("default"))).Should(BeNil())
+			Ω(r.ShowJobDetails(ts.URL, "1", true, formater("json"))).Should(BeNil())
+		})
+
+		It("should watch a job until it is finished", func() {
+			calls := 0
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if calls < 2 {
+					w.Write([]byte(`{"id":"1","state":4}`))
+					return
+				}
+				w.Write([]byte(`{"id":"1","state":8}`))
+			}))
+			defer ts.Close()
+
+			err := NewRequest("", "", &otp).WatchJobDetails(ts.URL, "1", time.Millisecond, formater("json"))
+			Ω(err).Should(BeNil())
+			Ω(calls).Should(Equal(2))
+		})
+
+		It("should reject a watch interval which is not positive", func() {
+			calls := 0
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				w.Write([]byte(`{"id":"1","state":4}`))
+			}))
+			defer ts.Close()
+
+			r := NewRequest("", "", &otp)
+			Ω(r.WatchJobDetails(ts.URL, "1", 0, formater("json"))).ShouldNot(BeNil())
+			Ω(r.WatchJobDetails(ts.URL, "1", -time.Second, formater("json"))).ShouldNot(BeNil())
+			Ω(calls).Should(Equal(0))
+		})
+
+	})
+
+	Context("when the proxy is temporarily not available", func() {
+
+		It("should retry GET requests on server errors", func() {
+			calls := 0
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if calls < 3 {
+					http.Error(w, "try later", http.StatusServiceUnavailable)
+					return
+				}
+				w.Write([]byte(`[{"id":"1"}]`))
+			}))
+			defer ts.Close()
+
+			r := NewRequest("", "", &otp)
+			r.SetRetries(2, time.Millisecond)
+			jobs, err := r.GetJobs(ts.URL, "all", "")
+			Ω(err).Should(BeNil())
+			Ω(jobs).Should(HaveLen(1))
+			Ω(calls).Should(Equal(3))
+		})
+
+		It("should retry all GET requests with the OTP of the request", func() {
+			calls := 0
+			var otps []string
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				otps = append(otps, r.FormValue("otp"))
+				if calls%2 == 1 {
+					http.Error(w, "try later", http.StatusServiceUnavailable)
+					return
+				}
+				if strings.Contains(r.URL.Path, "jobinfo/") {
+					w.Write([]byte(`{"id":"1"}`))
+					return
+				}
+				w.Write([]byte(`[]`))
+			}))
+			defer ts.Close()
+
+			requestOTP := "secret"
+			r := NewRequest("", "", &requestOTP)
+			r.SetRetries(1, time.Millisecond)
+			_, err := r.GetJob(ts.URL, "1")
+			Ω(err).Should(BeNil())
+			_, err = r.GetQueues(ts.URL, "all")
+			Ω(err).Should(BeNil())
+			_, err = r.GetMachines(ts.URL, "all")
+			Ω(err).Should(BeNil())
+			_, err = r.GetJobCategories(ts.URL, "ubercluster", "all")
+			Ω(err).Should(BeNil())
+			_, err = r.GetJobSessions(ts.URL, "ubercluster")
+			Ω(err).Should(BeNil())
+			Ω(calls).Should(Equal(10))
+			for _, o := range otps {
+				Ω(o).Should(Equal("secret"))
+			}
+		})
+
+		It("should abort requests which exceed the timeout", func() {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(200 * time.Millisecond)
+				w.Write([]byte(`[{"id":"1"}]`))
+			}))
+			defer ts.Close()
+
+			r := NewRequest("", "", &otp)
+			r.SetTimeout(50 * time.Millisecond)
+			_, err := r.GetJobs(ts.URL, "all", "")
+			Ω(err).ShouldNot(BeNil())
+			r.SetTimeout(0)
+			_, err = r.GetJobs(ts.URL, "all", "")
+			Ω(err).Should(BeNil())
+		})
+
+		It("should give up after the configured amount of retries", func() {
+			calls := 0
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				http.Error(w, "try later", http.StatusServiceUnavailable)
+			}))
+			defer ts.Close()
+
+			r := NewRequest("", "", &otp)
+			r.SetRetries(1, time.Millisecond)
+			_, err := r.GetJobs(ts.URL, "all", "")
+			Ω(err).ShouldNot(BeNil())
+			Ω(calls).Should(Equal(2))
+		})
+
+		It("should not repeat a job submission the proxy may have processed", func() {
+			calls := 0
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				http.Error(w, "internal error", http.StatusInternalServerError)
+			}))
+			defer ts.Close()
+
+			r := NewRequest("", "", &otp)
+			r.SetRetries(2, time.Millisecond)
+			_, err := r.SubmitJobTemplate(ts.URL, r.CreateJobTemplate("", "sleep", "1", "", ""))
+			Ω(err).ShouldNot(BeNil())
+			Ω(calls).Should(Equal(1))
+		})
+
+	})
+
+	Context("error cases", func() {
+
+		It("should return an error for an unknown cluster selection algorithm", func() {
+			r := NewRequest("", "", &otp)
+			_, _, err := r.SelectClusterAddress("default", "unknown", "", "")
+			Ω(err).ShouldNot(BeNil())
+		})
+
+		It("should reject invalid array job ranges", func() {
+			begin, end, step, err := ParseArrayRange("1:10")
+			Ω(err).Should(BeNil())
+			Ω([]int{begin, end, step}).Should(Equal([]int{1, 10, 1}))
+			_, _, step, err = ParseArrayRange("2:8:3")
+			Ω(err).Should(BeNil())
+			Ω(step).Should(Equal(3))
+			_, _, _, err = ParseArrayRange("10")
+			Ω(err).ShouldNot(BeNil())
+			_, _, _, err = ParseArrayRange("10:1")
+			Ω(err).ShouldNot(BeNil())
+			_, _, _, err = ParseArrayRange("1:a:1")
+			Ω(err).ShouldNot(BeNil())
+			_, _, _, err = ParseArrayRange("1:10:0")
+			Ω(err).ShouldNot(BeNil())
+		})
+
+		It("should return an error when the proxy fails", func() {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "authorization failed", http.StatusUnauthorized)
+			}))
+			defer ts.Close()
+
+			r := NewRequest("", "", &otp)
+			_, err := r.GetJobs(ts.URL, "all", "")
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Error()).Should(ContainSubstring("authorization failed"))
+			_, err = r.GetMachines(ts.URL, "all")
+			Ω(err).ShouldNot(BeNil())
+			_, err = r.GetJobCategories(ts.URL, "ubercluster", "all")
+			Ω(err).ShouldNot(BeNil())
+		})
+
+		It("should return the proxy error when a submission or operation fails", func() {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "queue does not exist", http.StatusBadRequest)
+			}))
+			defer ts.Close()
+
+			r := NewRequest("", "", &otp)
+			_, err := r.SubmitJobTemplate(ts.URL, r.CreateJobTemplate("", "sleep", "1", "nq", ""))
+			Ω(err).ShouldNot(BeNil())
+			pe, isProxyError := err.(*ProxyError)
+			Ω(isProxyError).Should(BeTrue())
+			Ω(pe.StatusCode).Should(Equal(http.StatusBadRequest))
+			Ω(pe.Message).Should(Equal("queue does not exist"))
+
+			err = r.SubmitJob(ts.URL, "c1", r.CreateJobTemplate("", "sleep", "1", "nq", ""), false)
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Error()).Should(ContainSubstring("queue does not exist"))
+
+			err = r.PerformOperation(ts.URL, "ubercluster", "terminate", "1")
+			Ω(err).ShouldNot(BeNil())
+		})
+
+		It("should reject an invalid job state filter", func() {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`[{"id":"1"}]`))
+			}))
+			defer ts.Close()
+
+			_, err := NewRequest("", "", &otp).GetJobs(ts.URL, "xyz", "")
+			Ω(err).ShouldNot(BeNil())
+		})
+
+		It("should return an error when the proxy is not reachable", func() {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			ts.Close()
+
+			_, err := NewRequest("", "", &otp).GetJob(ts.URL, "1")
+			Ω(err).ShouldNot(BeNil())
+		})
+
+	})
+
+})
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResolveJobCluster(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}))
+	defer ts.Close()
+
+	saved := config
+	defer func() { config = saved }()
+	config = makeTestConfig(2)
+	for i := range config.Cluster {
+		config.Cluster[i].Address = ts.URL
+	}
+
+	otp := ""
+	r := NewRequest("", "", &otp)
+
+	jobid, address, name, err := r.ResolveJobCluster("1@cluster1", "default", "default", "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jobid != "1" || address != ts.URL+"/v1" || name != "cluster1" {
+		t.Errorf("Expected 1 at cluster1 (%s/v1) but got %s at %s (%s)", ts.URL, jobid, name, address)
+	}
+
+	tests := []struct {
+		jobid   string
+		cluster string
+	}{
+		{"1@cluster1", "cluster0"},
+		{"1", "default"},
+		{"1@unknown", "default"},
+		{"@cluster1", "default"},
+	}
+	for _, test := range tests {
+		jobid, address, name, err := r.ResolveJobCluster(test.jobid, test.cluster, "addr", test.cluster)
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %s", test.jobid, err)
+		}
+		if jobid != test.jobid || address != "addr" || name != test.cluster {
+			t.Errorf("Expected %s to be unchanged but got %s at %s (%s)", test.jobid, jobid, name, address)
+		}
+	}
+}
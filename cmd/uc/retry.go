@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"github.com/dgruber/ubercluster/pkg/http_helper"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// retryPolicy defines how often a failed request to a proxy is
+// repeated. Like the DRMAA2 TryLater error it covers situations
+// where the proxy is only temporarily not able to serve a request.
+type retryPolicy struct {
+	attempts int           // amount of retries after the first request
+	delay    time.Duration // wait time before the first retry, doubled for each further retry
+}
+
+// SetRetries configures how often and with which initial delay
+// requests are repeated when the proxy is temporarily not available.
+func (r *Request) SetRetries(attempts int, delay time.Duration) {
+	r.retry = retryPolicy{
+		attempts: attempts,
+		delay:    delay,
+	}
+}
+
+// isDialError returns true when the connection to the proxy could
+// not be established, i.e. the request was never sent.
+func isDialError(err error) bool {
+	if uerr, ok := err.(*url.Error); ok {
+		err = uerr.Err
+	}
+	if oerr, ok := err.(*net.OpError); ok {
+		return oerr.Op == "dial"
+	}
+	return false
+}
+
+// isTransientFailure decides if an idempotent request can be repeated.
+func isTransientFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode >= http.StatusInternalServerError
+}
+
+// isUnprocessedFailure decides if a non-idempotent request can be
+// repeated. This is only the case when the proxy did not process it.
+func isUnprocessedFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		return isDialError(err)
+	}
+	return resp.StatusCode == http.StatusServiceUnavailable
+}
+
+// withRetry performs the request and repeats it with exponential
+// backoff as long as the failure is retryable and the configured
+// amount of retries is not exceeded.
+func (r *Request) withRetry(do func() (*http.Response, error), retryable func(*http.Response, error) bool) (*http.Response, error) {
+	delay := r.retry.delay
+	for attempt := 1; ; attempt++ {
+		resp, err := do()
+		if attempt > r.retry.attempts || !retryable(resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			log.Println("Request failed with status: ", resp.Status)
+			resp.Body.Close()
+		} else {
+			log.Println("Request failed: ", err)
+		}
+		log.Printf("Retrying request in %s (%d of %d)\n", delay, attempt, r.retry.attempts)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// get sends a GET request to the proxy which is repeated on
+// connection errors and server errors.
+func (r *Request) get(request string) (*http.Response, error) {
+	return r.withRetry(func() (*http.Response, error) {
+		return http_helper.UberGetWithContext(r.context(), r.client, *r.otp, request)
+	}, isTransientFailure)
+}
+
+// post sends a POST request to the proxy. Idempotent requests are
+// repeated like GET requests, all others only when the proxy did
+// not process them.
+func (r *Request) post(request, bodyType string, body []byte, idempotent bool) (*http.Response, error) {
+	retryable := isUnprocessedFailure
+	if idempotent {
+		retryable = isTransientFailure
+	}
+	return r.withRetry(func() (*http.Response, error) {
+		return http_helper.UberPostWithContext(r.context(), r.client, *r.otp, request, bodyType, bytes.NewReader(body))
+	}, retryable)
+}
@@ -17,24 +17,55 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/dgruber/ubercluster/pkg/http_helper"
+	"github.com/dgruber/ubercluster/pkg/types"
+	"hash/fnv"
 	"log"
 	"math"
 	"math/rand"
-	"net/http"
+	"net/url"
+	"strings"
 	"sync"
 	"time"
 )
 
-// just seed random number generator one time
-var seeded bool = false
+// randSource is the source of random numbers of the cluster selection.
+// It is satisfied by *rand.Rand and by the lockedRand of the schedulers.
+type randSource interface {
+	Int63n(n int64) int64
+	Intn(n int) int
+}
+
+// lockedRand serializes the access to a random number generator since
+// a rand.Rand must not be used by concurrent cluster selections (like
+// in inception mode).
+type lockedRand struct {
+	sync.Mutex
+	rnd *rand.Rand
+}
+
+func (lr *lockedRand) Int63n(n int64) int64 {
+	lr.Lock()
+	defer lr.Unlock()
+	return lr.rnd.Int63n(n)
+}
+
+func (lr *lockedRand) Intn(n int) int {
+	lr.Lock()
+	defer lr.Unlock()
+	return lr.rnd.Intn(n)
+}
 
 // Scheduler is an interface all scheduler needs to
 // implement.
 type Scheduler interface {
 	SelectCluster() string
+	// SelectClusterWithReason returns the name of the selected
+	// cluster and a human readable reason for the selection.
+	SelectClusterWithReason() (string, string)
 }
 
 type SchedulerType int
@@ -43,6 +74,9 @@ const (
 	ProbabilisticSchedulerType SchedulerType = iota
 	RandomSchedulerType
 	LoadBasedSchedulerType
+	WeightedSchedulerType
+	SlotBasedSchedulerType
+	AffinitySchedulerType
 )
 
 type SchedulerImpl struct {
@@ -50,38 +84,84 @@ type SchedulerImpl struct {
 }
 
 // MakeNewScheduler create a new scheduler implementation based
-// on the SchedulerType and the cluster Config.
-func MakeNewScheduler(st SchedulerType, config Config, client *http.Client) *SchedulerImpl {
-	if seeded == false {
-		rand.Seed(time.Now().UTC().UnixNano())
-		seeded = true
-	}
+// on the SchedulerType and the cluster Config. The load and the
+// slots of the clusters are requested through r.
+func MakeNewScheduler(st SchedulerType, config Config, r *Request) *SchedulerImpl {
+	return MakeNewSchedulerWithRand(st, config, r, rand.New(rand.NewSource(time.Now().UTC().UnixNano())))
+}
+
+// MakeNewSchedulerWithRand creates a new scheduler like MakeNewScheduler
+// which uses the given random number generator for its selections, so
+// that the selections are reproducible for a fixed seed. The generator
+// must not be used elsewhere.
+func MakeNewSchedulerWithRand(st SchedulerType, config Config, r *Request, rnd *rand.Rand) *SchedulerImpl {
+	lr := &lockedRand{rnd: rnd}
 	var s SchedulerImpl
 	switch st {
 	case ProbabilisticSchedulerType:
 		s.Impl = &ProbSched{
-			conf:   config,
-			client: client,
+			conf:    config,
+			request: r,
+			rnd:     lr,
 		}
 	case RandomSchedulerType:
 		s.Impl = &RandomSched{
-			conf:   config,
-			client: client,
+			conf:    config,
+			request: r,
+			rnd:     lr,
 		}
 	case LoadBasedSchedulerType:
 		s.Impl = &LoadBasedSched{
-			conf:   config,
-			client: client,
+			conf:    config,
+			request: r,
+		}
+	case WeightedSchedulerType:
+		s.Impl = &WeightedSched{
+			conf:    config,
+			request: r,
+			rnd:     lr,
+		}
+	case SlotBasedSchedulerType:
+		s.Impl = &SlotBasedSched{
+			conf:    config,
+			request: r,
+		}
+	case AffinitySchedulerType:
+		s.Impl = &AffinitySched{
+			conf: config,
+			key:  currentUser(),
 		}
 	}
 	return &s
 }
 
+// MakeSchedulerByAlg creates a scheduler for the selection algorithm
+// given on command line ("rand", "prob", "load", "weighted", "slots",
+// "affinity").
+func MakeSchedulerByAlg(alg string, config Config, r *Request) (*SchedulerImpl, error) {
+	switch alg {
+	case "rand": // random scheduling
+		return MakeNewScheduler(RandomSchedulerType, config, r), nil
+	case "prob": // probabilistic scheduling
+		return MakeNewScheduler(ProbabilisticSchedulerType, config, r), nil
+	case "load": // load based scheduling
+		return MakeNewScheduler(LoadBasedSchedulerType, config, r), nil
+	case "weighted": // probabilistic scheduling biased by cluster weights
+		return MakeNewScheduler(WeightedSchedulerType, config, r), nil
+	case "slots": // lowest load of the clusters with free slots in the queue
+		return MakeNewScheduler(SlotBasedSchedulerType, config, r), nil
+	case "affinity": // same cluster for the same user or affinity key
+		return MakeNewScheduler(AffinitySchedulerType, config, r), nil
+	}
+	return nil, fmt.Errorf("Unkown scheduler selection algorithm: %s", alg)
+}
+
 // Implements the cluster selection algorithms.
 
 type ProbSched struct {
-	conf   Config
-	client *http.Client
+	conf    Config
+	request *Request
+	rnd     randSource
 }
 
 // probabilisticScheduler returns the name of the selected
@@ -92,18 +172,73 @@ type ProbSched struct {
 // If all clusters have the same load all of them have the
 // same probability to be chosen.
 func (ps *ProbSched) SelectCluster() string {
+	name, _ := ps.SelectClusterWithReason()
+	return name
+}
+
+// SelectClusterWithReason selects a cluster like SelectCluster and
+// reports the load values and the resulting probabilities.
+func (ps *ProbSched) SelectClusterWithReason() (string, string) {
 	// get load of each cluster
-	selection := probabilisticSelection(getAllLoadValues(ps.conf, ps.client))
+	loads := getAllLoadValues(ps.conf, ps.request)
+	selection := probabilisticSelection(ps.rnd, loads, nil)
 	if selection >= 0 {
 		log.Printf("Selected cluster %s due to probabilistic selection.\n",
 			ps.conf.Cluster[selection].Name)
-		return ps.conf.Cluster[selection].Name
+		return ps.conf.Cluster[selection].Name, fmt.Sprintf("probabilistic selection with loads %s and probabilities %s",
+			formatLoads(ps.conf, loads), formatProbabilities(ps.conf, loads, nil))
 	}
 	log.Println("No cluster selected, using default cluster.")
-	return "default"
+	return "default", fmt.Sprintf("no cluster has a load lower than 1 (loads %s), using default cluster",
+		formatLoads(ps.conf, loads))
+}
+
+// formatLoads returns the load values of all clusters as
+// human readable list.
+func formatLoads(conf Config, loads []float64) string {
+	values := make([]string, 0, len(loads))
+	for i, load := range loads {
+		values = append(values, fmt.Sprintf("%s=%.2f", conf.Cluster[i].Name, load))
+	}
+	return strings.Join(values, ", ")
+}
+
+// selectionWeight returns the relative likelihood of the cluster with
+// the given index to be chosen by the probabilistic selection. Without
+// weights all clusters have a weight of 1.
+func selectionWeight(loads, weights []float64, index int) float64 {
+	weight := 1.0
+	if weights != nil {
+		weight = math.Max(0, weights[index])
+	}
+	return weight * math.Max(0, 1.0-loads[index])
 }
 
-func probabilisticSelection(loads []float64) int {
+// formatProbabilities returns the probabilities of the clusters to
+// be selected by the probabilistic selection as human readable list.
+func formatProbabilities(conf Config, loads, weights []float64) string {
+	var sum float64
+	for i := range loads {
+		sum += selectionWeight(loads, weights, i)
+	}
+	values := make([]string, 0, len(loads))
+	for i := range loads {
+		var p float64
+		if sum > 0 {
+			p = selectionWeight(loads, weights, i) / sum
+		}
+		values = append(values, fmt.Sprintf("%s=%.0f%%", conf.Cluster[i].Name, p*100))
+	}
+	return strings.Join(values, ", ")
+}
+
+// probabilisticSelection returns the index of a randomly chosen
+// cluster where clusters with a lower load are more likely to be
+// chosen. The optional weights (one per cluster) multiply the
+// likelihood, clusters with weight 0 are never chosen. -1 is
+// returned when no cluster can be chosen. The random numbers are
+// taken from rnd.
+func probabilisticSelection(rnd randSource, loads []float64, weights []float64) int {
 	// invert the load to get a value which refledts the likelyhood
 	// multiply by a large value (since we are choosing int random
 	// numbers later on)
@@ -112,11 +247,12 @@ func probabilisticSelection(loads []float64) int {
 		return -1
 	}
 	likelyhood := make([]int64, len(loads), len(loads))
-	for k, v := range loads {
+	for k := range loads {
+		l := int64(selectionWeight(loads, weights, k) * 10000)
 		if k >= 1 {
-			likelyhood[k] = likelyhood[k-1] + int64(((1.0 - v) * 10000))
+			likelyhood[k] = likelyhood[k-1] + l
 		} else {
-			likelyhood[k] = int64((1.0 - v) * 10000)
+			likelyhood[k] = l
 		}
 	}
 	// if all cluster reports 1.0 -> chose default cluster 0
@@ -124,7 +260,7 @@ func probabilisticSelection(loads []float64) int {
 		return -1
 	}
 	// choose cluster depending on its likelyhood
-	selection := rand.Int63n(likelyhood[len(loads)-1] - 1)
+	selection := rnd.Int63n(likelyhood[len(loads)-1])
 	for k, v := range likelyhood {
 		if v > selection {
 			return k
@@ -139,28 +275,52 @@ type loadValues struct {
 	load []float64
 }
 
-func getClusterLoad(lv *loadValues, index int, request string, client *http.Client) {
-	if resp, err := http_helper.UberGet(client, *otp, request); err == nil {
-		defer resp.Body.Close()
-		decoder := json.NewDecoder(resp.Body)
-		var load float64
-		if err := decoder.Decode(&load); err != nil {
-			lv.load[index] = load
-		} else {
-			log.Println("Error during decoding cluster load from ", request, err)
-		}
+// loadRequestTimeout limits the time to wait for the load of a cluster
+// so that a single slow cluster can not block the cluster selection.
+var loadRequestTimeout = 5 * time.Second
+
+// versionedAddress returns the address of the proxy of the cluster
+// including the protocol version (like http://localhost:8888/v1).
+func versionedAddress(c ClusterConfig) string {
+	return fmt.Sprintf("%s/%s", strings.TrimSuffix(c.Address, "/"), strings.TrimPrefix(c.ProtocolVersion, "/"))
+}
+
+// requestClusterLoad requests the load of the cluster with the given
+// (versioned) address.
+func (r *Request) requestClusterLoad(clusteraddress string) (float64, error) {
+	ctx, cancel := context.WithTimeout(r.context(), loadRequestTimeout)
+	defer cancel()
+	resp, err := http_helper.UberGetWithContext(ctx, r.client, *r.otp, fmt.Sprintf("%s/msession/drmsload", clusteraddress))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if err := responseError(resp); err != nil {
+		return 0, err
+	}
+	var load float64
+	if err := json.NewDecoder(resp.Body).Decode(&load); err != nil {
+		return 0, err
+	}
+	return load, nil
+}
+
+func getClusterLoad(lv *loadValues, index int, cc ClusterConfig, r *Request) {
+	clusteraddress := r.requestAddress(cc)
+	if load, err := r.requestClusterLoad(clusteraddress); err == nil {
+		lv.load[index] = load
+	} else {
+		log.Println("Error during requesting cluster load from ", clusteraddress, err)
 	}
 	lv.Done()
 }
 
-func getAllLoadValues(conf Config, client *http.Client) []float64 {
+func getAllLoadValues(conf Config, r *Request) []float64 {
 	var lv loadValues
 	lv.load = make([]float64, len(conf.Cluster), len(conf.Cluster))
 	lv.Add(len(conf.Cluster))
 	for i := range conf.Cluster {
-		addr := conf.Cluster[i].Address
-		ver := conf.Cluster[i].ProtocolVersion
-		go getClusterLoad(&lv, i, fmt.Sprintf("%s/%s/drmsload", addr, ver), client)
+		go getClusterLoad(&lv, i, conf.Cluster[i], r)
 	}
 	lv.Wait()
 	return lv.load
@@ -179,25 +339,257 @@ func minLoad(load []float64) int {
 }
 
 type LoadBasedSched struct {
-	conf   Config
-	client *http.Client
+	conf    Config
+	request *Request
 }
 
 // SelectCluster of the LoadBasedSched is a simple scheduler
 // that selects the cluster with the lowest load.
 func (lbs *LoadBasedSched) SelectCluster() string {
+	name, _ := lbs.SelectClusterWithReason()
+	return name
+}
+
+// SelectClusterWithReason selects a cluster like SelectCluster
+// and reports the load values of all clusters.
+func (lbs *LoadBasedSched) SelectClusterWithReason() (string, string) {
 	// get all load values (time consuming)
-	load := getAllLoadValues(lbs.conf, lbs.client)
-	return lbs.conf.Cluster[minLoad(load)].Name
+	load := getAllLoadValues(lbs.conf, lbs.request)
+	selection := minLoad(load)
+	return lbs.conf.Cluster[selection].Name, fmt.Sprintf("lowest load %.2f of loads %s",
+		load[selection], formatLoads(lbs.conf, load))
 }
 
 type RandomSched struct {
-	conf   Config
-	client *http.Client
+	conf    Config
+	request *Request
+	rnd     randSource
 }
 
 // SelectCluster of the random scheduler selects a
 // a cluster randomly and returns its name.
 func (rs *RandomSched) SelectCluster() string {
-	return rs.conf.Cluster[rand.Intn(len(rs.conf.Cluster))].Name
+	name, _ := rs.SelectClusterWithReason()
+	return name
+}
+
+// SelectClusterWithReason selects a cluster like SelectCluster.
+func (rs *RandomSched) SelectClusterWithReason() (string, string) {
+	return rs.conf.Cluster[rs.rnd.Intn(len(rs.conf.Cluster))].Name,
+		fmt.Sprintf("random selection out of %d clusters", len(rs.conf.Cluster))
+}
+
+// WeightedSched selects clusters like the ProbSched but multiplies
+// the likelihood of each cluster with its configured weight. This
+// biases the selection towards bigger clusters independent of their
+// current load.
+type WeightedSched struct {
+	conf    Config
+	request *Request
+	rnd     randSource
+}
+
+// SelectCluster of the WeightedSched returns the name of a cluster
+// chosen by its weight and load. Clusters with weight 0 are never
+// chosen.
+func (ws *WeightedSched) SelectCluster() string {
+	name, _ := ws.SelectClusterWithReason()
+	return name
+}
+
+// SelectClusterWithReason selects a cluster like SelectCluster and
+// reports the weights, the load values, and the probabilities. When
+// all clusters are fully loaded the selection is based on the weights
+// only.
+func (ws *WeightedSched) SelectClusterWithReason() (string, string) {
+	weights := make([]float64, len(ws.conf.Cluster))
+	for i, c := range ws.conf.Cluster {
+		weights[i] = c.SelectionWeight()
+	}
+	loads := getAllLoadValues(ws.conf, ws.request)
+	if selection := probabilisticSelection(ws.rnd, loads, weights); selection >= 0 {
+		return ws.conf.Cluster[selection].Name, fmt.Sprintf("weighted selection with weights %s, loads %s, and probabilities %s",
+			formatWeights(ws.conf, weights), formatLoads(ws.conf, loads), formatProbabilities(ws.conf, loads, weights))
+	}
+	idle := make([]float64, len(loads))
+	if selection := probabilisticSelection(ws.rnd, idle, weights); selection >= 0 {
+		return ws.conf.Cluster[selection].Name, fmt.Sprintf("all clusters are fully loaded (loads %s), weighted selection with weights %s",
+			formatLoads(ws.conf, loads), formatWeights(ws.conf, weights))
+	}
+	log.Println("No cluster has a weight larger than 0, using default cluster.")
+	return "default", fmt.Sprintf("no cluster has a weight larger than 0 (weights %s), using default cluster",
+		formatWeights(ws.conf, weights))
+}
+
+// formatWeights returns the weights of all clusters as human
+// readable list.
+func formatWeights(conf Config, weights []float64) string {
+	values := make([]string, 0, len(weights))
+	for i, weight := range weights {
+		values = append(values, fmt.Sprintf("%s=%g", conf.Cluster[i].Name, weight))
+	}
+	return strings.Join(values, ", ")
+}
+
+// QueueScheduler is implemented by schedulers which select the cluster
+// depending on the queue requested by the job.
+type QueueScheduler interface {
+	SelectClusterForQueue(queue string) (string, string)
+}
+
+// requestFreeSlots requests the slot availability of the queues of the
+// cluster with the given (versioned) address and returns the free slots
+// of the queue (of all queues when no queue is given).
+func (r *Request) requestFreeSlots(clusteraddress, queue string) (int64, error) {
+	ctx, cancel := context.WithTimeout(r.context(), loadRequestTimeout)
+	defer cancel()
+	query := url.Values{}
+	if queue != "" {
+		query.Set("queue", queue)
+	}
+	resp, err := http_helper.UberGetWithContext(ctx, r.client, *r.otp, fmt.Sprintf("%s/msession/queueslots?%s", clusteraddress, query.Encode()))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if err := responseError(resp); err != nil {
+		return 0, err
+	}
+	var slots []types.QueueSlots
+	if err := json.NewDecoder(resp.Body).Decode(&slots); err != nil {
+		return 0, err
+	}
+	var free int64
+	for _, qs := range slots {
+		if queue == "" || qs.Name == queue {
+			free += qs.FreeSlots
+		}
+	}
+	return free, nil
+}
+
+// getAllFreeSlots requests the free slots of the queue from all clusters
+// at the same time. Clusters which can't report their slots get -1.
+func getAllFreeSlots(conf Config, queue string, r *Request) []int64 {
+	free := make([]int64, len(conf.Cluster))
+	var wg sync.WaitGroup
+	wg.Add(len(conf.Cluster))
+	for i := range conf.Cluster {
+		go func(i int) {
+			defer wg.Done()
+			slots, err := r.requestFreeSlots(r.requestAddress(conf.Cluster[i]), queue)
+			if err != nil {
+				log.Println("Error during requesting free slots from ", conf.Cluster[i].Name, err)
+				slots = -1
+			}
+			free[i] = slots
+		}(i)
+	}
+	wg.Wait()
+	return free
+}
+
+// formatFreeSlots returns the free slots of all clusters as human
+// readable list.
+func formatFreeSlots(conf Config, free []int64) string {
+	values := make([]string, 0, len(free))
+	for i, slots := range free {
+		if slots < 0 {
+			values = append(values, fmt.Sprintf("%s=unknown", conf.Cluster[i].Name))
+		} else {
+			values = append(values, fmt.Sprintf("%s=%d", conf.Cluster[i].Name, slots))
+		}
+	}
+	return strings.Join(values, ", ")
+}
+
+// SlotBasedSched selects the cluster with the lowest load among the
+// clusters which have free slots in the requested queue. This avoids
+// sending jobs to a lightly loaded cluster whose queue is full. When no
+// cluster has free slots the cluster with the lowest load is selected.
+type SlotBasedSched struct {
+	conf    Config
+	request *Request
+}
+
+// SelectCluster of the SlotBasedSched selects a cluster with free slots
+// in any queue.
+func (sbs *SlotBasedSched) SelectCluster() string {
+	name, _ := sbs.SelectClusterForQueue("")
+	return name
+}
+
+// SelectClusterWithReason selects a cluster like SelectCluster and
+// reports the free slots and load values of all clusters.
+func (sbs *SlotBasedSched) SelectClusterWithReason() (string, string) {
+	return sbs.SelectClusterForQueue("")
+}
+
+// SelectClusterForQueue selects a cluster with free slots in the queue
+// (in any queue when the queue is empty) and reports the free slots and
+// load values of all clusters.
+func (sbs *SlotBasedSched) SelectClusterForQueue(queue string) (string, string) {
+	free := getAllFreeSlots(sbs.conf, queue, sbs.request)
+	load := getAllLoadValues(sbs.conf, sbs.request)
+	queueName := queue
+	if queueName == "" {
+		queueName = "any queue"
+	}
+	selection := -1
+	for i := range sbs.conf.Cluster {
+		if free[i] > 0 && (selection < 0 || load[i] < load[selection]) {
+			selection = i
+		}
+	}
+	if selection >= 0 {
+		return sbs.conf.Cluster[selection].Name, fmt.Sprintf("lowest load %.2f of clusters with free slots in %s (free slots %s, loads %s)",
+			load[selection], queueName, formatFreeSlots(sbs.conf, free), formatLoads(sbs.conf, load))
+	}
+	selection = minLoad(load)
+	return sbs.conf.Cluster[selection].Name, fmt.Sprintf("no free slots in %s (free slots %s), lowest load %.2f of loads %s",
+		queueName, formatFreeSlots(sbs.conf, free), load[selection], formatLoads(sbs.conf, load))
+}
+
+// AffinityScheduler is implemented by schedulers which select the
+// cluster depending on an affinity key (like the user name).
+type AffinityScheduler interface {
+	SelectClusterForKey(key string) (string, string)
+}
+
+// AffinitySched always selects the same cluster for the same affinity
+// key so that the jobs of a user (or of a workflow sharing data) run
+// together. Different keys are spread over all clusters. The selection
+// uses rendezvous hashing: the cluster with the highest hash of key and
+// cluster name is selected, hence adding or removing a cluster moves
+// only the keys of that cluster. Without a key the user name is used.
+type AffinitySched struct {
+	conf Config
+	key  string
+}
+
+// SelectCluster of the AffinitySched selects the cluster of the
+// current user.
+func (as *AffinitySched) SelectCluster() string {
+	name, _ := as.SelectClusterWithReason()
+	return name
+}
+
+// SelectClusterWithReason selects a cluster like SelectCluster.
+func (as *AffinitySched) SelectClusterWithReason() (string, string) {
+	return as.SelectClusterForKey(as.key)
+}
+
+// SelectClusterForKey returns the cluster of the affinity key.
+func (as *AffinitySched) SelectClusterForKey(key string) (string, string) {
+	selection, highest := 0, uint64(0)
+	for i, c := range as.conf.Cluster {
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		h.Write([]byte{0})
+		h.Write([]byte(c.Name))
+		if sum := h.Sum64(); i == 0 || sum > highest {
+			selection, highest = i, sum
+		}
+	}
+	return as.conf.Cluster[selection].Name, fmt.Sprintf("affinity key %q is mapped to it (out of %d clusters)", key, len(as.conf.Cluster))
 }
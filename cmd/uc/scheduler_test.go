@@ -18,7 +18,11 @@ package main
 
 import (
 	"fmt"
+	"math/rand"
 	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -28,10 +32,11 @@ func TestProbabilisticSelection(t *testing.T) {
 	var p = []float64{
 		0.9, 0.8, 1.0, 0.3,
 	}
+	rnd := rand.New(rand.NewSource(42))
 	distribution := make([]int, 4, 4)
 	selection := make([]int, amount, amount)
 	for i := 0; i < amount; i++ {
-		selection[i] = probabilisticSelection(p)
+		selection[i] = probabilisticSelection(rnd, p, nil)
 		distribution[selection[i]]++
 	}
 	// expecting to have 10%, 20%, 0% and 70%
@@ -56,15 +61,16 @@ func BenchmarkProbabilisticSelection(b *testing.B) {
 	var loads = []float64{
 		0.9, 0.8, 1.0, 0.3, 0.1, 0.2, 0.4,
 	}
+	rnd := rand.New(rand.NewSource(1))
 	for i := 0; i < b.N; i++ {
-		probabilisticSelection(loads)
+		probabilisticSelection(rnd, loads, nil)
 	}
 }
 
 func TestRandomScheduling(t *testing.T) {
 	for amountOfCluster := 1; amountOfCluster < 10; amountOfCluster++ {
 		conf := makeTestConfig(amountOfCluster)
-		sched := MakeNewScheduler(RandomSchedulerType, conf, &http.Client{})
+		sched := MakeNewScheduler(RandomSchedulerType, conf, NewRequest("", "", otp))
 		names := make([]string, 10000, 10000)
 		for i := 0; i < 10000; i++ {
 			names[i] = sched.Impl.SelectCluster()
@@ -93,9 +99,70 @@ func TestRandomScheduling(t *testing.T) {
 	}
 }
 
+func TestLoadRequestsUseNegotiatedVersion(t *testing.T) {
+	var paths []string
+	var mutex sync.Mutex
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		paths = append(paths, r.URL.Path)
+		mutex.Unlock()
+		switch r.URL.Path {
+		case "/versions":
+			w.Write([]byte(`{"versions":["v1"]}`))
+		case "/v1/msession/drmsload":
+			w.Write([]byte("0.5"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	conf := Config{Cluster: []ClusterConfig{{Name: "c1", Address: ts.URL, ProtocolVersion: "v0"}}}
+
+	loads := getAllLoadValues(conf, NewRequest("", "", otp))
+	if loads[0] != 0.5 {
+		t.Errorf("Expected the load of the negotiated protocol version but got %v (requests %v)", loads, paths)
+	}
+
+	paths = nil
+	conf.Cluster[0].NegotiatedVersion = "v1"
+	getAllLoadValues(conf, NewRequest("", "", otp))
+	if len(paths) != 1 || paths[0] != "/v1/msession/drmsload" {
+		t.Errorf("Expected the stored protocol version to be used without negotiation but got %v", paths)
+	}
+}
+
+func TestSelectClusterWithReason(t *testing.T) {
+	low := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("0.25"))
+	}))
+	defer low.Close()
+	high := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("0.75"))
+	}))
+	defer high.Close()
+	conf := Config{Cluster: []ClusterConfig{
+		{Name: "high", Address: high.URL, ProtocolVersion: "v1"},
+		{Name: "low", Address: low.URL, ProtocolVersion: "v1"},
+	}}
+
+	sched := MakeNewScheduler(LoadBasedSchedulerType, conf, NewRequest("", "", otp))
+	name, reason := sched.Impl.SelectClusterWithReason()
+	if name != "low" {
+		t.Errorf("Expected cluster low to be selected but got %s", name)
+	}
+	if !strings.Contains(reason, "high=0.75, low=0.25") {
+		t.Errorf("Expected the load values in the reason but got: %s", reason)
+	}
+
+	sched = MakeNewScheduler(ProbabilisticSchedulerType, conf, NewRequest("", "", otp))
+	if _, reason = sched.Impl.SelectClusterWithReason(); !strings.Contains(reason, "high=25%, low=75%") {
+		t.Errorf("Expected the probabilities in the reason but got: %s", reason)
+	}
+}
+
 func BenchmarkRandomScheduling(b *testing.B) {
 	conf := makeTestConfig(10)
-	sched := MakeNewScheduler(RandomSchedulerType, conf, &http.Client{})
+	sched := MakeNewScheduler(RandomSchedulerType, conf, NewRequest("", "", otp))
 	for i := 0; i < b.N; i++ {
 		sched.Impl.SelectCluster()
 	}
@@ -105,8 +172,207 @@ func BenchmarkLoadBasedScheduling(b *testing.B) {
 	// doesn't make much sense since it tries to get the load
 	// from the clusters (which does not exist of course)
 	conf := makeTestConfig(10)
-	sched := MakeNewScheduler(LoadBasedSchedulerType, conf, &http.Client{})
+	sched := MakeNewScheduler(LoadBasedSchedulerType, conf, NewRequest("", "", otp))
 	for i := 0; i < b.N; i++ {
 		sched.Impl.SelectCluster()
 	}
 }
+
+func TestWeightedProbabilisticSelection(t *testing.T) {
+	loads := []float64{0.5, 0.5, 0.0}
+	weights := []float64{3, 1, 0}
+	rnd := rand.New(rand.NewSource(42))
+	distribution := make([]int, 3)
+	for i := 0; i < 100000; i++ {
+		distribution[probabilisticSelection(rnd, loads, weights)]++
+	}
+	if distribution[2] != 0 {
+		t.Errorf("Cluster with weight 0 was selected %d times", distribution[2])
+	}
+	// expecting 75% and 25%
+	if distribution[0] < 73000 || distribution[0] > 77000 {
+		t.Errorf("Expected cluster 0 to be selected around 75000 times but got %d", distribution[0])
+	}
+	if probabilisticSelection(rnd, loads, []float64{0, 0, 0}) != -1 {
+		t.Errorf("Expected no selection when all weights are 0")
+	}
+}
+
+func TestWeightedScheduling(t *testing.T) {
+	busy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("1.0"))
+	}))
+	defer busy.Close()
+	zero, four := 0.0, 4.0
+	conf := Config{Cluster: []ClusterConfig{
+		{Name: "default", Address: busy.URL, ProtocolVersion: "v1", Weight: &zero},
+		{Name: "big", Address: busy.URL, ProtocolVersion: "v1", Weight: &four},
+		{Name: "small", Address: busy.URL, ProtocolVersion: "v1"},
+	}}
+
+	sched, err := MakeSchedulerByAlg("weighted", conf, NewRequest("", "", otp))
+	if err != nil {
+		t.Fatalf("Couldn't create weighted scheduler: %s", err)
+	}
+	for i := 0; i < 100; i++ {
+		name, reason := sched.Impl.SelectClusterWithReason()
+		if name == "default" {
+			t.Fatalf("Cluster with weight 0 was selected: %s", reason)
+		}
+		if !strings.Contains(reason, "default=0, big=4, small=1") {
+			t.Errorf("Expected the weights in the reason but got: %s", reason)
+		}
+	}
+}
+
+func TestSeededSelection(t *testing.T) {
+	loads := []float64{0.9, 0.8, 1.0, 0.3}
+	first, second := rand.New(rand.NewSource(7)), rand.New(rand.NewSource(7))
+	for i := 0; i < 1000; i++ {
+		if a, b := probabilisticSelection(first, loads, nil), probabilisticSelection(second, loads, nil); a != b {
+			t.Fatalf("Expected the same selection for the same seed in round %d but got %d and %d", i, a, b)
+		}
+	}
+}
+
+func TestSeededProbabilisticScheduling(t *testing.T) {
+	loads := map[string]string{"a": "0.9", "b": "0.5", "c": "0.0"}
+	var clusters []ClusterConfig
+	for _, name := range []string{"a", "b", "c"} {
+		load := loads[name]
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(load))
+		}))
+		defer ts.Close()
+		clusters = append(clusters, ClusterConfig{Name: name, Address: ts.URL, ProtocolVersion: "v1"})
+	}
+	conf := Config{Cluster: clusters}
+
+	selections := func(seed int64) map[string]int {
+		sched := MakeNewSchedulerWithRand(ProbabilisticSchedulerType, conf, NewRequest("", "", otp), rand.New(rand.NewSource(seed)))
+		m := make(map[string]int)
+		for i := 0; i < 160; i++ {
+			m[sched.Impl.SelectCluster()]++
+		}
+		return m
+	}
+	m := selections(42)
+	// expecting 1/16, 5/16, and 10/16
+	expected := map[string]float64{"a": 10, "b": 50, "c": 100}
+	for name, e := range expected {
+		if float64(m[name]) < e*0.5 || float64(m[name]) > e*1.5 {
+			t.Errorf("Expected cluster %s to be selected around %.0f times but got %d", name, e, m[name])
+		}
+	}
+	if again := selections(42); fmt.Sprint(again) != fmt.Sprint(m) {
+		t.Errorf("Expected the same selections for the same seed but got %v and %v", m, again)
+	}
+}
+
+func TestConcurrentRandomScheduling(t *testing.T) {
+	sched := MakeNewSchedulerWithRand(RandomSchedulerType, makeTestConfig(5), NewRequest("", "", otp), rand.New(rand.NewSource(1)))
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				sched.Impl.SelectCluster()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// makeSlotServer returns a proxy reporting the load and the slots of
+// its all.q queue.
+func makeSlotServer(load string, used, total int64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/queueslots") {
+			fmt.Fprintf(w, `[{"name":"all.q","usedSlots":%d,"totalSlots":%d,"freeSlots":%d}]`, used, total, total-used)
+			return
+		}
+		w.Write([]byte(load))
+	}))
+}
+
+func TestSlotBasedScheduling(t *testing.T) {
+	full := makeSlotServer("0.1", 8, 8)
+	defer full.Close()
+	free := makeSlotServer("0.9", 4, 8)
+	defer free.Close()
+	old := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/queueslots") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("0.0"))
+	}))
+	defer old.Close()
+	conf := Config{Cluster: []ClusterConfig{
+		{Name: "full", Address: full.URL, ProtocolVersion: "v1"},
+		{Name: "free", Address: free.URL, ProtocolVersion: "v1"},
+		{Name: "old", Address: old.URL, ProtocolVersion: "v1"},
+	}}
+
+	sched, err := MakeSchedulerByAlg("slots", conf, NewRequest("", "", otp))
+	if err != nil {
+		t.Fatalf("Couldn't create slot based scheduler: %s", err)
+	}
+	qs, ok := sched.Impl.(QueueScheduler)
+	if !ok {
+		t.Fatalf("Expected slot based scheduler to select clusters by queue")
+	}
+	name, reason := qs.SelectClusterForQueue("all.q")
+	if name != "free" {
+		t.Errorf("Expected cluster free to be selected but got %s: %s", name, reason)
+	}
+	if !strings.Contains(reason, "full=0, free=4, old=unknown") {
+		t.Errorf("Expected the free slots in the reason but got: %s", reason)
+	}
+
+	// no cluster has free slots in the queue
+	name, reason = qs.SelectClusterForQueue("other.q")
+	if name != "old" {
+		t.Errorf("Expected fallback to cluster old with lowest load but got %s: %s", name, reason)
+	}
+	if !strings.Contains(reason, "no free slots in other.q") {
+		t.Errorf("Expected the fallback in the reason but got: %s", reason)
+	}
+}
+
+func TestAffinityScheduling(t *testing.T) {
+	conf := makeTestConfig(5)
+	sched, err := MakeSchedulerByAlg("affinity", conf, NewRequest("", "", otp))
+	if err != nil {
+		t.Fatalf("Couldn't create affinity scheduler: %s", err)
+	}
+	as, ok := sched.Impl.(AffinityScheduler)
+	if !ok {
+		t.Fatalf("Expected affinity scheduler to select clusters by key")
+	}
+	selected := make(map[string]string)
+	used := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("user%d", i)
+		name, _ := as.SelectClusterForKey(key)
+		if again, _ := as.SelectClusterForKey(key); again != name {
+			t.Fatalf("Key %s was mapped to %s and %s", key, name, again)
+		}
+		selected[key] = name
+		used[name] = true
+	}
+	if len(used) != len(conf.Cluster) {
+		t.Errorf("Expected keys to be spread over all %d clusters but got %v", len(conf.Cluster), used)
+	}
+
+	// removing a cluster moves only the keys of that cluster
+	removed := conf.Cluster[0].Name
+	smaller := Config{Cluster: conf.Cluster[1:]}
+	as = MakeNewScheduler(AffinitySchedulerType, smaller, NewRequest("", "", otp)).Impl.(AffinityScheduler)
+	for key, name := range selected {
+		if now, _ := as.SelectClusterForKey(key); name != removed && now != name {
+			t.Errorf("Key %s moved from %s to %s", key, name, now)
+		}
+	}
+}
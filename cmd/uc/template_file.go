@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/dgruber/ubercluster/pkg/types"
+	"github.com/ghodss/yaml"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// LoadJobTemplate reads a job template from a JSON file or, when the
+// file has a .yaml or .yml extension, from a YAML file. The field names
+// are the same as in the JSON representation of the job template and
+// numeric fields which are not part of the file are unset.
+func LoadJobTemplate(filename string) (types.JobTemplate, error) {
+	jt := types.CreateJobTemplate()
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return jt, err
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		if content, err = yaml.YAMLToJSON(content); err != nil {
+			return jt, fmt.Errorf("invalid job template file %s: %s", filename, err)
+		}
+	}
+	if err := json.Unmarshal(content, &jt); err != nil {
+		return jt, fmt.Errorf("invalid job template file %s: %s", filename, err)
+	}
+	return jt, nil
+}
+
+// OverrideJobTemplate sets the fields of the job template which are given
+// on the command line. Empty values don't change the job template.
+func OverrideJobTemplate(jt types.JobTemplate, jobname, cmd, arg, queue, category string) types.JobTemplate {
+	if jobname != "" {
+		jt.JobName = jobname
+	}
+	if cmd != "" {
+		jt.RemoteCommand = cmd
+	}
+	if arg != "" {
+		jt.Args = []string{arg}
+	}
+	if queue != "" {
+		jt.QueueName = queue
+	}
+	if category != "" {
+		jt.JobCategory = category
+	}
+	return jt
+}
+
+// SelectionHints returns the queue and the affinity key which are
+// considered when a cluster is selected for a job submission. Values
+// given on the command line are preferred over the ones of the job
+// template file. The file is not required to exist here since it is
+// loaded (and errors are reported) when the job template is created.
+func SelectionHints(filename, queue, affinityKey string) (string, string) {
+	if filename == "" || (queue != "" && affinityKey != "") {
+		return queue, affinityKey
+	}
+	jt, err := LoadJobTemplate(filename)
+	if err != nil {
+		return queue, affinityKey
+	}
+	if queue == "" {
+		queue = jt.QueueName
+	}
+	if affinityKey == "" {
+		affinityKey = jt.AffinityKey
+	}
+	return queue, affinityKey
+}
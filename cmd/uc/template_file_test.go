@@ -0,0 +1,101 @@
+package main_test
+
+import (
+	. "github.com/dgruber/ubercluster/cmd/uc"
+	"github.com/dgruber/ubercluster/pkg/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+var _ = Describe("TemplateFile", func() {
+
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "templatefile")
+		Ω(err).Should(BeNil())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	writeFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		Ω(ioutil.WriteFile(path, []byte(content), 0600)).Should(BeNil())
+		return path
+	}
+
+	Context("basic functions", func() {
+
+		It("should load a JSON job template", func() {
+			jt, err := LoadJobTemplate(writeFile("job.json",
+				`{"remoteCommand":"sleep","args":["10"],"jobEnvironment":{"A":"B"},"priority":0}`))
+			Ω(err).Should(BeNil())
+			Ω(jt.RemoteCommand).Should(Equal("sleep"))
+			Ω(jt.Args).Should(Equal([]string{"10"}))
+			Ω(jt.JobEnvironment).Should(HaveKeyWithValue("A", "B"))
+			Ω(jt.Priority).Should(Equal(int64(0)))
+			Ω(jt.MinSlots).Should(Equal(types.UnsetNum))
+		})
+
+		It("should load a YAML job template", func() {
+			jt, err := LoadJobTemplate(writeFile("job.yml", `
+remoteCommand: sleep
+args:
+  - "10"
+queueName: all.q
+minSlots: 4
+`))
+			Ω(err).Should(BeNil())
+			Ω(jt.RemoteCommand).Should(Equal("sleep"))
+			Ω(jt.Args).Should(Equal([]string{"10"}))
+			Ω(jt.QueueName).Should(Equal("all.q"))
+			Ω(jt.MinSlots).Should(Equal(int64(4)))
+			Ω(jt.MaxSlots).Should(Equal(types.UnsetNum))
+		})
+
+		It("should override the fields given on the command line", func() {
+			jt := types.JobTemplate{RemoteCommand: "sleep", Args: []string{"10"}, QueueName: "all.q", JobName: "file"}
+			jt = OverrideJobTemplate(jt, "", "", "20", "other.q", "")
+			Ω(jt.RemoteCommand).Should(Equal("sleep"))
+			Ω(jt.JobName).Should(Equal("file"))
+			Ω(jt.Args).Should(Equal([]string{"20"}))
+			Ω(jt.QueueName).Should(Equal("other.q"))
+		})
+
+		It("should select the cluster by the queue and affinity key of the file", func() {
+			file := writeFile("job.json", `{"remoteCommand":"sleep","queueName":"all.q","affinityKey":"team"}`)
+			queue, key := SelectionHints(file, "", "")
+			Ω(queue).Should(Equal("all.q"))
+			Ω(key).Should(Equal("team"))
+			queue, key = SelectionHints(file, "other.q", "user")
+			Ω(queue).Should(Equal("other.q"))
+			Ω(key).Should(Equal("user"))
+			queue, key = SelectionHints("", "", "")
+			Ω(queue).Should(Equal(""))
+			Ω(key).Should(Equal(""))
+		})
+
+	})
+
+	Context("error cases", func() {
+
+		It("should reject invalid job template files", func() {
+			_, err := LoadJobTemplate(writeFile("job.json", `{"remoteCommand":`))
+			Ω(err).ShouldNot(BeNil())
+			_, err = LoadJobTemplate(writeFile("job.yaml", "remoteCommand: [sleep"))
+			Ω(err).ShouldNot(BeNil())
+			_, err = LoadJobTemplate(filepath.Join(dir, "missing.json"))
+			Ω(err).ShouldNot(BeNil())
+		})
+
+	})
+
+})
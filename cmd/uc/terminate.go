@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/dgruber/ubercluster/pkg/types"
+	"io"
+	"strings"
+)
+
+// TerminateJobs terminates all unfinished jobs matching the given state
+// and user. The matching jobs are passed to confirm before any of them
+// is terminated; when confirm returns false nothing is done. The result
+// of each termination and a summary is written to w. An error is
+// returned when the jobs can't be listed or any termination failed.
+func (r *Request) TerminateJobs(clusteraddress, state, user string, confirm func([]types.JobInfo) bool, w io.Writer) error {
+	joblist, err := r.GetJobs(clusteraddress, state, user)
+	if err != nil {
+		return err
+	}
+	jobs := make([]types.JobInfo, 0, len(joblist))
+	for _, ji := range joblist {
+		if !ji.State.IsTerminal() {
+			jobs = append(jobs, ji)
+		}
+	}
+	if len(jobs) == 0 {
+		fmt.Fprintln(w, "No matching jobs to terminate.")
+		return nil
+	}
+	if !confirm(jobs) {
+		fmt.Fprintln(w, "No jobs terminated.")
+		return nil
+	}
+	failed := 0
+	for _, ji := range jobs {
+		if _, err := r.JobOperation(clusteraddress, "ubercluster", "terminate", ji.Id); err != nil {
+			failed++
+			fmt.Fprintf(w, "Job %s: %s\n", ji.Id, err)
+			continue
+		}
+		fmt.Fprintf(w, "Job %s: terminated\n", ji.Id)
+	}
+	fmt.Fprintf(w, "Terminated %d of %d jobs.\n", len(jobs)-failed, len(jobs))
+	if failed > 0 {
+		return fmt.Errorf("termination of %d jobs failed", failed)
+	}
+	return nil
+}
+
+// confirmTermination returns a confirmation for TerminateJobs which
+// lists the jobs and asks the user whether they should be terminated.
+// Only an answer starting with "y" confirms the termination.
+func confirmTermination(in io.Reader, out io.Writer) func([]types.JobInfo) bool {
+	return func(jobs []types.JobInfo) bool {
+		ids := make([]string, 0, len(jobs))
+		for _, ji := range jobs {
+			ids = append(ids, ji.Id)
+		}
+		fmt.Fprintf(out, "Terminate %d jobs (%s)? [y/N] ", len(jobs), strings.Join(ids, ", "))
+		answer, _ := bufio.NewReader(in).ReadString('\n')
+		return strings.HasPrefix(strings.ToLower(strings.TrimSpace(answer)), "y")
+	}
+}
@@ -0,0 +1,76 @@
+package main_test
+
+import (
+	. "github.com/dgruber/ubercluster/cmd/uc"
+	"github.com/dgruber/ubercluster/pkg/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+var _ = Describe("Terminate", func() {
+
+	var (
+		otp        string
+		ts         *httptest.Server
+		mtx        sync.Mutex
+		terminated []string
+	)
+
+	BeforeEach(func() {
+		terminated = nil
+		ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, "/msession/jobinfos") {
+				Ω(r.FormValue("state")).Should(Equal("q"))
+				Ω(r.FormValue("user")).Should(Equal("alice"))
+				w.Write([]byte(`[{"id":"1","state":2},{"id":"2","state":2},{"id":"3","state":8}]`))
+				return
+			}
+			jobid := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+			if jobid == "2" {
+				http.Error(w, "job 2 can't be terminated", http.StatusInternalServerError)
+				return
+			}
+			mtx.Lock()
+			terminated = append(terminated, jobid)
+			mtx.Unlock()
+			w.Write([]byte(`"terminated"`))
+		}))
+	})
+
+	AfterEach(func() {
+		ts.Close()
+	})
+
+	It("should terminate all unfinished matching jobs and report failures", func() {
+		var confirmed []types.JobInfo
+		var out bytes.Buffer
+		err := NewRequest("", "", &otp).TerminateJobs(ts.URL, "q", "alice", func(jobs []types.JobInfo) bool {
+			confirmed = jobs
+			return true
+		}, &out)
+		Ω(err).ShouldNot(BeNil())
+		Ω(confirmed).Should(HaveLen(2))
+		Ω(terminated).Should(Equal([]string{"1"}))
+		Ω(out.String()).Should(ContainSubstring("Job 1: terminated"))
+		Ω(out.String()).Should(ContainSubstring("job 2 can't be terminated"))
+		Ω(out.String()).Should(ContainSubstring("Terminated 1 of 2 jobs."))
+	})
+
+	It("should not terminate jobs without confirmation", func() {
+		var out bytes.Buffer
+		err := NewRequest("", "", &otp).TerminateJobs(ts.URL, "q", "alice", func([]types.JobInfo) bool {
+			return false
+		}, &out)
+		Ω(err).Should(BeNil())
+		Ω(terminated).Should(BeEmpty())
+		Ω(out.String()).Should(ContainSubstring("No jobs terminated."))
+	})
+
+})
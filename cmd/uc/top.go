@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"github.com/dgruber/ubercluster/pkg/types"
+	"golang.org/x/crypto/ssh/terminal"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+// ClusterSummary contains the aggregated state of a cluster
+// as displayed by "uc top".
+type ClusterSummary struct {
+	Name       string
+	Load       float64
+	Running    int   // running jobs
+	Queued     int   // queued and queued held jobs
+	Suspended  int   // suspended jobs
+	UsedSlots  int64 // used slots of all queues
+	TotalSlots int64 // slots of all queues
+	Err        error // set when the cluster could not be reached
+}
+
+// Utilization returns the fraction of used slots of all queues of
+// the cluster or 0 if the amount of slots is unknown.
+func (cs *ClusterSummary) Utilization() float64 {
+	if cs.TotalSlots <= 0 {
+		return 0
+	}
+	return float64(cs.UsedSlots) / float64(cs.TotalSlots)
+}
+
+// GetClusterSummary requests the load, the jobs, and the queues of a
+// cluster and aggregates them.
+func (r *Request) GetClusterSummary(name, clusteraddress string) ClusterSummary {
+	cs := ClusterSummary{Name: name}
+	load, err := r.requestClusterLoad(clusteraddress)
+	if err != nil {
+		cs.Err = err
+		return cs
+	}
+	cs.Load = load
+	jobs, err := r.GetJobs(clusteraddress, "all", "")
+	if err != nil {
+		cs.Err = err
+		return cs
+	}
+	for _, job := range jobs {
+		switch job.State {
+		case types.Running:
+			cs.Running++
+		case types.Queued, types.QueuedHeld, types.Requeued, types.RequeuedHeld:
+			cs.Queued++
+		case types.Suspended:
+			cs.Suspended++
+		}
+	}
+	queues, err := r.GetQueues(clusteraddress, "all")
+	if err != nil {
+		cs.Err = err
+		return cs
+	}
+	for _, q := range queues {
+		cs.UsedSlots += q.UsedSlots
+		cs.TotalSlots += q.TotalSlots
+	}
+	return cs
+}
+
+// GetClusterSummaries requests the summaries of all given clusters in
+// parallel. The addresses of the clusters must contain the protocol
+// version.
+func (r *Request) GetClusterSummaries(clusters []ClusterConfig) []ClusterSummary {
+	summaries := make([]ClusterSummary, len(clusters))
+	var wg sync.WaitGroup
+	wg.Add(len(clusters))
+	for i := range clusters {
+		go func(i int) {
+			defer wg.Done()
+			summaries[i] = r.GetClusterSummary(clusters[i].Name, clusters[i].Address)
+		}(i)
+	}
+	wg.Wait()
+	return summaries
+}
+
+// PrintClusterSummaries writes the summaries as table.
+func PrintClusterSummaries(w io.Writer, summaries []ClusterSummary) {
+	fmt.Fprintf(w, "%-20s %6s %8s %7s %9s %13s %6s\n", "CLUSTER", "LOAD", "RUNNING", "QUEUED", "SUSPENDED", "SLOTS", "UTIL")
+	for _, cs := range summaries {
+		if cs.Err != nil {
+			fmt.Fprintf(w, "%-20s error: %s\n", cs.Name, cs.Err)
+			continue
+		}
+		fmt.Fprintf(w, "%-20s %6.2f %8d %7d %9d %13s %5.1f%%\n", cs.Name, cs.Load,
+			cs.Running, cs.Queued, cs.Suspended,
+			fmt.Sprintf("%d/%d", cs.UsedSlots, cs.TotalSlots), cs.Utilization()*100)
+	}
+}
+
+// Top periodically prints the summaries of the given clusters until it
+// is interrupted (Ctrl-C) or the summaries were printed count times
+// (count <= 0 means no limit).
+func (r *Request) Top(clusters []ClusterConfig, interval time.Duration, count int, w io.Writer) error {
+	if interval <= 0 {
+		return fmt.Errorf("invalid refresh interval %s (must be positive)", interval)
+	}
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	tty := w == os.Stdout && terminal.IsTerminal(int(os.Stdout.Fd()))
+	for i := 1; ; i++ {
+		summaries := r.GetClusterSummaries(clusters)
+		if tty {
+			// move cursor to top left and clear the screen
+			fmt.Fprint(w, "\033[H\033[2J")
+		}
+		fmt.Fprintf(w, "uc top - %s (every %s)\n\n", time.Now().Format("15:04:05"), interval)
+		PrintClusterSummaries(w, summaries)
+		if count > 0 && i >= count {
+			return nil
+		}
+		select {
+		case <-interrupt:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// topClusters returns the clusters shown by "uc top": either all
+// configured clusters or just the selected one.
+func (r *Request) topClusters(all bool, clustername, clusteraddress string) []ClusterConfig {
+	if !all {
+		return []ClusterConfig{{Name: clustername, Address: clusteraddress}}
+	}
+	clusters := make([]ClusterConfig, 0, len(config.Cluster))
+	for _, c := range config.Cluster {
+		clusters = append(clusters, ClusterConfig{Name: c.Name, Address: r.requestAddress(c)})
+	}
+	return clusters
+}
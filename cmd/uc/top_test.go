@@ -0,0 +1,72 @@
+package main_test
+
+import (
+	. "github.com/dgruber/ubercluster/cmd/uc"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+var _ = Describe("Top", func() {
+
+	var otp string
+	var ts *httptest.Server
+
+	BeforeEach(func() {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/v1/msession/drmsload", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("0.75"))
+		})
+		mux.HandleFunc("/v1/msession/jobinfos", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`[{"id":"1","state":4},{"id":"2","state":4},{"id":"3","state":2},{"id":"4","state":5},{"id":"5","state":8}]`))
+		})
+		mux.HandleFunc("/v1/msession/queues", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`[{"name":"a.q","usedSlots":2,"totalSlots":4},{"name":"b.q","usedSlots":0,"totalSlots":4}]`))
+		})
+		ts = httptest.NewServer(mux)
+	})
+
+	AfterEach(func() {
+		ts.Close()
+	})
+
+	It("should aggregate the load, jobs, and slots of a cluster", func() {
+		cs := NewRequest("", "", &otp).GetClusterSummary("c1", ts.URL+"/v1")
+		Ω(cs.Err).Should(BeNil())
+		Ω(cs.Load).Should(Equal(0.75))
+		Ω(cs.Running).Should(Equal(2))
+		Ω(cs.Queued).Should(Equal(1))
+		Ω(cs.Suspended).Should(Equal(1))
+		Ω(cs.UsedSlots).Should(Equal(int64(2)))
+		Ω(cs.TotalSlots).Should(Equal(int64(8)))
+		Ω(cs.Utilization()).Should(Equal(0.25))
+	})
+
+	It("should print the summaries of all clusters the given amount of times", func() {
+		clusters := []ClusterConfig{
+			{Name: "c1", Address: ts.URL + "/v1"},
+			{Name: "unreachable", Address: "http://127.0.0.1:1/v1"},
+		}
+		var out bytes.Buffer
+		err := NewRequest("", "", &otp).Top(clusters, 10*time.Millisecond, 2, &out)
+		Ω(err).Should(BeNil())
+		Ω(bytes.Count(out.Bytes(), []byte("uc top"))).Should(Equal(2))
+		Ω(out.String()).Should(MatchRegexp(`c1 +0\.75 +2 +1 +1 +2/8 +25\.0%`))
+		Ω(out.String()).Should(ContainSubstring("unreachable          error:"))
+	})
+
+	It("should reject an interval which is not positive", func() {
+		var out bytes.Buffer
+		err := NewRequest("", "", &otp).Top([]ClusterConfig{}, 0, 1, &out)
+		Ω(err).ShouldNot(BeNil())
+		err = NewRequest("", "", &otp).Top([]ClusterConfig{}, -time.Second, 1, &out)
+		Ω(err).ShouldNot(BeNil())
+		Ω(out.Len()).Should(Equal(0))
+	})
+
+})
@@ -17,13 +17,17 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"github.com/dgruber/ubercluster/pkg/output"
 	"github.com/dgruber/ubercluster/pkg/staging"
+	"github.com/dgruber/ubercluster/pkg/types"
+	"golang.org/x/crypto/ssh/terminal"
 	"gopkg.in/alecthomas/kingpin.v1"
 	"io/ioutil"
 	"log"
 	"os"
+	"strings"
 )
 
 // Disable logging by default
@@ -34,44 +38,88 @@ func init() {
 var (
 	app       = kingpin.New("uc", "A tool which can interact with multiple compute clusters.")
 	verbose   = app.Flag("verbose", "Enables enhanced logging for debugging.").Bool()
-	cluster   = app.Flag("cluster", "Cluster name to interact with.").Default("default").String()
-	otp       = app.Flag("otp", "One time password (\"yubikey\") or shared secret.").Default("").String()
-	outformat = app.Flag("format", "Output format specifier (default/json).").Default("default").String()
+	cluster   = app.Flag("cluster", "Cluster name to interact with (group:name for all clusters of a group).").Default("default").String()
+	otp       = app.Flag("otp", "One time password (\"yubikey\") or shared secret (visible in the process list, see --otp-file and UC_OTP).").Default("").String()
+	otpFile   = app.Flag("otp-file", "File containing the one time password (\"yubikey\") or shared secret in its first line.").Default("").String()
+	outformat = app.Flag("format", "Output format specifier (default/json/xml/wide).").Default("default").String()
+	noColor   = app.Flag("no-color", "Disables coloring of job states on a terminal.").Bool()
+
+	timeout    = app.Flag("timeout", "Maximum time of a request to a proxy (0 disables the limit).").Default("30s").Duration()
+	retries    = app.Flag("retries", "Amount of retries when a proxy is temporarily not reachable (not with yubikey).").Default("0").Int()
+	retryDelay = app.Flag("retry-delay", "Delay before the first retry, doubled for each further retry.").Default("1s").Duration()
 
 	certFile = app.Flag("cert", "PEM encoded certificate file.").Default("").String()
 	keyFile  = app.Flag("key", "PEM encoded private key file.").Default("").String()
 
-	show               = app.Command("show", "Displays information about connected clusters.")
-	showJob            = show.Command("job", "Information about a particular job.")
-	showJobStateId     = showJob.Flag("state", "Show only jobs in that state (r/q/h/s/R/Rh/d/f/u/all).").Default("all").String()
-	showJobId          = showJob.Arg("id", "Id of job").Default("").String()
-	showJobUser        = showJob.Flag("user", "Shows only jobs of a particular user.").Default("").String()
-	showMachine        = show.Command("machine", "Information about compute hosts.")
-	showMachineName    = showMachine.Arg("name", "Name of machine (or \"all\" for all.").Default("all").String()
-	showQueue          = show.Command("queue", "Information about queues.")
-	showQueueName      = showQueue.Arg("name", "Name of queue to show.").Default("all").String()
-	showCategories     = show.Command("category", "Information about job categories.")
-	showCategoriesName = showCategories.Arg("name", "Name of job category to show.").Default("all").String()
-	showSession        = show.Command("session", "Information about job sessions.")
-	showSessionName    = showSession.Arg("name", "Name of the job session to show.").Default("all").String()
-
-	run         = app.Command("run", "Submits an application to a cluster.")
-	runCommand  = run.Arg("command", "Command to submit.").Default("#nocommand#").String()
-	runArg      = run.Flag("arg", "Argument of the command (use \" when having spaces).").Default("").String()
-	runName     = run.Flag("name", "Reference name of the command.").Default("").String()
-	runQueue    = run.Flag("queue", "Queue name for the job.").Default("").String()
-	runCategory = run.Flag("category", "Job category / job class of the job.").Default("").String()
-	alg         = run.Flag("alg", "Automatic cluster selection when submitting jobs (\"rand\", \"prob\", \"load\")").Default("").String()
-	fileUp      = run.Flag("upload", "Path to job which is uploaded before execution.").Default("").String()
+	show                 = app.Command("show", "Displays information about connected clusters.")
+	showJob              = show.Command("job", "Information about a particular job.")
+	showJobStateId       = showJob.Flag("state", "Show only jobs in that state (r/q/h/s/R/Rh/d/f/u/all).").Default("all").String()
+	showJobId            = showJob.Arg("id", "Id of job").Default("").String()
+	showJobUser          = showJob.Flag("user", "Shows only jobs of a particular user (default is the current user, \"all\" shows the jobs of all users).").Default("").String()
+	showJobWatch         = showJob.Flag("watch", "Refreshes the job state until the job is finished.").Bool()
+	showJobInterval      = showJob.Flag("interval", "Refresh interval when watching a job.").Default("5s").Duration()
+	showJobFull          = showJob.Flag("full", "Shows all details of the job (like allocated machines and resource usage).").Bool()
+	showJobCount         = showJob.Flag("count", "Prints only the number of matching jobs.").Bool()
+	showJobSince         = showJob.Flag("since", "Shows only jobs submitted since that time (RFC 3339 or duration before now like 30m, 2h, 1d).").Default("").String()
+	showJobUntil         = showJob.Flag("until", "Shows only jobs submitted until that time (RFC 3339 or duration before now like 30m, 2h, 1d).").Default("").String()
+	showMachine          = show.Command("machine", "Information about compute hosts.")
+	showMachineName      = showMachine.Arg("name", "Name of machine (or \"all\" for all.").Default("all").String()
+	showMachineSortBy    = showMachine.Flag("sort-by", "Sorts the machines by \"load\", \"name\", or \"cores\".").Default("").String()
+	showMachineMaxLoad   = showMachine.Flag("max-load", "Shows only machines with a load lower or equal than the given value.").Default("-1").Float()
+	showMachineByCluster = showMachine.Flag("by-cluster", "Shows a summary of the machines of each cluster (for uc in inception mode).").Bool()
+	showQueue            = show.Command("queue", "Information about queues.")
+	showQueueName        = showQueue.Arg("name", "Name of queue to show.").Default("all").String()
+	showCategories       = show.Command("category", "Information about job categories.")
+	showCategoriesName   = showCategories.Arg("name", "Name of job category to show.").Default("all").String()
+	showSession          = show.Command("session", "Information about job sessions.")
+	showSessionName      = showSession.Arg("name", "Name of the job session to show.").Default("all").String()
+
+	run            = app.Command("run", "Submits an application to a cluster.")
+	runCommand     = run.Arg("command", "Command to submit.").Default("#nocommand#").String()
+	runArg         = run.Flag("arg", "Argument of the command (use \" when having spaces).").Default("").String()
+	runArgFile     = run.Flag("arg-file", "File with further arguments of the command (one per line, # starts a comment) appended after --arg.").Default("").String()
+	runName        = run.Flag("name", "Reference name of the command.").Default("").String()
+	runQueue       = run.Flag("queue", "Queue name for the job.").Default("").String()
+	runCategory    = run.Flag("category", "Job category / job class of the job.").Default("").String()
+	alg            = run.Flag("alg", "Automatic cluster selection when submitting jobs (\"rand\", \"prob\", \"load\", \"weighted\", \"slots\", \"affinity\")").Default("").String()
+	runAffinityKey = run.Flag("affinity-key", "Key mapped to the same cluster by --alg=affinity (default is the user name).").Default("").String()
+	fileUp         = run.Flag("upload", "Path to job which is uploaded before execution.").Default("").String()
+	runArray       = run.Flag("array", "Submits an array job with tasks begin:end:step (step is optional).").Default("").String()
+	runTemplate    = run.Flag("template-file", "JSON or YAML (.yaml/.yml) file with the job template. Given flags override its fields.").Default("").String()
+	runDryRun      = run.Flag("dry-run", "Prints the job template and the selected cluster without submitting the job.").Bool()
+	runMaxParallel = run.Flag("max-parallel", "Maximum amount of array job tasks running at the same time (0 is unlimited).").Default("0").Int()
+	runInteractive = run.Flag("interactive", "Prints the output of the job while it runs and exits with the exit code of the job.").Bool()
+
+	logs       = app.Command("logs", "Shows the output of a job.")
+	logsJobId  = logs.Arg("jobid", "Id of the job.").Required().String()
+	logsFollow = logs.Flag("follow", "Keeps printing new output until the job is finished.").Bool()
+
+	events     = app.Command("events", "Prints the job state changes of the cluster as they happen.")
+	eventsJob  = events.Flag("job", "Prints only the state changes of the job with that id.").Default("").String()
+	eventsUser = events.Flag("user", "Prints only the state changes of the jobs of a particular user (default is the current user, \"all\" selects all users).").Default("").String()
+
+	top         = app.Command("top", "Shows a periodically refreshed summary of the load, jobs, and slots of the cluster.")
+	topAll      = top.Flag("all", "Shows all configured clusters instead of the selected one.").Bool()
+	topInterval = top.Flag("interval", "Refresh interval.").Default("5s").Duration()
+	topCount    = top.Flag("count", "Amount of refreshes before exiting (0 runs until interrupted by Ctrl-C).").Default("0").Int()
+
+	report                = app.Command("report", "Reports about the usage of the cluster.")
+	reportAccounting      = report.Command("accounting", "CPU time and wallclock time of finished jobs per user.")
+	reportAccountingUser  = reportAccounting.Flag("user", "Shows only the usage of a particular user (default shows all users).").Default("all").String()
+	reportAccountingSince = reportAccounting.Flag("since", "Takes only jobs into account which finished within the given duration (like 720h, 0 for all).").Default("0").Duration()
 
 	runlocal        = app.Command("runlocal", "Runs a command as child of the proxy.")
 	runlocalCommand = runlocal.Arg("command", "Command to run.").Required().String()
 	runlocalArg     = runlocal.Flag("arg", "Argument of the command (use \" when having spaces.)").Default("").String()
 
 	// operations on job
-	terminate      = app.Command("terminate", "Terminate operation.")
-	terminateJob   = terminate.Command("job", "Terminates (ends) a job in a cluster.")
-	terminateJobId = terminateJob.Arg("jobid", "Id of the job to terminate.").Default("").String()
+	terminate         = app.Command("terminate", "Terminate operation.")
+	terminateJob      = terminate.Command("job", "Terminates (ends) a job in a cluster.")
+	terminateJobId    = terminateJob.Arg("jobid", "Id of the job to terminate.").Default("").String()
+	terminateJobAll   = terminateJob.Flag("all", "Terminates all unfinished jobs matching --state and --user instead of a single job.").Bool()
+	terminateJobState = terminateJob.Flag("state", "Terminates with --all only jobs in that state (r/q/h/s/R/Rh/all).").Default("all").String()
+	terminateJobUser  = terminateJob.Flag("user", "Terminates with --all only jobs of a particular user (default is the current user, \"all\" selects all users).").Default("").String()
+	terminateJobYes   = terminateJob.Flag("yes", "Terminates the jobs selected by --all without asking for confirmation.").Bool()
 
 	suspend      = app.Command("suspend", "Suspend operation.")
 	suspendJob   = suspend.Command("job", "Suspends (pauses) a job in a cluster.")
@@ -90,12 +138,24 @@ var (
 	fsDownFiles = fsDown.Arg("files", "Filenames to download from staging area.").Required().Strings()
 
 	// configuration
-	cfg     = app.Command("config", "Configuration of cluster proxies.")
-	cfgList = cfg.Command("list", "Lists all configured cluster proxies.")
+	cfg            = app.Command("config", "Configuration of cluster proxies.")
+	cfgList        = cfg.Command("list", "Lists all configured cluster proxies.")
+	cfgAdd         = cfg.Command("add", "Adds a cluster proxy to the configuration.")
+	cfgAddName     = cfgAdd.Flag("name", "Name to reference the cluster.").Required().String()
+	cfgAddAddress  = cfgAdd.Flag("address", "Address of the cluster proxy (like http://localhost:8888/).").Required().String()
+	cfgAddProtocol = cfgAdd.Flag("protocol", "Protocol version the proxy speaks.").Default("v1").String()
+	cfgAddNoCheck  = cfgAdd.Flag("no-check", "Adds the cluster without checking if the proxy is reachable.").Bool()
+	cfgRemove      = cfg.Command("remove", "Removes a cluster proxy from the configuration.")
+	cfgRemoveName  = cfgRemove.Flag("name", "Name of the cluster to remove.").Required().String()
+	cfgTest        = cfg.Command("test", "Tests the connection to all configured cluster proxies.")
+
+	versionCmd    = app.Command("version", "Shows the versions of uc and of the proxies and DRMS of connected clusters.")
+	versionCmdAll = versionCmd.Flag("all", "Shows all configured clusters instead of the selected one.").Bool()
 
 	// uc as proxy itself
 	incpt     = app.Command("inception", "Run uc as compatible proxy itself. Allows to create trees of clusters.")
 	incptPort = incpt.Arg("port", "Address to bind uc http server to.").Default(":8989").String()
+	incptAlg  = incpt.Flag("alg", "Cluster selection for forwarded job submissions (\"rand\", \"prob\", \"load\", \"weighted\", \"slots\", \"affinity\")").Default("").String()
 )
 
 func main() {
@@ -104,17 +164,42 @@ func main() {
 		arguments = append(arguments, "--help")
 	}
 
+	if handled, err := handleCompletion(arguments, os.Stdout); handled {
+		if err != nil {
+			fmt.Printf("Error: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	p := kingpin.MustParse(app.Parse(arguments))
 
 	if *verbose {
 		log.SetOutput(os.Stdout)
 	}
 
-	// read in configuration
-	ReadConfig()
+	// read in configuration (the first cluster is added to a new one)
+	if p == cfgAdd.FullCommand() {
+		ReadConfigOrEmpty()
+	} else {
+		ReadConfig()
+	}
 
 	// output can be produced in different formats
-	of := output.MakeOutputFormater(*outformat)
+	of, err := output.MakeOutputFormater(*outformat)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	// job states are colored only when a user looks at them
+	if !*noColor && os.Getenv("NO_COLOR") == "" && terminal.IsTerminal(int(os.Stdout.Fd())) {
+		output.EnableColors(of)
+	}
+
+	if *otp, err = ResolveOTP(*otp, *otpFile); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 
 	// read in one time password in case of yubikey
 	var yubi bool
@@ -126,13 +211,41 @@ func main() {
 	}
 
 	r := NewRequest(*certFile, *keyFile, otp)
+	if yubi {
+		// a yubikey OTP is accepted only once by a proxy, so a repeated
+		// request would always be rejected as replay
+		if *retries > 0 {
+			fmt.Println("Requests with yubikey OTP are not retried.")
+		}
+	} else {
+		r.SetRetries(*retries, *retryDelay)
+	}
+	r.SetTimeout(*timeout)
 
 	// based on cluster name or selection algorithm
 	// create the address to send requests
-	clusteraddress, clustername, err := r.SelectClusterAddress(*cluster, *alg)
+	queue, affinityKey := SelectionHints(*runTemplate, *runQueue, *runAffinityKey)
+	clusteraddress, clustername, err := r.SelectClusterAddress(*cluster, *alg, queue, affinityKey)
 	if err != nil {
-		fmt.Println(err.Error())
-		os.Exit(1)
+		// the configuration can be changed and the version of uc
+		// shown without a valid cluster
+		if !strings.HasPrefix(p, cfg.FullCommand()) && p != versionCmd.FullCommand() {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		err = nil
+	}
+
+	// job ids printed after automatically scheduled submissions
+	// contain the cluster of the job (jobid@cluster)
+	for _, jobid := range []*string{showJobId, logsJobId, eventsJob, terminateJobId, suspendJobId, resumeJobId} {
+		if *jobid == "" {
+			continue
+		}
+		if *jobid, clusteraddress, clustername, err = r.ResolveJobCluster(*jobid, *cluster, clusteraddress, clustername); err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
 	}
 
 	fs := staging.NewFilesystem(r.client)
@@ -141,43 +254,151 @@ func main() {
 	case showJob.FullCommand():
 		if showJobId != nil && *showJobId != "" {
 			log.Println("showJobId: ", *showJobId)
-			r.ShowJobDetails(clusteraddress, *showJobId, of)
+			if *showJobWatch {
+				err = r.WatchJobDetails(clusteraddress, *showJobId, *showJobInterval, of)
+			} else {
+				err = r.ShowJobDetails(clusteraddress, *showJobId, *showJobFull, of)
+			}
+		} else if *showJobWatch {
+			err = errors.New("--watch requires a job id")
+		} else if window, werr := ParseSubmissionWindow(*showJobSince, *showJobUntil); werr != nil {
+			err = werr
+		} else if *showJobCount {
+			err = r.ShowJobCount(clusteraddress, *showJobStateId, jobOwnerFilter(*showJobUser), window)
 		} else {
-			r.ShowJobs(clusteraddress, *showJobStateId, *showJobUser, of)
+			err = r.ShowJobs(clusteraddress, *showJobStateId, jobOwnerFilter(*showJobUser), window, of)
 		}
 	case cfgList.FullCommand():
 		listConfig(clusteraddress)
+	case cfgAdd.FullCommand():
+		err = addConfig(r, ClusterConfig{
+			Name:            *cfgAddName,
+			Address:         *cfgAddAddress,
+			ProtocolVersion: *cfgAddProtocol,
+		}, !*cfgAddNoCheck)
+	case cfgRemove.FullCommand():
+		err = removeConfig(*cfgRemoveName)
+	case cfgTest.FullCommand():
+		err = testConfig(r, os.Stdout)
 	case showMachine.FullCommand():
-		r.ShowMachines(clusteraddress, *showMachineName, of)
+		err = r.ShowMachines(clusteraddress, clustername, *showMachineName, MachineListOptions{SortBy: *showMachineSortBy, MaxLoad: *showMachineMaxLoad, ByCluster: *showMachineByCluster}, of)
 	case showQueue.FullCommand():
-		r.ShowQueues(clusteraddress, *showQueueName, of)
+		err = r.ShowQueues(clusteraddress, *showQueueName, of)
 	case showCategories.FullCommand():
-		r.ShowJobCategories(clusteraddress, "ubercluster", *showCategoriesName)
+		err = r.ShowJobCategories(clusteraddress, "ubercluster", *showCategoriesName, of)
 	case showSession.FullCommand():
-		r.ShowJobSessions(clusteraddress, *showSessionName)
+		err = r.ShowJobSessions(clusteraddress, *showSessionName, of)
 	case run.FullCommand():
+		var jt types.JobTemplate
+		if jt, err = runJobTemplate(r); err != nil {
+			break
+		}
+		if *runDryRun {
+			err = r.DryRunJob(clusteraddress, clustername, jt, *runArray, of)
+			break
+		}
 		if *fileUp != "" {
 			fs.FsUploadFile(*otp, clusteraddress, "ubercluster", *fileUp)
-			if yubi {
+			// the local proxy of a group uses its own secret
+			if yubi && !IsGroup(clustername) {
 				*otp = GetYubiKeyOrExit() // we need another one time password for submission
 			}
 		}
-		r.SubmitJob(clusteraddress, clustername, *runName, *runCommand, *runArg, *runQueue, *runCategory, *otp)
+		if *runInteractive {
+			if *runArray != "" {
+				err = fmt.Errorf("--interactive can't be used for array jobs")
+				break
+			}
+			var exitCode int
+			if exitCode, err = r.RunInteractive(clusteraddress, clustername, jt, *alg != "", os.Stdout); err == nil && exitCode != 0 {
+				os.Exit(exitCode)
+			}
+		} else if *runArray != "" {
+			err = r.SubmitArrayJob(clusteraddress, clustername, jt, *runArray, *runMaxParallel, *alg != "")
+		} else {
+			err = r.SubmitJob(clusteraddress, clustername, jt, *alg != "")
+		}
+	case top.FullCommand():
+		err = r.Top(r.topClusters(*topAll, clustername, clusteraddress), *topInterval, *topCount, os.Stdout)
+	case reportAccounting.FullCommand():
+		err = r.ShowAccounting(clusteraddress, *reportAccountingUser, *reportAccountingSince, of)
 	case runlocal.FullCommand():
-		r.RunLocalRequest(*otp, clusteraddress, *runlocalCommand, *runlocalArg)
+		err = r.RunLocalRequest(*otp, clusteraddress, *runlocalCommand, *runlocalArg)
+	case logs.FullCommand():
+		err = r.ShowJobOutput(clusteraddress, *logsJobId, *logsFollow, os.Stdout)
+	case events.FullCommand():
+		err = r.ShowJobEvents(clusteraddress, *eventsJob, jobOwnerFilter(*eventsUser), os.Stdout)
 	case terminateJob.FullCommand():
-		r.PerformOperation(clusteraddress, "ubercluster", "terminate", *terminateJobId)
+		if !*terminateJobAll {
+			err = r.PerformOperation(clusteraddress, "ubercluster", "terminate", *terminateJobId)
+		} else if *terminateJobId != "" {
+			err = errors.New("--all can't be used with a job id")
+		} else if *terminateJobYes {
+			err = r.TerminateJobs(clusteraddress, *terminateJobState, jobOwnerFilter(*terminateJobUser), func([]types.JobInfo) bool { return true }, os.Stdout)
+		} else if terminal.IsTerminal(int(os.Stdin.Fd())) {
+			err = r.TerminateJobs(clusteraddress, *terminateJobState, jobOwnerFilter(*terminateJobUser), confirmTermination(os.Stdin, os.Stdout), os.Stdout)
+		} else {
+			err = errors.New("--all requires --yes when not running interactively")
+		}
 	case suspendJob.FullCommand():
-		r.PerformOperation(clusteraddress, "ubercluster", "suspend", *suspendJobId)
+		err = r.PerformOperation(clusteraddress, "ubercluster", "suspend", *suspendJobId)
 	case resumeJob.FullCommand():
-		r.PerformOperation(clusteraddress, "ubercluster", "resume", *resumeJobId)
+		err = r.PerformOperation(clusteraddress, "ubercluster", "resume", *resumeJobId)
 	case fsLs.FullCommand():
 		fs.FsListFiles(*otp, clusteraddress, "ubercluster", of)
 	case fsUp.FullCommand():
 		fs.FsUploadFiles(*otp, clusteraddress, "ubercluster", *fsUpFiles, of)
 	case fsDown.FullCommand():
 		fs.FsDownloadFiles(*otp, clusteraddress, "ubercluster", *fsDownFiles, of)
+	case versionCmd.FullCommand():
+		err = r.ShowVersions(r.versionClusters(*versionCmdAll, clustername, clusteraddress), os.Stdout)
 	case incpt.FullCommand():
-		inceptionMode(*certFile, *keyFile, *otp, *incptPort)
+		inceptionMode(*certFile, *keyFile, *otp, *incptPort, *incptAlg)
+	}
+
+	// later calls use the stored versions without asking the proxies
+	if r.StoreNegotiatedVersions(&config) {
+		if werr := WriteConfig(config); werr != nil {
+			log.Println("Can't store the negotiated protocol versions: ", werr)
+		}
+	}
+
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// runJobTemplate creates the job template of the run command out of
+// the job template file and the command line flags.
+func runJobTemplate(r *Request) (types.JobTemplate, error) {
+	var jt types.JobTemplate
+	if *runTemplate == "" {
+		jt = r.CreateJobTemplate(*runName, *runCommand, *runArg, *runQueue, *runCategory)
+	} else {
+		var err error
+		if jt, err = LoadJobTemplate(*runTemplate); err != nil {
+			return jt, err
+		}
+		command := *runCommand
+		if command == "#nocommand#" {
+			command = ""
+		}
+		jt = OverrideJobTemplate(jt, *runName, command, *runArg, *runQueue, *runCategory)
+	}
+	if *runArgFile != "" {
+		args, err := LoadArgFile(*runArgFile)
+		if err != nil {
+			return jt, err
+		}
+		jt.Args = append(jt.Args, args...)
+	}
+	// lets a uc in inception mode select the cluster of the user
+	if jt.AffinityKey == "" {
+		jt.AffinityKey = *runAffinityKey
+	}
+	if jt.AffinityKey == "" {
+		jt.AffinityKey = currentUser()
 	}
+	return jt, nil
 }
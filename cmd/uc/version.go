@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/dgruber/ubercluster/pkg/proxy"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Version is the build version of uc. It can be set at build time
+// with -ldflags "-X main.Version=1.0".
+var Version = "dev"
+
+// ClusterVersion contains the versions reported by the proxy of a
+// cluster.
+type ClusterVersion struct {
+	Name            string
+	ProxyVersion    string // "unknown" for proxies without /version
+	ProtocolVersion string // protocol version used by uc
+	DRMSName        string
+	DRMSVersion     string
+	Err             error
+}
+
+// requestString requests a JSON encoded string from the proxy.
+func (r *Request) requestString(request string) (string, error) {
+	log.Println("Requesting:" + request)
+	resp, err := r.get(request)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if err := responseError(resp); err != nil {
+		return "", err
+	}
+	var value string
+	if err := json.NewDecoder(resp.Body).Decode(&value); err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+// requestProxyVersion requests the build version of the proxy from
+// its /version endpoint. Older proxies which don't offer the endpoint
+// are reported as "unknown".
+func (r *Request) requestProxyVersion(address string) (string, error) {
+	request := fmt.Sprintf("%s/version", address)
+	log.Println("Requesting:" + request)
+	resp, err := r.get(request)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "unknown", nil
+	}
+	if err := responseError(resp); err != nil {
+		return "", err
+	}
+	var vi proxy.VersionInfo
+	if err := json.NewDecoder(resp.Body).Decode(&vi); err != nil {
+		return "", err
+	}
+	return vi.Version, nil
+}
+
+// GetClusterVersion requests the proxy version and the name and version
+// of the DRMS of a cluster. The address of the cluster must contain the
+// protocol version.
+func (r *Request) GetClusterVersion(name, clusteraddress string) ClusterVersion {
+	cv := ClusterVersion{Name: name}
+	clusteraddress = strings.TrimSuffix(clusteraddress, "/")
+	address := clusteraddress
+	if i := strings.LastIndex(clusteraddress, "/"); i >= 0 {
+		address, cv.ProtocolVersion = clusteraddress[:i], clusteraddress[i+1:]
+	}
+	if cv.ProxyVersion, cv.Err = r.requestProxyVersion(address); cv.Err != nil {
+		return cv
+	}
+	if cv.DRMSName, cv.Err = r.requestString(fmt.Sprintf("%s/msession/drmsname", clusteraddress)); cv.Err != nil {
+		return cv
+	}
+	cv.DRMSVersion, cv.Err = r.requestString(fmt.Sprintf("%s/msession/drmsversion", clusteraddress))
+	return cv
+}
+
+// GetClusterVersions requests the versions of all given clusters in
+// parallel. The addresses of the clusters must contain the protocol
+// version.
+func (r *Request) GetClusterVersions(clusters []ClusterConfig) []ClusterVersion {
+	versions := make([]ClusterVersion, len(clusters))
+	var wg sync.WaitGroup
+	wg.Add(len(clusters))
+	for i := range clusters {
+		go func(i int) {
+			defer wg.Done()
+			versions[i] = r.GetClusterVersion(clusters[i].Name, clusters[i].Address)
+		}(i)
+	}
+	wg.Wait()
+	return versions
+}
+
+// PrintVersions writes the version of uc, the protocol versions it
+// speaks, and the versions of the clusters as table.
+func PrintVersions(w io.Writer, versions []ClusterVersion) {
+	protocols := make([]string, 0, len(proxy.SupportedProtocolVersions))
+	for _, v := range proxy.SupportedProtocolVersions {
+		protocols = append(protocols, string(v))
+	}
+	fmt.Fprintf(w, "uc version:        %s\n", Version)
+	fmt.Fprintf(w, "protocol versions: %s\n\n", strings.Join(protocols, ", "))
+	fmt.Fprintf(w, "%-20s %-10s %-8s %-20s %s\n", "CLUSTER", "PROXY", "PROTOCOL", "DRMS", "DRMS VERSION")
+	for _, cv := range versions {
+		if cv.Err != nil {
+			fmt.Fprintf(w, "%-20s error: %s\n", cv.Name, cv.Err)
+			continue
+		}
+		fmt.Fprintf(w, "%-20s %-10s %-8s %-20s %s\n", cv.Name, cv.ProxyVersion, cv.ProtocolVersion, cv.DRMSName, cv.DRMSVersion)
+	}
+}
+
+// ShowVersions prints the versions of uc and of the given clusters.
+// An error is returned when any of the clusters did not answer.
+func (r *Request) ShowVersions(clusters []ClusterConfig, w io.Writer) error {
+	versions := r.GetClusterVersions(clusters)
+	PrintVersions(w, versions)
+	failed := 0
+	for _, cv := range versions {
+		if cv.Err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d clusters did not report their versions", failed, len(versions))
+	}
+	return nil
+}
+
+// versionClusters returns the clusters shown by "uc version": either
+// all configured clusters (the clusters uc forwards to in inception
+// mode) with their negotiated protocol versions or just the selected
+// one.
+func (r *Request) versionClusters(all bool, clustername, clusteraddress string) []ClusterConfig {
+	if !all {
+		if clusteraddress == "" {
+			return nil
+		}
+		return []ClusterConfig{{Name: clustername, Address: clusteraddress}}
+	}
+	clusters := make([]ClusterConfig, 0, len(config.Cluster))
+	for _, c := range config.Cluster {
+		if c.Address == "" {
+			continue
+		}
+		clusters = append(clusters, ClusterConfig{Name: c.Name, Address: r.requestAddress(c)})
+	}
+	return clusters
+}
@@ -0,0 +1,88 @@
+package main_test
+
+import (
+	. "github.com/dgruber/ubercluster/cmd/uc"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+)
+
+var _ = Describe("Version", func() {
+
+	var otp string
+	var ts, old *httptest.Server
+
+	BeforeEach(func() {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"version":"1.2.0","protocolVersion":"v1"}`))
+		})
+		mux.HandleFunc("/v1/msession/drmsname", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`"Univa Grid Engine"`))
+		})
+		mux.HandleFunc("/v1/msession/drmsversion", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`"8.2.1"`))
+		})
+		ts = httptest.NewServer(mux)
+
+		// proxy without /version endpoint
+		oldMux := http.NewServeMux()
+		oldMux.HandleFunc("/v1/msession/drmsname", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`"processes"`))
+		})
+		oldMux.HandleFunc("/v1/msession/drmsversion", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`"0.1"`))
+		})
+		old = httptest.NewServer(oldMux)
+	})
+
+	AfterEach(func() {
+		ts.Close()
+		old.Close()
+	})
+
+	It("should request the proxy and DRMS versions of a cluster", func() {
+		cv := NewRequest("", "", &otp).GetClusterVersion("c1", ts.URL+"/v1")
+		Ω(cv.Err).Should(BeNil())
+		Ω(cv.Name).Should(Equal("c1"))
+		Ω(cv.ProxyVersion).Should(Equal("1.2.0"))
+		Ω(cv.ProtocolVersion).Should(Equal("v1"))
+		Ω(cv.DRMSName).Should(Equal("Univa Grid Engine"))
+		Ω(cv.DRMSVersion).Should(Equal("8.2.1"))
+	})
+
+	It("should report the proxy version of older proxies as unknown", func() {
+		cv := NewRequest("", "", &otp).GetClusterVersion("old", old.URL+"/v1")
+		Ω(cv.Err).Should(BeNil())
+		Ω(cv.ProxyVersion).Should(Equal("unknown"))
+		Ω(cv.DRMSName).Should(Equal("processes"))
+	})
+
+	It("should print the versions of uc and of all clusters", func() {
+		clusters := []ClusterConfig{
+			{Name: "c1", Address: ts.URL + "/v1"},
+			{Name: "old", Address: old.URL + "/v1"},
+			{Name: "unreachable", Address: "http://127.0.0.1:1/v1"},
+		}
+		var out bytes.Buffer
+		err := NewRequest("", "", &otp).ShowVersions(clusters, &out)
+		Ω(err).ShouldNot(BeNil())
+		Ω(err.Error()).Should(Equal("1 of 3 clusters did not report their versions"))
+		Ω(out.String()).Should(ContainSubstring("uc version:        " + Version))
+		Ω(out.String()).Should(ContainSubstring("protocol versions: v1"))
+		Ω(out.String()).Should(MatchRegexp(`c1 +1\.2\.0 +v1 +Univa Grid Engine +8\.2\.1`))
+		Ω(out.String()).Should(MatchRegexp(`old +unknown +v1 +processes +0\.1`))
+		Ω(out.String()).Should(ContainSubstring("unreachable          error:"))
+	})
+
+	It("should print only the version of uc without clusters", func() {
+		var out bytes.Buffer
+		Ω(NewRequest("", "", &otp).ShowVersions(nil, &out)).Should(BeNil())
+		Ω(out.String()).Should(ContainSubstring("uc version:"))
+	})
+
+})
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dgruber/ubercluster/pkg/http_helper"
+	"github.com/dgruber/ubercluster/pkg/proxy"
+	"github.com/dgruber/ubercluster/pkg/types"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// versionsRequestTimeout limits the time to wait for the protocol
+// versions of a proxy so that an unreachable proxy doesn't delay the
+// following request by a whole client timeout.
+var versionsRequestTimeout = 5 * time.Second
+
+// versionCache stores the negotiated protocol version of each
+// cluster so that the proxy is asked only once.
+type versionCache struct {
+	sync.Mutex
+	versions   map[string]string // cluster address -> protocol version
+	negotiated map[string]string // cluster address -> version agreed on with the proxy
+}
+
+// remember stores the protocol version used for the cluster. Versions
+// agreed on with the proxy are also kept for StoreNegotiatedVersions.
+func (vc *versionCache) remember(address, version string, negotiated bool) {
+	if vc == nil {
+		return
+	}
+	vc.Lock()
+	defer vc.Unlock()
+	vc.versions[address] = version
+	if negotiated {
+		vc.negotiated[address] = version
+	}
+}
+
+// requestProtocolVersions requests the protocol versions the proxy of
+// the cluster supports from its /versions endpoint.
+func (r *Request) requestProtocolVersions(cc ClusterConfig) ([]types.ProtocolVersion, error) {
+	ctx, cancel := context.WithTimeout(r.context(), versionsRequestTimeout)
+	defer cancel()
+	request := fmt.Sprintf("%s/versions", strings.TrimSuffix(cc.Address, "/"))
+	resp, err := http_helper.UberGetWithContext(ctx, r.client, "", request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := responseError(resp); err != nil {
+		return nil, err
+	}
+	var vi proxy.VersionsInfo
+	if err := json.NewDecoder(resp.Body).Decode(&vi); err != nil {
+		return nil, err
+	}
+	return vi.Versions, nil
+}
+
+// NegotiateProtocolVersion returns the highest protocol version which
+// is supported by uc and the proxy of the cluster. When the proxy does
+// not offer the /versions endpoint (older proxies) or there is no
+// common version the configured ProtocolVersion is used. The result
+// is cached per cluster. The cache is not locked while the proxy is
+// asked, so that slow proxies don't delay requests to other clusters.
+// A version negotiated by an earlier uc call (NegotiatedVersion of the
+// configuration) is used without asking the proxy again.
+func (r *Request) NegotiateProtocolVersion(cc ClusterConfig) string {
+	if cc.NegotiatedVersion != "" {
+		return cc.NegotiatedVersion
+	}
+	if r.versions != nil {
+		r.versions.Lock()
+		version, cached := r.versions.versions[cc.Address]
+		r.versions.Unlock()
+		if cached {
+			return version
+		}
+	}
+	version := cc.ProtocolVersion
+	if version == "" {
+		version = string(types.ProtocolV1)
+	}
+	negotiated := false
+	if supported, err := r.requestProtocolVersions(cc); err != nil {
+		log.Printf("Using configured protocol version %s for %s: %s\n", version, cc.Name, err)
+	} else if common, ok := types.HighestCommonProtocolVersion(proxy.SupportedProtocolVersions, supported); ok {
+		version = string(common)
+		negotiated = true
+	} else {
+		log.Printf("No common protocol version with %s (%v), using %s\n", cc.Name, supported, version)
+	}
+	r.versions.remember(cc.Address, version, negotiated)
+	return version
+}
+
+// StoreNegotiatedVersions sets the NegotiatedVersion of the clusters
+// of the configuration for which a protocol version was agreed on with
+// the proxy. It returns true when the configuration was changed and
+// should be written, so that later uc calls don't ask the proxies.
+func (r *Request) StoreNegotiatedVersions(c *Config) bool {
+	if r.versions == nil {
+		return false
+	}
+	r.versions.Lock()
+	defer r.versions.Unlock()
+	changed := false
+	for i := range c.Cluster {
+		version, ok := r.versions.negotiated[c.Cluster[i].Address]
+		if ok && c.Cluster[i].NegotiatedVersion != version {
+			c.Cluster[i].NegotiatedVersion = version
+			changed = true
+		}
+	}
+	return changed
+}
+
+// requestAddress returns the address of the proxy of the cluster
+// including the negotiated protocol version.
+func (r *Request) requestAddress(cc ClusterConfig) string {
+	cc.ProtocolVersion = r.NegotiateProtocolVersion(cc)
+	return versionedAddress(cc)
+}
+
+// ClusterAddress searches the cluster in the configuration like
+// GetClusterAddress and returns its address with the negotiated
+// protocol version.
+func (r *Request) ClusterAddress(cluster string) (string, string, error) {
+	for _, cc := range config.Cluster {
+		if cc.Name == cluster && cc.Address != "" {
+			clusteraddress := r.requestAddress(cc)
+			log.Println("Chosen cluster: ", cluster, clusteraddress)
+			return clusteraddress, cluster, nil
+		}
+	}
+	text := fmt.Sprintf("Cluster %s not found in configuration", cluster)
+	fmt.Printf("%s\n", text)
+	return "", "", errors.New(text)
+}
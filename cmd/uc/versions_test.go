@@ -0,0 +1,115 @@
+package main_test
+
+import (
+	. "github.com/dgruber/ubercluster/cmd/uc"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+var _ = Describe("Versions", func() {
+
+	var otp string
+
+	It("should select the highest common protocol version and cache it", func() {
+		requests := 0
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Ω(r.URL.Path).Should(Equal("/versions"))
+			requests++
+			w.Write([]byte(`{"versions":["v1","v7"]}`))
+		}))
+		defer ts.Close()
+
+		r := NewRequest("", "", &otp)
+		cc := ClusterConfig{Name: "c1", Address: ts.URL + "/", ProtocolVersion: "v0"}
+		Ω(r.NegotiateProtocolVersion(cc)).Should(Equal("v1"))
+		Ω(r.NegotiateProtocolVersion(cc)).Should(Equal("v1"))
+		Ω(requests).Should(Equal(1))
+	})
+
+	It("should use a stored negotiated version without asking the proxy", func() {
+		requests := 0
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.Write([]byte(`{"versions":["v1"]}`))
+		}))
+		defer ts.Close()
+
+		r := NewRequest("", "", &otp)
+		cc := ClusterConfig{Name: "c1", Address: ts.URL, ProtocolVersion: "v0", NegotiatedVersion: "v1"}
+		Ω(r.NegotiateProtocolVersion(cc)).Should(Equal("v1"))
+		Ω(requests).Should(Equal(0))
+	})
+
+	It("should store only the versions agreed on with the proxies", func() {
+		agreeing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"versions":["v1"]}`))
+		}))
+		defer agreeing.Close()
+		old := httptest.NewServer(http.NotFoundHandler())
+		defer old.Close()
+
+		conf := Config{Cluster: []ClusterConfig{
+			{Name: "new", Address: agreeing.URL, ProtocolVersion: "v0"},
+			{Name: "old", Address: old.URL, ProtocolVersion: "v1"},
+		}}
+		r := NewRequest("", "", &otp)
+		Ω(r.StoreNegotiatedVersions(&conf)).Should(BeFalse())
+		for _, cc := range conf.Cluster {
+			r.NegotiateProtocolVersion(cc)
+		}
+		Ω(r.StoreNegotiatedVersions(&conf)).Should(BeTrue())
+		Ω(conf.Cluster[0].NegotiatedVersion).Should(Equal("v1"))
+		Ω(conf.Cluster[1].NegotiatedVersion).Should(Equal(""))
+		Ω(r.StoreNegotiatedVersions(&conf)).Should(BeFalse())
+	})
+
+	It("should fall back to the configured version for proxies without /versions", func() {
+		ts := httptest.NewServer(http.NotFoundHandler())
+		defer ts.Close()
+
+		r := NewRequest("", "", &otp)
+		cc := ClusterConfig{Name: "c1", Address: ts.URL, ProtocolVersion: "v1"}
+		Ω(r.NegotiateProtocolVersion(cc)).Should(Equal("v1"))
+	})
+
+	It("should fall back to the configured version when there is no common version", func() {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"versions":["v5"]}`))
+		}))
+		defer ts.Close()
+
+		r := NewRequest("", "", &otp)
+		cc := ClusterConfig{Name: "c1", Address: ts.URL, ProtocolVersion: "v1"}
+		Ω(r.NegotiateProtocolVersion(cc)).Should(Equal("v1"))
+	})
+
+	It("should not wait for a slow proxy when negotiating with another one", func() {
+		release := make(chan struct{})
+		slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+			w.Write([]byte(`{"versions":["v1"]}`))
+		}))
+		defer slow.Close()
+		defer close(release)
+		fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"versions":["v1"]}`))
+		}))
+		defer fast.Close()
+
+		r := NewRequest("", "", &otp)
+		go r.NegotiateProtocolVersion(ClusterConfig{Name: "slow", Address: slow.URL})
+		negotiated := make(chan string, 1)
+		go func() {
+			// give the slow negotiation time to start
+			time.Sleep(50 * time.Millisecond)
+			negotiated <- r.NegotiateProtocolVersion(ClusterConfig{Name: "fast", Address: fast.URL})
+		}()
+		Eventually(negotiated, time.Second).Should(Receive(Equal("v1")))
+	})
+
+})
@@ -17,13 +17,45 @@
 package http_helper
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"strings"
+	"time"
 )
 
+// ClientConfig contains the settings of an http client which
+// is used for accessing ubercluster proxies.
+type ClientConfig struct {
+	Timeout            time.Duration // overall timeout of a request (0 means no timeout)
+	InsecureSkipVerify bool          // accept self-signed proxy certificates
+	TLSConfig          *tls.Config   // TLS settings (like client certs), can be nil
+}
+
+// NewClient creates an http client based on the given configuration.
+// The client requests gzip compressed responses from the proxies and
+// decompresses them transparently.
+func NewClient(cc ClientConfig) *http.Client {
+	tlsConfig := &tls.Config{}
+	if cc.TLSConfig != nil {
+		tlsConfig = cc.TLSConfig.Clone()
+	}
+	if cc.InsecureSkipVerify {
+		tlsConfig.InsecureSkipVerify = true
+	}
+	tr := &http.Transport{
+		Proxy:              http.ProxyFromEnvironment,
+		MaxIdleConns:       10,
+		IdleConnTimeout:    30 * time.Second,
+		DisableCompression: false, // sends Accept-Encoding: gzip
+		TLSClientConfig:    tlsConfig,
+	}
+	return &http.Client{Transport: tr, Timeout: cc.Timeout}
+}
+
 func addOneTimePassword(request, otp string) string {
 	if otp != "" {
 		// adding http secret key (OTP)
@@ -36,19 +68,45 @@ func addOneTimePassword(request, otp string) string {
 	return request
 }
 
-// uberGet makes an http GET request. Depending on the uc
+// UberGet makes an http GET request. Depending on the uc
 // configuration (currently cli param) it adds a one time
-// password.
+// password. The request gets a new request id.
 func UberGet(client *http.Client, otp, request string) (resp *http.Response, err error) {
+	return UberGetWithContext(context.Background(), client, otp, request)
+}
+
+// UberGetWithContext makes an http GET request like UberGet
+// which is aborted when the context is canceled or its
+// deadline is exceeded. The request id of the context is
+// sent in the X-Uber-Request-Id header.
+func UberGetWithContext(ctx context.Context, client *http.Client, otp, request string) (resp *http.Response, err error) {
 	newRequest := addOneTimePassword(request, otp)
-	log.Println("New request: ", newRequest)
-	return client.Get(newRequest)
+	req, err := http.NewRequest("GET", newRequest, nil)
+	if err != nil {
+		return nil, err
+	}
+	id := setRequestID(ctx, req)
+	log.Printf("New request [%s]: %s\n", id, newRequest)
+	return client.Do(req.WithContext(ctx))
 }
 
-// uberPost is a http.Post replacement which adds otp requests
-// and possibly others depending on the configuration.
+// UberPost is a http.Post replacement which adds otp requests
+// and possibly others depending on the configuration. The
+// request gets a new request id.
 func UberPost(client *http.Client, otp, url string, bodyType string, body io.Reader) (resp *http.Response, err error) {
+	return UberPostWithContext(context.Background(), client, otp, url, bodyType, body)
+}
+
+// UberPostWithContext makes an http POST request like UberPost
+// which sends the request id of the context.
+func UberPostWithContext(ctx context.Context, client *http.Client, otp, url string, bodyType string, body io.Reader) (resp *http.Response, err error) {
 	newUrl := addOneTimePassword(url, otp)
-	log.Println("New POST: ", newUrl)
-	return client.Post(newUrl, bodyType, body)
+	req, err := http.NewRequest("POST", newUrl, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", bodyType)
+	id := setRequestID(ctx, req)
+	log.Printf("New POST [%s]: %s\n", id, newUrl)
+	return client.Do(req.WithContext(ctx))
 }
@@ -7,8 +7,12 @@ import (
 	. "github.com/onsi/gomega"
 
 	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"time"
 )
 
 var _ = Describe("HttpHelper", func() {
@@ -57,6 +61,93 @@ var _ = Describe("HttpHelper", func() {
 			Ω(otp).Should(Equal(""))
 		})
 
+		It("should abort a GET request when the context deadline is exceeded", func() {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(200 * time.Millisecond)
+			}))
+			defer ts.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			_, err := UberGetWithContext(ctx, &http.Client{}, otpRequest, ts.URL)
+			Ω(err).ShouldNot(BeNil())
+		})
+
+		It("should create a client with a timeout", func() {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(200 * time.Millisecond)
+			}))
+			defer ts.Close()
+
+			client := NewClient(ClientConfig{Timeout: 10 * time.Millisecond})
+			_, err := UberGet(client, "", ts.URL)
+			Ω(err).ShouldNot(BeNil())
+		})
+
+		It("should create a client accepting self-signed certificates", func() {
+			ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			defer ts.Close()
+
+			_, err := UberGet(NewClient(ClientConfig{}), "", ts.URL)
+			Ω(err).ShouldNot(BeNil())
+			_, err = UberGet(NewClient(ClientConfig{InsecureSkipVerify: true}), "", ts.URL)
+			Ω(err).Should(BeNil())
+		})
+
+		It("should request and decompress gzip encoded responses", func() {
+			var acceptEncoding string
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				acceptEncoding = r.Header.Get("Accept-Encoding")
+				w.Header().Set("Content-Encoding", "gzip")
+				gz := gzip.NewWriter(w)
+				gz.Write([]byte("compressed"))
+				gz.Close()
+			}))
+			defer ts.Close()
+
+			resp, err := UberGet(NewClient(ClientConfig{}), "", ts.URL)
+			Ω(err).Should(BeNil())
+			defer resp.Body.Close()
+			Ω(acceptEncoding).Should(Equal("gzip"))
+			Ω(resp.Uncompressed).Should(BeTrue())
+			body, err := ioutil.ReadAll(resp.Body)
+			Ω(err).Should(BeNil())
+			Ω(string(body)).Should(Equal("compressed"))
+		})
+
+		It("should send the request id of the context", func() {
+			var ids []string
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ids = append(ids, r.Header.Get(RequestIDHeader))
+			}))
+			defer ts.Close()
+
+			ctx := WithRequestID(context.Background(), "abc123")
+			Ω(RequestIDFromContext(ctx)).Should(Equal("abc123"))
+			_, err := UberGetWithContext(ctx, &http.Client{}, "", ts.URL)
+			Ω(err).Should(BeNil())
+			_, err = UberPostWithContext(ctx, &http.Client{}, "", ts.URL, "application/json", bytes.NewReader(nil))
+			Ω(err).Should(BeNil())
+			Ω(ids).Should(Equal([]string{"abc123", "abc123"}))
+		})
+
+		It("should create a new request id when there is none", func() {
+			var ids []string
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ids = append(ids, r.Header.Get(RequestIDHeader))
+			}))
+			defer ts.Close()
+
+			_, err := UberGet(&http.Client{}, "", ts.URL)
+			Ω(err).Should(BeNil())
+			_, err = UberPost(&http.Client{}, "", ts.URL, "", bytes.NewReader(nil))
+			Ω(err).Should(BeNil())
+			Ω(ids).Should(HaveLen(2))
+			Ω(ids[0]).ShouldNot(BeEmpty())
+			Ω(ids[1]).ShouldNot(BeEmpty())
+			Ω(ids[0]).ShouldNot(Equal(ids[1]))
+		})
+
 	})
 
 })
@@ -0,0 +1,49 @@
+package http_helper
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// RequestIDHeader is the http header which carries the id of a
+// logical request through all proxies it is forwarded to (like
+// from uc over a uc in inception mode to the cluster proxy).
+const RequestIDHeader = "X-Uber-Request-Id"
+
+type requestIDKey struct{}
+
+// NewRequestID returns a new random request id.
+func NewRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// WithRequestID returns a copy of the context which carries the
+// request id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request id stored in the context
+// or an empty string if there is none.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// setRequestID sets the request id header of the request to the
+// id of the context. Without an id in the context a new one is
+// created. The id is returned.
+func setRequestID(ctx context.Context, req *http.Request) string {
+	id := RequestIDFromContext(ctx)
+	if id == "" {
+		id = NewRequestID()
+	}
+	req.Header.Set(RequestIDHeader, id)
+	return id
+}
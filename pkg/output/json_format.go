@@ -47,6 +47,53 @@ func (jf *JSONFormat) PrintJobDetails(ji types.JobInfo) {
 	jf.marshalJSON(ji)
 }
 
+// PrintFullJobDetails writes the job info like PrintJobDetails
+// since the JSON output always contains all fields.
+func (jf *JSONFormat) PrintFullJobDetails(ji types.JobInfo) {
+	jf.marshalJSON(ji)
+}
+
 func (jf *JSONFormat) PrintMachine(m types.Machine) {
 	jf.marshalJSON(m)
 }
+
+func (jf *JSONFormat) PrintQueue(q types.Queue) {
+	jf.marshalJSON(q)
+}
+
+// PrintMachineSummaries writes the per cluster summaries as JSON array.
+func (jf *JSONFormat) PrintMachineSummaries(summaries []types.MachineSummary) {
+	if summaries == nil {
+		summaries = []types.MachineSummary{}
+	}
+	jf.marshalJSON(summaries)
+}
+
+// PrintAccounting writes the per user accounting summaries as JSON array.
+func (jf *JSONFormat) PrintAccounting(summaries []types.AccountingSummary) {
+	if summaries == nil {
+		summaries = []types.AccountingSummary{}
+	}
+	jf.marshalJSON(summaries)
+}
+
+// PrintJobSessions writes the job session names as JSON array.
+func (jf *JSONFormat) PrintJobSessions(sessions []string) {
+	if sessions == nil {
+		sessions = []string{}
+	}
+	jf.marshalJSON(sessions)
+}
+
+// PrintJobCategories writes the job categories as JSON array.
+func (jf *JSONFormat) PrintJobCategories(categories []string) {
+	if categories == nil {
+		categories = []string{}
+	}
+	jf.marshalJSON(categories)
+}
+
+// PrintJobTemplate writes the set fields of the job template as JSON.
+func (jf *JSONFormat) PrintJobTemplate(jt types.JobTemplate) {
+	jf.marshalJSON(jt)
+}
@@ -21,38 +21,65 @@ import (
 	"github.com/dgruber/ubercluster/pkg/types"
 	"log"
 	"os"
+	"strings"
 )
 
+// Formats are the names of the supported output formats.
+var Formats = []string{"default", "json", "xml", "wide"}
+
 // OutputFormater is an interface which defines
 // all required functions needed for the uc client
 // to print out the results.
 type OutputFormater interface {
 	PrintFiles(fs []types.FileInfo) // output format of "uc ls"
 	PrintJobDetails(ji types.JobInfo)
+	PrintFullJobDetails(ji types.JobInfo) // output format of "uc show job <id> --full"
 	PrintMachine(m types.Machine)
+	PrintQueue(q types.Queue)                               // output format of "uc show queue"
+	PrintJobSessions(sessions []string)                     // output format of "uc show session"
+	PrintJobCategories(categories []string)                 // output format of "uc show category"
+	PrintJobTemplate(jt types.JobTemplate)                  // output format of "uc run --dry-run"
+	PrintMachineSummaries(summaries []types.MachineSummary) // output format of "uc show machine --by-cluster"
+	PrintAccounting(summaries []types.AccountingSummary)    // output format of "uc report accounting"
 }
 
 // MakeOutputFormater creates an output formater depending
-// on the chosen output format.
-func MakeOutputFormater(format string) OutputFormater {
+// on the chosen output format. An unknown format results in
+// an error listing the supported formats.
+func MakeOutputFormater(format string) (OutputFormater, error) {
 	switch format {
 	case "default":
 		log.Println("Standard output format selected.")
 		var sf StandardFormat
 		sf.output = os.Stdout
-		return &sf
+		return &sf, nil
 	case "JSON", "json":
 		log.Println("JSON output format selected.")
 		var jf JSONFormat
 		jf.output = os.Stdout
-		return &jf
+		return &jf, nil
 	case "XML", "xml":
 		log.Println("XML output format selected.")
 		var jf XMLFormat
 		jf.output = os.Stdout
-		return &jf
+		return &jf, nil
+	case "wide":
+		log.Println("Wide output format selected.")
+		var wf WideFormat
+		wf.output = os.Stdout
+		return &wf, nil
+	}
+	return nil, fmt.Errorf("unknown format: %s (supported: %s)", format, strings.Join(Formats, ", "))
+}
+
+// EnableColors turns on coloring of job states for the formaters
+// which write text for a terminal (default and wide). Machine readable
+// formats like JSON and XML are never colored.
+func EnableColors(of OutputFormater) {
+	switch f := of.(type) {
+	case *StandardFormat:
+		f.colors = true
+	case *WideFormat:
+		f.colors = true
 	}
-	fmt.Println("Error selecting output format module.")
-	os.Exit(1)
-	return nil
 }
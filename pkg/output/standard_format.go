@@ -17,16 +17,32 @@
 package output
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/dgruber/ubercluster/pkg/types"
 	"io"
 	"os"
+	"sort"
 	"time"
 )
 
 // StandardFormat defines how information is published.
 type StandardFormat struct {
 	output io.Writer // defines where to print
+	colors bool      // job states are printed in color
+}
+
+// ansiReset resets the terminal color.
+const ansiReset = "\x1b[0m"
+
+// colored returns the text in the color of the job state when
+// colors are enabled.
+func (sf *StandardFormat) colored(state types.JobState, text string) string {
+	color := state.ANSIColor()
+	if !sf.colors || color == "" {
+		return text
+	}
+	return color + text + ansiReset
 }
 
 // PrintFiles writes information about each file in one
@@ -62,10 +78,11 @@ func makeDate(date time.Time) string {
 }
 
 // emulateQstat prints DRMAA2 JobInfo information on
-// stdout in a similar way than qstat -j (same keyes)
-func emulateQstat(ji types.JobInfo) {
+// stdout in a similar way than qstat -j (same keyes).
+// The state is passed already formatted.
+func emulateQstat(ji types.JobInfo, state string) {
 	fmt.Fprintf(os.Stdout, "job_number:\t\t%s\n", ji.Id)
-	fmt.Fprintf(os.Stdout, "state:\t\t\t%s\n", ji.State)
+	fmt.Fprintf(os.Stdout, "state:\t\t\t%s\n", state)
 	fmt.Fprintf(os.Stdout, "submission_time:\t%s\n", makeDate(ji.SubmissionTime))
 	fmt.Fprintf(os.Stdout, "dispatch_time:\t\t%s\n", makeDate(ji.DispatchTime))
 	fmt.Fprintf(os.Stdout, "finish_time:\t\t%s\n", makeDate(ji.FinishTime))
@@ -91,17 +108,126 @@ func emulateQstat(ji types.JobInfo) {
 	fmt.Fprintf(os.Stdout, "exit_status:\t\t%d\n", ji.ExitStatus)
 }
 
-// emulateQhost prints machine information in SGE style out
+// emulateQhost prints machine information in SGE style out. The
+// last column shows whether the machine is available, draining, or
+// unavailable (including the reason).
 func emulateQhost(m types.Machine) {
-	fmt.Fprintf(os.Stdout, "%s %s %d %d %d %f %d %d\n", m.Name, m.Architecture.String(), m.Sockets,
-		m.Sockets*m.CoresPerSocket, m.Sockets*m.CoresPerSocket*m.ThreadsPerCore, m.Load,
-		m.PhysicalMemory, m.VirtualMemory)
+	fmt.Fprintf(os.Stdout, "%s %s %d %d %d %f %s %s %s\n", m.Name, m.Architecture.String(), m.Sockets,
+		m.Cores(), m.Cores()*m.ThreadsPerCore, m.Load,
+		m.PhysicalMemoryHuman(), m.VirtualMemoryHuman(), m.Status())
 }
 
 func (sf *StandardFormat) PrintJobDetails(ji types.JobInfo) {
-	emulateQstat(ji)
+	emulateQstat(ji, sf.colored(ji.State, ji.State.String()))
+}
+
+// valueOrDash returns "-" for empty strings.
+func valueOrDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// PrintFullJobDetails writes all fields of the job info including
+// the machines the job runs on (one per line) and its resource usage.
+func (sf *StandardFormat) PrintFullJobDetails(ji types.JobInfo) {
+	fmt.Fprintf(sf.output, "job_number:\t\t%s\n", ji.Id)
+	fmt.Fprintf(sf.output, "state:\t\t\t%s\n", sf.colored(ji.State, ji.State.String()))
+	fmt.Fprintf(sf.output, "sub_state:\t\t%s\n", valueOrDash(ji.SubState))
+	fmt.Fprintf(sf.output, "annotation:\t\t%s\n", valueOrDash(ji.Annotation))
+	fmt.Fprintf(sf.output, "owner:\t\t\t%s\n", valueOrDash(ji.JobOwner))
+	fmt.Fprintf(sf.output, "submission_machine:\t%s\n", valueOrDash(ji.SubmissionMachine))
+	fmt.Fprintf(sf.output, "queue:\t\t\t%s\n", valueOrDash(ji.QueueName))
+	fmt.Fprintf(sf.output, "submission_time:\t%s\n", makeDate(ji.SubmissionTime))
+	fmt.Fprintf(sf.output, "dispatch_time:\t\t%s\n", makeDate(ji.DispatchTime))
+	fmt.Fprintf(sf.output, "finish_time:\t\t%s\n", makeDate(ji.FinishTime))
+	fmt.Fprintf(sf.output, "slots:\t\t\t%d\n", ji.Slots)
+	fmt.Fprintf(sf.output, "allocated_machines:\t")
+	if len(ji.AllocatedMachines) == 0 {
+		fmt.Fprintf(sf.output, "NONE\n")
+	} else {
+		fmt.Fprintf(sf.output, "%d\n", len(ji.AllocatedMachines))
+		for _, machine := range ji.AllocatedMachines {
+			fmt.Fprintf(sf.output, "\t\t\t%s\n", machine)
+		}
+	}
+	fmt.Fprintf(sf.output, "exit_status:\t\t%d\n", ji.ExitStatus)
+	fmt.Fprintf(sf.output, "terminating_signal:\t%s\n", valueOrDash(ji.TerminatingSignal))
+	fmt.Fprintf(sf.output, "wallclock_time:\t\t%s\n", ji.WallclockTime)
+	fmt.Fprintf(sf.output, "cpu_time:\t\t%ds\n", ji.CPUTime)
+	fmt.Fprintf(sf.output, "queue_duration:\t\t%s\n", ji.QueueDuration())
+	fmt.Fprintf(sf.output, "run_duration:\t\t%s\n", ji.RunDuration())
+	keys := make([]string, 0, len(ji.ExtensionList))
+	for key := range ji.ExtensionList {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(sf.output, "%s:\t\t\t%s\n", key, ji.ExtensionList[key])
+	}
 }
 
 func (sf *StandardFormat) PrintMachine(m types.Machine) {
 	emulateQhost(m)
 }
+
+// PrintQueue writes the name, state, and slot utilization of the
+// queue in one line.
+func (sf *StandardFormat) PrintQueue(q types.Queue) {
+	state := q.State
+	if state == "" {
+		state = "-"
+	}
+	fmt.Fprintf(sf.output, "%-20s %-10s %6d/%-6d %5.1f%%\n", q.Name, state,
+		q.UsedSlots, q.TotalSlots, q.Utilization()*100)
+}
+
+// PrintMachineSummaries writes a table with the resources of each cluster.
+func (sf *StandardFormat) PrintMachineSummaries(summaries []types.MachineSummary) {
+	fmt.Fprintf(sf.output, "%-20s %8s %9s %8s %12s %12s\n", "CLUSTER", "MACHINES", "AVAILABLE", "CORES", "MEMORY", "VIRTUAL")
+	for i := range summaries {
+		fmt.Fprintf(sf.output, "%-20s %8d %9d %8d %12s %12s\n", summaries[i].Cluster,
+			summaries[i].Machines, summaries[i].Available, summaries[i].Cores,
+			summaries[i].PhysicalMemoryHuman(), summaries[i].VirtualMemoryHuman())
+	}
+}
+
+// PrintAccounting writes a table with the resource usage of the
+// finished jobs of each user.
+func (sf *StandardFormat) PrintAccounting(summaries []types.AccountingSummary) {
+	fmt.Fprintf(sf.output, "%-20s %8s %8s %16s %16s\n", "USER", "JOBS", "FAILED", "CPU", "WALLCLOCK")
+	for i := range summaries {
+		fmt.Fprintf(sf.output, "%-20s %8d %8d %16s %16s\n", summaries[i].JobOwner,
+			summaries[i].Jobs, summaries[i].Failed,
+			time.Duration(summaries[i].CPUTime)*time.Second,
+			summaries[i].WallclockTime.Round(time.Second))
+	}
+}
+
+func (sf *StandardFormat) printLines(lines []string) {
+	for _, line := range lines {
+		fmt.Fprintln(sf.output, line)
+	}
+}
+
+// PrintJobSessions writes each job session name in one line.
+func (sf *StandardFormat) PrintJobSessions(sessions []string) {
+	sf.printLines(sessions)
+}
+
+// PrintJobCategories writes each job category in one line.
+func (sf *StandardFormat) PrintJobCategories(categories []string) {
+	sf.printLines(categories)
+}
+
+// PrintJobTemplate writes the set fields of the job template as
+// indented JSON.
+func (sf *StandardFormat) PrintJobTemplate(jt types.JobTemplate) {
+	out, err := json.MarshalIndent(jt, "", "  ")
+	if err != nil {
+		fmt.Fprintln(sf.output, err)
+		return
+	}
+	fmt.Fprintln(sf.output, string(out))
+}
@@ -0,0 +1,56 @@
+package output
+
+import (
+	"fmt"
+	"github.com/dgruber/ubercluster/pkg/types"
+	"time"
+)
+
+// WideFormat prints jobs in one line each like qstat without -f.
+// All other information is printed like in the StandardFormat.
+type WideFormat struct {
+	StandardFormat
+	headerPrinted bool // the column header is printed only before the first job
+}
+
+// wideJobFormat defines the columns of a job line.
+const wideJobFormat = "%-16s %-16s %-5s %-19s %-20s %5s\n"
+
+// wideStateWidth is the width of the state column.
+const wideStateWidth = 5
+
+// wideDate returns the time in the format of the qstat "submit/start at"
+// column or "-" when the time is not set.
+func wideDate(date time.Time) string {
+	if date.IsZero() || date.Unix() <= 0 {
+		return "-"
+	}
+	return date.Format("2006-01-02 15:04:05")
+}
+
+// PrintJobDetails writes the job in one line. Before the first job
+// a header with the column names is written. For running and finished
+// jobs the dispatch time is shown, otherwise the submission time.
+func (wf *WideFormat) PrintJobDetails(ji types.JobInfo) {
+	if !wf.headerPrinted {
+		fmt.Fprintf(wf.output, wideJobFormat, "JOBID", "USER", "STATE", "SUBMIT/START AT", "QUEUE", "SLOTS")
+		wf.headerPrinted = true
+	}
+	at := ji.SubmissionTime
+	if !ji.DispatchTime.IsZero() && ji.DispatchTime.Unix() > 0 {
+		at = ji.DispatchTime
+	}
+	// the state is padded before it is colored since the escape
+	// sequences would count for the column width
+	state := fmt.Sprintf("%-*s", wideStateWidth, valueOrDash(ji.State.ShortCode()))
+	fmt.Fprintf(wf.output, wideJobFormat, ji.Id, valueOrDash(ji.JobOwner),
+		wf.colored(ji.State, state), wideDate(at), valueOrDash(ji.QueueName),
+		fmt.Sprintf("%d", ji.Slots))
+}
+
+// IsTableFormat returns true when the formater prints jobs as table
+// rows which must not be separated by empty lines.
+func IsTableFormat(of OutputFormater) bool {
+	_, ok := of.(*WideFormat)
+	return ok
+}
@@ -22,6 +22,8 @@ import (
 	"github.com/dgruber/ubercluster/pkg/types"
 	"io"
 	"log"
+	"sort"
+	"time"
 )
 
 func (xf *XMLFormat) marshalXML(data interface{}) {
@@ -47,6 +49,155 @@ func (xf *XMLFormat) PrintJobDetails(ji types.JobInfo) {
 	xf.marshalXML(ji)
 }
 
+// PrintFullJobDetails writes the job info like PrintJobDetails
+// since the XML output always contains all fields.
+func (xf *XMLFormat) PrintFullJobDetails(ji types.JobInfo) {
+	xf.marshalXML(ji)
+}
+
 func (xf *XMLFormat) PrintMachine(m types.Machine) {
 	xf.marshalXML(m)
 }
+
+func (xf *XMLFormat) PrintQueue(q types.Queue) {
+	xf.marshalXML(q)
+}
+
+type xmlMachineSummaries struct {
+	XMLName   xml.Name               `xml:"clusters"`
+	Summaries []types.MachineSummary `xml:"cluster"`
+}
+
+func (xf *XMLFormat) PrintMachineSummaries(summaries []types.MachineSummary) {
+	xf.marshalXML(xmlMachineSummaries{Summaries: summaries})
+}
+
+type xmlAccounting struct {
+	XMLName   xml.Name                  `xml:"accounting"`
+	Summaries []types.AccountingSummary `xml:"user"`
+}
+
+func (xf *XMLFormat) PrintAccounting(summaries []types.AccountingSummary) {
+	xf.marshalXML(xmlAccounting{Summaries: summaries})
+}
+
+type xmlJobSessions struct {
+	XMLName  xml.Name `xml:"sessions"`
+	Sessions []string `xml:"session"`
+}
+
+type xmlJobCategories struct {
+	XMLName    xml.Name `xml:"categories"`
+	Categories []string `xml:"category"`
+}
+
+func (xf *XMLFormat) PrintJobSessions(sessions []string) {
+	xf.marshalXML(xmlJobSessions{Sessions: sessions})
+}
+
+func (xf *XMLFormat) PrintJobCategories(categories []string) {
+	xf.marshalXML(xmlJobCategories{Categories: categories})
+}
+
+type xmlEntry struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type xmlMap struct {
+	Entries []xmlEntry `xml:"entry"`
+}
+
+// xmlEntries converts a map into a list sorted by keys since
+// encoding/xml does not support maps.
+func xmlEntries(m map[string]string) *xmlMap {
+	if len(m) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	entries := make([]xmlEntry, 0, len(m))
+	for _, key := range keys {
+		entries = append(entries, xmlEntry{Key: key, Value: m[key]})
+	}
+	return &xmlMap{Entries: entries}
+}
+
+type xmlJobTemplate struct {
+	XMLName           xml.Name   `xml:"jobTemplate"`
+	RemoteCommand     string     `xml:"remoteCommand,omitempty"`
+	Args              []string   `xml:"arg,omitempty"`
+	SubmitAsHold      bool       `xml:"submitAsHold,omitempty"`
+	ReRunnable        bool       `xml:"reRunnable,omitempty"`
+	JobEnvironment    *xmlMap    `xml:"jobEnvironment,omitempty"`
+	WorkingDirectory  string     `xml:"workingDirectory,omitempty"`
+	JobCategory       string     `xml:"jobCategory,omitempty"`
+	Email             []string   `xml:"email,omitempty"`
+	EmailOnStarted    bool       `xml:"emailOnStarted,omitempty"`
+	EmailOnTerminated bool       `xml:"emailOnTerminated,omitempty"`
+	JobName           string     `xml:"jobName,omitempty"`
+	InputPath         string     `xml:"inputPath,omitempty"`
+	OutputPath        string     `xml:"outputPath,omitempty"`
+	ErrorPath         string     `xml:"errorPath,omitempty"`
+	JoinFiles         bool       `xml:"joinFiles,omitempty"`
+	ReservationId     string     `xml:"reservationId,omitempty"`
+	QueueName         string     `xml:"queueName,omitempty"`
+	MinSlots          int64      `xml:"minSlots"`
+	MaxSlots          int64      `xml:"maxSlots"`
+	Priority          int64      `xml:"priority"`
+	CandidateMachines []string   `xml:"candidateMachine,omitempty"`
+	MinPhysMemory     int64      `xml:"minPhysMemory"`
+	MachineOs         string     `xml:"machineOs,omitempty"`
+	MachineArch       string     `xml:"machineArch,omitempty"`
+	StartTime         *time.Time `xml:"startTime,omitempty"`
+	DeadlineTime      *time.Time `xml:"deadlineTime,omitempty"`
+	StageInFiles      *xmlMap    `xml:"stageInFiles,omitempty"`
+	StageOutFiles     *xmlMap    `xml:"stageOutFiles,omitempty"`
+	ResourceLimits    *xmlMap    `xml:"resourceLimits,omitempty"`
+	AccountingId      string     `xml:"accountingString,omitempty"`
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func (xf *XMLFormat) PrintJobTemplate(jt types.JobTemplate) {
+	xf.marshalXML(xmlJobTemplate{
+		RemoteCommand:     jt.RemoteCommand,
+		Args:              jt.Args,
+		SubmitAsHold:      jt.SubmitAsHold,
+		ReRunnable:        jt.ReRunnable,
+		JobEnvironment:    xmlEntries(jt.JobEnvironment),
+		WorkingDirectory:  jt.WorkingDirectory,
+		JobCategory:       jt.JobCategory,
+		Email:             jt.Email,
+		EmailOnStarted:    jt.EmailOnStarted,
+		EmailOnTerminated: jt.EmailOnTerminated,
+		JobName:           jt.JobName,
+		InputPath:         jt.InputPath,
+		OutputPath:        jt.OutputPath,
+		ErrorPath:         jt.ErrorPath,
+		JoinFiles:         jt.JoinFiles,
+		ReservationId:     jt.ReservationId,
+		QueueName:         jt.QueueName,
+		MinSlots:          jt.MinSlots,
+		MaxSlots:          jt.MaxSlots,
+		Priority:          jt.Priority,
+		CandidateMachines: jt.CandidateMachines,
+		MinPhysMemory:     jt.MinPhysMemory,
+		MachineOs:         jt.MachineOs,
+		MachineArch:       jt.MachineArch,
+		StartTime:         optionalTime(jt.StartTime),
+		DeadlineTime:      optionalTime(jt.DeadlineTime),
+		StageInFiles:      xmlEntries(jt.StageInFiles),
+		StageOutFiles:     xmlEntries(jt.StageOutFiles),
+		ResourceLimits:    xmlEntries(jt.ResourceLimits),
+		AccountingId:      jt.AccountingId,
+	})
+}
@@ -0,0 +1,165 @@
+package persistency
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dgruber/ubercluster/pkg/types"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+// ErrNotFound is returned when a requested element was not saved.
+var ErrNotFound = errors.New("not found")
+
+var (
+	jobTemplateBucket = []byte("jobtemplates")
+	jobInfoBucket     = []byte("jobinfos")
+	jobSessionBucket  = []byte("jobsessions")
+)
+
+// BoltPersistency implements the PersistencyImplementer interface
+// with an embedded BoltDB key-value store. Job templates and job infos
+// are stored as JSON by job id, job sessions by their name. It can be
+// used by multiple goroutines at the same time.
+type BoltPersistency struct {
+	db *bolt.DB
+}
+
+// NewBoltPersistency opens (or creates) the database file. The file
+// can be opened by one process only; when it is locked by another
+// process an error is returned after a second.
+func NewBoltPersistency(path string) (*BoltPersistency, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("can't open persistency database %s: %s", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{jobTemplateBucket, jobInfoBucket, jobSessionBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltPersistency{db: db}, nil
+}
+
+// Close closes the database.
+func (bp *BoltPersistency) Close() error {
+	return bp.db.Close()
+}
+
+// put stores the value as JSON under the key of the bucket.
+func (bp *BoltPersistency) put(bucket []byte, key string, v interface{}) error {
+	if key == "" {
+		return errors.New("empty key")
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return bp.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).Put([]byte(key), data)
+	})
+}
+
+// get decodes the value stored under the key of the bucket.
+func (bp *BoltPersistency) get(bucket []byte, key string, v interface{}) error {
+	return bp.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(bucket).Get([]byte(key))
+		if data == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(data, v)
+	})
+}
+
+// SaveJobTemplate stores the job template of a submitted job.
+func (bp *BoltPersistency) SaveJobTemplate(jobid string, jt types.JobTemplate) error {
+	return bp.put(jobTemplateBucket, jobid, jt)
+}
+
+// LoadJobTemplate returns the saved job template of the job.
+func (bp *BoltPersistency) LoadJobTemplate(jobid string) (types.JobTemplate, error) {
+	var jt types.JobTemplate
+	err := bp.get(jobTemplateBucket, jobid, &jt)
+	return jt, err
+}
+
+// SaveJobInfo stores the job info, replacing the previously saved one.
+func (bp *BoltPersistency) SaveJobInfo(jobid string, ji types.JobInfo) error {
+	return bp.put(jobInfoBucket, jobid, ji)
+}
+
+// LoadJobInfo returns the saved job info of the job.
+func (bp *BoltPersistency) LoadJobInfo(jobid string) (types.JobInfo, error) {
+	var ji types.JobInfo
+	err := bp.get(jobInfoBucket, jobid, &ji)
+	return ji, err
+}
+
+// LoadJobInfos returns all saved job infos by job id.
+func (bp *BoltPersistency) LoadJobInfos() (map[string]types.JobInfo, error) {
+	jobinfos := make(map[string]types.JobInfo)
+	err := bp.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(jobInfoBucket).ForEach(func(k, v []byte) error {
+			var ji types.JobInfo
+			if err := json.Unmarshal(v, &ji); err != nil {
+				return fmt.Errorf("can't read job info of job %s: %s", k, err)
+			}
+			jobinfos[string(k)] = ji
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return jobinfos, nil
+}
+
+// DeleteJob removes the job template and the job info of the job.
+func (bp *BoltPersistency) DeleteJob(jobid string) error {
+	return bp.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(jobTemplateBucket).Delete([]byte(jobid)); err != nil {
+			return err
+		}
+		return tx.Bucket(jobInfoBucket).Delete([]byte(jobid))
+	})
+}
+
+// SaveJobSession stores a job session with its contact string.
+func (bp *BoltPersistency) SaveJobSession(name, contact string) error {
+	return bp.put(jobSessionBucket, name, contact)
+}
+
+// JobSessions returns the contact strings of all saved job sessions
+// by their names.
+func (bp *BoltPersistency) JobSessions() (map[string]string, error) {
+	sessions := make(map[string]string)
+	err := bp.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(jobSessionBucket).ForEach(func(k, v []byte) error {
+			var contact string
+			if err := json.Unmarshal(v, &contact); err != nil {
+				return fmt.Errorf("can't read job session %s: %s", k, err)
+			}
+			sessions[string(k)] = contact
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// DeleteJobSession removes the job session.
+func (bp *BoltPersistency) DeleteJobSession(name string) error {
+	return bp.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(jobSessionBucket).Delete([]byte(name))
+	})
+}
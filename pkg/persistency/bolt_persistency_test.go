@@ -0,0 +1,114 @@
+package persistency_test
+
+import (
+	. "github.com/dgruber/ubercluster/pkg/persistency"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"fmt"
+	"github.com/dgruber/ubercluster/pkg/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var _ = Describe("BoltPersistency", func() {
+
+	var (
+		dir string
+		bp  *BoltPersistency
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "boltpersistency")
+		Ω(err).Should(BeNil())
+		bp, err = NewBoltPersistency(filepath.Join(dir, "state.db"))
+		Ω(err).Should(BeNil())
+	})
+
+	AfterEach(func() {
+		bp.Close()
+		os.RemoveAll(dir)
+	})
+
+	It("should load the saved jobs after the database is opened again", func() {
+		jt := types.JobTemplate{RemoteCommand: "/bin/sleep", Args: []string{"10"}, JobName: "sleeper"}
+		ji := types.JobInfo{Id: "13", State: types.Running, JobOwner: "user",
+			SubmissionTime: time.Unix(64000000, 0).UTC()}
+		ji.ExtensionList = map[string]string{"pid": "4711"}
+		Ω(bp.SaveJobTemplate("13", jt)).Should(BeNil())
+		Ω(bp.SaveJobInfo("13", ji)).Should(BeNil())
+		Ω(bp.SaveJobSession("session", "contact")).Should(BeNil())
+		Ω(bp.Close()).Should(BeNil())
+
+		var err error
+		bp, err = NewBoltPersistency(filepath.Join(dir, "state.db"))
+		Ω(err).Should(BeNil())
+		loadedTemplate, err := bp.LoadJobTemplate("13")
+		Ω(err).Should(BeNil())
+		Ω(loadedTemplate.RemoteCommand).Should(Equal(jt.RemoteCommand))
+		Ω(loadedTemplate.Args).Should(Equal(jt.Args))
+		Ω(loadedTemplate.JobName).Should(Equal(jt.JobName))
+		loadedInfo, err := bp.LoadJobInfo("13")
+		Ω(err).Should(BeNil())
+		Ω(loadedInfo).Should(Equal(ji))
+		jobinfos, err := bp.LoadJobInfos()
+		Ω(err).Should(BeNil())
+		Ω(jobinfos).Should(Equal(map[string]types.JobInfo{"13": ji}))
+		sessions, err := bp.JobSessions()
+		Ω(err).Should(BeNil())
+		Ω(sessions).Should(Equal(map[string]string{"session": "contact"}))
+	})
+
+	It("should replace, delete, and report missing elements", func() {
+		Ω(bp.SaveJobInfo("1", types.JobInfo{Id: "1", State: types.Running})).Should(BeNil())
+		Ω(bp.SaveJobInfo("1", types.JobInfo{Id: "1", State: types.Done})).Should(BeNil())
+		ji, err := bp.LoadJobInfo("1")
+		Ω(err).Should(BeNil())
+		Ω(ji.State).Should(Equal(types.Done))
+
+		Ω(bp.DeleteJob("1")).Should(BeNil())
+		_, err = bp.LoadJobInfo("1")
+		Ω(err).Should(Equal(ErrNotFound))
+		_, err = bp.LoadJobTemplate("1")
+		Ω(err).Should(Equal(ErrNotFound))
+
+		Ω(bp.SaveJobSession("session", "contact")).Should(BeNil())
+		Ω(bp.DeleteJobSession("session")).Should(BeNil())
+		sessions, err := bp.JobSessions()
+		Ω(err).Should(BeNil())
+		Ω(sessions).Should(BeEmpty())
+
+		Ω(bp.SaveJobInfo("", types.JobInfo{})).ShouldNot(BeNil())
+	})
+
+	It("should save jobs from multiple goroutines at the same time", func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				jobid := fmt.Sprintf("%d", i)
+				for _, state := range []types.JobState{types.Queued, types.Running, types.Done} {
+					Ω(bp.SaveJobInfo(jobid, types.JobInfo{Id: jobid, State: state})).Should(BeNil())
+					_, err := bp.LoadJobInfos()
+					Ω(err).Should(BeNil())
+				}
+			}(i)
+		}
+		wg.Wait()
+		jobinfos, err := bp.LoadJobInfos()
+		Ω(err).Should(BeNil())
+		Ω(jobinfos).Should(HaveLen(20))
+		for jobid, ji := range jobinfos {
+			Ω(ji.Id).Should(Equal(jobid))
+			Ω(ji.State).Should(Equal(types.Done))
+		}
+	})
+
+})
@@ -20,3 +20,11 @@ type PersistencyImplementer interface {
 	// job is reaped from the DRM:
 	SaveJobInfo(jobid string, jinfo types.JobInfo) error
 }
+
+// JobInfoStore is implemented by persistency implementations which can
+// read back the saved job infos (like BoltPersistency) so that jobs can
+// be taken over after a restart.
+type JobInfoStore interface {
+	PersistencyImplementer
+	LoadJobInfos() (map[string]types.JobInfo, error)
+}
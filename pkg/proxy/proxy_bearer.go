@@ -0,0 +1,395 @@
+package proxy
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BearerVerifier checks the token of an "Authorization: Bearer" header
+// and returns an error when it is not valid.
+type BearerVerifier func(token string) error
+
+// jwksRefreshInterval limits how often the JSON Web Key Set is fetched
+// again when a token is signed by an unknown key.
+var jwksRefreshInterval = time.Minute
+
+// jwtLeeway is the allowed clock skew when checking the validity
+// period of a JSON Web Token.
+const jwtLeeway = 30 * time.Second
+
+// NewBearerVerifier returns the verifier for the bearer tokens configured
+// in the SecConfig or nil when bearer tokens are not configured. Tokens
+// are accepted when they match the static BearerToken or when they are
+// JSON Web Tokens (RS256 or ES256) signed by a key of the JWKSURL which
+// are issued for the JWTAudience (and by the JWTIssuer when set).
+func NewBearerVerifier(sc SecConfig) BearerVerifier {
+	var verifiers []BearerVerifier
+	if sc.BearerToken != "" {
+		verifiers = append(verifiers, staticTokenVerifier(sc.BearerToken))
+	}
+	if sc.JWKSURL != "" {
+		jv := &jwtVerifier{
+			url:      sc.JWKSURL,
+			audience: sc.JWTAudience,
+			issuer:   sc.JWTIssuer,
+			client:   &http.Client{Timeout: 10 * time.Second},
+		}
+		verifiers = append(verifiers, jv.verify)
+	}
+	if len(verifiers) == 0 {
+		return nil
+	}
+	return func(token string) error {
+		var err error
+		for _, verify := range verifiers {
+			if err = verify(token); err == nil {
+				return nil
+			}
+		}
+		return err
+	}
+}
+
+// MakeBearerTokenHandler protects an http handler by a bearer token.
+// Requests with an "Authorization: Bearer" header are passed to f when
+// the token is valid. Requests without bearer token are passed to the
+// fallback handler (like the one time password verification) or are
+// rejected when there is no fallback.
+func MakeBearerTokenHandler(verify BearerVerifier, fallback, f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if len(auth) < 7 || !strings.EqualFold(auth[:7], "Bearer ") {
+			if fallback != nil {
+				fallback(w, r)
+				return
+			}
+			logRequest(r, "Unauthorized access without bearer token by ", r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "authorization failed", http.StatusUnauthorized)
+			return
+		}
+		if err := verify(strings.TrimSpace(auth[7:])); err != nil {
+			logRequestf(r, "Unauthorized access by %s: %s\n", r.RemoteAddr, err)
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			http.Error(w, "authorization failed", http.StatusUnauthorized)
+			return
+		}
+		f(w, r)
+	}
+}
+
+// staticTokenVerifier accepts only the given token.
+func staticTokenVerifier(secret string) BearerVerifier {
+	return func(token string) error {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+			return errors.New("invalid bearer token")
+		}
+		return nil
+	}
+}
+
+// jwtVerifier validates JSON Web Tokens with the public keys of a JSON
+// Web Key Set which is fetched from an URL.
+type jwtVerifier struct {
+	url      string
+	audience string // expected audience (all tokens are rejected when empty)
+	issuer   string // expected issuer (not checked when empty)
+	client   *http.Client
+
+	mutex    sync.RWMutex
+	keys     map[string]crypto.PublicKey // keys by key id
+	fetched  time.Time
+	fetching chan struct{} // closed when the running fetch is done
+}
+
+// jwk is a JSON Web Key (RFC 7517) with RSA or EC public key parameters.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// decodeBigInt decodes a base64url encoded big-endian number.
+func decodeBigInt(value string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// publicKey returns the public key of the JSON Web Key. Keys which are
+// not used for signatures or have an unsupported type return nil.
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	if k.Use != "" && k.Use != "sig" {
+		return nil, nil
+	}
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, nil
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	}
+	return nil, nil
+}
+
+// fetchKeys requests the JSON Web Key Set and returns its signature keys.
+func (v *jwtVerifier) fetchKeys() (map[string]crypto.PublicKey, error) {
+	resp, err := v.client.Get(v.url)
+	if err != nil {
+		return nil, fmt.Errorf("can't fetch JSON Web Key Set: %s", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("can't fetch JSON Web Key Set: %s", resp.Status)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("invalid JSON Web Key Set: %s", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON Web Key %s: %s", k.Kid, err)
+		}
+		if key != nil {
+			keys[k.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+// lookupKey returns the key with the given id. Tokens without key id
+// can be verified when the key set contains only one key.
+func lookupKey(keys map[string]crypto.PublicKey, kid string) (crypto.PublicKey, bool) {
+	if key, ok := keys[kid]; ok {
+		return key, true
+	}
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// cachedKey returns the key with the given id from the fetched key set.
+func (v *jwtVerifier) cachedKey(kid string) (crypto.PublicKey, error) {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	if key, ok := lookupKey(v.keys, kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+// key returns the public key with the given id. The key set is fetched
+// again for unknown keys (since keys are rotated) but not more often
+// than the jwksRefreshInterval. The fetch is done without holding the
+// lock so that tokens signed by known keys are verified meanwhile;
+// concurrent requests for unknown keys wait for the running fetch.
+func (v *jwtVerifier) key(kid string) (crypto.PublicKey, error) {
+	if key, err := v.cachedKey(kid); err == nil {
+		return key, nil
+	}
+	v.mutex.Lock()
+	if key, ok := lookupKey(v.keys, kid); ok {
+		v.mutex.Unlock()
+		return key, nil
+	}
+	if done := v.fetching; done != nil {
+		v.mutex.Unlock()
+		<-done
+		return v.cachedKey(kid)
+	}
+	if !v.fetched.IsZero() && time.Since(v.fetched) < jwksRefreshInterval {
+		v.mutex.Unlock()
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	v.fetched = time.Now()
+	done := make(chan struct{})
+	v.fetching = done
+	v.mutex.Unlock()
+
+	keys, err := v.fetchKeys()
+
+	v.mutex.Lock()
+	if err == nil {
+		v.keys = keys
+	}
+	v.fetching = nil
+	close(done)
+	v.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return v.cachedKey(kid)
+}
+
+// audience is the "aud" claim which is either a string or a list.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = audience(list)
+	return nil
+}
+
+// jwtClaims are the registered claims of a token which are checked.
+type jwtClaims struct {
+	Expires   int64    `json:"exp"`
+	NotBefore int64    `json:"nbf"`
+	Audience  audience `json:"aud"`
+	Issuer    string   `json:"iss"`
+}
+
+// verify checks the signature, the validity period, the audience, and
+// the issuer of a JSON Web Token. Tokens without expiration time are
+// rejected.
+func (v *jwtVerifier) verify(token string) error {
+	if v.audience == "" {
+		return errors.New("no audience configured for JSON Web Tokens")
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errors.New("bearer token is not a JSON Web Token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return fmt.Errorf("invalid token header: %s", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return fmt.Errorf("invalid token signature: %s", err)
+	}
+	key, err := v.key(header.Kid)
+	if err != nil {
+		return err
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return err
+	}
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return fmt.Errorf("invalid token claims: %s", err)
+	}
+	now := time.Now()
+	if claims.Expires == 0 {
+		return errors.New("token has no expiration time")
+	}
+	if now.Add(-jwtLeeway).After(time.Unix(claims.Expires, 0)) {
+		return errors.New("token is expired")
+	}
+	if claims.NotBefore != 0 && now.Add(jwtLeeway).Before(time.Unix(claims.NotBefore, 0)) {
+		return errors.New("token is not valid yet")
+	}
+	if !containsString(claims.Audience, v.audience) {
+		return fmt.Errorf("token is not issued for audience %s", v.audience)
+	}
+	if v.issuer != "" && claims.Issuer != v.issuer {
+		return fmt.Errorf("token is not issued by %s", v.issuer)
+	}
+	return nil
+}
+
+// decodeSegment decodes a base64url encoded JSON part of a token.
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// verifySignature checks the signature of the signed part of a token
+// for the supported algorithms RS256 and ES256.
+func verifySignature(alg string, key crypto.PublicKey, signed string, signature []byte) error {
+	hash := sha256.Sum256([]byte(signed))
+	switch alg {
+	case "RS256":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("token algorithm RS256 does not match the key")
+		}
+		if err := rsa.VerifyPKCS1v15(rsaKey, crypto.SHA256, hash[:], signature); err != nil {
+			return errors.New("invalid token signature")
+		}
+		return nil
+	case "ES256":
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("token algorithm ES256 does not match the key")
+		}
+		if len(signature) != 64 {
+			return errors.New("invalid token signature")
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(ecKey, hash[:], r, s) {
+			return errors.New("invalid token signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported token algorithm %q", alg)
+}
+
+// containsString returns true if the list contains the value.
+func containsString(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package proxy
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestKeyLookupIsNotBlockedByFetch(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fetches int32
+	requested := make(chan struct{}, 2)
+	release := make(chan struct{})
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		requested <- struct{}{}
+		<-release
+		w.Write([]byte(`{"keys":[]}`))
+	}))
+	defer jwks.Close()
+
+	v := &jwtVerifier{
+		url:    jwks.URL,
+		client: &http.Client{},
+		keys:   map[string]crypto.PublicKey{"known": &rsaKey.PublicKey},
+	}
+
+	unknown := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := v.key("unknown")
+			unknown <- err
+		}()
+	}
+	<-requested
+
+	known := make(chan error)
+	go func() {
+		_, err := v.key("known")
+		known <- err
+	}()
+	select {
+	case err := <-known:
+		if err != nil {
+			t.Errorf("expected known key but got %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		close(release)
+		t.Fatal("lookup of known key is blocked by fetching the key set")
+	}
+
+	close(release)
+	for i := 0; i < 2; i++ {
+		if err := <-unknown; err == nil {
+			t.Errorf("expected error for unknown key")
+		}
+	}
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Errorf("expected key set to be fetched once but was fetched %d times", n)
+	}
+}
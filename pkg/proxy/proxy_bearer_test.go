@@ -0,0 +1,179 @@
+package proxy_test
+
+import (
+	. "github.com/dgruber/ubercluster/pkg/proxy"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/dgruber/ubercluster/pkg/persistency"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+// b64 encodes data like the segments of a JSON Web Token.
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// signedToken creates a JSON Web Token with the given claims.
+func signedToken(alg, kid string, claims map[string]interface{}, sign func(hash []byte) []byte) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := b64(header) + "." + b64(payload)
+	hash := sha256.Sum256([]byte(signed))
+	return signed + "." + b64(sign(hash[:]))
+}
+
+var _ = Describe("ProxyBearer", func() {
+
+	var ps persistency.DummyPersistency
+
+	request := func(sc SecConfig, authorization, otp string) int {
+		router := NewProxyRouter(&fakeProxy{drmsName: "fake"}, sc, &ps)
+		url := "/v1/msession/drmsname"
+		if otp != "" {
+			url += "?otp=" + otp
+		}
+		req := httptest.NewRequest("GET", url, nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	Context("static token", func() {
+
+		It("should accept the configured bearer token", func() {
+			sc := SecConfig{BearerToken: "s3cret"}
+			Ω(request(sc, "Bearer s3cret", "")).Should(Equal(http.StatusOK))
+			Ω(request(sc, "bearer s3cret", "")).Should(Equal(http.StatusOK))
+			Ω(request(sc, "Bearer wrong", "")).Should(Equal(http.StatusUnauthorized))
+			Ω(request(sc, "", "")).Should(Equal(http.StatusUnauthorized))
+		})
+
+		It("should accept the one time password besides the bearer token", func() {
+			sc := SecConfig{BearerToken: "s3cret", OTP: "otp"}
+			Ω(request(sc, "Bearer s3cret", "")).Should(Equal(http.StatusOK))
+			Ω(request(sc, "", "otp")).Should(Equal(http.StatusOK))
+			Ω(request(sc, "", "wrong")).Should(Equal(http.StatusUnauthorized))
+			Ω(request(sc, "Bearer wrong", "otp")).Should(Equal(http.StatusUnauthorized))
+		})
+
+	})
+
+	Context("JSON Web Tokens", func() {
+
+		var (
+			rsaKey *rsa.PrivateKey
+			ecKey  *ecdsa.PrivateKey
+			jwks   *httptest.Server
+			sc     SecConfig
+		)
+
+		BeforeEach(func() {
+			var err error
+			rsaKey, err = rsa.GenerateKey(rand.Reader, 2048)
+			Ω(err).Should(BeNil())
+			ecKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			Ω(err).Should(BeNil())
+			jwks = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintf(w, `{"keys":[{"kty":"RSA","kid":"rsa1","use":"sig","n":"%s","e":"%s"},`+
+					`{"kty":"EC","kid":"ec1","crv":"P-256","x":"%s","y":"%s"}]}`,
+					b64(rsaKey.N.Bytes()), b64(big.NewInt(int64(rsaKey.E)).Bytes()),
+					b64(ecKey.X.FillBytes(make([]byte, 32))), b64(ecKey.Y.FillBytes(make([]byte, 32))))
+			}))
+			sc = SecConfig{JWKSURL: jwks.URL, JWTAudience: "ubercluster"}
+		})
+
+		AfterEach(func() {
+			jwks.Close()
+		})
+
+		rs256 := func(kid string, claims map[string]interface{}) string {
+			return "Bearer " + signedToken("RS256", kid, claims, func(hash []byte) []byte {
+				sig, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, hash)
+				Ω(err).Should(BeNil())
+				return sig
+			})
+		}
+
+		validClaims := func() map[string]interface{} {
+			return map[string]interface{}{"sub": "service", "aud": "ubercluster", "exp": time.Now().Add(time.Hour).Unix()}
+		}
+
+		It("should accept tokens signed by a key of the key set", func() {
+			Ω(request(sc, rs256("rsa1", validClaims()), "")).Should(Equal(http.StatusOK))
+			es256 := "Bearer " + signedToken("ES256", "ec1", validClaims(), func(hash []byte) []byte {
+				r, s, err := ecdsa.Sign(rand.Reader, ecKey, hash)
+				Ω(err).Should(BeNil())
+				return append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+			})
+			Ω(request(sc, es256, "")).Should(Equal(http.StatusOK))
+		})
+
+		It("should accept a list of audiences", func() {
+			claims := validClaims()
+			claims["aud"] = []string{"other", "ubercluster"}
+			Ω(request(sc, rs256("rsa1", claims), "")).Should(Equal(http.StatusOK))
+		})
+
+		It("should reject expired tokens and tokens for other audiences", func() {
+			claims := validClaims()
+			claims["exp"] = time.Now().Add(-time.Hour).Unix()
+			Ω(request(sc, rs256("rsa1", claims), "")).Should(Equal(http.StatusUnauthorized))
+			claims = validClaims()
+			claims["nbf"] = time.Now().Add(time.Hour).Unix()
+			Ω(request(sc, rs256("rsa1", claims), "")).Should(Equal(http.StatusUnauthorized))
+			claims = validClaims()
+			claims["aud"] = "other"
+			Ω(request(sc, rs256("rsa1", claims), "")).Should(Equal(http.StatusUnauthorized))
+		})
+
+		It("should reject tokens without expiration time", func() {
+			claims := validClaims()
+			delete(claims, "exp")
+			Ω(request(sc, rs256("rsa1", claims), "")).Should(Equal(http.StatusUnauthorized))
+		})
+
+		It("should check the issuer when it is configured", func() {
+			sc.JWTIssuer = "https://issuer"
+			claims := validClaims()
+			Ω(request(sc, rs256("rsa1", claims), "")).Should(Equal(http.StatusUnauthorized))
+			claims["iss"] = "https://other"
+			Ω(request(sc, rs256("rsa1", claims), "")).Should(Equal(http.StatusUnauthorized))
+			claims["iss"] = "https://issuer"
+			Ω(request(sc, rs256("rsa1", claims), "")).Should(Equal(http.StatusOK))
+		})
+
+		It("should reject all tokens when no audience is configured", func() {
+			verify := NewBearerVerifier(SecConfig{JWKSURL: jwks.URL})
+			Ω(verify).ShouldNot(BeNil())
+			Ω(verify(rs256("rsa1", validClaims())[len("Bearer "):])).ShouldNot(BeNil())
+		})
+
+		It("should reject tokens with invalid signatures", func() {
+			token := rs256("rsa1", validClaims())
+			Ω(request(sc, token[:len(token)-4]+"AAAA", "")).Should(Equal(http.StatusUnauthorized))
+			Ω(request(sc, rs256("unknown", validClaims()), "")).Should(Equal(http.StatusUnauthorized))
+			unsigned := "Bearer " + signedToken("none", "rsa1", validClaims(), func([]byte) []byte { return nil })
+			Ω(request(sc, unsigned, "")).Should(Equal(http.StatusUnauthorized))
+			Ω(request(sc, "Bearer not.a.token", "")).Should(Equal(http.StatusUnauthorized))
+		})
+
+	})
+
+})
@@ -0,0 +1,64 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+)
+
+// corsMaxAge is the time in seconds browsers can cache the result
+// of a preflight request.
+const corsMaxAge = "600"
+
+// originAllowed returns true if the origin is in the list of allowed
+// origins. The origin "*" allows all origins.
+func originAllowed(origins []string, origin string) bool {
+	if origin == "" {
+		return false
+	}
+	for _, allowed := range origins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// MakeCORSHandler adds the CORS headers to the responses of the http
+// handler for requests of browsers from one of the allowed origins so
+// that web dashboards can access the proxy. Without allowed origins
+// the handler is returned unchanged.
+func MakeCORSHandler(origins []string, f http.HandlerFunc) http.HandlerFunc {
+	if len(origins) == 0 {
+		return f
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+		if origin := r.Header.Get("Origin"); originAllowed(origins, origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Expose-Headers", "X-Total-Count")
+		}
+		f(w, r)
+	}
+}
+
+// MakeCORSPreflightHandler answers the OPTIONS (preflight) requests of
+// browsers with the methods the routes matching the requested path accept.
+// Requests from origins which are not allowed are rejected with
+// http.StatusForbidden.
+func MakeCORSPreflightHandler(origins []string, methods func(r *http.Request) []string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+		origin := r.Header.Get("Origin")
+		if !originAllowed(origins, origin) {
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(append(methods(r), "OPTIONS"), ", "))
+		if headers := r.Header.Get("Access-Control-Request-Headers"); headers != "" {
+			w.Header().Set("Access-Control-Allow-Headers", headers)
+		}
+		w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,92 @@
+package proxy_test
+
+import (
+	. "github.com/dgruber/ubercluster/pkg/proxy"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"net/http"
+	"net/http/httptest"
+)
+
+var _ = Describe("ProxyCors", func() {
+
+	request := func(ts *httptest.Server, method, path, origin string) *http.Response {
+		r, _ := http.NewRequest(method, ts.URL+path, nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		r.Header.Set("Access-Control-Request-Headers", "Content-Type")
+		resp, err := http.DefaultClient.Do(r)
+		Ω(err).Should(BeNil())
+		resp.Body.Close()
+		return resp
+	}
+
+	Context("when CORS is configured", func() {
+
+		var ts *httptest.Server
+
+		BeforeEach(func() {
+			sc := SecConfig{CORSAllowedOrigins: []string{"https://dashboard.example.com"}}
+			ts = httptest.NewServer(NewProxyRouter(&fakeProxy{drmsName: "fake"}, sc, nil))
+		})
+
+		AfterEach(func() {
+			ts.Close()
+		})
+
+		It("should answer preflight requests of allowed origins", func() {
+			resp := request(ts, "OPTIONS", "/v1/jsession/ubercluster/run", "https://dashboard.example.com")
+			Ω(resp.StatusCode).Should(Equal(http.StatusNoContent))
+			Ω(resp.Header.Get("Access-Control-Allow-Origin")).Should(Equal("https://dashboard.example.com"))
+			Ω(resp.Header.Get("Access-Control-Allow-Methods")).Should(Equal("POST, OPTIONS"))
+			Ω(resp.Header.Get("Access-Control-Allow-Headers")).Should(Equal("Content-Type"))
+		})
+
+		It("should allow the methods of all routes matching the path", func() {
+			for _, path := range []string{
+				"/v1/jsession/ubercluster/jobcategory/default",
+				"/v1/jsession/ubercluster/staging/files",
+			} {
+				resp := request(ts, "OPTIONS", path, "https://dashboard.example.com")
+				Ω(resp.StatusCode).Should(Equal(http.StatusNoContent))
+				Ω(resp.Header.Get("Access-Control-Allow-Methods")).Should(Equal("POST, GET, OPTIONS"))
+			}
+			resp := request(ts, "OPTIONS", "/v1/jsession/ubercluster/suspend/13", "https://dashboard.example.com")
+			Ω(resp.Header.Get("Access-Control-Allow-Methods")).Should(Equal("POST, OPTIONS"))
+			resp = request(ts, "OPTIONS", "/v1/msession/jobinfos", "https://dashboard.example.com")
+			Ω(resp.Header.Get("Access-Control-Allow-Methods")).Should(Equal("GET, OPTIONS"))
+		})
+
+		It("should reject preflight requests of other origins", func() {
+			resp := request(ts, "OPTIONS", "/v1/msession/drmsname", "https://evil.example.com")
+			Ω(resp.StatusCode).Should(Equal(http.StatusForbidden))
+			Ω(resp.Header.Get("Access-Control-Allow-Origin")).Should(Equal(""))
+		})
+
+		It("should set the CORS headers only for allowed origins", func() {
+			resp := request(ts, "GET", "/v1/msession/drmsname", "https://dashboard.example.com")
+			Ω(resp.StatusCode).Should(Equal(http.StatusOK))
+			Ω(resp.Header.Get("Access-Control-Allow-Origin")).Should(Equal("https://dashboard.example.com"))
+			resp = request(ts, "GET", "/v1/msession/drmsname", "https://evil.example.com")
+			Ω(resp.Header.Get("Access-Control-Allow-Origin")).Should(Equal(""))
+		})
+
+	})
+
+	Context("when CORS is not configured", func() {
+
+		It("should not answer preflight requests", func() {
+			ts := httptest.NewServer(NewProxyRouter(&fakeProxy{drmsName: "fake"}, SecConfig{}, nil))
+			defer ts.Close()
+			resp := request(ts, "OPTIONS", "/v1/msession/drmsname", "https://dashboard.example.com")
+			Ω(resp.StatusCode).ShouldNot(Equal(http.StatusNoContent))
+			resp = request(ts, "GET", "/v1/msession/drmsname", "https://dashboard.example.com")
+			Ω(resp.Header.Get("Access-Control-Allow-Origin")).Should(Equal(""))
+		})
+
+	})
+
+})
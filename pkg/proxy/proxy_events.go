@@ -0,0 +1,238 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/dgruber/ubercluster/pkg/persistency"
+	"github.com/dgruber/ubercluster/pkg/types"
+	"github.com/gorilla/mux"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// JobEventNotifier is an optional interface which can be implemented
+// by a proxy which gets job state change notifications from its DRMS.
+// The channel must be closed when the context is canceled.
+type JobEventNotifier interface {
+	JobEvents(ctx context.Context) (<-chan types.Notification, error)
+}
+
+// eventPollInterval defines how often the job states are compared for
+// proxies which don't implement the JobEventNotifier interface.
+var eventPollInterval = 2 * time.Second
+
+// eventBufferSize is the amount of polled notifications which are kept
+// for an event stream which is not sending them fast enough. When the
+// buffer is full the stream is closed.
+const eventBufferSize = 64
+
+// jobStateChanges returns the notifications for all jobs which are new
+// or which changed their state compared to the known states. The known
+// states are updated. Jobs in an end state are removed from the known
+// states, so unknown jobs in an end state are only reported when they
+// finished after since (the time of the previous poll).
+func jobStateChanges(known map[string]types.JobState, jobinfos []types.JobInfo, since time.Time) []types.Notification {
+	var changes []types.Notification
+	for _, ji := range jobinfos {
+		state, exists := known[ji.Id]
+		if ji.State.IsTerminal() {
+			delete(known, ji.Id)
+			if !exists && !ji.FinishTime.After(since) {
+				continue
+			}
+		} else {
+			if exists && state == ji.State {
+				continue
+			}
+			known[ji.Id] = ji.State
+		}
+		changes = append(changes, types.Notification{
+			Evt:      types.NewState,
+			JobId:    ji.Id,
+			State:    ji.State,
+			JobOwner: ji.JobOwner,
+		})
+	}
+	return changes
+}
+
+// eventPoller derives job state change notifications for proxies which
+// don't implement the JobEventNotifier interface by comparing the job
+// infos in the poll interval. It is shared by all event streams, so the
+// job infos are requested once per interval independent of the amount
+// of streams. It polls only as long as there are subscribers.
+type eventPoller struct {
+	impl        ProxyImplementer
+	mutex       sync.Mutex
+	subscribers map[chan types.Notification]struct{}
+	stop        chan struct{}
+}
+
+func newEventPoller(impl ProxyImplementer) *eventPoller {
+	return &eventPoller{
+		impl:        impl,
+		subscribers: make(map[chan types.Notification]struct{}),
+	}
+}
+
+// subscribe returns a channel which receives the job state changes
+// after the call. The channel is closed when the context is done or
+// when the subscriber can't keep up with the changes.
+func (p *eventPoller) subscribe(ctx context.Context) <-chan types.Notification {
+	events := make(chan types.Notification, eventBufferSize)
+	p.mutex.Lock()
+	p.subscribers[events] = struct{}{}
+	if p.stop == nil {
+		known := make(map[string]types.JobState)
+		jobStateChanges(known, p.impl.GetJobInfosByFilter(false, types.JobInfo{}), time.Now())
+		p.stop = make(chan struct{})
+		go p.poll(known, p.stop)
+	}
+	p.mutex.Unlock()
+	go func() {
+		<-ctx.Done()
+		p.mutex.Lock()
+		p.unsubscribe(events)
+		p.mutex.Unlock()
+	}()
+	return events
+}
+
+// unsubscribe closes the channel of the subscriber and stops polling
+// when it was the last one. The mutex must be held.
+func (p *eventPoller) unsubscribe(events chan types.Notification) {
+	if _, exists := p.subscribers[events]; !exists {
+		return
+	}
+	delete(p.subscribers, events)
+	close(events)
+	if len(p.subscribers) == 0 {
+		close(p.stop)
+		p.stop = nil
+	}
+}
+
+// poll sends the job state changes to all subscribers until stop
+// is closed.
+func (p *eventPoller) poll(known map[string]types.JobState, stop chan struct{}) {
+	ticker := time.NewTicker(eventPollInterval)
+	defer ticker.Stop()
+	since := time.Now()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		jobinfos := p.impl.GetJobInfosByFilter(false, types.JobInfo{})
+		changes := jobStateChanges(known, jobinfos, since)
+		since = time.Now()
+		p.mutex.Lock()
+		for events := range p.subscribers {
+			for _, n := range changes {
+				select {
+				case events <- n:
+					continue
+				default:
+				}
+				log.Println("Closing job event stream which does not keep up with the job state changes")
+				p.unsubscribe(events)
+				break
+			}
+		}
+		p.mutex.Unlock()
+	}
+}
+
+// notificationMatches returns true if the notification is about a job
+// of the session, the given job (when set), and the given owner (when
+// set and not "all"). Notifications without session belong to all
+// sessions.
+func notificationMatches(n types.Notification, session, jobid, user string) bool {
+	if n.SessionName != "" && n.SessionName != session {
+		return false
+	}
+	if jobid != "" && n.JobId != jobid {
+		return false
+	}
+	if user != "" && user != types.AllJobOwners && n.JobOwner != user {
+		return false
+	}
+	return true
+}
+
+// MakeJSessionEventsHandler returns an http handler function which
+// streams job state change notifications as server-sent events (one
+// JSON encoded Notification per event) until the client closes the
+// connection. Only events of jobs of the session are sent; the "jobid"
+// and "user" form values restrict them further to a job or to the jobs
+// of an owner. Proxies which don't implement the JobEventNotifier
+// interface are polled for state changes by a poller which is shared by
+// all streams. The stream ends when the proxy shuts down.
+func MakeJSessionEventsHandler(impl ProxyImplementer, pi persistency.PersistencyImplementer) http.HandlerFunc {
+	notifier, isNotifier := impl.(JobEventNotifier)
+	var poller *eventPoller
+	if !isNotifier {
+		poller = newEventPoller(impl)
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+			return
+		}
+		session := mux.Vars(r)["jsname"]
+		jobid, user := r.FormValue("jobid"), r.FormValue("user")
+
+		var events <-chan types.Notification
+		if isNotifier {
+			var err error
+			if events, err = notifier.JobEvents(r.Context()); err != nil {
+				logRequestf(r, "Could not subscribe to job events: %s\n", err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		} else {
+			events = poller.subscribe(r.Context())
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+		logRequestf(r, "Streaming job events (job %q, user %q)\n", jobid, user)
+		shutdown := shuttingDown(r)
+		for {
+			var n types.Notification
+			select {
+			case <-shutdown:
+				logRequest(r, "Closing job event stream since the proxy shuts down")
+				return
+			case notification, ok := <-events:
+				if !ok {
+					return
+				}
+				n = notification
+			}
+			if !notificationMatches(n, session, jobid, user) {
+				continue
+			}
+			if n.SessionName == "" {
+				n.SessionName = session
+			}
+			data, err := json.Marshal(n)
+			if err != nil {
+				logRequestf(r, "Encoding error: %s\n", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: notification\ndata: %s\n\n", data); err != nil {
+				logRequest(r, "Error while sending job event: ", err)
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package proxy_test
+
+import (
+	. "github.com/dgruber/ubercluster/pkg/proxy"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"bufio"
+	"context"
+	"encoding/json"
+	"github.com/dgruber/ubercluster/pkg/persistency"
+	"github.com/dgruber/ubercluster/pkg/types"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// eventsProxy is a fakeProxy which sends a fixed set of notifications.
+type eventsProxy struct {
+	fakeProxy
+	notifications []types.Notification
+}
+
+func (e *eventsProxy) JobEvents(ctx context.Context) (<-chan types.Notification, error) {
+	events := make(chan types.Notification)
+	go func() {
+		defer close(events)
+		for _, n := range e.notifications {
+			select {
+			case events <- n:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// pollingProxy is a fakeProxy without job events which counts how
+// often the job infos are requested.
+type pollingProxy struct {
+	fakeProxy
+	sync.Mutex
+	jobinfos []types.JobInfo
+	calls    int
+}
+
+func (p *pollingProxy) GetJobInfosByFilter(filtered bool, filter types.JobInfo) []types.JobInfo {
+	p.Lock()
+	defer p.Unlock()
+	p.calls++
+	return append([]types.JobInfo{}, p.jobinfos...)
+}
+
+func (p *pollingProxy) setJobInfos(jobinfos []types.JobInfo) {
+	p.Lock()
+	defer p.Unlock()
+	p.jobinfos = jobinfos
+}
+
+func (p *pollingProxy) requests() int {
+	p.Lock()
+	defer p.Unlock()
+	return p.calls
+}
+
+// streamEvents sends the notifications of an event stream until the
+// context is canceled.
+func streamEvents(ctx context.Context, url string) <-chan types.Notification {
+	req, err := http.NewRequest("GET", url, nil)
+	Ω(err).Should(BeNil())
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	Ω(err).Should(BeNil())
+	Ω(resp.StatusCode).Should(Equal(http.StatusOK))
+	notifications := make(chan types.Notification, 16)
+	go func() {
+		defer resp.Body.Close()
+		defer close(notifications)
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if data := strings.TrimPrefix(scanner.Text(), "data: "); data != scanner.Text() {
+				var n types.Notification
+				if json.Unmarshal([]byte(data), &n) == nil {
+					notifications <- n
+				}
+			}
+		}
+	}()
+	return notifications
+}
+
+var _ = Describe("ProxyEvents", func() {
+
+	var ts *httptest.Server
+
+	BeforeEach(func() {
+		var ps persistency.DummyPersistency
+		impl := &eventsProxy{notifications: []types.Notification{
+			{JobId: "1", State: types.Running, JobOwner: "alice"},
+			{JobId: "2", State: types.Queued, JobOwner: "bob"},
+			{JobId: "1", State: types.Done, JobOwner: "alice"},
+		}}
+		ts = httptest.NewServer(NewProxyRouter(impl, SecConfig{}, &ps))
+	})
+
+	AfterEach(func() {
+		ts.Close()
+	})
+
+	events := func(query string) []types.Notification {
+		resp, err := http.Get(ts.URL + "/v1/jsession/default/events" + query)
+		Ω(err).Should(BeNil())
+		defer resp.Body.Close()
+		Ω(resp.StatusCode).Should(Equal(http.StatusOK))
+		Ω(resp.Header.Get("Content-Type")).Should(Equal("text/event-stream"))
+		var notifications []types.Notification
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if data := strings.TrimPrefix(scanner.Text(), "data: "); data != scanner.Text() {
+				var n types.Notification
+				Ω(json.Unmarshal([]byte(data), &n)).Should(BeNil())
+				notifications = append(notifications, n)
+			}
+		}
+		return notifications
+	}
+
+	It("should stream all job state changes as server-sent events", func() {
+		notifications := events("")
+		Ω(notifications).Should(HaveLen(3))
+		Ω(notifications[2].JobId).Should(Equal("1"))
+		Ω(notifications[2].State).Should(Equal(types.Done))
+	})
+
+	It("should stream only the events of the requested job or user", func() {
+		notifications := events("?jobid=1")
+		Ω(notifications).Should(HaveLen(2))
+		notifications = events("?user=bob")
+		Ω(notifications).Should(HaveLen(1))
+		Ω(notifications[0].JobId).Should(Equal("2"))
+		Ω(events("?user=all")).Should(HaveLen(3))
+	})
+
+	It("should stream only the events of the requested session", func() {
+		var ps persistency.DummyPersistency
+		impl := &eventsProxy{notifications: []types.Notification{
+			{JobId: "1", State: types.Running, SessionName: "default"},
+			{JobId: "2", State: types.Running, SessionName: "other"},
+			{JobId: "3", State: types.Running},
+		}}
+		sessionServer := httptest.NewServer(NewProxyRouter(impl, SecConfig{}, &ps))
+		defer sessionServer.Close()
+
+		var received []types.Notification
+		for n := range streamEvents(context.Background(), sessionServer.URL+"/v1/jsession/default/events") {
+			received = append(received, n)
+		}
+		Ω(received).Should(HaveLen(2))
+		Ω(received[0].JobId).Should(Equal("1"))
+		Ω(received[1].JobId).Should(Equal("3"))
+		Ω(received[1].SessionName).Should(Equal("default"))
+	})
+
+	It("should share one poller between the streams and report finished jobs once", func() {
+		var ps persistency.DummyPersistency
+		impl := &pollingProxy{jobinfos: []types.JobInfo{
+			{Id: "1", State: types.Running, JobOwner: "alice"},
+			{Id: "2", State: types.Done, JobOwner: "alice", FinishTime: time.Now().Add(-time.Hour)},
+		}}
+		pollingServer := httptest.NewServer(NewProxyRouter(impl, SecConfig{}, &ps))
+		defer pollingServer.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		streams := []<-chan types.Notification{
+			streamEvents(ctx, pollingServer.URL+"/v1/jsession/default/events"),
+			streamEvents(ctx, pollingServer.URL+"/v1/jsession/default/events"),
+		}
+		Ω(impl.requests()).Should(Equal(1))
+
+		impl.setJobInfos([]types.JobInfo{
+			{Id: "1", State: types.Done, JobOwner: "alice", FinishTime: time.Now()},
+			{Id: "2", State: types.Done, JobOwner: "alice", FinishTime: time.Now().Add(-time.Hour)},
+			{Id: "3", State: types.Queued, JobOwner: "bob"},
+		})
+		for _, stream := range streams {
+			var n types.Notification
+			Eventually(stream, 5*time.Second).Should(Receive(&n))
+			Ω(n.JobId).Should(Equal("1"))
+			Ω(n.State).Should(Equal(types.Done))
+			Ω(n.SessionName).Should(Equal("default"))
+			Eventually(stream).Should(Receive(&n))
+			Ω(n.JobId).Should(Equal("3"))
+		}
+		// the finished jobs are not reported again by the next poll
+		Consistently(streams[0], 2500*time.Millisecond).ShouldNot(Receive())
+		Ω(streams[1]).ShouldNot(Receive())
+	})
+
+})
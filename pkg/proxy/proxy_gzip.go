@@ -0,0 +1,71 @@
+package proxy
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+)
+
+// gzipResponseWriter compresses everything written to the response.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz *gzip.Writer
+}
+
+func (w *gzipResponseWriter) WriteHeader(code int) {
+	// the length of the compressed content is not known in advance
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	if w.Header().Get("Content-Type") == "" {
+		// otherwise it would be detected from the compressed data
+		w.Header().Set("Content-Type", http.DetectContentType(b))
+	}
+	return w.gz.Write(b)
+}
+
+// Flush sends the data compressed so far to the client so that
+// streaming handlers (like following job output) keep working.
+func (w *gzipResponseWriter) Flush() {
+	w.gz.Flush()
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// acceptsGzip returns true if the client announced that it can
+// handle gzip compressed responses.
+func acceptsGzip(r *http.Request) bool {
+	for _, encoding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		encoding = strings.TrimSpace(encoding)
+		if i := strings.Index(encoding, ";"); i >= 0 {
+			if strings.TrimSpace(encoding[i+1:]) == "q=0" {
+				continue
+			}
+			encoding = strings.TrimSpace(encoding[:i])
+		}
+		if encoding == "gzip" {
+			return true
+		}
+	}
+	return false
+}
+
+// MakeGzipHandler compresses the responses of the http handler with
+// gzip when the client sends an "Accept-Encoding: gzip" header. Other
+// clients get the uncompressed response.
+func MakeGzipHandler(f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(r) {
+			f(w, r)
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		f(&gzipResponseWriter{ResponseWriter: w, gz: gz}, r)
+	}
+}
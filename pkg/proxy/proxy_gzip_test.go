@@ -0,0 +1,71 @@
+package proxy_test
+
+import (
+	. "github.com/dgruber/ubercluster/pkg/proxy"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+var _ = Describe("ProxyGzip", func() {
+
+	body := strings.Repeat(`{"id":"1","state":"running"}`, 100)
+
+	request := func(acceptEncoding string) *httptest.ResponseRecorder {
+		h := MakeGzipHandler(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(body))
+		})
+		r, _ := http.NewRequest("GET", "/v1/msession/jobinfos", nil)
+		if acceptEncoding != "" {
+			r.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		w := httptest.NewRecorder()
+		h(w, r)
+		return w
+	}
+
+	Context("content encoding negotiation", func() {
+
+		It("should compress the response when the client accepts gzip", func() {
+			w := request("deflate, gzip")
+			Ω(w.Header().Get("Content-Encoding")).Should(Equal("gzip"))
+			Ω(w.Header().Get("Content-Type")).Should(Equal("application/json"))
+			Ω(w.Body.Len()).Should(BeNumerically("<", len(body)))
+			gz, err := gzip.NewReader(w.Body)
+			Ω(err).Should(BeNil())
+			uncompressed, err := ioutil.ReadAll(gz)
+			Ω(err).Should(BeNil())
+			Ω(string(uncompressed)).Should(Equal(body))
+		})
+
+		It("should not compress the response for other clients", func() {
+			for _, encoding := range []string{"", "deflate", "gzip;q=0"} {
+				w := request(encoding)
+				Ω(w.Header().Get("Content-Encoding")).Should(Equal(""))
+				Ω(w.Body.String()).Should(Equal(body))
+			}
+		})
+
+		It("should compress the responses of the proxy router", func() {
+			ts := httptest.NewServer(NewProxyRouter(&fakeProxy{drmsName: "fake"}, SecConfig{}, nil))
+			defer ts.Close()
+
+			r, _ := http.NewRequest("GET", ts.URL+"/v1/msession/drmsname", nil)
+			resp, err := http.DefaultClient.Do(r)
+			Ω(err).Should(BeNil())
+			defer resp.Body.Close()
+			Ω(resp.Uncompressed).Should(BeTrue())
+			name, _ := ioutil.ReadAll(resp.Body)
+			Ω(string(name)).Should(ContainSubstring("fake"))
+		})
+
+	})
+
+})
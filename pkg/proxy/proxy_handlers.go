@@ -18,6 +18,7 @@ package proxy
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/dgruber/ubercluster/pkg/persistency"
 	"github.com/dgruber/ubercluster/pkg/staging"
@@ -29,62 +30,201 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
-func getDRMAA2JobState(state string) types.JobState {
-	switch state {
-	case "r":
-		return types.Running
-	case "q":
-		return types.Queued
-	case "h":
-		return types.QueuedHeld
-	case "s":
-		return types.Suspended
-	case "R":
-		return types.Requeued
-	case "Rh":
-		return types.RequeuedHeld
-	case "d":
-		return types.Done
-	case "f":
-		return types.Failed
-	case "u":
-		return types.Undetermined
-	}
-	return types.Undetermined
+// parsePageParameter parses a non-negative pagination parameter.
+func parsePageParameter(r *http.Request, name string) (int, error) {
+	value := r.FormValue(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s: %s", name, value)
+	}
+	return n, nil
+}
+
+// defaultJobInfoLimit is the amount of job infos returned when a job
+// info request has no "limit" form value.
+const defaultJobInfoLimit = 1000
+
+// jobInfoPage is defined by the "limit" and "offset" form values of
+// a job info request. A limit of 0 means no limit.
+type jobInfoPage struct {
+	limit  int
+	offset int
+}
+
+func parseJobInfoPage(r *http.Request) (page jobInfoPage, err error) {
+	page.limit = defaultJobInfoLimit
+	if r.FormValue("limit") != "" {
+		if page.limit, err = parsePageParameter(r, "limit"); err != nil {
+			return page, err
+		}
+	}
+	page.offset, err = parsePageParameter(r, "offset")
+	return page, err
+}
+
+// apply returns the job infos of the page. The job infos are sorted
+// by job id so that the pages are stable between requests.
+func (page jobInfoPage) apply(jobinfos []types.JobInfo) []types.JobInfo {
+	if page.limit == 0 && page.offset == 0 {
+		return jobinfos
+	}
+	sort.SliceStable(jobinfos, func(i, j int) bool { return jobinfos[i].Id < jobinfos[j].Id })
+	offset := page.offset
+	if offset > len(jobinfos) {
+		offset = len(jobinfos)
+	}
+	jobinfos = jobinfos[offset:]
+	if page.limit > 0 && page.limit < len(jobinfos) {
+		jobinfos = jobinfos[:page.limit]
+	}
+	return jobinfos
+}
+
+// filterJobInfos returns the job infos which match the filter.
+func filterJobInfos(jobinfos []types.JobInfo, filter types.JobInfo) []types.JobInfo {
+	matching := make([]types.JobInfo, 0, len(jobinfos))
+	for _, ji := range jobinfos {
+		if ji.Matches(filter) {
+			matching = append(matching, ji)
+		}
+	}
+	return matching
+}
+
+// submittedBetween returns the job infos of the jobs submitted within
+// the time window.
+func submittedBetween(jobinfos []types.JobInfo, since, until time.Time) []types.JobInfo {
+	matching := make([]types.JobInfo, 0, len(jobinfos))
+	for _, ji := range jobinfos {
+		if ji.SubmittedBetween(since, until) {
+			matching = append(matching, ji)
+		}
+	}
+	return matching
 }
 
 // MakeMSessionJobInfosHandler retuns an http handler function which returns
 // a JSON encoded collection of DRMAA2 job info object of all jobs available.
+// The "state" form value filters for jobs in a state, the "user" form value
+// for jobs of an owner (JobOwner). A missing user or "all" returns the
+// jobs of all users. The "since" and "until" form values select the jobs
+// submitted within that time window.
+// The result can be paged by the "limit" and "offset" form values. Without
+// a limit at most 1000 job infos are returned, a limit of 0 returns all.
+// The total amount of matching jobs is returned in the X-Total-Count header.
 func MakeMSessionJobInfosHandler(impl ProxyImplementer, pi persistency.PersistencyImplementer) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		page, err := parseJobInfoPage(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		since, err := parseTimeValue(r, "since")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		until, err := parseTimeValue(r, "until")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		filterSet := false
-		var filter types.JobInfo
+		filter := types.CreateJobInfo()
 		if state := r.FormValue("state"); state != "all" && state != "" {
-			filter.State = getDRMAA2JobState(state)
-			log.Printf("filter for state: %s\n", filter.State)
+			js, err := types.ParseJobState(state)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			filter.State = js
+			logRequestf(r, "filter for state: %s\n", filter.State)
 			filterSet = true
 		}
-		if user := r.FormValue("user"); user != "" {
+		if user := r.FormValue("user"); user != "" && user != types.AllJobOwners {
 			filter.JobOwner = user
-			log.Printf("filter for user: %s\n", filter.JobOwner)
+			logRequestf(r, "filter for user: %s\n", filter.JobOwner)
 			filterSet = true
 		}
 		if jobinfos := impl.GetJobInfosByFilter(filterSet, filter); jobinfos != nil {
+			if filterSet {
+				// not all proxies support all filters
+				jobinfos = filterJobInfos(jobinfos, filter)
+			}
+			if !since.IsZero() || !until.IsZero() {
+				jobinfos = submittedBetween(jobinfos, since, until)
+			}
+			w.Header().Set("X-Total-Count", strconv.Itoa(len(jobinfos)))
+			jobinfos = page.apply(jobinfos)
 			encoder := json.NewEncoder(w)
 			if err := encoder.Encode(jobinfos); err != nil {
 				fmt.Printf("Encoding error: %s\n", err)
 			} else {
-				log.Printf("Encoded: %s\n", jobinfos)
+				logRequestf(r, "Encoded: %s\n", jobinfos)
 			}
 		}
 	}
 }
 
+// parseTimeValue parses a time form value (like "since") which is
+// either a point in time (RFC 3339) or a duration (like "24h" or "1d")
+// before now. An empty value returns the zero time.
+func parseTimeValue(r *http.Request, name string) (time.Time, error) {
+	value := r.FormValue(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := types.ParseTime(value, time.Now())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s: %s", name, err)
+	}
+	return t, nil
+}
+
+// MakeAccountingHandler returns an http handler function which returns
+// the JSON encoded CPU time and wallclock time of the finished jobs
+// summed up per job owner. The "user" form value restricts the summary
+// to the jobs of one owner (a missing user or "all" selects all users),
+// the "since" form value to jobs finished after that time.
+func MakeAccountingHandler(impl ProxyImplementer, pi persistency.PersistencyImplementer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		since, err := parseTimeValue(r, "since")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		filterSet := false
+		filter := types.CreateJobInfo()
+		if user := r.FormValue("user"); user != "" && user != types.AllJobOwners {
+			filter.JobOwner = user
+			filterSet = true
+		}
+		jobinfos := impl.GetJobInfosByFilter(filterSet, filter)
+		if filterSet {
+			jobinfos = filterJobInfos(jobinfos, filter)
+		}
+		summaries := types.SummarizeAccounting(jobinfos, since)
+		logRequestf(r, "accounting of %d jobs since %s\n", len(jobinfos), since)
+		if err := json.NewEncoder(w).Encode(summaries); err != nil {
+			logRequestf(r, "Encoding error: %s\n", err)
+		}
+	}
+}
+
 // MakeMSessionJobInfoHandler returns an http handler function which returns
-// a JSON encoded DRMAA2 Job Info object.
+// the complete DRMAA2 Job Info object of a job (including its allocated
+// machines, exit status, and resource usage) JSON encoded. Unknown jobs
+// are answered with http.StatusNotFound.
 func MakeMSessionJobInfoHandler(impl ProxyImplementer, pi persistency.PersistencyImplementer) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
@@ -92,7 +232,8 @@ func MakeMSessionJobInfoHandler(impl ProxyImplementer, pi persistency.Persistenc
 			if jobinfo := impl.GetJobInfo(jobid); jobinfo != nil {
 				json.NewEncoder(w).Encode(*jobinfo)
 			} else {
-				log.Printf("JobInfo not found for job %s\n", jobinfo)
+				logRequestf(r, "JobInfo not found for job %s\n", jobid)
+				http.Error(w, fmt.Sprintf("job %s not found", jobid), http.StatusNotFound)
 			}
 		}
 	}
@@ -105,7 +246,7 @@ func MakeMachinesHandler(impl ProxyImplementer, pi persistency.PersistencyImplem
 		if machines, err := impl.GetAllMachines(nil); err == nil {
 			json.NewEncoder(w).Encode(machines)
 		} else {
-			log.Printf("Error in GetAllMachines: %s\n", err)
+			logRequestf(r, "Error in GetAllMachines: %s\n", err)
 		}
 	}
 }
@@ -120,7 +261,7 @@ func MakeMachineHandler(impl ProxyImplementer, pi persistency.PersistencyImpleme
 		if machines, err := impl.GetAllMachines([]string{name}); err == nil {
 			json.NewEncoder(w).Encode(machines)
 		} else {
-			log.Printf("Error in GetAllMachines: %s\n", err)
+			logRequestf(r, "Error in GetAllMachines: %s\n", err)
 		}
 	}
 }
@@ -132,7 +273,7 @@ func MakeQueuesHandler(impl ProxyImplementer, pi persistency.PersistencyImplemen
 		if queues, err := impl.GetAllQueues(nil); err == nil {
 			json.NewEncoder(w).Encode(queues)
 		} else {
-			log.Printf("Error in GetAllQueues: %s\n", err)
+			logRequestf(r, "Error in GetAllQueues: %s\n", err)
 		}
 	}
 }
@@ -146,11 +287,36 @@ func MakeQueueHandler(impl ProxyImplementer, pi persistency.PersistencyImplement
 		if queues, err := impl.GetAllQueues([]string{name}); err == nil {
 			json.NewEncoder(w).Encode(queues)
 		} else {
-			log.Printf("Error in GetAllQueues: %s\n", err)
+			logRequestf(r, "Error in GetAllQueues: %s\n", err)
 		}
 	}
 }
 
+// MakeQueueSlotsHandler returns an http handler function which returns
+// the used, total, and free slots of the queues JSON encoded. The "queue"
+// form value (which can be repeated) selects queues by their name.
+func MakeQueueSlotsHandler(impl ProxyImplementer, pi persistency.PersistencyImplementer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		names := r.Form["queue"]
+		queues, err := impl.GetAllQueues(names)
+		if err != nil {
+			logRequestf(r, "Error in GetAllQueues: %s\n", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		slots := make([]types.QueueSlots, 0, len(queues))
+		for i := range queues {
+			// not all proxies select the queues by name
+			if len(names) > 0 && !containsString(names, queues[i].Name) {
+				continue
+			}
+			slots = append(slots, queues[i].Slots())
+		}
+		json.NewEncoder(w).Encode(slots)
+	}
+}
+
 // MakeMSessionDRMSVersionHandler returns an http handler function which
 // returns all available DRMAA2 job categories as JSON encoded string.
 func MakeJSessionCategoriesHandler(impl ProxyImplementer, pi persistency.PersistencyImplementer) http.HandlerFunc {
@@ -158,28 +324,42 @@ func MakeJSessionCategoriesHandler(impl ProxyImplementer, pi persistency.Persist
 		if categories, err := impl.GetAllCategories(); err == nil {
 			json.NewEncoder(w).Encode(categories)
 		} else {
-			log.Printf("Error in GetAllCategories: %s\n", err)
+			logRequestf(r, "Error in GetAllCategories: %s\n", err)
 		}
 	}
 }
 
 // MakeJSessionCategroyHandler returns an http handler function which
-// returns a requested job category when it is available.
+// returns a requested job category when it is available. Proxies
+// implementing the JobCategoryProvider interface are asked for the
+// category directly.
 func MakeJSessionCategoryHandler(impl ProxyImplementer, pi persistency.PersistencyImplementer) http.HandlerFunc {
 	// at the moment all job sessions have the same categories
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		name := vars["category"]
-		if categories, err := impl.GetAllCategories(); err == nil {
-			for _, c := range categories {
-				if c == name {
-					json.NewEncoder(w).Encode(c)
-					return
-				}
+		if cp, ok := impl.(JobCategoryProvider); ok {
+			if c, err := cp.GetJobCategory(name); err == nil {
+				json.NewEncoder(w).Encode(c)
+			} else {
+				logRequestf(r, "Error in GetJobCategory: %s\n", err)
+				http.Error(w, err.Error(), http.StatusNotFound)
 			}
-		} else {
-			log.Printf("Error in GetJobCategories: %s\n", err)
+			return
 		}
+		categories, err := impl.GetAllCategories()
+		if err != nil {
+			logRequestf(r, "Error in GetJobCategories: %s\n", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, c := range categories {
+			if c == name {
+				json.NewEncoder(w).Encode(c)
+				return
+			}
+		}
+		http.Error(w, fmt.Sprintf("unknown job category %s", name), http.StatusNotFound)
 	}
 }
 
@@ -220,42 +400,37 @@ type RunJobResult struct {
 // TODO In case a ProxyImplementer is given as a parameter the job template
 // is made persistent.
 func MakeJSessionSubmitHandler(impl ProxyImplementer, pi persistency.PersistencyImplementer) http.HandlerFunc {
-	var workingDir string
-	if wd, wdErr := os.Getwd(); wdErr == nil {
-		log.Println("(proxy) adapt cwd to ", wd, "uploads")
-		workingDir = wd + "/uploads"
-	} else {
-		fmt.Println("Can't set working directory for the jobs.")
-		os.Exit(2)
-	}
+	workingDir := jobWorkingDir()
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		if body, err := ioutil.ReadAll(r.Body); err != nil {
-			log.Printf("(proxy) %s\n", err)
+			logRequestf(r, "(proxy) %s\n", err)
 		} else {
 			var jt types.JobTemplate
 			if uerr := json.Unmarshal(body, &jt); uerr != nil {
-				log.Println("(proxy) Unmarshall error")
+				logRequest(r, "(proxy) Unmarshall error")
 				http.Error(w, uerr.Error(), http.StatusInternalServerError)
 			} else {
-				log.Printf("(proxy) Set working dir for job %s\n", workingDir)
+				logRequestf(r, "(proxy) Set working dir for job %s\n", workingDir)
 				jt.WorkingDirectory = workingDir
 				// required when file is in staging area but not for general path
 				// jt.RemoteCommand = workingDir + "/" + jt.RemoteCommand
-				log.Println("(proxy) Submit now job")
+				logRequest(r, "(proxy) Submit now job")
 				// Submit job in compute cluster
-				if jobid, joberr := impl.RunJob(jt); joberr != nil {
-					log.Printf("(proxy) Error during job submission: %s\n", joberr)
+				jobid, joberr := impl.RunJob(jt)
+				metricsFromContext(r.Context()).ObserveJobOperation("submit", joberr)
+				if joberr != nil {
+					logRequestf(r, "(proxy) Error during job submission: %s\n", joberr)
 					http.Error(w, joberr.Error(), http.StatusInternalServerError)
 				} else {
-					log.Printf("(proxy) Job successfully submitted: %s\n", jobid)
+					logRequestf(r, "(proxy) Job successfully submitted: %s\n", jobid)
 
 					// make job submission persistent on proxy
 					if pi != nil {
 						if err := pi.SaveJobTemplate(jobid, jt); err != nil {
-							log.Printf("(proxy) Error during making Job Template persistent: %s\n", err)
+							logRequestf(r, "(proxy) Error during making Job Template persistent: %s\n", err)
 						} else {
-							log.Printf("(proxy) Job template for job %s successfully made persistent.\n", jobid)
+							logRequestf(r, "(proxy) Job template for job %s successfully made persistent.\n", jobid)
 						}
 					}
 
@@ -268,15 +443,69 @@ func MakeJSessionSubmitHandler(impl ProxyImplementer, pi persistency.Persistency
 	}
 }
 
+// jobWorkingDir returns the working directory of the jobs which is
+// the uploads directory below the current working directory of the proxy.
+func jobWorkingDir() string {
+	wd, wdErr := os.Getwd()
+	if wdErr != nil {
+		fmt.Println("Can't set working directory for the jobs.")
+		os.Exit(2)
+	}
+	log.Println("(proxy) adapt cwd to ", wd, "uploads")
+	return wd + "/uploads"
+}
+
+// MakeJSessionBulkSubmitHandler returns an http handler function which
+// reads in an array job request (in JSON) in the body of the http request
+// and submits the job template as array job using the RunArrayJob
+// function of the proxy. Proxies which do not support array jobs
+// answer with http.StatusNotImplemented.
+func MakeJSessionBulkSubmitHandler(impl ProxyImplementer, pi persistency.PersistencyImplementer) http.HandlerFunc {
+	workingDir := jobWorkingDir()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		runner, ok := impl.(ArrayJobRunner)
+		if !ok {
+			http.Error(w, "array jobs are not supported by this proxy", http.StatusNotImplemented)
+			return
+		}
+		var ajr types.ArrayJobRequest
+		if err := json.NewDecoder(r.Body).Decode(&ajr); err != nil {
+			logRequest(r, "(proxy) Unmarshall error")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if ajr.Step <= 0 || ajr.Begin > ajr.End || ajr.MaxParallel < 0 {
+			http.Error(w, "invalid array job range", http.StatusBadRequest)
+			return
+		}
+		ajr.JobTemplate.WorkingDirectory = workingDir
+		jobid, joberr := runner.RunArrayJob(ajr.JobTemplate, ajr.Begin, ajr.End, ajr.Step, ajr.MaxParallel)
+		metricsFromContext(r.Context()).ObserveJobOperation("submitarray", joberr)
+		if joberr != nil {
+			logRequestf(r, "(proxy) Error during array job submission: %s\n", joberr)
+			http.Error(w, joberr.Error(), http.StatusInternalServerError)
+			return
+		}
+		logRequestf(r, "(proxy) Array job successfully submitted: %s\n", jobid)
+		if pi != nil {
+			if err := pi.SaveJobTemplate(jobid, ajr.JobTemplate); err != nil {
+				logRequestf(r, "(proxy) Error during making Job Template persistent: %s\n", err)
+			}
+		}
+		json.NewEncoder(w).Encode(RunJobResult{JobId: jobid})
+	}
+}
+
 // MakeRunLocalHandler spawns a process on the same host as proxy.
 func MakeRunLocalHandler(impl ProxyImplementer, pi persistency.PersistencyImplementer) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if body, err := ioutil.ReadAll(r.Body); err != nil {
-			log.Printf("(proxy) %s\n", err)
+			logRequestf(r, "(proxy) %s\n", err)
 		} else {
 			var rlr types.RunLocalRequest
 			if uerr := json.Unmarshal(body, &rlr); uerr != nil {
-				log.Println("(proxy) Unmarshall error")
+				logRequest(r, "(proxy) Unmarshall error")
 				http.Error(w, uerr.Error(), http.StatusInternalServerError)
 			}
 			cli := []string{"-c", rlr.Command + " " + rlr.Arg}
@@ -286,9 +515,9 @@ func MakeRunLocalHandler(impl ProxyImplementer, pi persistency.PersistencyImplem
 			cmd.Stdin = os.Stdin
 			cmd.Stderr = os.Stderr
 
-			log.Printf("Start command: %s %v\n", cmd.Path, cmd.Args)
+			logRequestf(r, "Start command: %s %v\n", cmd.Path, cmd.Args)
 			if errStart := cmd.Start(); errStart != nil {
-				log.Printf("(proxy) Error during starting command %s %s: %s\n", rlr.Command, rlr.Arg, errStart.Error())
+				logRequestf(r, "(proxy) Error during starting command %s %s: %s\n", rlr.Command, rlr.Arg, errStart.Error())
 				json.NewEncoder(w).Encode(fmt.Sprintf("Failed starting command: %s", errStart.Error()))
 			} else {
 				json.NewEncoder(w).Encode(fmt.Sprintf("Started command with PID %d", cmd.Process.Pid))
@@ -308,25 +537,25 @@ func MakeUCFileUploadHandler(impl ProxyImplementer, pi persistency.PersistencyIm
 		// currently limited to 1GB until tested
 		const maxSize = 1024 * 1024 * 1024
 		if r.ContentLength > maxSize {
-			log.Println("File content too large", r.ContentLength)
+			logRequest(r, "File content too large", r.ContentLength)
 			http.Error(w, "File too large", http.StatusExpectationFailed)
 			return
 		}
 		r.Body = http.MaxBytesReader(w, r.Body, maxSize)
 		err := r.ParseMultipartForm(1024 * 1024 * 128)
 		if err != nil {
-			log.Println(err)
+			logRequest(r, err)
 			http.Error(w, err.Error(), http.StatusExpectationFailed)
 			return
 		}
 
 		file, header, err := r.FormFile("file")
 		if err != nil {
-			log.Println("Error: ", err)
+			logRequest(r, "Error: ", err)
 			panic(err)
 		}
 		if strings.ContainsAny(header.Filename, "/\\!") || strings.Contains(header.Filename, "..") {
-			log.Println("File name contains invalid characters..", header.Filename)
+			logRequest(r, "File name contains invalid characters..", header.Filename)
 			http.Error(w, "File name contains invalid chars", http.StatusExpectationFailed)
 			return
 		}
@@ -337,23 +566,23 @@ func MakeUCFileUploadHandler(impl ProxyImplementer, pi persistency.PersistencyIm
 		}
 
 		if written, err := io.Copy(dst, io.LimitReader(file, maxSize)); err != nil {
-			log.Println("Error: ", err)
+			logRequest(r, "Error: ", err)
 			panic(err)
 		} else {
 			if written == maxSize {
-				log.Println("File upload too large.")
+				logRequest(r, "File upload too large.")
 				http.Error(w, "File too large", http.StatusExpectationFailed)
 				return
 			}
-			log.Println("File saved successfully")
+			logRequest(r, "File saved successfully")
 		}
-		log.Println(r.FormValue("permission"))
+		logRequest(r, r.FormValue("permission"))
 		if r.FormValue("permission") == "exec" {
 			// make the file an executable
 			if err := dst.Chmod(0700); err != nil {
-				log.Println(err)
+				logRequest(r, err)
 			} else {
-				log.Println("Made file executable.")
+				logRequest(r, "Made file executable.")
 			}
 		}
 
@@ -362,25 +591,37 @@ func MakeUCFileUploadHandler(impl ProxyImplementer, pi persistency.PersistencyIm
 }
 
 // MakeJSessionJobManipulationHandler returns an http handler function which
-// calls the JobOperation function defined by an ProxyImplementer.
+// calls the JobOperation function defined by an ProxyImplementer. Unknown
+// job sessions and jobs are answered with 404 Not Found, operations the
+// DRM doesn't support with 501 Not Implemented, and failed operations
+// with 500 Internal Server Error.
 func MakeJSessionJobManipulationHandler(impl ProxyImplementer, pi persistency.PersistencyImplementer) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		name := vars["jsname"]
 		operation := vars["operation"]
 		jobid := vars["jobid"]
-		log.Println("(jobManipulationHandler) called with: ", name, operation, jobid)
+		logRequest(r, "(jobManipulationHandler) called with: ", name, operation, jobid)
 
 		// job session name must be the one created by d2proxy
 		if name != "ubercluster" {
-			json.NewEncoder(w).Encode("invalid job session name")
+			http.Error(w, "invalid job session name", http.StatusNotFound)
 			return
 		}
-		if str, err := impl.JobOperation(name, operation, jobid); err == nil {
-			json.NewEncoder(w).Encode(str)
-		} else {
-			json.NewEncoder(w).Encode(err)
+		str, err := impl.JobOperation(name, operation, jobid)
+		metricsFromContext(r.Context()).ObserveJobOperation(operation, err)
+		if err != nil {
+			logRequestf(r, "(jobManipulationHandler) %s of job %s failed: %s\n", operation, jobid, err)
+			status := http.StatusInternalServerError
+			if errors.Is(err, ErrJobNotFound) {
+				status = http.StatusNotFound
+			} else if errors.Is(err, ErrUnsupportedOperation) {
+				status = http.StatusNotImplemented
+			}
+			http.Error(w, err.Error(), status)
+			return
 		}
+		json.NewEncoder(w).Encode(str)
 	}
 }
 
@@ -389,7 +630,7 @@ func MakeJSessionJobManipulationHandler(impl ProxyImplementer, pi persistency.Pe
 func MakeListFilesHandler(impl ProxyImplementer, pi persistency.PersistencyImplementer) http.HandlerFunc {
 	// TODO disallow based on config / startup params ...
 	return func(w http.ResponseWriter, r *http.Request) {
-		log.Println("(ListFilesHandler) called")
+		logRequest(r, "(ListFilesHandler) called")
 		// job session name must be the one created by d2proxy
 		// json.NewEncoder(w).Encode("invalid job session name")
 		if dir, err := os.Open("uploads"); err != nil {
@@ -397,17 +638,17 @@ func MakeListFilesHandler(impl ProxyImplementer, pi persistency.PersistencyImple
 			os.Exit(1)
 		} else {
 			if fi, err := dir.Stat(); err != nil {
-				log.Println("Can't stat file staging directory: ", err)
+				logRequest(r, "Can't stat file staging directory: ", err)
 				http.Error(w, "Error in staging area", http.StatusForbidden)
 				return
 			} else {
 				if fi.IsDir() == false {
-					log.Println("File staging directory not found: ", err)
+					logRequest(r, "File staging directory not found: ", err)
 					http.Error(w, "Error in staging area", http.StatusForbidden)
 					return
 				} else {
 					if fis, err := dir.Readdir(-1); err == nil {
-						log.Println("Files in staging directory found ")
+						logRequest(r, "Files in staging directory found ")
 						fileinfos := make([]types.FileInfo, 0, len(fis))
 						for _, fi := range fis {
 							if fi.IsDir() == false {
@@ -420,13 +661,13 @@ func MakeListFilesHandler(impl ProxyImplementer, pi persistency.PersistencyImple
 									info.Executable = false
 								}
 								fileinfos = append(fileinfos, info)
-								log.Println("added: ", info.Filename)
+								logRequest(r, "added: ", info.Filename)
 							}
 						}
 						fmt.Println(fileinfos)
 						json.NewEncoder(w).Encode(fileinfos)
 					} else {
-						log.Println("Error during dir.Readdir: ", err)
+						logRequest(r, "Error during dir.Readdir: ", err)
 						http.Error(w, "Error in staging area", http.StatusForbidden)
 					}
 				}
@@ -443,8 +684,13 @@ func MakeDownloadFilesHandler(impl ProxyImplementer, pi persistency.PersistencyI
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		if filename := vars["name"]; filename != "" {
-			log.Println("Serving file: ./uploads/", filename)
-			http.ServeFile(w, r, "./uploads/"+filename)
+			if err := ValidateFileName(filename); err != nil {
+				logRequest(r, "Rejected file download: ", err)
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			logRequest(r, "Serving file: ./uploads/", filename)
+			http.ServeFile(w, r, filepath.Join("uploads", filename))
 		} else {
 			http.Error(w, "No filename given.", http.StatusForbidden)
 		}
@@ -458,12 +704,12 @@ func MakeSessionListHandler(impl ProxyImplementer, pi persistency.PersistencyImp
 		if sessions, err := impl.GetAllSessions(nil); err == nil {
 			json.NewEncoder(w).Encode(sessions)
 		} else {
-			log.Println("Error in GetAllSessions: ", err)
+			logRequest(r, "Error in GetAllSessions: ", err)
 		}
 	}
 }
 
 func AutenticationErrorHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Authentication error")
+	logRequest(r, "Authentication error")
 	http.NotFound(w, r)
 }
@@ -0,0 +1,578 @@
+package proxy_test
+
+import (
+	. "github.com/dgruber/ubercluster/pkg/proxy"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dgruber/ubercluster/pkg/persistency"
+	"github.com/dgruber/ubercluster/pkg/types"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// jobsProxy is a fakeProxy which knows a fixed set of jobs.
+type jobsProxy struct {
+	fakeProxy
+	jobs []types.JobInfo
+}
+
+func (j *jobsProxy) GetJobInfosByFilter(filtered bool, filter types.JobInfo) []types.JobInfo {
+	jobs := make([]types.JobInfo, len(j.jobs))
+	copy(jobs, j.jobs)
+	return jobs
+}
+
+// resourcesProxy is a fakeProxy which knows a fixed set of queues and
+// job categories.
+type resourcesProxy struct {
+	fakeProxy
+	queues     []string
+	categories []string
+}
+
+func (rp *resourcesProxy) GetAllQueues(queues []string) ([]types.Queue, error) {
+	qs := make([]types.Queue, 0, len(rp.queues))
+	for _, q := range rp.queues {
+		qs = append(qs, types.Queue{Name: q})
+	}
+	return qs, nil
+}
+
+func (rp *resourcesProxy) GetAllCategories() ([]string, error) {
+	return rp.categories, nil
+}
+
+// slotsProxy is a fakeProxy which returns all its queues with their
+// slots regardless of the requested queue names.
+type slotsProxy struct {
+	fakeProxy
+	queues []types.Queue
+}
+
+func (sp *slotsProxy) GetAllQueues(queues []string) ([]types.Queue, error) {
+	return sp.queues, nil
+}
+
+// categoryProxy is a fakeProxy which looks up single job categories.
+type categoryProxy struct {
+	fakeProxy
+	requested string
+}
+
+func (c *categoryProxy) GetJobCategory(name string) (string, error) {
+	c.requested = name
+	if name != "docker" {
+		return "", errors.New("unknown job category")
+	}
+	return name, nil
+}
+
+// operationProxy is a fakeProxy which knows only the job "1" and
+// fails to suspend it.
+type operationProxy struct {
+	fakeProxy
+}
+
+func (o *operationProxy) JobOperation(jobsessionname, operation, jobid string) (string, error) {
+	if jobid != "1" {
+		return "", ErrJobNotFound
+	}
+	if operation == "suspend" {
+		return "", errors.New("DRM error")
+	}
+	if operation == "hold" {
+		return "", fmt.Errorf("%w: hold", ErrUnsupportedOperation)
+	}
+	return "Terminated Job", nil
+}
+
+var _ = Describe("ProxyHandlers", func() {
+
+	var ts *httptest.Server
+
+	BeforeEach(func() {
+		var ps persistency.DummyPersistency
+		impl := &jobsProxy{jobs: []types.JobInfo{{Id: "3"}, {Id: "1"}, {Id: "4"}, {Id: "2"}}}
+		ts = httptest.NewServer(NewProxyRouter(impl, SecConfig{}, &ps))
+	})
+
+	AfterEach(func() {
+		ts.Close()
+	})
+
+	jobInfos := func(query string) (int, []types.JobInfo, string) {
+		resp, err := http.Get(ts.URL + "/v1/msession/jobinfos" + query)
+		Ω(err).Should(BeNil())
+		defer resp.Body.Close()
+		var jobs []types.JobInfo
+		if resp.StatusCode == http.StatusOK {
+			Ω(json.NewDecoder(resp.Body).Decode(&jobs)).Should(BeNil())
+		}
+		return resp.StatusCode, jobs, resp.Header.Get("X-Total-Count")
+	}
+
+	Context("job info pagination", func() {
+
+		It("should return all jobs with a limit of 0", func() {
+			status, jobs, total := jobInfos("?limit=0")
+			Ω(status).Should(Equal(http.StatusOK))
+			Ω(jobs).Should(HaveLen(4))
+			Ω(total).Should(Equal("4"))
+		})
+
+		It("should return at most 1000 jobs without a limit", func() {
+			var ps persistency.DummyPersistency
+			impl := &jobsProxy{}
+			for i := 0; i < 1500; i++ {
+				impl.jobs = append(impl.jobs, types.JobInfo{Id: strconv.Itoa(i)})
+			}
+			many := httptest.NewServer(NewProxyRouter(impl, SecConfig{}, &ps))
+			defer many.Close()
+			resp, err := http.Get(many.URL + "/v1/msession/jobinfos")
+			Ω(err).Should(BeNil())
+			defer resp.Body.Close()
+			var jobs []types.JobInfo
+			Ω(json.NewDecoder(resp.Body).Decode(&jobs)).Should(BeNil())
+			Ω(jobs).Should(HaveLen(1000))
+			Ω(resp.Header.Get("X-Total-Count")).Should(Equal("1500"))
+		})
+
+		It("should return the requested page sorted by job id", func() {
+			_, jobs, total := jobInfos("?limit=2&offset=1")
+			Ω(jobs).Should(Equal([]types.JobInfo{{Id: "2"}, {Id: "3"}}))
+			Ω(total).Should(Equal("4"))
+			_, jobs, _ = jobInfos("?limit=2&offset=3")
+			Ω(jobs).Should(Equal([]types.JobInfo{{Id: "4"}}))
+			_, jobs, _ = jobInfos("?offset=10")
+			Ω(jobs).Should(BeEmpty())
+		})
+
+		It("should reject invalid pagination parameters", func() {
+			status, _, _ := jobInfos("?limit=-1")
+			Ω(status).Should(Equal(http.StatusBadRequest))
+			status, _, _ = jobInfos("?offset=abc")
+			Ω(status).Should(Equal(http.StatusBadRequest))
+		})
+
+	})
+
+	Context("job owner filter", func() {
+
+		var owners *httptest.Server
+
+		BeforeEach(func() {
+			var ps persistency.DummyPersistency
+			impl := &jobsProxy{jobs: []types.JobInfo{
+				{Id: "1", JobOwner: "alice"}, {Id: "2", JobOwner: "bob"}, {Id: "3", JobOwner: "alice"}}}
+			owners = httptest.NewServer(NewProxyRouter(impl, SecConfig{}, &ps))
+		})
+
+		AfterEach(func() {
+			owners.Close()
+		})
+
+		ownerJobs := func(query string) []types.JobInfo {
+			resp, err := http.Get(owners.URL + "/v1/msession/jobinfos" + query)
+			Ω(err).Should(BeNil())
+			defer resp.Body.Close()
+			var jobs []types.JobInfo
+			Ω(json.NewDecoder(resp.Body).Decode(&jobs)).Should(BeNil())
+			return jobs
+		}
+
+		It("should return the jobs of all users without a user or with user all", func() {
+			Ω(ownerJobs("")).Should(HaveLen(3))
+			Ω(ownerJobs("?user=all")).Should(HaveLen(3))
+		})
+
+		It("should return only the jobs of the requested user", func() {
+			jobs := ownerJobs("?user=alice")
+			Ω(jobs).Should(HaveLen(2))
+			for _, job := range jobs {
+				Ω(job.JobOwner).Should(Equal("alice"))
+			}
+			Ω(ownerJobs("?user=carol")).Should(BeEmpty())
+		})
+
+	})
+
+	Context("submission time filter", func() {
+
+		var submitted *httptest.Server
+
+		BeforeEach(func() {
+			var ps persistency.DummyPersistency
+			now := time.Now()
+			impl := &jobsProxy{jobs: []types.JobInfo{
+				{Id: "1", SubmissionTime: now.Add(-50 * time.Hour)},
+				{Id: "2", SubmissionTime: now.Add(-3 * time.Hour)},
+				{Id: "3", SubmissionTime: now.Add(-10 * time.Minute)}}}
+			submitted = httptest.NewServer(NewProxyRouter(impl, SecConfig{}, &ps))
+		})
+
+		AfterEach(func() {
+			submitted.Close()
+		})
+
+		submittedJobs := func(query string) (int, []string) {
+			resp, err := http.Get(submitted.URL + "/v1/msession/jobinfos" + query)
+			Ω(err).Should(BeNil())
+			defer resp.Body.Close()
+			var jobs []types.JobInfo
+			json.NewDecoder(resp.Body).Decode(&jobs)
+			var ids []string
+			for _, job := range jobs {
+				ids = append(ids, job.Id)
+			}
+			return resp.StatusCode, ids
+		}
+
+		It("should return the jobs submitted within the time window", func() {
+			_, ids := submittedJobs("?since=1d")
+			Ω(ids).Should(Equal([]string{"2", "3"}))
+			_, ids = submittedJobs("?since=1d&until=1h")
+			Ω(ids).Should(Equal([]string{"2"}))
+			_, ids = submittedJobs("?until=" + time.Now().Add(-24*time.Hour).Format(time.RFC3339))
+			Ω(ids).Should(Equal([]string{"1"}))
+		})
+
+		It("should reject invalid times", func() {
+			status, _ := submittedJobs("?until=tomorrow")
+			Ω(status).Should(Equal(http.StatusBadRequest))
+		})
+
+	})
+
+	Context("accounting", func() {
+
+		var acct *httptest.Server
+
+		BeforeEach(func() {
+			var ps persistency.DummyPersistency
+			finished := time.Now().Add(-2 * time.Hour)
+			impl := &jobsProxy{jobs: []types.JobInfo{
+				{Id: "1", JobOwner: "alice", State: types.Done, CPUTime: 10, WallclockTime: time.Minute, FinishTime: finished},
+				{Id: "2", JobOwner: "bob", State: types.Failed, CPUTime: 20, WallclockTime: time.Minute, FinishTime: finished},
+				{Id: "3", JobOwner: "alice", State: types.Done, CPUTime: 30, WallclockTime: time.Minute, FinishTime: time.Now()},
+				{Id: "4", JobOwner: "alice", State: types.Running, CPUTime: 40}}}
+			acct = httptest.NewServer(NewProxyRouter(impl, SecConfig{}, &ps))
+		})
+
+		AfterEach(func() {
+			acct.Close()
+		})
+
+		accounting := func(query string) (int, []types.AccountingSummary) {
+			resp, err := http.Get(acct.URL + "/v1/msession/accounting" + query)
+			Ω(err).Should(BeNil())
+			defer resp.Body.Close()
+			var summaries []types.AccountingSummary
+			if resp.StatusCode == http.StatusOK {
+				Ω(json.NewDecoder(resp.Body).Decode(&summaries)).Should(BeNil())
+			}
+			return resp.StatusCode, summaries
+		}
+
+		It("should sum up the finished jobs per user", func() {
+			status, summaries := accounting("")
+			Ω(status).Should(Equal(http.StatusOK))
+			Ω(summaries).Should(HaveLen(2))
+			Ω(summaries[0].JobOwner).Should(Equal("alice"))
+			Ω(summaries[0].Jobs).Should(Equal(int64(2)))
+			Ω(summaries[0].CPUTime).Should(Equal(int64(40)))
+			Ω(summaries[1].JobOwner).Should(Equal("bob"))
+			Ω(summaries[1].Failed).Should(Equal(int64(1)))
+		})
+
+		It("should filter by user and finish time", func() {
+			_, summaries := accounting("?user=alice&since=1h")
+			Ω(summaries).Should(HaveLen(1))
+			Ω(summaries[0].Jobs).Should(Equal(int64(1)))
+			Ω(summaries[0].CPUTime).Should(Equal(int64(30)))
+			_, summaries = accounting("?user=bob&since=" + time.Now().Add(-3*time.Hour).Format(time.RFC3339))
+			Ω(summaries).Should(HaveLen(1))
+			Ω(summaries[0].JobOwner).Should(Equal("bob"))
+		})
+
+		It("should reject an invalid since parameter", func() {
+			status, _ := accounting("?since=yesterday")
+			Ω(status).Should(Equal(http.StatusBadRequest))
+		})
+
+	})
+
+	Context("submission validation", func() {
+
+		var validating *httptest.Server
+
+		BeforeEach(func() {
+			var ps persistency.DummyPersistency
+			impl := &resourcesProxy{queues: []string{"all.q", "long.q"}, categories: []string{"docker"}}
+			validating = httptest.NewServer(NewProxyRouter(impl, SecConfig{ValidateSubmissions: true}, &ps))
+		})
+
+		AfterEach(func() {
+			validating.Close()
+		})
+
+		submit := func(jt types.JobTemplate) (int, string) {
+			body, err := json.Marshal(jt)
+			Ω(err).Should(BeNil())
+			resp, err := http.Post(validating.URL+"/v1/jsession/default/run", "application/json", bytes.NewReader(body))
+			Ω(err).Should(BeNil())
+			defer resp.Body.Close()
+			var msg bytes.Buffer
+			msg.ReadFrom(resp.Body)
+			return resp.StatusCode, strings.TrimSpace(msg.String())
+		}
+
+		It("should submit jobs with known or unset queues and job categories", func() {
+			status, _ := submit(types.JobTemplate{RemoteCommand: "sleep"})
+			Ω(status).Should(Equal(http.StatusOK))
+			status, _ = submit(types.JobTemplate{RemoteCommand: "sleep", QueueName: "long.q", JobCategory: "docker"})
+			Ω(status).Should(Equal(http.StatusOK))
+		})
+
+		It("should reject jobs with unknown queues or job categories", func() {
+			status, msg := submit(types.JobTemplate{RemoteCommand: "sleep", QueueName: "short.q"})
+			Ω(status).Should(Equal(http.StatusBadRequest))
+			Ω(msg).Should(ContainSubstring("valid queues: all.q, long.q"))
+			status, msg = submit(types.JobTemplate{RemoteCommand: "sleep", JobCategory: "vm"})
+			Ω(status).Should(Equal(http.StatusBadRequest))
+			Ω(msg).Should(ContainSubstring("valid job categories: docker"))
+		})
+
+		It("should not validate submissions when not configured", func() {
+			var ps persistency.DummyPersistency
+			impl := &resourcesProxy{queues: []string{"all.q"}}
+			unchecked := httptest.NewServer(NewProxyRouter(impl, SecConfig{}, &ps))
+			defer unchecked.Close()
+			body, _ := json.Marshal(types.JobTemplate{RemoteCommand: "sleep", QueueName: "short.q"})
+			resp, err := http.Post(unchecked.URL+"/v1/jsession/default/run", "application/json", bytes.NewReader(body))
+			Ω(err).Should(BeNil())
+			resp.Body.Close()
+			Ω(resp.StatusCode).Should(Equal(http.StatusOK))
+		})
+
+	})
+
+	It("should return the slots of the requested queues", func() {
+		var ps persistency.DummyPersistency
+		impl := &slotsProxy{queues: []types.Queue{
+			{Name: "all.q", UsedSlots: 3, TotalSlots: 8},
+			{Name: "long.q", UsedSlots: 4, TotalSlots: 4},
+		}}
+		ss := httptest.NewServer(NewProxyRouter(impl, SecConfig{}, &ps))
+		defer ss.Close()
+
+		resp, err := http.Get(ss.URL + "/v1/msession/queueslots")
+		Ω(err).Should(BeNil())
+		var slots []types.QueueSlots
+		Ω(json.NewDecoder(resp.Body).Decode(&slots)).Should(BeNil())
+		resp.Body.Close()
+		Ω(slots).Should(Equal([]types.QueueSlots{
+			{Name: "all.q", UsedSlots: 3, TotalSlots: 8, FreeSlots: 5},
+			{Name: "long.q", UsedSlots: 4, TotalSlots: 4, FreeSlots: 0},
+		}))
+
+		resp, err = http.Get(ss.URL + "/v1/msession/queueslots?queue=long.q")
+		Ω(err).Should(BeNil())
+		slots = nil
+		Ω(json.NewDecoder(resp.Body).Decode(&slots)).Should(BeNil())
+		resp.Body.Close()
+		Ω(slots).Should(HaveLen(1))
+		Ω(slots[0].Name).Should(Equal("long.q"))
+	})
+
+	It("should look up a single job category at proxies providing it", func() {
+		var ps persistency.DummyPersistency
+		impl := &categoryProxy{}
+		cs := httptest.NewServer(NewProxyRouter(impl, SecConfig{}, &ps))
+		defer cs.Close()
+
+		resp, err := http.Get(cs.URL + "/v1/jsession/default/jobcategory/docker")
+		Ω(err).Should(BeNil())
+		var category string
+		Ω(json.NewDecoder(resp.Body).Decode(&category)).Should(BeNil())
+		resp.Body.Close()
+		Ω(category).Should(Equal("docker"))
+		Ω(impl.requested).Should(Equal("docker"))
+
+		resp, err = http.Get(cs.URL + "/v1/jsession/default/jobcategory/vm")
+		Ω(err).Should(BeNil())
+		resp.Body.Close()
+		Ω(resp.StatusCode).Should(Equal(http.StatusNotFound))
+	})
+
+	It("should not find unknown job categories in the list of all categories", func() {
+		var ps persistency.DummyPersistency
+		impl := &resourcesProxy{categories: []string{"docker"}}
+		cs := httptest.NewServer(NewProxyRouter(impl, SecConfig{}, &ps))
+		defer cs.Close()
+
+		resp, err := http.Get(cs.URL + "/v1/jsession/default/jobcategory/docker")
+		Ω(err).Should(BeNil())
+		var category string
+		Ω(json.NewDecoder(resp.Body).Decode(&category)).Should(BeNil())
+		resp.Body.Close()
+		Ω(category).Should(Equal("docker"))
+
+		resp, err = http.Get(cs.URL + "/v1/jsession/default/jobcategory/vm")
+		Ω(err).Should(BeNil())
+		resp.Body.Close()
+		Ω(resp.StatusCode).Should(Equal(http.StatusNotFound))
+	})
+
+	Context("job operations", func() {
+
+		post := func(path string) (int, string) {
+			var ps persistency.DummyPersistency
+			ops := httptest.NewServer(NewProxyRouter(&operationProxy{}, SecConfig{}, &ps))
+			defer ops.Close()
+			resp, err := http.Post(ops.URL+path, "application/json", nil)
+			Ω(err).Should(BeNil())
+			defer resp.Body.Close()
+			var msg bytes.Buffer
+			msg.ReadFrom(resp.Body)
+			return resp.StatusCode, strings.TrimSpace(msg.String())
+		}
+
+		It("should return the result of a successful operation", func() {
+			status, msg := post("/v1/jsession/ubercluster/terminate/1")
+			Ω(status).Should(Equal(http.StatusOK))
+			Ω(msg).Should(Equal(`"Terminated Job"`))
+		})
+
+		It("should answer unknown job sessions and jobs with not found", func() {
+			status, _ := post("/v1/jsession/other/terminate/1")
+			Ω(status).Should(Equal(http.StatusNotFound))
+			status, _ = post("/v1/jsession/ubercluster/terminate/2")
+			Ω(status).Should(Equal(http.StatusNotFound))
+		})
+
+		It("should answer failed operations with an internal server error", func() {
+			status, msg := post("/v1/jsession/ubercluster/suspend/1")
+			Ω(status).Should(Equal(http.StatusInternalServerError))
+			Ω(msg).Should(Equal("DRM error"))
+		})
+
+		It("should answer unsupported operations with not implemented", func() {
+			status, _ := post("/v1/jsession/ubercluster/hold/1")
+			Ω(status).Should(Equal(http.StatusNotImplemented))
+		})
+
+	})
+
+	Context("path validation", func() {
+
+		post := func(path string) (int, string) {
+			resp, err := http.Post(ts.URL+path, "application/json", nil)
+			Ω(err).Should(BeNil())
+			defer resp.Body.Close()
+			var msg bytes.Buffer
+			msg.ReadFrom(resp.Body)
+			return resp.StatusCode, strings.TrimSpace(msg.String())
+		}
+
+		It("should accept the known job operations", func() {
+			for _, op := range JobOperations {
+				status, _ := post("/v1/jsession/ubercluster/" + op + "/3000000003.1")
+				Ω(status).Should(Equal(http.StatusOK))
+			}
+		})
+
+		It("should reject unknown job operations", func() {
+			status, msg := post("/v1/jsession/ubercluster/delete/1")
+			Ω(status).Should(Equal(http.StatusBadRequest))
+			Ω(msg).Should(ContainSubstring(`unknown operation "delete"`))
+		})
+
+		It("should reject malformed job ids and job session names", func() {
+			for _, path := range []string{
+				"/v1/jsession/ubercluster/terminate/1%202",
+				"/v1/jsession/ubercluster/terminate/1..2",
+				"/v1/jsession/ubercluster/terminate/-rf",
+				"/v1/jsession/uber;cluster/terminate/1",
+				"/v1/jsession/ubercluster/terminate/" + strings.Repeat("1", 257),
+			} {
+				status, _ := post(path)
+				Ω(status).Should(Equal(http.StatusBadRequest), path)
+			}
+			resp, err := http.Get(ts.URL + "/v1/msession/jobinfo/4%3B2")
+			Ω(err).Should(BeNil())
+			resp.Body.Close()
+			Ω(resp.StatusCode).Should(Equal(http.StatusBadRequest))
+			// an escaped slash doesn't match the route
+			status, _ := post("/v1/jsession/ubercluster/terminate/1%2F2")
+			Ω(status).ShouldNot(Equal(http.StatusOK))
+		})
+
+		It("should validate single path parameters", func() {
+			Ω(ValidatePathParam("jobid", "3000000003")).Should(BeNil())
+			Ω(ValidatePathParam("jobid", "task-1@cluster")).Should(BeNil())
+			Ω(ValidatePathParam("jsname", ".hidden")).ShouldNot(BeNil())
+			Ω(ValidatePathParam("jobid", "a..b")).ShouldNot(BeNil())
+			Ω(ValidatePathParam("jsname", "")).ShouldNot(BeNil())
+			// names of files, queues, and machines are only limited in length
+			Ω(ValidatePathParam("name", "all.q")).Should(BeNil())
+			Ω(ValidatePathParam("name", "my file ü.txt")).Should(BeNil())
+			Ω(ValidatePathParam("name", strings.Repeat("a", 257))).ShouldNot(BeNil())
+			Ω(ValidatePathParam("operation", "hold")).Should(BeNil())
+			Ω(ValidatePathParam("operation", "kill")).ShouldNot(BeNil())
+		})
+
+		It("should validate file names", func() {
+			Ω(ValidateFileName("my file ü.txt")).Should(BeNil())
+			Ω(ValidateFileName("a..b")).Should(BeNil())
+			Ω(ValidateFileName("")).ShouldNot(BeNil())
+			Ω(ValidateFileName("..")).ShouldNot(BeNil())
+			Ω(ValidateFileName("../secret")).ShouldNot(BeNil())
+			Ω(ValidateFileName(`..\secret`)).ShouldNot(BeNil())
+		})
+
+		It("should download files with spaces and non-ASCII characters", func() {
+			wd, err := os.Getwd()
+			Ω(err).Should(BeNil())
+			dir, err := ioutil.TempDir("", "proxystaging")
+			Ω(err).Should(BeNil())
+			defer os.RemoveAll(dir)
+			Ω(os.Chdir(dir)).Should(BeNil())
+			defer os.Chdir(wd)
+			Ω(os.Mkdir("uploads", 0700)).Should(BeNil())
+			Ω(ioutil.WriteFile(filepath.Join("uploads", "my file ü.txt"), []byte("content"), 0600)).Should(BeNil())
+
+			resp, err := http.Get(ts.URL + "/v1/jsession/ubercluster/staging/file/my%20file%20%C3%BC.txt")
+			Ω(err).Should(BeNil())
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			Ω(resp.StatusCode).Should(Equal(http.StatusOK))
+			Ω(string(body)).Should(Equal("content"))
+
+			resp, err = http.Get(ts.URL + "/v1/jsession/ubercluster/staging/file/..%2Fuploads%2Fmy%20file%20%C3%BC.txt")
+			Ω(err).Should(BeNil())
+			resp.Body.Close()
+			Ω(resp.StatusCode).ShouldNot(Equal(http.StatusOK))
+		})
+
+	})
+
+	It("should answer requests for unknown jobs with not found", func() {
+		resp, err := http.Get(ts.URL + "/v1/msession/jobinfo/42")
+		Ω(err).Should(BeNil())
+		resp.Body.Close()
+		Ω(resp.StatusCode).Should(Equal(http.StatusNotFound))
+	})
+
+})
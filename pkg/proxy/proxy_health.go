@@ -0,0 +1,98 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/dgruber/ubercluster/pkg/persistency"
+	"github.com/dgruber/ubercluster/pkg/types"
+)
+
+// Version is the build version of the proxy. It can be set at
+// build time with -ldflags "-X github.com/dgruber/ubercluster/pkg/proxy.Version=1.0".
+var Version = "dev"
+
+// ProtocolVersion is the version of the ubercluster protocol the proxy speaks.
+const ProtocolVersion = "v1"
+
+// SupportedProtocolVersions are all versions of the ubercluster protocol
+// the proxy (and uc as client) can speak.
+var SupportedProtocolVersions = []types.ProtocolVersion{types.ProtocolV1}
+
+// drmsTimeout limits the time the health check waits for the DRMS.
+var drmsTimeout = 5 * time.Second
+
+// HealthStatus is the JSON answer of the /healthz endpoint.
+type HealthStatus struct {
+	Status        string  `json:"status"`
+	Uptime        float64 `json:"uptime"` // in seconds
+	DRMSName      string  `json:"drmsName"`
+	DRMSReachable bool    `json:"drmsReachable"`
+}
+
+// VersionInfo is the JSON answer of the /version endpoint.
+type VersionInfo struct {
+	Version         string `json:"version"`
+	ProtocolVersion string `json:"protocolVersion"`
+}
+
+// VersionsInfo is the JSON answer of the /versions endpoint.
+type VersionsInfo struct {
+	Versions []types.ProtocolVersion `json:"versions"`
+}
+
+// drmsName requests the name of the DRMS from the proxy implementation.
+// It returns false when the DRMS does not answer in time or the name
+// is not known.
+func drmsName(impl ProxyImplementer) (string, bool) {
+	answer := make(chan string, 1)
+	go func() {
+		answer <- impl.DRMSName()
+	}()
+	select {
+	case name := <-answer:
+		return name, name != "" && name != "unknown"
+	case <-time.After(drmsTimeout):
+		return "", false
+	}
+}
+
+// MakeHealthzHandler returns an http handler function which returns
+// the uptime of the proxy and if the DRMS is reachable as JSON. It
+// always answers with http.StatusOK as long as the proxy is running.
+func MakeHealthzHandler(impl ProxyImplementer, pi persistency.PersistencyImplementer) http.HandlerFunc {
+	started := time.Now()
+	return func(w http.ResponseWriter, r *http.Request) {
+		name, reachable := drmsName(impl)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(HealthStatus{
+			Status:        "ok",
+			Uptime:        time.Since(started).Seconds(),
+			DRMSName:      name,
+			DRMSReachable: reachable,
+		})
+	}
+}
+
+// MakeVersionHandler returns an http handler function which returns
+// the build version and the protocol version of the proxy as JSON.
+func MakeVersionHandler(impl ProxyImplementer, pi persistency.PersistencyImplementer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(VersionInfo{
+			Version:         Version,
+			ProtocolVersion: ProtocolVersion,
+		})
+	}
+}
+
+// MakeVersionsHandler returns an http handler function which returns
+// the supported protocol versions of the proxy as JSON. Clients use it
+// for selecting the highest protocol version both sides understand.
+func MakeVersionsHandler(impl ProxyImplementer, pi persistency.PersistencyImplementer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(VersionsInfo{Versions: SupportedProtocolVersions})
+	}
+}
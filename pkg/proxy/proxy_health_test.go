@@ -0,0 +1,94 @@
+package proxy_test
+
+import (
+	. "github.com/dgruber/ubercluster/pkg/proxy"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"encoding/json"
+	"github.com/dgruber/ubercluster/pkg/persistency"
+	"github.com/dgruber/ubercluster/pkg/types"
+	"net/http"
+	"net/http/httptest"
+)
+
+// fakeProxy is a ProxyImplementer which returns fixed values.
+type fakeProxy struct {
+	drmsName string
+}
+
+func (f *fakeProxy) GetJobInfosByFilter(filtered bool, filter types.JobInfo) []types.JobInfo {
+	return []types.JobInfo{}
+}
+func (f *fakeProxy) GetJobInfo(jobid string) *types.JobInfo                    { return nil }
+func (f *fakeProxy) GetAllMachines(machines []string) ([]types.Machine, error) { return nil, nil }
+func (f *fakeProxy) GetAllQueues(queues []string) ([]types.Queue, error)       { return nil, nil }
+func (f *fakeProxy) GetAllCategories() ([]string, error)                       { return nil, nil }
+func (f *fakeProxy) GetAllSessions(session []string) ([]string, error)         { return nil, nil }
+func (f *fakeProxy) DRMSVersion() string                                       { return "1.0" }
+func (f *fakeProxy) DRMSName() string                                          { return f.drmsName }
+func (f *fakeProxy) RunJob(template types.JobTemplate) (string, error)         { return "1", nil }
+func (f *fakeProxy) JobOperation(jobsessionname, operation, jobid string) (string, error) {
+	return "success", nil
+}
+func (f *fakeProxy) DRMSLoad() float64 { return 0.5 }
+
+var _ = Describe("ProxyHealth", func() {
+
+	var ts *httptest.Server
+
+	BeforeEach(func() {
+		var ps persistency.DummyPersistency
+		router := NewProxyRouter(&fakeProxy{drmsName: "fake"}, SecConfig{OTP: "secret"}, &ps)
+		ts = httptest.NewServer(router)
+	})
+
+	AfterEach(func() {
+		ts.Close()
+	})
+
+	Context("basic functions", func() {
+
+		It("should report the health without a one time password", func() {
+			resp, err := http.Get(ts.URL + "/healthz")
+			Ω(err).Should(BeNil())
+			defer resp.Body.Close()
+			Ω(resp.StatusCode).Should(Equal(http.StatusOK))
+			var status HealthStatus
+			Ω(json.NewDecoder(resp.Body).Decode(&status)).Should(BeNil())
+			Ω(status.Status).Should(Equal("ok"))
+			Ω(status.DRMSName).Should(Equal("fake"))
+			Ω(status.DRMSReachable).Should(BeTrue())
+		})
+
+		It("should report the version", func() {
+			resp, err := http.Get(ts.URL + "/version")
+			Ω(err).Should(BeNil())
+			defer resp.Body.Close()
+			var version VersionInfo
+			Ω(json.NewDecoder(resp.Body).Decode(&version)).Should(BeNil())
+			Ω(version.Version).Should(Equal(Version))
+			Ω(version.ProtocolVersion).Should(Equal("v1"))
+		})
+
+		It("should report the supported protocol versions", func() {
+			resp, err := http.Get(ts.URL + "/versions")
+			Ω(err).Should(BeNil())
+			defer resp.Body.Close()
+			Ω(resp.StatusCode).Should(Equal(http.StatusOK))
+			var versions VersionsInfo
+			Ω(json.NewDecoder(resp.Body).Decode(&versions)).Should(BeNil())
+			Ω(versions.Versions).Should(Equal(SupportedProtocolVersions))
+		})
+
+		It("should still protect the other endpoints", func() {
+			resp, err := http.Get(ts.URL + "/v1/msession/drmsload")
+			Ω(err).Should(BeNil())
+			resp.Body.Close()
+			Ω(resp.StatusCode).Should(Equal(http.StatusUnauthorized))
+		})
+
+	})
+
+})
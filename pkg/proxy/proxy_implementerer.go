@@ -1,9 +1,19 @@
 package proxy
 
 import (
+	"errors"
 	"github.com/dgruber/ubercluster/pkg/types"
 )
 
+// ErrJobNotFound is returned (or wrapped) by JobOperation when the job
+// is not known, so that the proxy can answer with 404 Not Found.
+var ErrJobNotFound = errors.New("job not found")
+
+// ErrUnsupportedOperation is returned (or wrapped) by JobOperation when
+// the DRM doesn't support the operation (like hold for processes), so
+// that the proxy can answer with 501 Not Implemented.
+var ErrUnsupportedOperation = errors.New("unsupported operation")
+
 // ProxyImplementer interface specified functions required to interface
 // a ubercluster proxy. Those functions are called in the standard
 // http request handlers.
@@ -20,3 +30,22 @@ type ProxyImplementer interface {
 	JobOperation(jobsessionname, operation, jobid string) (string, error)
 	DRMSLoad() float64
 }
+
+// ArrayJobRunner is an optional interface which can be implemented
+// by a proxy in order to support array job (bulk job) submission.
+type ArrayJobRunner interface {
+	RunArrayJob(template types.JobTemplate, begin, end, step, maxParallel int) (string, error)
+}
+
+// JobOutputProvider is an optional interface which can be implemented
+// by a proxy in order to give access to the output file of a job.
+type JobOutputProvider interface {
+	JobOutputPath(jobsessionname, jobid string) (string, error)
+}
+
+// JobCategoryProvider is an optional interface which can be implemented
+// by a proxy in order to look up a single job category in the DRMS
+// instead of searching it in the list of all categories.
+type JobCategoryProvider interface {
+	GetJobCategory(name string) (string, error)
+}
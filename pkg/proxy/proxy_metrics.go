@@ -0,0 +1,222 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgruber/ubercluster/pkg/persistency"
+)
+
+// durationBuckets are the upper bounds (in seconds) of the request
+// duration histogram.
+var durationBuckets = []float64{0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10}
+
+// requestLabels identify the requests counted together.
+type requestLabels struct {
+	route  string
+	method string
+	code   int
+}
+
+// operationLabels identify the job operations counted together.
+type operationLabels struct {
+	operation string
+	result    string
+}
+
+// histogram counts observed durations in buckets.
+type histogram struct {
+	buckets []int64 // cumulative, one per durationBuckets entry
+	count   int64
+	sum     float64
+}
+
+func (h *histogram) observe(seconds float64) {
+	for i, bound := range durationBuckets {
+		if seconds <= bound {
+			h.buckets[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// Metrics collects the request and job operation metrics of the proxy
+// which are exported in the Prometheus text format at /metrics. It can
+// be used by multiple goroutines at the same time.
+type Metrics struct {
+	sync.Mutex
+	requests   map[requestLabels]int64
+	durations  map[string]*histogram // by route
+	operations map[operationLabels]int64
+}
+
+// NewMetrics returns an empty metrics registry.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		requests:   make(map[requestLabels]int64),
+		durations:  make(map[string]*histogram),
+		operations: make(map[operationLabels]int64),
+	}
+}
+
+// DefaultMetrics is the registry updated by the http handlers of the
+// proxy and exported by MakeMetricsHandler when the router is not
+// configured with another one (SecConfig.Metrics).
+var DefaultMetrics = NewMetrics()
+
+type metricsContextKey struct{}
+
+// metricsFromContext returns the registry of the request which is set
+// by MakeMetricsRecordingHandler or the DefaultMetrics.
+func metricsFromContext(ctx context.Context) *Metrics {
+	if m, ok := ctx.Value(metricsContextKey{}).(*Metrics); ok {
+		return m
+	}
+	return DefaultMetrics
+}
+
+// ObserveRequest counts a finished request of the route.
+func (m *Metrics) ObserveRequest(route, method string, code int, duration time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+	m.requests[requestLabels{route: route, method: method, code: code}]++
+	h, exists := m.durations[route]
+	if !exists {
+		h = &histogram{buckets: make([]int64, len(durationBuckets))}
+		m.durations[route] = h
+	}
+	h.observe(duration.Seconds())
+}
+
+// ObserveJobOperation counts a job submission or a job operation
+// (like "terminate") and whether it succeeded.
+func (m *Metrics) ObserveJobOperation(operation string, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	m.Lock()
+	defer m.Unlock()
+	m.operations[operationLabels{operation: operation, result: result}]++
+}
+
+// WriteTo writes the metrics in the Prometheus text format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.Lock()
+	defer m.Unlock()
+	var b strings.Builder
+
+	requests := make([]requestLabels, 0, len(m.requests))
+	for l := range m.requests {
+		requests = append(requests, l)
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		x, y := requests[i], requests[j]
+		if x.route != y.route {
+			return x.route < y.route
+		}
+		if x.method != y.method {
+			return x.method < y.method
+		}
+		return x.code < y.code
+	})
+	writeHeader(&b, "ubercluster_proxy_requests_total", "counter", "Amount of handled http requests.")
+	for _, l := range requests {
+		fmt.Fprintf(&b, "ubercluster_proxy_requests_total{route=%s,method=%s,code=\"%d\"} %d\n",
+			labelValue(l.route), labelValue(l.method), l.code, m.requests[l])
+	}
+
+	routes := make([]string, 0, len(m.durations))
+	for route := range m.durations {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	writeHeader(&b, "ubercluster_proxy_request_duration_seconds", "histogram", "Duration of handled http requests.")
+	for _, route := range routes {
+		h := m.durations[route]
+		for i, bound := range durationBuckets {
+			fmt.Fprintf(&b, "ubercluster_proxy_request_duration_seconds_bucket{route=%s,le=\"%g\"} %d\n",
+				labelValue(route), bound, h.buckets[i])
+		}
+		fmt.Fprintf(&b, "ubercluster_proxy_request_duration_seconds_bucket{route=%s,le=\"+Inf\"} %d\n", labelValue(route), h.count)
+		fmt.Fprintf(&b, "ubercluster_proxy_request_duration_seconds_sum{route=%s} %g\n", labelValue(route), h.sum)
+		fmt.Fprintf(&b, "ubercluster_proxy_request_duration_seconds_count{route=%s} %d\n", labelValue(route), h.count)
+	}
+
+	operations := make([]operationLabels, 0, len(m.operations))
+	for l := range m.operations {
+		operations = append(operations, l)
+	}
+	sort.Slice(operations, func(i, j int) bool {
+		if operations[i].operation != operations[j].operation {
+			return operations[i].operation < operations[j].operation
+		}
+		return operations[i].result < operations[j].result
+	})
+	writeHeader(&b, "ubercluster_proxy_job_operations_total", "counter", "Amount of job submissions and job operations.")
+	for _, l := range operations {
+		fmt.Fprintf(&b, "ubercluster_proxy_job_operations_total{operation=%s,result=%s} %d\n",
+			labelValue(l.operation), labelValue(l.result), m.operations[l])
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// writeHeader writes the HELP and TYPE lines of a metric.
+func writeHeader(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// labelValue returns the quoted and escaped label value.
+func labelValue(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value) + `"`
+}
+
+// statusRecorder remembers the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (w *statusRecorder) WriteHeader(code int) {
+	w.code = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Flush keeps streaming handlers (like the job events) working.
+func (w *statusRecorder) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// MakeMetricsRecordingHandler counts the requests of the route and
+// their durations in the given registry. The handlers find the registry
+// in the request context for counting the job operations.
+func MakeMetricsRecordingHandler(m *Metrics, route string, f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+		f(rec, r.WithContext(context.WithValue(r.Context(), metricsContextKey{}, m)))
+		m.ObserveRequest(route, r.Method, rec.code, time.Since(start))
+	}
+}
+
+// MakeMetricsHandler returns an http handler function which exports
+// the metrics registry of the request in the Prometheus text format.
+// Only counters are exported so that scraping doesn't put load on the
+// cluster.
+func MakeMetricsHandler(impl ProxyImplementer, pi persistency.PersistencyImplementer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		metricsFromContext(r.Context()).WriteTo(w)
+	}
+}
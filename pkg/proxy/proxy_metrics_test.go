@@ -0,0 +1,69 @@
+package proxy_test
+
+import (
+	. "github.com/dgruber/ubercluster/pkg/proxy"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"bytes"
+	"errors"
+	"github.com/dgruber/ubercluster/pkg/persistency"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+var _ = Describe("ProxyMetrics", func() {
+
+	It("should export requests, durations, and job operations", func() {
+		m := NewMetrics()
+		m.ObserveRequest("JobSubmit", "POST", http.StatusOK, 20*time.Millisecond)
+		m.ObserveRequest("JobSubmit", "POST", http.StatusOK, 2*time.Second)
+		m.ObserveJobOperation("submit", nil)
+		m.ObserveJobOperation("submit", errors.New("failed"))
+		var out bytes.Buffer
+		_, err := m.WriteTo(&out)
+		Ω(err).Should(BeNil())
+		Ω(out.String()).Should(ContainSubstring("# TYPE ubercluster_proxy_requests_total counter\n"))
+		Ω(out.String()).Should(ContainSubstring(`ubercluster_proxy_requests_total{route="JobSubmit",method="POST",code="200"} 2`))
+		Ω(out.String()).Should(ContainSubstring(`ubercluster_proxy_request_duration_seconds_bucket{route="JobSubmit",le="0.05"} 1`))
+		Ω(out.String()).Should(ContainSubstring(`ubercluster_proxy_request_duration_seconds_bucket{route="JobSubmit",le="5"} 2`))
+		Ω(out.String()).Should(ContainSubstring(`ubercluster_proxy_request_duration_seconds_count{route="JobSubmit"} 2`))
+		Ω(out.String()).Should(ContainSubstring(`ubercluster_proxy_job_operations_total{operation="submit",result="failure"} 1`))
+		Ω(out.String()).Should(ContainSubstring(`ubercluster_proxy_job_operations_total{operation="submit",result="success"} 1`))
+	})
+
+	It("should serve the counters of the configured registry only with a one time password", func() {
+		var ps persistency.DummyPersistency
+		m := NewMetrics()
+		ts := httptest.NewServer(NewProxyRouter(&fakeProxy{}, SecConfig{OTP: "secret", Metrics: m}, &ps))
+		defer ts.Close()
+
+		resp, err := http.Post(ts.URL+"/v1/jsession/ubercluster/terminate/1?otp=secret", "application/json", nil)
+		Ω(err).Should(BeNil())
+		resp.Body.Close()
+		resp, err = http.Get(ts.URL + "/v1/msession/jobinfos")
+		Ω(err).Should(BeNil())
+		resp.Body.Close()
+		Ω(resp.StatusCode).Should(Equal(http.StatusUnauthorized))
+
+		resp, err = http.Get(ts.URL + "/metrics")
+		Ω(err).Should(BeNil())
+		resp.Body.Close()
+		Ω(resp.StatusCode).Should(Equal(http.StatusUnauthorized))
+
+		resp, err = http.Get(ts.URL + "/metrics?otp=secret")
+		Ω(err).Should(BeNil())
+		defer resp.Body.Close()
+		Ω(resp.StatusCode).Should(Equal(http.StatusOK))
+		body, err := ioutil.ReadAll(resp.Body)
+		Ω(err).Should(BeNil())
+		Ω(string(body)).Should(ContainSubstring(`ubercluster_proxy_requests_total{route="msessionJobInfos",method="GET",code="401"} 1`))
+		Ω(string(body)).Should(ContainSubstring(`ubercluster_proxy_requests_total{route="metrics",method="GET",code="401"} 1`))
+		Ω(string(body)).Should(ContainSubstring(`ubercluster_proxy_job_operations_total{operation="terminate",result="success"} 1`))
+		Ω(string(body)).ShouldNot(ContainSubstring("ubercluster_proxy_jobs"))
+	})
+
+})
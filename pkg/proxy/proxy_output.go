@@ -0,0 +1,97 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/dgruber/ubercluster/pkg/persistency"
+	"github.com/gorilla/mux"
+)
+
+// outputPollInterval defines how often the output file of a job
+// is checked for new content when following the output.
+var outputPollInterval = 500 * time.Millisecond
+
+// jobFinished returns true when the job is in an end state or
+// when it is not known anymore.
+func jobFinished(impl ProxyImplementer, jobid string) bool {
+	ji := impl.GetJobInfo(jobid)
+	return ji == nil || ji.State.IsTerminal()
+}
+
+// waitForNextPoll blocks until the next poll interval or returns false
+// when the client closed the connection or the proxy shuts down.
+func waitForNextPoll(r *http.Request) bool {
+	select {
+	case <-r.Context().Done():
+		return false
+	case <-shuttingDown(r):
+		return false
+	case <-time.After(outputPollInterval):
+		return true
+	}
+}
+
+// openJobOutput opens the output file of a job. When following the
+// output it waits until the file is created as long as the job runs.
+func openJobOutput(impl ProxyImplementer, r *http.Request, path, jobid string, follow bool) (*os.File, error) {
+	for {
+		finished := jobFinished(impl, jobid)
+		file, err := os.Open(path)
+		if err == nil || !follow || finished || !waitForNextPoll(r) {
+			return file, err
+		}
+	}
+}
+
+// MakeJobOutputHandler returns an http handler function which streams the
+// output file of a job to the client. When the "follow" form value is set
+// to "true" the file is tailed until the job is finished or the client
+// closes the connection.
+func MakeJobOutputHandler(impl ProxyImplementer, pi persistency.PersistencyImplementer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		provider, ok := impl.(JobOutputProvider)
+		if !ok {
+			http.Error(w, "job output is not supported by the proxy", http.StatusNotImplemented)
+			return
+		}
+		vars := mux.Vars(r)
+		jobid := vars["jobid"]
+		path, err := provider.JobOutputPath(vars["jsname"], jobid)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		follow := r.FormValue("follow") == "true"
+		file, err := openJobOutput(impl, r, path, jobid, follow)
+		if err != nil {
+			logRequest(r, "Could not open job output: ", err)
+			http.Error(w, "job output is not available", http.StatusNotFound)
+			return
+		}
+		defer file.Close()
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		flusher, _ := w.(http.Flusher)
+		for {
+			// check the state before reading so that the output written
+			// before the job finished is always sent
+			finished := !follow || jobFinished(impl, jobid)
+			if _, err := io.Copy(w, file); err != nil {
+				logRequest(r, "Error while sending job output: ", err)
+				return
+			}
+			if finished {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+			if !waitForNextPoll(r) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package proxy_test
+
+import (
+	. "github.com/dgruber/ubercluster/pkg/proxy"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/dgruber/ubercluster/pkg/persistency"
+	"github.com/dgruber/ubercluster/pkg/types"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"time"
+)
+
+// outputProxy is a fakeProxy which provides the output file of a job.
+type outputProxy struct {
+	fakeProxy
+	sync.Mutex
+	path  string
+	state types.JobState
+}
+
+func (o *outputProxy) JobOutputPath(jobsessionname, jobid string) (string, error) {
+	return o.path, nil
+}
+
+func (o *outputProxy) GetJobInfo(jobid string) *types.JobInfo {
+	o.Lock()
+	defer o.Unlock()
+	return &types.JobInfo{Id: jobid, State: o.state}
+}
+
+func (o *outputProxy) setState(state types.JobState) {
+	o.Lock()
+	defer o.Unlock()
+	o.state = state
+}
+
+var _ = Describe("ProxyOutput", func() {
+
+	var ps persistency.DummyPersistency
+
+	get := func(impl ProxyImplementer, request string) (int, string) {
+		ts := httptest.NewServer(NewProxyRouter(impl, SecConfig{}, &ps))
+		defer ts.Close()
+		resp, err := http.Get(ts.URL + request)
+		Ω(err).Should(BeNil())
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		Ω(err).Should(BeNil())
+		return resp.StatusCode, string(body)
+	}
+
+	Context("basic functions", func() {
+
+		var out *os.File
+
+		BeforeEach(func() {
+			var err error
+			out, err = ioutil.TempFile("", "proxyOutput")
+			Ω(err).Should(BeNil())
+			out.WriteString("line 1\n")
+		})
+
+		AfterEach(func() {
+			out.Close()
+			os.Remove(out.Name())
+		})
+
+		It("should return the output of a job", func() {
+			impl := &outputProxy{path: out.Name(), state: types.Running}
+			status, body := get(impl, "/v1/jsession/default/job/1/output")
+			Ω(status).Should(Equal(http.StatusOK))
+			Ω(body).Should(Equal("line 1\n"))
+		})
+
+		It("should follow the output of a job until it is finished", func() {
+			impl := &outputProxy{path: out.Name(), state: types.Running}
+			go func() {
+				time.Sleep(100 * time.Millisecond)
+				out.WriteString("line 2\n")
+				impl.setState(types.Done)
+			}()
+			status, body := get(impl, "/v1/jsession/default/job/1/output?follow=true")
+			Ω(status).Should(Equal(http.StatusOK))
+			Ω(body).Should(Equal("line 1\nline 2\n"))
+		})
+
+	})
+
+	Context("error cases", func() {
+
+		It("should answer with not implemented when the proxy has no job output", func() {
+			status, _ := get(&fakeProxy{}, "/v1/jsession/default/job/1/output")
+			Ω(status).Should(Equal(http.StatusNotImplemented))
+		})
+
+		It("should answer with not found when the output file does not exist", func() {
+			impl := &outputProxy{path: "/does/not/exist", state: types.Done}
+			status, _ := get(impl, "/v1/jsession/default/job/1/output?follow=true")
+			Ω(status).Should(Equal(http.StatusNotFound))
+		})
+
+	})
+
+})
@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RateLimiter limits the amount of requests per client (remote address)
+// using a token bucket per client. Each bucket holds up to burst tokens
+// and is refilled with rate tokens per second. A request consumes one token.
+type RateLimiter struct {
+	sync.Mutex
+	rate      float64
+	burst     float64
+	buckets   map[string]*tokenBucket
+	lastPurge time.Time
+	now       func() time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a rate limiter which allows rate requests per
+// second and client with bursts of up to burst requests. If burst is
+// smaller than 1 it is set to 1.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+// Allow consumes a token of the bucket of the given client. If no token
+// is left it returns false and the duration after which the next token
+// is available.
+func (rl *RateLimiter) Allow(client string) (bool, time.Duration) {
+	rl.Lock()
+	defer rl.Unlock()
+
+	now := rl.now()
+	rl.purge(now)
+
+	bucket, exists := rl.buckets[client]
+	if !exists {
+		bucket = &tokenBucket{tokens: rl.burst, last: now}
+		rl.buckets[client] = bucket
+	}
+	bucket.tokens = math.Min(rl.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*rl.rate)
+	bucket.last = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - bucket.tokens) / rl.rate * float64(time.Second))
+	return false, wait
+}
+
+// purge removes the buckets of clients which are refilled completely
+// so that the amount of buckets does not grow without limits.
+func (rl *RateLimiter) purge(now time.Time) {
+	if now.Sub(rl.lastPurge) < time.Minute {
+		return
+	}
+	rl.lastPurge = now
+	refill := time.Duration(rl.burst / rl.rate * float64(time.Second))
+	for client, bucket := range rl.buckets {
+		if now.Sub(bucket.last) > refill {
+			delete(rl.buckets, client)
+		}
+	}
+}
+
+// MakeRateLimitHandler protects an http handler by a rate limiter. The
+// clients are distinguished by the host part of their remote address.
+// When a client exceeds its rate the request is rejected with
+// http.StatusTooManyRequests and a Retry-After header (in seconds).
+func MakeRateLimitHandler(rl *RateLimiter, f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		client, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			client = r.RemoteAddr
+		}
+		if ok, wait := rl.Allow(client); !ok {
+			logRequest(r, "Rate limit exceeded by ", r.RemoteAddr)
+			w.Header().Set("Retry-After", fmt.Sprintf("%d", int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+		f(w, r)
+	}
+}
@@ -0,0 +1,47 @@
+package proxy_test
+
+import (
+	. "github.com/dgruber/ubercluster/pkg/proxy"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"net/http"
+	"net/http/httptest"
+)
+
+var _ = Describe("ProxyRatelimit", func() {
+
+	request := func(h http.HandlerFunc, remoteAddr string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("GET", "/v1/msession/drmsload", nil)
+		r.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		h(w, r)
+		return w
+	}
+
+	Context("basic functions", func() {
+
+		It("should allow bursts up to the configured size", func() {
+			rl := NewRateLimiter(0.01, 3)
+			for i := 0; i < 3; i++ {
+				ok, _ := rl.Allow("client")
+				Ω(ok).Should(BeTrue())
+			}
+			ok, wait := rl.Allow("client")
+			Ω(ok).Should(BeFalse())
+			Ω(wait.Seconds()).Should(BeNumerically(">", 0))
+		})
+
+		It("should throttle each client on its own", func() {
+			h := MakeRateLimitHandler(NewRateLimiter(0.01, 1), func(w http.ResponseWriter, r *http.Request) {})
+			Ω(request(h, "10.0.0.1:4711").Code).Should(Equal(http.StatusOK))
+			Ω(request(h, "10.0.0.2:4711").Code).Should(Equal(http.StatusOK))
+			w := request(h, "10.0.0.1:4712")
+			Ω(w.Code).Should(Equal(http.StatusTooManyRequests))
+			Ω(w.Header().Get("Retry-After")).Should(Equal("100"))
+		})
+
+	})
+
+})
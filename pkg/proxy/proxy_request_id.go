@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"context"
+	"github.com/dgruber/ubercluster/pkg/http_helper"
+	"github.com/dgruber/ubercluster/pkg/persistency"
+	"log"
+	"net/http"
+	"time"
+)
+
+// ContextProxyImplementer is an optional interface which can be
+// implemented by a proxy which forwards requests to other proxies
+// (like uc in inception mode). For each request the proxy is replaced
+// by the one returned by WithContext so that the id of the incoming
+// request can be passed on.
+type ContextProxyImplementer interface {
+	WithContext(ctx context.Context) ProxyImplementer
+}
+
+// validRequestID checks that a request id received from a client is
+// safe to be written into the log.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// MakeRequestIDHandler assigns an id to each request which is taken
+// from the X-Uber-Request-Id header or newly created. The id is stored
+// in the context of the request, returned in the response header, and
+// is part of all log lines written for the request.
+func MakeRequestIDHandler(f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(http_helper.RequestIDHeader)
+		if !validRequestID(id) {
+			id = http_helper.NewRequestID()
+		}
+		w.Header().Set(http_helper.RequestIDHeader, id)
+		r = r.WithContext(http_helper.WithRequestID(r.Context(), id))
+		start := time.Now()
+		logRequestf(r, "%s %s from %s\n", r.Method, r.URL.Path, r.RemoteAddr)
+		f(w, r)
+		logRequestf(r, "%s %s finished after %s\n", r.Method, r.URL.Path, time.Since(start))
+	}
+}
+
+// makeHandler creates the http handler function of the route. For
+// proxies which implement the ContextProxyImplementer interface the
+// handler is created for each request with the proxy returned by
+// WithContext.
+func makeHandler(route Route, impl ProxyImplementer, pi persistency.PersistencyImplementer) http.HandlerFunc {
+	ci, ok := impl.(ContextProxyImplementer)
+	if !ok {
+		return route.MakeHandlerFunc(impl, pi)
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		route.MakeHandlerFunc(ci.WithContext(r.Context()), pi)(w, r)
+	}
+}
+
+// requestTag returns the prefix of the log lines of a request.
+func requestTag(r *http.Request) string {
+	return "[" + http_helper.RequestIDFromContext(r.Context()) + "]"
+}
+
+// logRequest logs like log.Println with the id of the request as prefix.
+func logRequest(r *http.Request, v ...interface{}) {
+	log.Println(append([]interface{}{requestTag(r)}, v...)...)
+}
+
+// logRequestf logs like log.Printf with the id of the request as prefix.
+func logRequestf(r *http.Request, format string, v ...interface{}) {
+	log.Printf(requestTag(r)+" "+format, v...)
+}
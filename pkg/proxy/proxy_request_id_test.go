@@ -0,0 +1,67 @@
+package proxy_test
+
+import (
+	"github.com/dgruber/ubercluster/pkg/http_helper"
+	. "github.com/dgruber/ubercluster/pkg/proxy"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+)
+
+// contextProxy returns the request id as DRMS name
+type contextProxy struct {
+	fakeProxy
+}
+
+func (c *contextProxy) WithContext(ctx context.Context) ProxyImplementer {
+	return &fakeProxy{drmsName: http_helper.RequestIDFromContext(ctx)}
+}
+
+var _ = Describe("ProxyRequestId", func() {
+
+	get := func(ts *httptest.Server, id string) *http.Response {
+		r, _ := http.NewRequest("GET", ts.URL+"/v1/msession/drmsname", nil)
+		if id != "" {
+			r.Header.Set(http_helper.RequestIDHeader, id)
+		}
+		resp, err := http.DefaultClient.Do(r)
+		Ω(err).Should(BeNil())
+		return resp
+	}
+
+	It("should return the request id of the client", func() {
+		ts := httptest.NewServer(NewProxyRouter(&fakeProxy{drmsName: "fake"}, SecConfig{}, nil))
+		defer ts.Close()
+		resp := get(ts, "abc-123")
+		resp.Body.Close()
+		Ω(resp.Header.Get(http_helper.RequestIDHeader)).Should(Equal("abc-123"))
+	})
+
+	It("should create a request id if the client sent none or an invalid one", func() {
+		ts := httptest.NewServer(NewProxyRouter(&fakeProxy{drmsName: "fake"}, SecConfig{}, nil))
+		defer ts.Close()
+		resp := get(ts, "")
+		resp.Body.Close()
+		Ω(resp.Header.Get(http_helper.RequestIDHeader)).ShouldNot(BeEmpty())
+		resp = get(ts, "bad id;rm")
+		resp.Body.Close()
+		Ω(resp.Header.Get(http_helper.RequestIDHeader)).ShouldNot(Equal("bad id;rm"))
+		Ω(resp.Header.Get(http_helper.RequestIDHeader)).ShouldNot(BeEmpty())
+	})
+
+	It("should pass the request id to a ContextProxyImplementer", func() {
+		ts := httptest.NewServer(NewProxyRouter(&contextProxy{}, SecConfig{}, nil))
+		defer ts.Close()
+		resp := get(ts, "forwarded")
+		defer resp.Body.Close()
+		var name string
+		Ω(json.NewDecoder(resp.Body).Decode(&name)).Should(BeNil())
+		Ω(name).Should(Equal("forwarded"))
+	})
+
+})
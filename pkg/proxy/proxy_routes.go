@@ -41,9 +41,18 @@ var routes = Routes{
 	Route{
 		"JobSubmit", "POST", "/v1/jsession/{jsname}/run", MakeJSessionSubmitHandler,
 	},
+	Route{
+		"ArrayJobSubmit", "POST", "/v1/jsession/{jsname}/runbulk", MakeJSessionBulkSubmitHandler,
+	},
 	// Operations are: suspend resume delete (hold / release)
 	Route{
-		"JobManipulation", "POST", "/v1/jsession/{jsname}/{operation:suspend|resume|terminate}/{jobid}", MakeJSessionJobManipulationHandler,
+		"JobManipulation", "POST", "/v1/jsession/{jsname}/{operation}/{jobid}", MakeJSessionJobManipulationHandler,
+	},
+	Route{
+		"JobOutput", "GET", "/v1/jsession/{jsname}/job/{jobid}/output", MakeJobOutputHandler,
+	},
+	Route{
+		"JobEvents", "GET", "/v1/jsession/{jsname}/events", MakeJSessionEventsHandler,
 	},
 	Route{
 		"JobCategories", "GET", "/v1/jsession/{jsname}/jobcategories", MakeJSessionCategoriesHandler,
@@ -57,6 +66,9 @@ var routes = Routes{
 	Route{
 		"jobid", "GET", "/v1/msession/jobinfo/{jobid}", MakeMSessionJobInfoHandler,
 	},
+	Route{
+		"msessionAccounting", "GET", "/v1/msession/accounting", MakeAccountingHandler,
+	},
 	Route{
 		"msessionMachines", "GET", "/v1/msession/machines", MakeMachinesHandler,
 	},
@@ -69,6 +81,9 @@ var routes = Routes{
 	Route{
 		"msessionQueue", "GET", "/v1/msession/queue/{name}", MakeQueueHandler,
 	},
+	Route{
+		"msessionQueueSlots", "GET", "/v1/msession/queueslots", MakeQueueSlotsHandler,
+	},
 	Route{
 		"msessionDRMSName", "GET", "/v1/msession/drmsname", MakeMSessionDRMSNameHandler,
 	},
@@ -93,6 +108,23 @@ var routes = Routes{
 	Route{
 		"runLocal", "POST", "/v1/local/run", MakeRunLocalHandler,
 	},
+	Route{
+		"metrics", "GET", "/metrics", MakeMetricsHandler,
+	},
+}
+
+// publicRoutes are not protected by the one time password since
+// they are used by load balancers and monitoring systems.
+var publicRoutes = Routes{
+	Route{
+		"healthz", "GET", "/healthz", MakeHealthzHandler,
+	},
+	Route{
+		"version", "GET", "/version", MakeVersionHandler,
+	},
+	Route{
+		"versions", "GET", "/versions", MakeVersionsHandler,
+	},
 }
 
 // MakeFixedSecretHandler protects an http handler by a simple shared secret
@@ -111,7 +143,7 @@ func MakeFixedSecretHandler(secret string, f http.HandlerFunc) http.HandlerFunc
 			if otpFromClient == secret {
 				f(w, r)
 			} else {
-				log.Println("Unauthorized access by ", r.RemoteAddr)
+				logRequest(r, "Unauthorized access by ", r.RemoteAddr)
 				// slow down
 				http.Error(w, "authorization failed", http.StatusUnauthorized)
 				return
@@ -151,23 +183,23 @@ func MakeYubikeyHandler(id, key string, allowedIDs []string, f http.HandlerFunc)
 		}
 		// check if ID of OTP is allowed (first 12 chars)
 		if len(otpFromClient) != 44 {
-			log.Println("Unauthorized access by ", r.RemoteAddr)
-			log.Printf("Length of OTP does not match 44: %d", len(otpFromClient))
+			logRequest(r, "Unauthorized access by ", r.RemoteAddr)
+			logRequestf(r, "Length of OTP does not match 44: %d", len(otpFromClient))
 			http.Error(w, "authorization failed", http.StatusUnauthorized)
 		}
 
 		id := otpFromClient[0:12]
 		found := false
 		for _, v := range allowedIDs {
-			log.Printf("Compare %s with %s\n", v, id)
+			logRequestf(r, "Compare %s with %s\n", v, id)
 			if v == id {
 				found = true
 				break
 			}
 		}
 		if found == false {
-			log.Println("Unauthorized access by ", r.RemoteAddr)
-			log.Printf("ID %s not in list of allowed IDs", id)
+			logRequest(r, "Unauthorized access by ", r.RemoteAddr)
+			logRequestf(r, "ID %s not in list of allowed IDs", id)
 			http.Error(w, "authorization failed", http.StatusUnauthorized)
 			return
 		}
@@ -180,30 +212,102 @@ func MakeYubikeyHandler(id, key string, allowedIDs []string, f http.HandlerFunc)
 			if err != nil {
 				// something really bad! probably best to abort
 				fmt.Println("Verification of yubikey failed with error: ", err)
-				log.Println("Unauthorized access by ", r.RemoteAddr)
+				logRequest(r, "Unauthorized access by ", r.RemoteAddr)
 				http.Error(w, "authorization failed", http.StatusUnauthorized)
 			} else {
-				log.Println("Verification of yubikey OTP failed: ", result)
-				log.Println("Unauthorized access by ", r.RemoteAddr)
+				logRequest(r, "Verification of yubikey OTP failed: ", result)
+				logRequest(r, "Unauthorized access by ", r.RemoteAddr)
 				http.Error(w, "authorization failed", http.StatusUnauthorized)
 			}
 		}
 	}
 }
 
+// limitRate protects the handler by the rate limiter if one is configured.
+func limitRate(rl *RateLimiter, f http.HandlerFunc) http.HandlerFunc {
+	if rl == nil {
+		return f
+	}
+	return MakeRateLimitHandler(rl, f)
+}
+
+// wrapHandler adds the request id, the rate limiting, the CORS headers,
+// and the gzip compression of responses to the handler.
+func wrapHandler(rl *RateLimiter, origins []string, f http.HandlerFunc) http.HandlerFunc {
+	return MakeRequestIDHandler(limitRate(rl, MakeCORSHandler(origins, MakeGzipHandler(f))))
+}
+
+// routeMethods returns a function which lists the methods of all routes
+// registered at the router which match the path of a request.
+func routeMethods(router *mux.Router, rs Routes) func(r *http.Request) []string {
+	return func(r *http.Request) []string {
+		var methods []string
+		for _, route := range rs {
+			registered := router.Get(route.Name)
+			if registered == nil {
+				continue
+			}
+			req := *r
+			req.Method = route.Method
+			if !registered.Match(&req, &mux.RouteMatch{}) {
+				continue
+			}
+			known := false
+			for _, m := range methods {
+				known = known || m == route.Method
+			}
+			if !known {
+				methods = append(methods, route.Method)
+			}
+		}
+		return methods
+	}
+}
+
 // NewProxyRouter creates a mux router for matching http requests to handlers.
 // When security is configured it adds neccessary closures around the functions.
+// When a rate limit is configured the requests of each client are throttled.
+// Responses are gzip compressed for clients sending "Accept-Encoding: gzip".
+// Each request gets an id (X-Uber-Request-Id) which is logged.
+// When CORS origins are configured the preflight (OPTIONS) requests of
+// browsers are answered without authentication. With ValidateSubmissions
+// set job submissions requesting unknown queues or job categories are
+// rejected before they reach the cluster. When bearer tokens are
+// configured requests can authenticate by an "Authorization: Bearer"
+// header instead of the one time password. The requests are counted
+// in the metrics registry of the SecConfig (or the DefaultMetrics) which
+// is exported at /metrics.
 func NewProxyRouter(impl ProxyImplementer, sc SecConfig, pi persistency.PersistencyImplementer) *mux.Router {
 	router := mux.NewRouter().StrictSlash(true)
-	if sc.OTP == "" {
-		for _, route := range routes {
+	metrics := sc.Metrics
+	if metrics == nil {
+		metrics = DefaultMetrics
+	}
+	var rl *RateLimiter
+	if sc.RateLimit > 0 {
+		rl = NewRateLimiter(sc.RateLimit, sc.RateBurst)
+	}
+	if len(sc.CORSAllowedOrigins) > 0 {
+		// patterns like the one of JobManipulation match the paths of
+		// other routes hence the methods are looked up for each request
+		methods := routeMethods(router, append(publicRoutes, routes...))
+		for _, route := range append(publicRoutes, routes...) {
 			router.
-				Methods(route.Method).
+				Methods("OPTIONS").
 				Path(route.Pattern).
-				Name(route.Name).
-				Handler(route.MakeHandlerFunc(impl, pi))
+				Name(route.Name + "Preflight").
+				Handler(MakeRequestIDHandler(limitRate(rl, MakeCORSPreflightHandler(sc.CORSAllowedOrigins, methods))))
 		}
-	} else if sc.OTP == "yubikey" {
+	}
+	for _, route := range publicRoutes {
+		router.
+			Methods(route.Method).
+			Path(route.Pattern).
+			Name(route.Name).
+			Handler(MakeMetricsRecordingHandler(metrics, route.Name, wrapHandler(rl, sc.CORSAllowedOrigins, makeHandler(route, impl, pi))))
+	}
+	var authenticate func(f http.HandlerFunc) http.HandlerFunc
+	if sc.OTP == "yubikey" {
 		// add yubikey one-time-password verifcation for each call
 		if sc.YubiID == "" || sc.YubiSecret == "" {
 			fmt.Println("yubikey is configured but ID or Secret not set!")
@@ -213,22 +317,40 @@ func NewProxyRouter(impl ProxyImplementer, sc SecConfig, pi persistency.Persiste
 			fmt.Println("yubikey is configured but no allowed keys set (first 12 chars of your OTP)!")
 			os.Exit(1)
 		}
-		for _, route := range routes {
-			router.
-				Methods(route.Method).
-				Path(route.Pattern).
-				Name(route.Name).
-				Handler(MakeYubikeyHandler(sc.YubiID, sc.YubiSecret, sc.YubiAllowedIDs, route.MakeHandlerFunc(impl, pi)))
+		authenticate = func(f http.HandlerFunc) http.HandlerFunc {
+			return MakeYubikeyHandler(sc.YubiID, sc.YubiSecret, sc.YubiAllowedIDs, f)
 		}
-	} else {
+	} else if sc.OTP != "" {
 		// fixed key
-		for _, route := range routes {
-			router.
-				Methods(route.Method).
-				Path(route.Pattern).
-				Name(route.Name).
-				Handler(MakeFixedSecretHandler(sc.OTP, route.MakeHandlerFunc(impl, pi)))
+		authenticate = func(f http.HandlerFunc) http.HandlerFunc {
+			return MakeFixedSecretHandler(sc.OTP, f)
+		}
+	}
+	if sc.JWKSURL != "" && sc.JWTAudience == "" {
+		fmt.Println("jwksURL is configured but no jwtAudience set!")
+		os.Exit(1)
+	}
+	if verify := NewBearerVerifier(sc); verify != nil {
+		// bearer tokens are accepted besides the one time password
+		otpAuthenticate := authenticate
+		authenticate = func(f http.HandlerFunc) http.HandlerFunc {
+			var fallback http.HandlerFunc
+			if otpAuthenticate != nil {
+				fallback = otpAuthenticate(f)
+			}
+			return MakeBearerTokenHandler(verify, fallback, f)
+		}
+	}
+	for _, route := range routes {
+		h := makeRouteHandler(route, impl, pi, sc)
+		if authenticate != nil {
+			h = authenticate(h)
 		}
+		router.
+			Methods(route.Method).
+			Path(route.Pattern).
+			Name(route.Name).
+			Handler(MakeMetricsRecordingHandler(metrics, route.Name, wrapHandler(rl, sc.CORSAllowedOrigins, h)))
 	}
 	return router
 }
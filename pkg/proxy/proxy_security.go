@@ -1,19 +1,34 @@
 package proxy
 
 import (
+	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"time"
 )
 
 // SecConfig stores security related configuration settings for the ubercluster Proxy
 type SecConfig struct {
-	OTP                  string   // secret key or "yubikey"
-	YubiID               string   // ID of yubiservice in case of yubikey https://upgrade.yubico.com/getapikey/
-	YubiSecret           string   // Secret of yubiservice in case of yubikey https://upgrade.yubico.com/getapikey/
-	YubiAllowedIDs       []string // IDs of yubkeys which are allowed
-	TrustedClientCertDir string   // Directory which contains trusted certs for mutual TLS
+	OTP                  string        // secret key or "yubikey"
+	YubiID               string        // ID of yubiservice in case of yubikey https://upgrade.yubico.com/getapikey/
+	YubiSecret           string        // Secret of yubiservice in case of yubikey https://upgrade.yubico.com/getapikey/
+	YubiAllowedIDs       []string      // IDs of yubkeys which are allowed
+	TrustedClientCertDir string        // Directory which contains trusted certs for mutual TLS
+	ClientCAFile         string        // PEM file with CA certs which sign the client certs for mutual TLS
+	NoClientCerts        bool          // serves TLS without client certs when no trusted certs or client CA are configured
+	RateLimit            float64       // allowed requests per second and client (0 disables rate limiting)
+	RateBurst            int           // amount of requests a client can send at once before being throttled
+	CORSAllowedOrigins   []string      // origins of browser based clients allowed by CORS ("*" for all, empty disables CORS)
+	ValidateSubmissions  bool          // rejects job submissions with unknown queues or job categories
+	ShutdownTimeout      time.Duration // time in-flight requests get for finishing on shutdown (0 is 30s)
+	BearerToken          string        // static token accepted in an "Authorization: Bearer" header
+	JWKSURL              string        // URL of the JSON Web Key Set for validating JWT bearer tokens
+	JWTAudience          string        // audience JWT bearer tokens must be issued for (required with JWKSURL)
+	JWTIssuer            string        // issuer of JWT bearer tokens (not checked when empty)
+	Metrics              *Metrics      // registry of the metrics exported at /metrics (nil uses DefaultMetrics)
 }
 
 func ReadTrustedClientCertPool(directory string) (*x509.CertPool, error) {
@@ -40,3 +55,64 @@ func ReadTrustedClientCertPool(directory string) (*x509.CertPool, error) {
 
 	return clientCertPool, nil
 }
+
+// ReadClientCAFile reads the PEM encoded CA certificates from the given
+// file into a certificate pool which is used for verifying client certs.
+func ReadClientCAFile(file string) (*x509.CertPool, error) {
+	return appendClientCAFile(x509.NewCertPool(), file)
+}
+
+func appendClientCAFile(pool *x509.CertPool, file string) (*x509.CertPool, error) {
+	caBytes, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if ok := pool.AppendCertsFromPEM(caBytes); !ok {
+		return nil, fmt.Errorf("unable to add CA certificates of %s to certificate pool", file)
+	}
+	return pool, nil
+}
+
+// NewTLSConfig creates the TLS configuration of the proxy. Clients are
+// required to present a certificate which can be verified with the
+// trusted client certs (TrustedClientCertDir) or the client CA file
+// (ClientCAFile). When none of them is configured an error is returned
+// unless client certs are explicitly turned off by NoClientCerts.
+func NewTLSConfig(certFile, keyFile string, sc SecConfig) (*tls.Config, error) {
+	servTLSCert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key pair: %v", err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{servTLSCert},
+		//CipherSuites:             []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384},
+		//PreferServerCipherSuites: true,
+		//MinVersion: tls.VersionTLS12,
+	}
+
+	if sc.TrustedClientCertDir == "" && sc.ClientCAFile == "" {
+		if sc.NoClientCerts {
+			return tlsConfig, nil
+		}
+		return nil, errors.New("TLS requires trusted client certs or a client CA file for verifying clients (or turning client certs off)")
+	}
+
+	clientCertPool := x509.NewCertPool()
+	if sc.TrustedClientCertDir != "" {
+		if clientCertPool, err = ReadTrustedClientCertPool(sc.TrustedClientCertDir); err != nil {
+			return nil, err
+		}
+	}
+	if sc.ClientCAFile != "" {
+		if clientCertPool, err = appendClientCAFile(clientCertPool, sc.ClientCAFile); err != nil {
+			return nil, err
+		}
+	}
+
+	// Reject any TLS certificate that cannot be validated
+	tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	// Ensure that we only use our "CA" to validate certificates
+	tlsConfig.ClientCAs = clientCertPool
+	return tlsConfig, nil
+}
@@ -5,6 +5,8 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+
+	"crypto/tls"
 )
 
 var _ = Describe("ProxySecurity", func() {
@@ -17,6 +19,25 @@ var _ = Describe("ProxySecurity", func() {
 			Ω(pool).ShouldNot(BeNil())
 		})
 
+		It("should read the client CA file", func() {
+			pool, err := ReadClientCAFile("./testClientCA.pem")
+			Ω(err).Should(BeNil())
+			Ω(pool).ShouldNot(BeNil())
+		})
+
+		It("should require client certs when a client CA file is configured", func() {
+			config, err := NewTLSConfig("./testServer.pem", "./testServer.key", SecConfig{ClientCAFile: "./testClientCA.pem"})
+			Ω(err).Should(BeNil())
+			Ω(config.ClientAuth).Should(Equal(tls.RequireAndVerifyClientCert))
+			Ω(config.ClientCAs).ShouldNot(BeNil())
+		})
+
+		It("should not require client certs when they are turned off", func() {
+			config, err := NewTLSConfig("./testServer.pem", "./testServer.key", SecConfig{NoClientCerts: true})
+			Ω(err).Should(BeNil())
+			Ω(config.ClientAuth).Should(Equal(tls.NoClientCert))
+		})
+
 	})
 
 	Context("error cases", func() {
@@ -27,6 +48,20 @@ var _ = Describe("ProxySecurity", func() {
 			Ω(pool).Should(BeNil())
 		})
 
+		It("fail when the client CA file does not contain certificates", func() {
+			pool, err := ReadClientCAFile("./testServer.key")
+			Ω(err).ShouldNot(BeNil())
+			Ω(pool).Should(BeNil())
+			_, err = NewTLSConfig("./testServer.pem", "./testServer.key", SecConfig{ClientCAFile: "./unknownFile"})
+			Ω(err).ShouldNot(BeNil())
+		})
+
+		It("fail when neither client certs nor a client CA are configured", func() {
+			config, err := NewTLSConfig("./testServer.pem", "./testServer.key", SecConfig{})
+			Ω(err).ShouldNot(BeNil())
+			Ω(config).Should(BeNil())
+		})
+
 	})
 
 })
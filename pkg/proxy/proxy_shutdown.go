@@ -0,0 +1,122 @@
+package proxy
+
+import (
+	"context"
+	"github.com/dgruber/ubercluster/pkg/persistency"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// SessionCloser is an optional interface which can be implemented by
+// a proxy which holds sessions to the DRMS (like DRMAA2 job sessions
+// and monitoring sessions). CloseSessions is called when the proxy is
+// shut down after all in-flight requests are finished.
+type SessionCloser interface {
+	CloseSessions() error
+}
+
+// defaultShutdownTimeout is the time in-flight requests get for
+// finishing when the proxy shuts down and no timeout is configured.
+const defaultShutdownTimeout = 30 * time.Second
+
+// shutdownKey is the context key of the channel which is closed when
+// the server starts to shut down.
+type shutdownKey struct{}
+
+// shuttingDown returns a channel which is closed when the server which
+// received the request is shut down. Long running requests (like event
+// streams) must finish then, otherwise they delay the shutdown until
+// the timeout. For requests which are not served by ListenAndServeContext
+// the channel is never closed.
+func shuttingDown(r *http.Request) <-chan struct{} {
+	if done, ok := r.Context().Value(shutdownKey{}).(chan struct{}); ok {
+		return done
+	}
+	return nil
+}
+
+// ListenAndServeContext starts the proxy like ProxyListenAndServe but
+// returns errors instead of exiting. When the context is done the
+// server stops accepting new connections and waits until the in-flight
+// requests are finished or the shutdown timeout of the SecConfig is
+// reached. Afterwards the sessions of proxies which implement the
+// SessionCloser interface are closed, unless requests which may still
+// use them did not finish in time.
+func ListenAndServeContext(ctx context.Context, addr, certFile, keyFile string, sc SecConfig, pi persistency.PersistencyImplementer, impl ProxyImplementer) error {
+	httpServer := &http.Server{
+		Addr:    addr,
+		Handler: NewProxyRouter(impl, sc, pi),
+	}
+	tls := certFile != "" && keyFile != ""
+	if tls {
+		tlsConfig, err := NewTLSConfig(certFile, keyFile, sc)
+		if err != nil {
+			return err
+		}
+		httpServer.TLSConfig = tlsConfig
+	}
+	done := make(chan struct{})
+	httpServer.BaseContext = func(net.Listener) context.Context {
+		return context.WithValue(context.Background(), shutdownKey{}, done)
+	}
+	httpServer.RegisterOnShutdown(func() { close(done) })
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if tls {
+			serveErr <- httpServer.ListenAndServeTLS(certFile, keyFile)
+		} else {
+			serveErr <- httpServer.ListenAndServe()
+		}
+	}()
+
+	var err error
+	select {
+	case err = <-serveErr:
+	case <-ctx.Done():
+		timeout := sc.ShutdownTimeout
+		if timeout <= 0 {
+			timeout = defaultShutdownTimeout
+		}
+		log.Printf("(proxy) Shutting down, waiting up to %s for in-flight requests\n", timeout)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		if err = httpServer.Shutdown(shutdownCtx); err != nil {
+			log.Printf("(proxy) Not closing sessions since in-flight requests did not finish: %s\n", err)
+			return err
+		}
+		err = <-serveErr
+	}
+	if err == http.ErrServerClosed {
+		err = nil
+	}
+	if closer, ok := impl.(SessionCloser); ok {
+		if cerr := closer.CloseSessions(); cerr != nil {
+			log.Printf("(proxy) Error while closing sessions: %s\n", cerr)
+			if err == nil {
+				err = cerr
+			}
+		}
+	}
+	return err
+}
+
+// signalContext returns a context which is done when the process
+// receives SIGINT or SIGTERM.
+func signalContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-interrupt
+		signal.Stop(interrupt)
+		log.Printf("(proxy) Received %s\n", sig)
+		cancel()
+	}()
+	return ctx
+}
@@ -0,0 +1,172 @@
+package proxy_test
+
+import (
+	. "github.com/dgruber/ubercluster/pkg/proxy"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"bufio"
+	"context"
+	"errors"
+	"github.com/dgruber/ubercluster/pkg/persistency"
+	"github.com/dgruber/ubercluster/pkg/types"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// shutdownProxy is a fakeProxy with a slow machines request which
+// records when its sessions are closed.
+type shutdownProxy struct {
+	fakeProxy
+	started chan struct{}
+	closed  int32
+}
+
+func (s *shutdownProxy) GetAllMachines(machines []string) ([]types.Machine, error) {
+	close(s.started)
+	time.Sleep(300 * time.Millisecond)
+	if atomic.LoadInt32(&s.closed) != 0 {
+		return nil, errors.New("sessions closed during request")
+	}
+	return []types.Machine{{Name: "m1"}}, nil
+}
+
+func (s *shutdownProxy) CloseSessions() error {
+	atomic.StoreInt32(&s.closed, 1)
+	return nil
+}
+
+var _ = Describe("ProxyShutdown", func() {
+
+	freeAddress := func() string {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		Ω(err).Should(BeNil())
+		defer l.Close()
+		return l.Addr().String()
+	}
+
+	It("should finish in-flight requests before closing the sessions", func() {
+		var ps persistency.DummyPersistency
+		impl := &shutdownProxy{started: make(chan struct{})}
+		addr := freeAddress()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		served := make(chan error, 1)
+		go func() {
+			served <- ListenAndServeContext(ctx, addr, "", "", SecConfig{ShutdownTimeout: 5 * time.Second}, &ps, impl)
+		}()
+		Eventually(func() error {
+			conn, err := net.Dial("tcp", addr)
+			if err == nil {
+				conn.Close()
+			}
+			return err
+		}).Should(BeNil())
+
+		status := make(chan int, 1)
+		go func() {
+			defer GinkgoRecover()
+			resp, err := http.Get("http://" + addr + "/v1/msession/machines")
+			Ω(err).Should(BeNil())
+			resp.Body.Close()
+			status <- resp.StatusCode
+		}()
+		<-impl.started
+		cancel()
+
+		Eventually(served, 5*time.Second).Should(Receive(BeNil()))
+		Ω(status).Should(Receive(Equal(http.StatusOK)))
+		Ω(atomic.LoadInt32(&impl.closed)).Should(Equal(int32(1)))
+
+		_, err := http.Get("http://" + addr + "/v1/msession/machines")
+		Ω(err).ShouldNot(BeNil())
+	})
+
+	It("should not close the sessions when in-flight requests don't finish in time", func() {
+		var ps persistency.DummyPersistency
+		impl := &shutdownProxy{started: make(chan struct{})}
+		addr := freeAddress()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		served := make(chan error, 1)
+		go func() {
+			served <- ListenAndServeContext(ctx, addr, "", "", SecConfig{ShutdownTimeout: 50 * time.Millisecond}, &ps, impl)
+		}()
+		Eventually(func() error {
+			conn, err := net.Dial("tcp", addr)
+			if err == nil {
+				conn.Close()
+			}
+			return err
+		}).Should(BeNil())
+
+		status := make(chan int, 1)
+		go func() {
+			defer GinkgoRecover()
+			resp, err := http.Get("http://" + addr + "/v1/msession/machines")
+			Ω(err).Should(BeNil())
+			resp.Body.Close()
+			status <- resp.StatusCode
+		}()
+		<-impl.started
+		cancel()
+
+		Eventually(served, 5*time.Second).Should(Receive(HaveOccurred()))
+		Ω(atomic.LoadInt32(&impl.closed)).Should(Equal(int32(0)))
+		Eventually(status).Should(Receive(Equal(http.StatusOK)))
+	})
+
+	It("should stop following job output when shutting down", func() {
+		var ps persistency.DummyPersistency
+		out, err := ioutil.TempFile("", "proxyOutput")
+		Ω(err).Should(BeNil())
+		defer os.Remove(out.Name())
+		defer out.Close()
+		out.WriteString("line 1\n")
+		impl := &outputProxy{path: out.Name(), state: types.Running}
+		addr := freeAddress()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		served := make(chan error, 1)
+		go func() {
+			served <- ListenAndServeContext(ctx, addr, "", "", SecConfig{ShutdownTimeout: 5 * time.Second}, &ps, impl)
+		}()
+		Eventually(func() error {
+			conn, err := net.Dial("tcp", addr)
+			if err == nil {
+				conn.Close()
+			}
+			return err
+		}).Should(BeNil())
+
+		resp, err := http.Get("http://" + addr + "/v1/jsession/default/job/1/output?follow=true")
+		Ω(err).Should(BeNil())
+		defer resp.Body.Close()
+		line, err := bufio.NewReader(resp.Body).ReadString('\n')
+		Ω(err).Should(BeNil())
+		Ω(line).Should(Equal("line 1\n"))
+
+		start := time.Now()
+		cancel()
+		Eventually(served, 5*time.Second).Should(Receive(BeNil()))
+		Ω(time.Since(start)).Should(BeNumerically("<", 2*time.Second))
+	})
+
+	It("should return listen errors", func() {
+		var ps persistency.DummyPersistency
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		Ω(err).Should(BeNil())
+		defer l.Close()
+		err = ListenAndServeContext(context.Background(), l.Addr().String(), "", "", SecConfig{}, &ps, &fakeProxy{})
+		Ω(err).ShouldNot(BeNil())
+	})
+
+})
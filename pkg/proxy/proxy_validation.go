@@ -0,0 +1,181 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/dgruber/ubercluster/pkg/persistency"
+	"github.com/dgruber/ubercluster/pkg/types"
+	"github.com/gorilla/mux"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// JobOperations are the operations which can be requested for a job
+// at /v1/jsession/{jsname}/{operation}/{jobid}. Proxies which don't
+// support an operation (like hold for processes) return an error
+// wrapping ErrUnsupportedOperation.
+var JobOperations = []string{"suspend", "resume", "hold", "release", "terminate"}
+
+// maxPathParamLength is the maximum length of a job id, a job session
+// name, or any other name given in the URL path.
+const maxPathParamLength = 256
+
+// pathParam matches the job ids and job session names accepted in URL
+// paths. They must not start with a dot (no "..") or a dash (no options
+// of commands).
+var pathParam = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.@:+-]*$`)
+
+// strictPathParams are the URL path variables which are passed to the
+// DRM and hence must match pathParam. Other variables (like file, queue,
+// or machine names) are only limited in length; their handlers check
+// them where required.
+var strictPathParams = map[string]bool{"jsname": true, "jobid": true}
+
+// ValidatePathParam returns an error when the value of the URL path
+// variable (like "jobid") is malformed. Operations must be one of the
+// JobOperations.
+func ValidatePathParam(name, value string) error {
+	if name == "operation" {
+		for _, op := range JobOperations {
+			if value == op {
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown operation %q (allowed are %s)", value, strings.Join(JobOperations, ", "))
+	}
+	if len(value) > maxPathParamLength {
+		return fmt.Errorf("%s is longer than %d characters", name, maxPathParamLength)
+	}
+	if !strictPathParams[name] {
+		return nil
+	}
+	if !pathParam.MatchString(value) || strings.Contains(value, "..") {
+		return fmt.Errorf("invalid %s %q", name, value)
+	}
+	return nil
+}
+
+// ValidateFileName returns an error when the (decoded) file name is
+// not a plain name of a file in the staging directory.
+func ValidateFileName(name string) error {
+	if name == "" || name == "." || name == ".." || name != filepath.Base(name) || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid file name %q", name)
+	}
+	return nil
+}
+
+// MakePathValidationHandler returns an http handler function which
+// rejects requests with malformed URL path variables (see
+// ValidatePathParam) with http.StatusBadRequest before f is called.
+func MakePathValidationHandler(f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for name, value := range mux.Vars(r) {
+			if err := ValidatePathParam(name, value); err != nil {
+				logRequestf(r, "(proxy) Rejected request: %s\n", err)
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		f(w, r)
+	}
+}
+
+// templateDecoder extracts the job template from the body of a
+// submission request.
+type templateDecoder func(body []byte) (types.JobTemplate, error)
+
+// submitTemplateDecoders contains the decoders of the job templates of
+// the routes which are validated before submission.
+var submitTemplateDecoders = map[string]templateDecoder{
+	"JobSubmit": func(body []byte) (types.JobTemplate, error) {
+		var jt types.JobTemplate
+		err := json.Unmarshal(body, &jt)
+		return jt, err
+	},
+	"ArrayJobSubmit": func(body []byte) (types.JobTemplate, error) {
+		var ajr types.ArrayJobRequest
+		err := json.Unmarshal(body, &ajr)
+		return ajr.JobTemplate, err
+	},
+}
+
+// MakeSubmitValidationHandler returns an http handler function which
+// rejects job submissions requesting a queue or a job category the
+// cluster doesn't know with http.StatusBadRequest before f is called.
+// When the queues or categories can't be determined the corresponding
+// check is skipped. Requests which can't be decoded are passed to f
+// which reports the error.
+func MakeSubmitValidationHandler(impl ProxyImplementer, decode templateDecoder, f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			logRequestf(r, "(proxy) %s\n", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		jt, err := decode(body)
+		if err != nil {
+			f(w, r)
+			return
+		}
+		p := impl
+		if ci, ok := impl.(ContextProxyImplementer); ok {
+			p = ci.WithContext(r.Context())
+		}
+		if verr := jt.ValidateResources(validQueues(r, p, jt), validCategories(r, p, jt)); verr != nil {
+			logRequestf(r, "(proxy) Rejected job submission: %s\n", verr)
+			http.Error(w, verr.Error(), http.StatusBadRequest)
+			return
+		}
+		f(w, r)
+	}
+}
+
+// validQueues returns the names of the queues of the cluster when the
+// job template requests a queue.
+func validQueues(r *http.Request, impl ProxyImplementer, jt types.JobTemplate) []string {
+	if jt.QueueName == "" {
+		return nil
+	}
+	queues, err := impl.GetAllQueues(nil)
+	if err != nil {
+		logRequestf(r, "(proxy) Skipping queue validation: %s\n", err)
+		return nil
+	}
+	names := make([]string, 0, len(queues))
+	for _, q := range queues {
+		names = append(names, q.Name)
+	}
+	return names
+}
+
+// validCategories returns the job categories of the cluster when the
+// job template requests a job category.
+func validCategories(r *http.Request, impl ProxyImplementer, jt types.JobTemplate) []string {
+	if jt.JobCategory == "" {
+		return nil
+	}
+	categories, err := impl.GetAllCategories()
+	if err != nil {
+		logRequestf(r, "(proxy) Skipping job category validation: %s\n", err)
+		return nil
+	}
+	return categories
+}
+
+// makeRouteHandler creates the http handler function of the route like
+// makeHandler. The URL path variables are validated first. When
+// submission validation is configured the job templates of submission
+// routes are validated as well.
+func makeRouteHandler(route Route, impl ProxyImplementer, pi persistency.PersistencyImplementer, sc SecConfig) http.HandlerFunc {
+	h := makeHandler(route, impl, pi)
+	if decode, ok := submitTemplateDecoders[route.Name]; ok && sc.ValidateSubmissions {
+		h = MakeSubmitValidationHandler(impl, decode, h)
+	}
+	return MakePathValidationHandler(h)
+}
@@ -0,0 +1,54 @@
+package types
+
+import (
+	"sort"
+	"time"
+)
+
+// AccountingSummary contains the aggregated resource usage of the
+// finished jobs of one job owner.
+type AccountingSummary struct {
+	JobOwner      string        `json:"jobOwner" xml:"name,attr"`
+	Jobs          int64         `json:"jobs" xml:"jobs"`
+	Failed        int64         `json:"failed" xml:"failed"`
+	CPUTime       int64         `json:"cpuTime" xml:"cpuTime"`             // in seconds
+	WallclockTime time.Duration `json:"wallclockTime" xml:"wallclockTime"` // in nanoseconds
+}
+
+// SummarizeAccounting sums up the CPU time and wallclock time of the
+// finished (Done and Failed) jobs per job owner. When since is set only
+// jobs which finished at or after that time are taken into account.
+// The wallclock time of jobs which don't report it is derived from
+// their dispatch and finish time. The summaries are sorted by the job
+// owner.
+func SummarizeAccounting(jobinfos []JobInfo, since time.Time) []AccountingSummary {
+	summaries := make([]AccountingSummary, 0)
+	index := make(map[string]int)
+	for i := range jobinfos {
+		ji := &jobinfos[i]
+		if !ji.State.IsTerminal() {
+			continue
+		}
+		if !since.IsZero() && (!timeSet(ji.FinishTime) || ji.FinishTime.Before(since)) {
+			continue
+		}
+		pos, exists := index[ji.JobOwner]
+		if !exists {
+			pos = len(summaries)
+			index[ji.JobOwner] = pos
+			summaries = append(summaries, AccountingSummary{JobOwner: ji.JobOwner})
+		}
+		summaries[pos].Jobs++
+		if ji.State == Failed {
+			summaries[pos].Failed++
+		}
+		summaries[pos].CPUTime += ji.CPUTime
+		if ji.WallclockTime > 0 {
+			summaries[pos].WallclockTime += ji.WallclockTime
+		} else {
+			summaries[pos].WallclockTime += ji.RunDuration()
+		}
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].JobOwner < summaries[j].JobOwner })
+	return summaries
+}
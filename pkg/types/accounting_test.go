@@ -0,0 +1,45 @@
+package types_test
+
+import (
+	"time"
+
+	"github.com/dgruber/ubercluster/pkg/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Accounting", func() {
+
+	dispatched := time.Date(2016, 1, 1, 10, 0, 0, 0, time.UTC)
+	finished := dispatched.Add(time.Hour)
+
+	jobinfos := []types.JobInfo{
+		{Id: "1", JobOwner: "bob", State: types.Done, CPUTime: 100, WallclockTime: time.Minute, FinishTime: finished},
+		{Id: "2", JobOwner: "alice", State: types.Failed, CPUTime: 10, DispatchTime: dispatched, FinishTime: finished},
+		{Id: "3", JobOwner: "bob", State: types.Done, CPUTime: 50, WallclockTime: 2 * time.Minute, FinishTime: finished.Add(time.Hour)},
+		{Id: "4", JobOwner: "bob", State: types.Running, CPUTime: 1000, WallclockTime: time.Hour},
+	}
+
+	It("should sum up the usage of the finished jobs per owner", func() {
+		summaries := types.SummarizeAccounting(jobinfos, time.Time{})
+		Ω(summaries).Should(HaveLen(2))
+		Ω(summaries[0]).Should(Equal(types.AccountingSummary{
+			JobOwner: "alice", Jobs: 1, Failed: 1, CPUTime: 10, WallclockTime: time.Hour}))
+		Ω(summaries[1]).Should(Equal(types.AccountingSummary{
+			JobOwner: "bob", Jobs: 2, CPUTime: 150, WallclockTime: 3 * time.Minute}))
+	})
+
+	It("should only take jobs into account which finished since the given time", func() {
+		summaries := types.SummarizeAccounting(jobinfos, finished.Add(time.Minute))
+		Ω(summaries).Should(HaveLen(1))
+		Ω(summaries[0].JobOwner).Should(Equal("bob"))
+		Ω(summaries[0].Jobs).Should(Equal(int64(1)))
+		Ω(summaries[0].CPUTime).Should(Equal(int64(50)))
+	})
+
+	It("should return an empty list for no jobs", func() {
+		Ω(types.SummarizeAccounting(nil, time.Time{})).Should(BeEmpty())
+	})
+
+})
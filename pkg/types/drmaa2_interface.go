@@ -7,6 +7,7 @@ package types
 
 import (
 	"fmt"
+	"strings"
 	"time"
 	"unsafe"
 )
@@ -52,6 +53,111 @@ func (js JobState) String() string {
 	return "Unset"
 }
 
+// jobStateShortCodes maps the job states to the short codes
+// which are used for filtering jobs by state (like "uc show job --state").
+var jobStateShortCodes = map[JobState]string{
+	Undetermined: "u",
+	Queued:       "q",
+	QueuedHeld:   "h",
+	Running:      "r",
+	Suspended:    "s",
+	Requeued:     "R",
+	RequeuedHeld: "Rh",
+	Done:         "d",
+	Failed:       "f",
+}
+
+// ShortCode returns the short code of the job state which is
+// understood by ParseJobState. For Unset an empty string is returned.
+func (js JobState) ShortCode() string {
+	return jobStateShortCodes[js]
+}
+
+// ParseJobState converts a job state given as short code (r/q/h/s/R/Rh/d/f/u)
+// or as name (like "Running") into a JobState. Names are case insensitive.
+func ParseJobState(state string) (JobState, error) {
+	for js, code := range jobStateShortCodes {
+		if state == code || strings.EqualFold(state, js.String()) {
+			return js, nil
+		}
+	}
+	return Unset, fmt.Errorf("unknown job state %q (expected r/q/h/s/R/Rh/d/f/u)", state)
+}
+
+// IsTerminal returns true for the end states Done and Failed. Jobs in
+// these states don't change their state anymore.
+func (js JobState) IsTerminal() bool {
+	return js == Done || js == Failed
+}
+
+// IsActive returns true when the job is known by the DRMS and not yet
+// finished, i.e. it is queued (also held or requeued), running, or
+// suspended.
+func (js JobState) IsActive() bool {
+	switch js {
+	case Queued, QueuedHeld, Running, Suspended, Requeued, RequeuedHeld:
+		return true
+	}
+	return false
+}
+
+// IsHeld returns true when the job waits in the queue on hold
+// (QueuedHeld or RequeuedHeld).
+func (js JobState) IsHeld() bool {
+	return js == QueuedHeld || js == RequeuedHeld
+}
+
+// ANSIColor returns the ANSI escape sequence which sets the terminal
+// color used for showing the job state: green for finished jobs, red
+// for failed jobs, yellow for waiting jobs, and blue for running jobs.
+// States without color return an empty string.
+func (js JobState) ANSIColor() string {
+	switch js {
+	case Done:
+		return "\x1b[32m"
+	case Failed:
+		return "\x1b[31m"
+	case Queued, QueuedHeld, Requeued, RequeuedHeld:
+		return "\x1b[33m"
+	case Running:
+		return "\x1b[34m"
+	}
+	return ""
+}
+
+// Event is a job status change event used by the Notification struct.
+type Event int
+
+const (
+	NewState Event = iota
+	Migrated
+	AttributeChange
+)
+
+// Implements the Stringer interface
+func (e Event) String() string {
+	switch e {
+	case NewState:
+		return "NewState"
+	case Migrated:
+		return "Migrated"
+	case AttributeChange:
+		return "AttributeChange"
+	}
+	return "Unknown"
+}
+
+// Notification represents a job status change event. The JobOwner is
+// not part of DRMAA2, it is set by the proxy so that clients can
+// subscribe to the events of the jobs of one user.
+type Notification struct {
+	Evt         Event    `json:"event"`
+	JobId       string   `json:"jobId"`
+	SessionName string   `json:"sessionName"`
+	State       JobState `json:"jobState"`
+	JobOwner    string   `json:"jobOwner,omitempty"`
+}
+
 // StructType is needed for extending the structs.
 type StructType int
 
@@ -69,14 +175,16 @@ const (
 // Extension struct which is embedded in DRMAA2 objects
 // which are extensible.
 type Extension struct {
-	SType         StructType        // Stores the type of the struct
-	Internal      unsafe.Pointer    // Enhancmement of C struct
-	ExtensionList map[string]string // stores the extension requests as string
+	SType         StructType        `json:"-"`                    // Stores the type of the struct
+	Internal      unsafe.Pointer    `json:"-"`                    // Enhancmement of C struct
+	ExtensionList map[string]string `json:"extensions,omitempty"` // stores the extension requests as string
 }
 
 // JobInfo is an extensible struct which represents all data known by for the job.
+// Implementation specific values (like the process id of a job of the process
+// proxy) are part of the JSON representation as "extensions".
 type JobInfo struct {
-	Extension         `xml:"-" json:"-"`
+	Extension         `xml:"-"`
 	Id                string        `json:"id"`
 	ExitStatus        int           `json:"exitStatus"`
 	TerminatingSignal string        `json:"terminationSignal"`
@@ -95,6 +203,79 @@ type JobInfo struct {
 	FinishTime        time.Time     `json:"finishTime"`
 }
 
+// Matches returns true when the job info satisfies the given filter. It
+// follows the DRMAA2 filter semantics: unset fields of the filter (empty
+// strings, nil slices, zero or negative numbers, a negative exit status,
+// zero times, and the Unset job state) are ignored, set fields must match.
+// Filters should be created by CreateJobInfo since an exit status of 0
+// selects the jobs which succeeded. For AllocatedMachines the
+// job must run on (at least) all given machines, for WallclockTime and
+// CPUTime the job must have consumed at least the given time, and for the
+// submission, dispatch, and finish times the event must have happened at
+// or after the given time.
+func (ji JobInfo) Matches(filter JobInfo) bool {
+	if filter.Id != "" && filter.Id != ji.Id {
+		return false
+	}
+	if filter.ExitStatus >= 0 && filter.ExitStatus != ji.ExitStatus {
+		return false
+	}
+	if filter.TerminatingSignal != "" && filter.TerminatingSignal != ji.TerminatingSignal {
+		return false
+	}
+	if filter.Annotation != "" && filter.Annotation != ji.Annotation {
+		return false
+	}
+	if filter.State != Unset && filter.State != ji.State {
+		return false
+	}
+	if filter.SubState != "" && filter.SubState != ji.SubState {
+		return false
+	}
+	for _, machine := range filter.AllocatedMachines {
+		if !containsString(ji.AllocatedMachines, machine) {
+			return false
+		}
+	}
+	if filter.SubmissionMachine != "" && filter.SubmissionMachine != ji.SubmissionMachine {
+		return false
+	}
+	if filter.JobOwner != "" && filter.JobOwner != ji.JobOwner {
+		return false
+	}
+	if filter.Slots > 0 && filter.Slots != ji.Slots {
+		return false
+	}
+	if filter.QueueName != "" && filter.QueueName != ji.QueueName {
+		return false
+	}
+	if filter.WallclockTime > 0 && ji.WallclockTime < filter.WallclockTime {
+		return false
+	}
+	if filter.CPUTime > 0 && ji.CPUTime < filter.CPUTime {
+		return false
+	}
+	if !filter.SubmissionTime.IsZero() && ji.SubmissionTime.Before(filter.SubmissionTime) {
+		return false
+	}
+	if !filter.DispatchTime.IsZero() && ji.DispatchTime.Before(filter.DispatchTime) {
+		return false
+	}
+	if !filter.FinishTime.IsZero() && ji.FinishTime.Before(filter.FinishTime) {
+		return false
+	}
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for i := range list {
+		if list[i] == s {
+			return true
+		}
+	}
+	return false
+}
+
 // JobTemplate is an extensible struct which represents a template which
 // specifies the job for job submission.
 type JobTemplate struct {
@@ -129,6 +310,11 @@ type JobTemplate struct {
 	StageOutFiles     map[string]string `json:"stageOutFiles"`
 	ResourceLimits    map[string]string `json:"resourceLimits"`
 	AccountingId      string            `json:"accountingString"`
+	// Cluster and AffinityKey are hints for a uc in inception mode: the
+	// name of the cluster the job is forwarded to and the key its affinity
+	// scheduler uses for selecting the cluster. Cluster proxies ignore them.
+	Cluster     string `json:"cluster,omitempty"`
+	AffinityKey string `json:"affinityKey,omitempty"`
 }
 
 // CPU architecture types
@@ -181,6 +367,8 @@ func (cpu CPU) String() string {
 		return "MIPS64"
 	case PowerPC:
 		return "PowerPC"
+	case PowerPC64:
+		return "PowerPC64"
 	case SPARC:
 		return "SPARC"
 	case SPARC64:
@@ -264,13 +452,25 @@ type Machine struct {
 	Architecture   CPU     `json:"architecture"`
 	OSVersion      Version `json:"osVersion"`
 	OS             OS      `json:"os"`
+	// UnavailableReason explains why the machine doesn't accept jobs
+	// (like "maintenance", "alarm", or "full") if the DRMS reports it.
+	UnavailableReason string `json:"unavailableReason,omitempty" xml:",omitempty"`
+	// Draining is true when the machine doesn't accept new jobs but
+	// its running jobs are finished.
+	Draining bool `json:"draining,omitempty" xml:",omitempty"`
+	// Cluster is the name of the cluster the machine belongs to. It is
+	// set by uc in inception mode when machines of clusters are aggregated.
+	Cluster string `json:"cluster,omitempty" xml:",omitempty"`
 }
 
 // Queue is an extensible struct which contains all information about
 // a queue in the DRM.
 type Queue struct {
-	Extension `xml:"-" json:"-"`
-	Name      string `xml:"name"`
+	Extension  `xml:"-" json:"-"`
+	Name       string `xml:"name" json:"name"`
+	State      string `xml:"state,omitempty" json:"state,omitempty"` // like "enabled" or "disabled", empty if unknown
+	UsedSlots  int64  `xml:"usedSlots" json:"usedSlots"`
+	TotalSlots int64  `xml:"totalSlots" json:"totalSlots"`
 }
 
 // Special timeout value: Don't wait
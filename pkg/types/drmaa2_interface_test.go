@@ -0,0 +1,119 @@
+package types_test
+
+import (
+	"github.com/dgruber/ubercluster/pkg/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"time"
+)
+
+var _ = Describe("Drmaa2Interface", func() {
+
+	Context("job states", func() {
+
+		It("should parse short codes and names of job states", func() {
+			for _, js := range []types.JobState{types.Undetermined, types.Queued, types.QueuedHeld, types.Running,
+				types.Suspended, types.Requeued, types.RequeuedHeld, types.Done, types.Failed} {
+				parsed, err := types.ParseJobState(js.ShortCode())
+				Ω(err).Should(BeNil())
+				Ω(parsed).Should(Equal(js))
+				parsed, err = types.ParseJobState(js.String())
+				Ω(err).Should(BeNil())
+				Ω(parsed).Should(Equal(js))
+			}
+			parsed, err := types.ParseJobState("running")
+			Ω(err).Should(BeNil())
+			Ω(parsed).Should(Equal(types.Running))
+		})
+
+		It("should reject unknown job states", func() {
+			_, err := types.ParseJobState("xyz")
+			Ω(err).ShouldNot(BeNil())
+			_, err = types.ParseJobState("")
+			Ω(err).ShouldNot(BeNil())
+			Ω(types.Unset.ShortCode()).Should(Equal(""))
+		})
+
+		It("should classify terminal, active, and held job states", func() {
+			for _, js := range []types.JobState{types.Done, types.Failed} {
+				Ω(js.IsTerminal()).Should(BeTrue())
+				Ω(js.IsActive()).Should(BeFalse())
+			}
+			for _, js := range []types.JobState{types.Queued, types.QueuedHeld, types.Running,
+				types.Suspended, types.Requeued, types.RequeuedHeld} {
+				Ω(js.IsTerminal()).Should(BeFalse())
+				Ω(js.IsActive()).Should(BeTrue())
+			}
+			for _, js := range []types.JobState{types.Unset, types.Undetermined} {
+				Ω(js.IsTerminal()).Should(BeFalse())
+				Ω(js.IsActive()).Should(BeFalse())
+			}
+			Ω(types.QueuedHeld.IsHeld()).Should(BeTrue())
+			Ω(types.RequeuedHeld.IsHeld()).Should(BeTrue())
+			Ω(types.Queued.IsHeld()).Should(BeFalse())
+		})
+
+		It("should map job states to terminal colors", func() {
+			Ω(types.Done.ANSIColor()).Should(Equal("\x1b[32m"))
+			Ω(types.Failed.ANSIColor()).Should(Equal("\x1b[31m"))
+			Ω(types.Queued.ANSIColor()).Should(Equal("\x1b[33m"))
+			Ω(types.QueuedHeld.ANSIColor()).Should(Equal("\x1b[33m"))
+			Ω(types.Running.ANSIColor()).Should(Equal("\x1b[34m"))
+			Ω(types.Undetermined.ANSIColor()).Should(BeEmpty())
+		})
+
+	})
+
+	Context("job info filter", func() {
+
+		now := time.Now()
+		ji := types.JobInfo{
+			Id:                "13",
+			State:             types.Running,
+			AllocatedMachines: []string{"node1", "node2"},
+			JobOwner:          "user",
+			QueueName:         "all.q",
+			WallclockTime:     time.Minute,
+			SubmissionTime:    now,
+		}
+
+		It("should match when the filter is unset", func() {
+			Ω(ji.Matches(types.CreateJobInfo())).Should(BeTrue())
+			failed := ji
+			failed.ExitStatus = 1
+			Ω(failed.Matches(types.CreateJobInfo())).Should(BeTrue())
+		})
+
+		It("should filter for an exit status of 0", func() {
+			succeeded := types.JobInfo{Id: "13", State: types.Done, ExitStatus: 0}
+			failed := types.JobInfo{Id: "14", State: types.Failed, ExitStatus: 1}
+			filter := types.CreateJobInfo()
+			filter.ExitStatus = 0
+			Ω(succeeded.Matches(filter)).Should(BeTrue())
+			Ω(failed.Matches(filter)).Should(BeFalse())
+			filter.ExitStatus = 1
+			Ω(succeeded.Matches(filter)).Should(BeFalse())
+			Ω(failed.Matches(filter)).Should(BeTrue())
+		})
+
+		It("should match when all set fields of the filter match", func() {
+			Ω(ji.Matches(types.JobInfo{Id: "13", State: types.Running, JobOwner: "user"})).Should(BeTrue())
+			Ω(ji.Matches(types.JobInfo{AllocatedMachines: []string{"node2"}})).Should(BeTrue())
+			Ω(ji.Matches(types.JobInfo{WallclockTime: time.Second})).Should(BeTrue())
+			Ω(ji.Matches(types.JobInfo{SubmissionTime: now.Add(-time.Hour)})).Should(BeTrue())
+		})
+
+		It("should not match when a set field of the filter differs", func() {
+			Ω(ji.Matches(types.JobInfo{Id: "14"})).Should(BeFalse())
+			Ω(ji.Matches(types.JobInfo{State: types.Done})).Should(BeFalse())
+			Ω(ji.Matches(types.JobInfo{QueueName: "other.q"})).Should(BeFalse())
+			Ω(ji.Matches(types.JobInfo{AllocatedMachines: []string{"node1", "node3"}})).Should(BeFalse())
+			Ω(ji.Matches(types.JobInfo{WallclockTime: time.Hour})).Should(BeFalse())
+			Ω(ji.Matches(types.JobInfo{SubmissionTime: now.Add(time.Hour)})).Should(BeFalse())
+		})
+
+	})
+
+})
@@ -0,0 +1,105 @@
+package types
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AllJobOwners is the value of the "user" filter of job info requests
+// which selects the jobs of all users. An empty user filter selects
+// all jobs as well.
+const AllJobOwners = "all"
+
+// CreateJobInfo returns a job info for filtering jobs where the exit
+// status is unset (UnsetNum) so that it matches any exit status. Other
+// fields are unset by their zero values.
+func CreateJobInfo() JobInfo {
+	return JobInfo{ExitStatus: int(UnsetNum)}
+}
+
+// timeSet returns true when t holds an actual point in time and not
+// one of the special DRMAA2 time values.
+func timeSet(t time.Time) bool {
+	if t.IsZero() {
+		return false
+	}
+	switch t.Unix() {
+	case ZeroTime, InfiniteTime, UnsetTime:
+		return false
+	}
+	return true
+}
+
+// RunDuration returns how long the job has been running. For finished
+// jobs this is the time between dispatch and finish, for jobs which are
+// still running the time since dispatch. It returns 0 when the job was
+// not dispatched yet.
+func (ji *JobInfo) RunDuration() time.Duration {
+	if !timeSet(ji.DispatchTime) {
+		return 0
+	}
+	if !timeSet(ji.FinishTime) {
+		return time.Since(ji.DispatchTime)
+	}
+	if ji.FinishTime.Before(ji.DispatchTime) {
+		return 0
+	}
+	return ji.FinishTime.Sub(ji.DispatchTime)
+}
+
+// QueueDuration returns how long the job was waiting between submission
+// and dispatch. It returns 0 when one of both times is not set.
+func (ji *JobInfo) QueueDuration() time.Duration {
+	if !timeSet(ji.SubmissionTime) || !timeSet(ji.DispatchTime) {
+		return 0
+	}
+	if ji.DispatchTime.Before(ji.SubmissionTime) {
+		return 0
+	}
+	return ji.DispatchTime.Sub(ji.SubmissionTime)
+}
+
+// ParseTime parses a point in time which is either given in RFC 3339
+// format or as a duration before now (like "30m" or "2h"). Durations
+// accept days as "d" unit (like "1d" or "1d12h").
+func ParseTime(value string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	invalid := fmt.Errorf("invalid time %q (expected RFC 3339 time or duration like 30m, 2h, 1d)", value)
+	var days time.Duration
+	rest := value
+	if i := strings.Index(value, "d"); i >= 0 {
+		n, err := strconv.Atoi(value[:i])
+		if err != nil || n < 0 {
+			return time.Time{}, invalid
+		}
+		days = time.Duration(n) * 24 * time.Hour
+		rest = value[i+1:]
+	}
+	var d time.Duration
+	if rest != "" {
+		var err error
+		if d, err = time.ParseDuration(rest); err != nil || d < 0 {
+			return time.Time{}, invalid
+		}
+	} else if rest == value {
+		return time.Time{}, invalid
+	}
+	return now.Add(-(days + d)), nil
+}
+
+// SubmittedBetween returns true when the job was submitted within the
+// time window (including both ends). A zero since or until leaves the
+// window open at that side.
+func (ji *JobInfo) SubmittedBetween(since, until time.Time) bool {
+	if !since.IsZero() && ji.SubmissionTime.Before(since) {
+		return false
+	}
+	if !until.IsZero() && ji.SubmissionTime.After(until) {
+		return false
+	}
+	return true
+}
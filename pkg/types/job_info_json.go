@@ -0,0 +1,72 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// jobInfoJSON has the fields of JobInfo but not its JSON methods.
+type jobInfoJSON JobInfo
+
+// MarshalJSON implements the json.Marshaler interface. The times are
+// encoded in RFC 3339 format. The wallclock time is kept as integer
+// amount of nanoseconds in "wallockTime" as the v1 protocol defines it
+// and is additionally given as readable duration string (like "1h30m0s")
+// in "wallclockTime".
+func (ji JobInfo) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		jobInfoJSON
+		Wallclock string `json:"wallclockTime"`
+	}{
+		jobInfoJSON: jobInfoJSON(ji),
+		Wallclock:   ji.WallclockTime.String(),
+	})
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. The duration
+// string in "wallclockTime" is preferred, older proxies send only the
+// nanoseconds in "wallockTime".
+func (ji *JobInfo) UnmarshalJSON(data []byte) error {
+	var j struct {
+		jobInfoJSON
+		WallclockTime json.RawMessage `json:"wallockTime"`
+		Wallclock     json.RawMessage `json:"wallclockTime"`
+	}
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	raw := j.WallclockTime
+	if len(j.Wallclock) > 0 && string(j.Wallclock) != "null" {
+		raw = j.Wallclock
+	}
+	wallclock, err := unmarshalDuration(raw)
+	if err != nil {
+		return err
+	}
+	*ji = JobInfo(j.jobInfoJSON)
+	ji.WallclockTime = wallclock
+	return nil
+}
+
+// unmarshalDuration parses the JSON representation of a duration which
+// is either a duration string or an integer amount of nanoseconds. A
+// missing value or null is 0.
+func unmarshalDuration(data json.RawMessage) (time.Duration, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return 0, nil
+	}
+	var ns int64
+	if err := json.Unmarshal(data, &ns); err == nil {
+		return time.Duration(ns), nil
+	}
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return 0, fmt.Errorf("duration must be a string or a number: %s", string(data))
+	}
+	d, err := time.ParseDuration(str)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration: %s", str)
+	}
+	return d, nil
+}
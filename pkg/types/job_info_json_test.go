@@ -0,0 +1,74 @@
+package types_test
+
+import (
+	"github.com/dgruber/ubercluster/pkg/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"encoding/json"
+	"time"
+)
+
+var _ = Describe("JobInfoJson", func() {
+
+	submitted := time.Date(2015, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	It("should encode the wallclock time in nanoseconds and as duration string", func() {
+		ji := types.JobInfo{Id: "1", State: types.Done, WallclockTime: 90 * time.Minute, SubmissionTime: submitted}
+		out, err := json.Marshal(ji)
+		Ω(err).Should(BeNil())
+		Ω(string(out)).Should(ContainSubstring(`"wallockTime":5400000000000`))
+		Ω(string(out)).Should(ContainSubstring(`"wallclockTime":"1h30m0s"`))
+		Ω(string(out)).Should(ContainSubstring(`"submissionTime":"2015-01-02T03:04:05Z"`))
+		Ω(string(out)).Should(ContainSubstring(`"id":"1"`))
+	})
+
+	It("should decode what it encodes", func() {
+		ji := types.JobInfo{
+			Id:                "2",
+			JobOwner:          "alice",
+			State:             types.Running,
+			AllocatedMachines: []string{"u1010"},
+			WallclockTime:     2*time.Second + 500*time.Millisecond,
+			DispatchTime:      submitted.Add(time.Minute),
+		}
+		ji.ExtensionList = map[string]string{"uge_ji_pe": "mpi"}
+		out, err := json.Marshal(ji)
+		Ω(err).Should(BeNil())
+		var decoded types.JobInfo
+		Ω(json.Unmarshal(out, &decoded)).Should(BeNil())
+		Ω(decoded.Id).Should(Equal("2"))
+		Ω(decoded.JobOwner).Should(Equal("alice"))
+		Ω(decoded.State).Should(Equal(types.Running))
+		Ω(decoded.AllocatedMachines).Should(Equal([]string{"u1010"}))
+		Ω(decoded.WallclockTime).Should(Equal(ji.WallclockTime))
+		Ω(decoded.DispatchTime.Equal(ji.DispatchTime)).Should(BeTrue())
+		Ω(decoded.ExtensionList).Should(Equal(ji.ExtensionList))
+	})
+
+	It("should accept the wallclock time in nanoseconds from older proxies", func() {
+		var ji types.JobInfo
+		Ω(json.Unmarshal([]byte(`{"id":"3","wallockTime":60000000000}`), &ji)).Should(BeNil())
+		Ω(ji.Id).Should(Equal("3"))
+		Ω(ji.WallclockTime).Should(Equal(time.Minute))
+
+		ji = types.JobInfo{}
+		Ω(json.Unmarshal([]byte(`{"id":"4"}`), &ji)).Should(BeNil())
+		Ω(ji.WallclockTime).Should(BeZero())
+	})
+
+	It("should prefer the duration string", func() {
+		var ji types.JobInfo
+		Ω(json.Unmarshal([]byte(`{"wallockTime":60000000000,"wallclockTime":"2m"}`), &ji)).Should(BeNil())
+		Ω(ji.WallclockTime).Should(Equal(2 * time.Minute))
+	})
+
+	It("should reject invalid wallclock times", func() {
+		var ji types.JobInfo
+		Ω(json.Unmarshal([]byte(`{"wallockTime":"long"}`), &ji)).ShouldNot(BeNil())
+		Ω(json.Unmarshal([]byte(`{"wallockTime":true}`), &ji)).ShouldNot(BeNil())
+		Ω(json.Unmarshal([]byte(`{"wallclockTime":"long"}`), &ji)).ShouldNot(BeNil())
+	})
+
+})
@@ -0,0 +1,89 @@
+package types_test
+
+import (
+	"time"
+
+	"github.com/dgruber/ubercluster/pkg/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("JobInfo", func() {
+
+	Context("submission time window", func() {
+
+		now := time.Date(2016, 1, 2, 12, 0, 0, 0, time.UTC)
+
+		It("should parse RFC 3339 times and durations before now", func() {
+			t, err := types.ParseTime("2016-01-01T10:00:00Z", now)
+			Ω(err).Should(BeNil())
+			Ω(t).Should(Equal(time.Date(2016, 1, 1, 10, 0, 0, 0, time.UTC)))
+			t, err = types.ParseTime("30m", now)
+			Ω(err).Should(BeNil())
+			Ω(t).Should(Equal(now.Add(-30 * time.Minute)))
+			t, err = types.ParseTime("2h", now)
+			Ω(err).Should(BeNil())
+			Ω(t).Should(Equal(now.Add(-2 * time.Hour)))
+			t, err = types.ParseTime("1d", now)
+			Ω(err).Should(BeNil())
+			Ω(t).Should(Equal(now.Add(-24 * time.Hour)))
+			t, err = types.ParseTime("1d12h", now)
+			Ω(err).Should(BeNil())
+			Ω(t).Should(Equal(now.Add(-36 * time.Hour)))
+		})
+
+		It("should reject invalid times", func() {
+			for _, value := range []string{"", "yesterday", "d", "-1h", "1x", "1d-2h"} {
+				_, err := types.ParseTime(value, now)
+				Ω(err).ShouldNot(BeNil(), value)
+			}
+		})
+
+		It("should select jobs submitted within the window", func() {
+			ji := types.JobInfo{SubmissionTime: now}
+			Ω(ji.SubmittedBetween(time.Time{}, time.Time{})).Should(BeTrue())
+			Ω(ji.SubmittedBetween(now, now)).Should(BeTrue())
+			Ω(ji.SubmittedBetween(now.Add(-time.Hour), time.Time{})).Should(BeTrue())
+			Ω(ji.SubmittedBetween(now.Add(time.Hour), time.Time{})).Should(BeFalse())
+			Ω(ji.SubmittedBetween(time.Time{}, now.Add(-time.Hour))).Should(BeFalse())
+		})
+
+	})
+
+	Context("durations", func() {
+
+		submitted := time.Date(2016, 1, 1, 10, 0, 0, 0, time.UTC)
+		dispatched := submitted.Add(5 * time.Minute)
+		finished := dispatched.Add(time.Hour)
+
+		It("should return the run and queue duration of a finished job", func() {
+			ji := types.JobInfo{
+				SubmissionTime: submitted,
+				DispatchTime:   dispatched,
+				FinishTime:     finished,
+			}
+			Ω(ji.RunDuration()).Should(Equal(time.Hour))
+			Ω(ji.QueueDuration()).Should(Equal(5 * time.Minute))
+		})
+
+		It("should return the time since dispatch for a running job", func() {
+			ji := types.JobInfo{
+				SubmissionTime: time.Now().Add(-2 * time.Minute),
+				DispatchTime:   time.Now().Add(-time.Minute),
+			}
+			Ω(ji.RunDuration()).Should(BeNumerically("~", time.Minute, time.Second))
+			Ω(ji.QueueDuration()).Should(BeNumerically("~", time.Minute, time.Second))
+		})
+
+		It("should return 0 when the times are not set", func() {
+			ji := types.JobInfo{SubmissionTime: submitted}
+			Ω(ji.RunDuration()).Should(BeZero())
+			Ω(ji.QueueDuration()).Should(BeZero())
+			ji.DispatchTime = time.Unix(types.UnsetTime, 0)
+			ji.FinishTime = time.Unix(types.UnsetTime, 0)
+			Ω(ji.RunDuration()).Should(BeZero())
+			Ω(ji.QueueDuration()).Should(BeZero())
+		})
+	})
+})
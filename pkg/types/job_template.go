@@ -0,0 +1,62 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Clone returns a deep copy of the job template. Slices and maps of the
+// copy can be modified without changing the original template, which
+// allows to derive multiple jobs from a base template. Only the
+// internal C pointer of the extension is shared.
+func (jt JobTemplate) Clone() JobTemplate {
+	c := jt
+	c.Extension.ExtensionList = copyStringMap(jt.Extension.ExtensionList)
+	c.Args = copyStrings(jt.Args)
+	c.JobEnvironment = copyStringMap(jt.JobEnvironment)
+	c.Email = copyStrings(jt.Email)
+	c.CandidateMachines = copyStrings(jt.CandidateMachines)
+	c.StageInFiles = copyStringMap(jt.StageInFiles)
+	c.StageOutFiles = copyStringMap(jt.StageOutFiles)
+	c.ResourceLimits = copyStringMap(jt.ResourceLimits)
+	return c
+}
+
+// copyStrings returns a copy of the slice keeping nil slices nil.
+func copyStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	c := make([]string, len(s))
+	copy(c, s)
+	return c
+}
+
+// copyStringMap returns a copy of the map keeping nil maps nil.
+func copyStringMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// ValidateResources checks that the queue and the job category requested
+// by the job template are part of the given lists of valid queues and job
+// categories. Unset requests are not checked, an empty list of valid
+// values disables the corresponding check. The error lists the valid
+// values.
+func (jt JobTemplate) ValidateResources(queues, categories []string) error {
+	if jt.QueueName != "" && len(queues) > 0 && !containsString(queues, jt.QueueName) {
+		return fmt.Errorf("unknown queue %s (valid queues: %s)",
+			jt.QueueName, strings.Join(queues, ", "))
+	}
+	if jt.JobCategory != "" && len(categories) > 0 && !containsString(categories, jt.JobCategory) {
+		return fmt.Errorf("unknown job category %s (valid job categories: %s)",
+			jt.JobCategory, strings.Join(categories, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package types
+
+import (
+	"time"
+)
+
+// JobTemplateBuilder creates job templates with fluent setters. Fields
+// which are not set keep their unset values (see CreateJobTemplate) so
+// that they are omitted when the job template is sent to a proxy.
+//
+//	jt := types.NewJobTemplate("/bin/sleep").WithArgs("60").
+//		WithQueue("all.q").WithResourceLimit("h_rt", "120").Build()
+type JobTemplateBuilder struct {
+	jt JobTemplate
+}
+
+// NewJobTemplate returns a builder for a job template running the
+// given command.
+func NewJobTemplate(command string) *JobTemplateBuilder {
+	jt := CreateJobTemplate()
+	jt.RemoteCommand = command
+	return &JobTemplateBuilder{jt: jt}
+}
+
+// Build returns the job template. The builder can be used further
+// without changing the returned job template.
+func (b *JobTemplateBuilder) Build() JobTemplate {
+	return b.jt.Clone()
+}
+
+// WithArgs appends arguments of the command.
+func (b *JobTemplateBuilder) WithArgs(args ...string) *JobTemplateBuilder {
+	b.jt.Args = append(b.jt.Args, args...)
+	return b
+}
+
+// WithName sets the job name.
+func (b *JobTemplateBuilder) WithName(name string) *JobTemplateBuilder {
+	b.jt.JobName = name
+	return b
+}
+
+// WithQueue sets the queue the job is submitted to.
+func (b *JobTemplateBuilder) WithQueue(queue string) *JobTemplateBuilder {
+	b.jt.QueueName = queue
+	return b
+}
+
+// WithCategory sets the job category.
+func (b *JobTemplateBuilder) WithCategory(category string) *JobTemplateBuilder {
+	b.jt.JobCategory = category
+	return b
+}
+
+// WithEnv adds the environment variables to the job environment.
+func (b *JobTemplateBuilder) WithEnv(env map[string]string) *JobTemplateBuilder {
+	b.jt.JobEnvironment = addToStringMap(b.jt.JobEnvironment, env)
+	return b
+}
+
+// WithWorkingDirectory sets the working directory of the job.
+func (b *JobTemplateBuilder) WithWorkingDirectory(dir string) *JobTemplateBuilder {
+	b.jt.WorkingDirectory = dir
+	return b
+}
+
+// WithInputPath sets the file used as stdin of the job.
+func (b *JobTemplateBuilder) WithInputPath(path string) *JobTemplateBuilder {
+	b.jt.InputPath = path
+	return b
+}
+
+// WithOutputPath sets the file used as stdout of the job.
+func (b *JobTemplateBuilder) WithOutputPath(path string) *JobTemplateBuilder {
+	b.jt.OutputPath = path
+	return b
+}
+
+// WithErrorPath sets the file used as stderr of the job.
+func (b *JobTemplateBuilder) WithErrorPath(path string) *JobTemplateBuilder {
+	b.jt.ErrorPath = path
+	return b
+}
+
+// WithJoinedFiles writes stderr of the job into its stdout file.
+func (b *JobTemplateBuilder) WithJoinedFiles() *JobTemplateBuilder {
+	b.jt.JoinFiles = true
+	return b
+}
+
+// WithSlots sets the minimum and maximum amount of slots of the job.
+func (b *JobTemplateBuilder) WithSlots(min, max int64) *JobTemplateBuilder {
+	b.jt.MinSlots = min
+	b.jt.MaxSlots = max
+	return b
+}
+
+// WithPriority sets the priority of the job.
+func (b *JobTemplateBuilder) WithPriority(priority int64) *JobTemplateBuilder {
+	b.jt.Priority = priority
+	return b
+}
+
+// WithMinPhysMemory sets the minimum amount of physical memory (in
+// kilobyte) of the machines the job runs on.
+func (b *JobTemplateBuilder) WithMinPhysMemory(memory int64) *JobTemplateBuilder {
+	b.jt.MinPhysMemory = memory
+	return b
+}
+
+// WithCandidateMachines appends machines the job can run on.
+func (b *JobTemplateBuilder) WithCandidateMachines(machines ...string) *JobTemplateBuilder {
+	b.jt.CandidateMachines = append(b.jt.CandidateMachines, machines...)
+	return b
+}
+
+// WithMachine sets the operating system and the CPU architecture of
+// the machines the job runs on.
+func (b *JobTemplateBuilder) WithMachine(os, arch string) *JobTemplateBuilder {
+	b.jt.MachineOs = os
+	b.jt.MachineArch = arch
+	return b
+}
+
+// WithResourceLimit sets a resource limit of the job.
+func (b *JobTemplateBuilder) WithResourceLimit(name, value string) *JobTemplateBuilder {
+	b.jt.ResourceLimits = addToStringMap(b.jt.ResourceLimits, map[string]string{name: value})
+	return b
+}
+
+// WithStageInFile adds a file which is transferred to the job.
+func (b *JobTemplateBuilder) WithStageInFile(source, destination string) *JobTemplateBuilder {
+	b.jt.StageInFiles = addToStringMap(b.jt.StageInFiles, map[string]string{source: destination})
+	return b
+}
+
+// WithStageOutFile adds a file which is transferred from the job.
+func (b *JobTemplateBuilder) WithStageOutFile(source, destination string) *JobTemplateBuilder {
+	b.jt.StageOutFiles = addToStringMap(b.jt.StageOutFiles, map[string]string{source: destination})
+	return b
+}
+
+// WithEmail appends addresses notified when the job starts or ends.
+func (b *JobTemplateBuilder) WithEmail(onStarted, onTerminated bool, addresses ...string) *JobTemplateBuilder {
+	b.jt.Email = append(b.jt.Email, addresses...)
+	b.jt.EmailOnStarted = onStarted
+	b.jt.EmailOnTerminated = onTerminated
+	return b
+}
+
+// WithStartTime sets the earliest time the job is started.
+func (b *JobTemplateBuilder) WithStartTime(t time.Time) *JobTemplateBuilder {
+	b.jt.StartTime = t
+	return b
+}
+
+// WithDeadline sets the time the job must be finished.
+func (b *JobTemplateBuilder) WithDeadline(t time.Time) *JobTemplateBuilder {
+	b.jt.DeadlineTime = t
+	return b
+}
+
+// WithReservation runs the job in the advance reservation.
+func (b *JobTemplateBuilder) WithReservation(id string) *JobTemplateBuilder {
+	b.jt.ReservationId = id
+	return b
+}
+
+// WithAccounting sets the accounting string of the job.
+func (b *JobTemplateBuilder) WithAccounting(accounting string) *JobTemplateBuilder {
+	b.jt.AccountingId = accounting
+	return b
+}
+
+// AsHold submits the job in hold state.
+func (b *JobTemplateBuilder) AsHold() *JobTemplateBuilder {
+	b.jt.SubmitAsHold = true
+	return b
+}
+
+// AsReRunnable allows the DRMS to restart the job.
+func (b *JobTemplateBuilder) AsReRunnable() *JobTemplateBuilder {
+	b.jt.ReRunnable = true
+	return b
+}
+
+// addToStringMap adds the values to the map which is created when
+// it is nil.
+func addToStringMap(m, values map[string]string) map[string]string {
+	if len(values) == 0 {
+		return m
+	}
+	if m == nil {
+		m = make(map[string]string, len(values))
+	}
+	for k, v := range values {
+		m[k] = v
+	}
+	return m
+}
@@ -0,0 +1,43 @@
+package types_test
+
+import (
+	"github.com/dgruber/ubercluster/pkg/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"encoding/json"
+)
+
+var _ = Describe("JobTemplateBuilder", func() {
+
+	It("should set the given fields only", func() {
+		jt := types.NewJobTemplate("/bin/sleep").WithArgs("60").WithQueue("all.q").
+			WithEnv(map[string]string{"KEY": "value"}).WithResourceLimit("h_rt", "120").Build()
+		Ω(jt.RemoteCommand).Should(Equal("/bin/sleep"))
+		Ω(jt.Args).Should(Equal([]string{"60"}))
+		Ω(jt.QueueName).Should(Equal("all.q"))
+		Ω(jt.JobEnvironment).Should(Equal(map[string]string{"KEY": "value"}))
+		Ω(jt.ResourceLimits).Should(Equal(map[string]string{"h_rt": "120"}))
+		Ω(jt.MinSlots).Should(Equal(types.UnsetNum))
+		Ω(jt.Priority).Should(Equal(types.UnsetNum))
+	})
+
+	It("should omit unset fields and keep set zero values in JSON", func() {
+		jt := types.NewJobTemplate("sleep").WithPriority(0).Build()
+		out, err := json.Marshal(jt)
+		Ω(err).Should(BeNil())
+		Ω(string(out)).Should(Equal(`{"remoteCommand":"sleep","priority":0}`))
+	})
+
+	It("should not change built job templates when used further", func() {
+		b := types.NewJobTemplate("sleep").WithArgs("1").WithResourceLimit("h_rt", "60")
+		first := b.Build()
+		second := b.WithArgs("2").WithResourceLimit("h_rt", "120").Build()
+		Ω(first.Args).Should(Equal([]string{"1"}))
+		Ω(first.ResourceLimits["h_rt"]).Should(Equal("60"))
+		Ω(second.Args).Should(Equal([]string{"1", "2"}))
+		Ω(second.ResourceLimits["h_rt"]).Should(Equal("120"))
+	})
+
+})
@@ -0,0 +1,190 @@
+package types
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// UnsetNum marks a numeric job template field (MinSlots, MaxSlots,
+// Priority, MinPhysMemory) as not set, like DRMAA2_UNSET_NUM does
+// in the DRMAA2 C API.
+const UnsetNum = int64(-1)
+
+// CreateJobTemplate returns a job template where all numeric
+// fields are unset.
+func CreateJobTemplate() JobTemplate {
+	return JobTemplate{
+		MinSlots:      UnsetNum,
+		MaxSlots:      UnsetNum,
+		Priority:      UnsetNum,
+		MinPhysMemory: UnsetNum,
+	}
+}
+
+// UnsetNumToZero converts numeric job template values which are not
+// set into 0 which is treated as unset by the DRMAA2 layers of the
+// proxies (drmaa2os and the DRMAA2 C API).
+func UnsetNumToZero(v int64) int64 {
+	if v == UnsetNum {
+		return 0
+	}
+	return v
+}
+
+// jobTemplateJSON is the JSON representation of a job template
+// where unset fields are omitted.
+type jobTemplateJSON struct {
+	RemoteCommand     string            `json:"remoteCommand,omitempty"`
+	Args              []string          `json:"args,omitempty"`
+	SubmitAsHold      bool              `json:"submitAsHold,omitempty"`
+	ReRunnable        bool              `json:"reRunnable,omitempty"`
+	JobEnvironment    map[string]string `json:"jobEnvironment,omitempty"`
+	WorkingDirectory  string            `json:"workingDirectory,omitempty"`
+	JobCategory       string            `json:"jobCategory,omitempty"`
+	Email             []string          `json:"email,omitempty"`
+	EmailOnStarted    bool              `json:"emailOnStarted,omitempty"`
+	EmailOnTerminated bool              `json:"emailOnTerminated,omitempty"`
+	JobName           string            `json:"jobName,omitempty"`
+	InputPath         string            `json:"inputPath,omitempty"`
+	OutputPath        string            `json:"outputPath,omitempty"`
+	ErrorPath         string            `json:"errorPath,omitempty"`
+	JoinFiles         bool              `json:"joinFiles,omitempty"`
+	ReservationId     string            `json:"reservationId,omitempty"`
+	QueueName         string            `json:"queueName,omitempty"`
+	MinSlots          *int64            `json:"minSlots,omitempty"`
+	MaxSlots          *int64            `json:"maxSlots,omitempty"`
+	Priority          *int64            `json:"priority,omitempty"`
+	CandidateMachines []string          `json:"candidateMachines,omitempty"`
+	MinPhysMemory     *int64            `json:"minPhysMemory,omitempty"`
+	MachineOs         string            `json:"machineOs,omitempty"`
+	MachineArch       string            `json:"machineArch,omitempty"`
+	StartTime         *time.Time        `json:"startTime,omitempty"`
+	DeadlineTime      *time.Time        `json:"deadlineTime,omitempty"`
+	StageInFiles      map[string]string `json:"stageInFiles,omitempty"`
+	StageOutFiles     map[string]string `json:"stageOutFiles,omitempty"`
+	ResourceLimits    map[string]string `json:"resourceLimits,omitempty"`
+	AccountingId      string            `json:"accountingString,omitempty"`
+	Cluster           string            `json:"cluster,omitempty"`
+	AffinityKey       string            `json:"affinityKey,omitempty"`
+}
+
+func setNum(v int64) *int64 {
+	if v == UnsetNum {
+		return nil
+	}
+	return &v
+}
+
+func getNum(v *int64) int64 {
+	if v == nil {
+		return UnsetNum
+	}
+	return *v
+}
+
+func setTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func getTime(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
+// MarshalJSON implements the json.Marshaler interface. Unset fields
+// (empty strings and lists, false, UnsetNum, and zero times) are
+// omitted so that the receiver can distinguish them from fields which
+// are set to 0.
+func (jt JobTemplate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jobTemplateJSON{
+		RemoteCommand:     jt.RemoteCommand,
+		Args:              jt.Args,
+		SubmitAsHold:      jt.SubmitAsHold,
+		ReRunnable:        jt.ReRunnable,
+		JobEnvironment:    jt.JobEnvironment,
+		WorkingDirectory:  jt.WorkingDirectory,
+		JobCategory:       jt.JobCategory,
+		Email:             jt.Email,
+		EmailOnStarted:    jt.EmailOnStarted,
+		EmailOnTerminated: jt.EmailOnTerminated,
+		JobName:           jt.JobName,
+		InputPath:         jt.InputPath,
+		OutputPath:        jt.OutputPath,
+		ErrorPath:         jt.ErrorPath,
+		JoinFiles:         jt.JoinFiles,
+		ReservationId:     jt.ReservationId,
+		QueueName:         jt.QueueName,
+		MinSlots:          setNum(jt.MinSlots),
+		MaxSlots:          setNum(jt.MaxSlots),
+		Priority:          setNum(jt.Priority),
+		CandidateMachines: jt.CandidateMachines,
+		MinPhysMemory:     setNum(jt.MinPhysMemory),
+		MachineOs:         jt.MachineOs,
+		MachineArch:       jt.MachineArch,
+		StartTime:         setTime(jt.StartTime),
+		DeadlineTime:      setTime(jt.DeadlineTime),
+		StageInFiles:      jt.StageInFiles,
+		StageOutFiles:     jt.StageOutFiles,
+		ResourceLimits:    jt.ResourceLimits,
+		AccountingId:      jt.AccountingId,
+		Cluster:           jt.Cluster,
+		AffinityKey:       jt.AffinityKey,
+	})
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. Numeric
+// fields which are not part of the JSON document are set to UnsetNum.
+func (jt *JobTemplate) UnmarshalJSON(data []byte) error {
+	var j jobTemplateJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	jt.RemoteCommand = j.RemoteCommand
+	jt.Args = j.Args
+	jt.SubmitAsHold = j.SubmitAsHold
+	jt.ReRunnable = j.ReRunnable
+	jt.JobEnvironment = j.JobEnvironment
+	jt.WorkingDirectory = j.WorkingDirectory
+	jt.JobCategory = j.JobCategory
+	jt.Email = j.Email
+	jt.EmailOnStarted = j.EmailOnStarted
+	jt.EmailOnTerminated = j.EmailOnTerminated
+	jt.JobName = j.JobName
+	jt.InputPath = j.InputPath
+	jt.OutputPath = j.OutputPath
+	jt.ErrorPath = j.ErrorPath
+	jt.JoinFiles = j.JoinFiles
+	jt.ReservationId = j.ReservationId
+	jt.QueueName = j.QueueName
+	jt.MinSlots = getNum(j.MinSlots)
+	jt.MaxSlots = getNum(j.MaxSlots)
+	jt.Priority = getNum(j.Priority)
+	jt.CandidateMachines = j.CandidateMachines
+	jt.MinPhysMemory = getNum(j.MinPhysMemory)
+	jt.MachineOs = j.MachineOs
+	jt.MachineArch = j.MachineArch
+	jt.StartTime = getTime(j.StartTime)
+	jt.DeadlineTime = getTime(j.DeadlineTime)
+	jt.StageInFiles = j.StageInFiles
+	jt.StageOutFiles = j.StageOutFiles
+	jt.ResourceLimits = j.ResourceLimits
+	jt.AccountingId = j.AccountingId
+	jt.Cluster = j.Cluster
+	jt.AffinityKey = j.AffinityKey
+	return nil
+}
+
+// String implements the Stringer interface by returning the
+// JSON representation of the set fields of the job template.
+func (jt JobTemplate) String() string {
+	out, err := jt.MarshalJSON()
+	if err != nil {
+		return err.Error()
+	}
+	return string(out)
+}
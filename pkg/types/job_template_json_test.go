@@ -0,0 +1,67 @@
+package types_test
+
+import (
+	"github.com/dgruber/ubercluster/pkg/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"encoding/json"
+)
+
+var _ = Describe("JobTemplateJson", func() {
+
+	Context("converting unset values", func() {
+
+		It("should convert only UnsetNum into 0", func() {
+			Ω(types.UnsetNumToZero(types.UnsetNum)).Should(BeNumerically("==", 0))
+			Ω(types.UnsetNumToZero(0)).Should(BeNumerically("==", 0))
+			Ω(types.UnsetNumToZero(4)).Should(BeNumerically("==", 4))
+		})
+
+	})
+
+	Context("marshalling", func() {
+
+		It("should omit unset fields", func() {
+			jt := types.CreateJobTemplate()
+			jt.RemoteCommand = "sleep"
+			out, err := json.Marshal(jt)
+			Ω(err).Should(BeNil())
+			Ω(string(out)).Should(Equal(`{"remoteCommand":"sleep"}`))
+		})
+
+		It("should keep numeric fields which are set to 0", func() {
+			jt := types.CreateJobTemplate()
+			jt.Priority = 0
+			out, err := json.Marshal(jt)
+			Ω(err).Should(BeNil())
+			Ω(string(out)).Should(Equal(`{"priority":0}`))
+		})
+
+		It("should distinguish unset from 0 when unmarshalling", func() {
+			var jt types.JobTemplate
+			err := json.Unmarshal([]byte(`{"remoteCommand":"sleep","minSlots":0}`), &jt)
+			Ω(err).Should(BeNil())
+			Ω(jt.RemoteCommand).Should(Equal("sleep"))
+			Ω(jt.MinSlots).Should(BeNumerically("==", 0))
+			Ω(jt.MaxSlots).Should(Equal(types.UnsetNum))
+			Ω(jt.Priority).Should(Equal(types.UnsetNum))
+		})
+
+		It("should survive a round trip", func() {
+			jt := types.CreateJobTemplate()
+			jt.RemoteCommand = "sleep"
+			jt.Args = []string{"1"}
+			jt.MaxSlots = 4
+			jt.JobEnvironment = map[string]string{"KEY": "value"}
+			out, err := json.Marshal(jt)
+			Ω(err).Should(BeNil())
+			var jt2 types.JobTemplate
+			Ω(json.Unmarshal(out, &jt2)).Should(BeNil())
+			Ω(jt2).Should(Equal(jt))
+		})
+
+	})
+
+})
@@ -0,0 +1,91 @@
+package types_test
+
+import (
+	"github.com/dgruber/ubercluster/pkg/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("JobTemplate", func() {
+
+	Context("Clone", func() {
+
+		It("should not share slices and maps with the original", func() {
+			jt := types.CreateJobTemplate()
+			jt.RemoteCommand = "sleep"
+			jt.Args = []string{"10"}
+			jt.JobEnvironment = map[string]string{"A": "1"}
+			jt.Email = []string{"a@example.com"}
+			jt.CandidateMachines = []string{"host1"}
+			jt.StageInFiles = map[string]string{"in": "in"}
+			jt.StageOutFiles = map[string]string{"out": "out"}
+			jt.ResourceLimits = map[string]string{"mem": "1G"}
+			jt.ExtensionList = map[string]string{"ext": "1"}
+
+			c := jt.Clone()
+			Ω(c).Should(Equal(jt))
+
+			c.Args[0] = "20"
+			c.JobEnvironment["A"] = "2"
+			c.Email[0] = "b@example.com"
+			c.CandidateMachines[0] = "host2"
+			c.StageInFiles["in"] = "changed"
+			c.StageOutFiles["out"] = "changed"
+			c.ResourceLimits["mem"] = "2G"
+			c.ExtensionList["ext"] = "2"
+
+			Ω(jt.Args).Should(Equal([]string{"10"}))
+			Ω(jt.JobEnvironment).Should(Equal(map[string]string{"A": "1"}))
+			Ω(jt.Email).Should(Equal([]string{"a@example.com"}))
+			Ω(jt.CandidateMachines).Should(Equal([]string{"host1"}))
+			Ω(jt.StageInFiles).Should(Equal(map[string]string{"in": "in"}))
+			Ω(jt.StageOutFiles).Should(Equal(map[string]string{"out": "out"}))
+			Ω(jt.ResourceLimits).Should(Equal(map[string]string{"mem": "1G"}))
+			Ω(jt.ExtensionList).Should(Equal(map[string]string{"ext": "1"}))
+		})
+
+		It("should keep unset slices and maps nil", func() {
+			c := types.CreateJobTemplate().Clone()
+			Ω(c.Args).Should(BeNil())
+			Ω(c.JobEnvironment).Should(BeNil())
+			Ω(c).Should(Equal(types.CreateJobTemplate()))
+		})
+	})
+
+	Context("ValidateResources", func() {
+
+		queues := []string{"all.q", "long.q"}
+		categories := []string{"docker"}
+
+		It("should accept known and unset queues and job categories", func() {
+			jt := types.CreateJobTemplate()
+			Ω(jt.ValidateResources(queues, categories)).Should(BeNil())
+			jt.QueueName = "long.q"
+			jt.JobCategory = "docker"
+			Ω(jt.ValidateResources(queues, categories)).Should(BeNil())
+		})
+
+		It("should reject unknown queues and job categories with the valid values", func() {
+			jt := types.CreateJobTemplate()
+			jt.QueueName = "short.q"
+			err := jt.ValidateResources(queues, categories)
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Error()).Should(ContainSubstring("short.q"))
+			Ω(err.Error()).Should(ContainSubstring("all.q, long.q"))
+
+			jt.QueueName = ""
+			jt.JobCategory = "vm"
+			err = jt.ValidateResources(queues, categories)
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Error()).Should(ContainSubstring("valid job categories: docker"))
+		})
+
+		It("should skip the checks when no valid values are known", func() {
+			jt := types.CreateJobTemplate()
+			jt.QueueName = "short.q"
+			jt.JobCategory = "vm"
+			Ω(jt.ValidateResources(nil, nil)).Should(BeNil())
+		})
+	})
+})
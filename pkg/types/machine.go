@@ -0,0 +1,113 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+)
+
+var byteUnits = []string{"KiB", "MiB", "GiB", "TiB", "PiB"}
+
+// humanBytes formats an amount of bytes with binary units.
+func humanBytes(bytes int64) string {
+	if bytes < 1024 {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	value := float64(bytes) / 1024
+	unit := 0
+	for value >= 1024 && unit < len(byteUnits)-1 {
+		value /= 1024
+		unit++
+	}
+	return fmt.Sprintf("%.1f %s", value, byteUnits[unit])
+}
+
+// PhysicalMemoryHuman returns the physical memory of the machine (in bytes)
+// in a human readable format like "15.6 GiB".
+func (m *Machine) PhysicalMemoryHuman() string {
+	return humanBytes(m.PhysicalMemory)
+}
+
+// VirtualMemoryHuman returns the virtual memory of the machine (in bytes)
+// in a human readable format like "15.6 GiB".
+func (m *Machine) VirtualMemoryHuman() string {
+	return humanBytes(m.VirtualMemory)
+}
+
+// Cores returns the amount of cores of the machine.
+func (m *Machine) Cores() int64 {
+	return m.Sockets * m.CoresPerSocket
+}
+
+// Utilization returns the load of the machine per core, i.e. 1.0 means
+// that all cores are busy. When the amount of cores is not known the
+// load is returned.
+func (m *Machine) Utilization() float64 {
+	if cores := m.Cores(); cores > 0 {
+		return m.Load / float64(cores)
+	}
+	return m.Load
+}
+
+// Status returns "available", "draining", or "unavailable" followed by
+// the reason (if known) in brackets. Draining machines are reported as
+// draining independent of their availability.
+func (m *Machine) Status() string {
+	if m.Draining {
+		return "draining"
+	}
+	if m.Available {
+		return "available"
+	}
+	if m.UnavailableReason != "" {
+		return fmt.Sprintf("unavailable (%s)", m.UnavailableReason)
+	}
+	return "unavailable"
+}
+
+// MachineSummary contains the aggregated resources of the machines
+// of one cluster.
+type MachineSummary struct {
+	Cluster        string `json:"cluster" xml:"name,attr"`
+	Machines       int64  `json:"machines" xml:"machines"`
+	Available      int64  `json:"available" xml:"available"`
+	Cores          int64  `json:"cores" xml:"cores"`
+	PhysicalMemory int64  `json:"physicalMemory" xml:"physicalMemory"`
+	VirtualMemory  int64  `json:"virtualMemory" xml:"virtualMemory"`
+}
+
+// SummarizeMachinesByCluster groups the machines by the cluster they
+// belong to and sums up their resources. The summaries are sorted by
+// the cluster name.
+func SummarizeMachinesByCluster(machines []Machine) []MachineSummary {
+	summaries := make([]MachineSummary, 0)
+	index := make(map[string]int)
+	for i := range machines {
+		pos, exists := index[machines[i].Cluster]
+		if !exists {
+			pos = len(summaries)
+			index[machines[i].Cluster] = pos
+			summaries = append(summaries, MachineSummary{Cluster: machines[i].Cluster})
+		}
+		summaries[pos].Machines++
+		if machines[i].Available {
+			summaries[pos].Available++
+		}
+		summaries[pos].Cores += machines[i].Cores()
+		summaries[pos].PhysicalMemory += machines[i].PhysicalMemory
+		summaries[pos].VirtualMemory += machines[i].VirtualMemory
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Cluster < summaries[j].Cluster })
+	return summaries
+}
+
+// PhysicalMemoryHuman returns the physical memory of all machines
+// in a human readable format.
+func (ms *MachineSummary) PhysicalMemoryHuman() string {
+	return humanBytes(ms.PhysicalMemory)
+}
+
+// VirtualMemoryHuman returns the virtual memory of all machines
+// in a human readable format.
+func (ms *MachineSummary) VirtualMemoryHuman() string {
+	return humanBytes(ms.VirtualMemory)
+}
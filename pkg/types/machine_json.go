@@ -0,0 +1,64 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// unmarshalEnum parses the JSON representation of an enum which is
+// either its name or (like in former versions) its integer value.
+// The names are compared with the String() values of first to last.
+func unmarshalEnum(data []byte, kind string, last int, name func(int) string) (int, error) {
+	var value int
+	if err := json.Unmarshal(data, &value); err == nil {
+		return value, nil
+	}
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return 0, fmt.Errorf("%s must be a string or a number: %s", kind, string(data))
+	}
+	for i := 0; i <= last; i++ {
+		if name(i) == str {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("Unknown %s: %s", kind, str)
+}
+
+// MarshalJSON implements the json.Marshaler interface. The CPU
+// architecture is encoded by its name (like "x64").
+func (cpu CPU) MarshalJSON() ([]byte, error) {
+	return json.Marshal(cpu.String())
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. Besides
+// the name of the architecture its integer value is accepted.
+func (cpu *CPU) UnmarshalJSON(data []byte) error {
+	value, err := unmarshalEnum(data, "CPU architecture", int(SPARC64), func(i int) string {
+		return CPU(i).String()
+	})
+	if err != nil {
+		return err
+	}
+	*cpu = CPU(value)
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface. The operating
+// system is encoded by its name (like "Linux").
+func (os OS) MarshalJSON() ([]byte, error) {
+	return json.Marshal(os.String())
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. Besides
+// the name of the operating system its integer value is accepted.
+func (os *OS) UnmarshalJSON(data []byte) error {
+	value, err := unmarshalEnum(data, "operating system", int(WinNT), func(i int) string {
+		return OS(i).String()
+	})
+	if err != nil {
+		return err
+	}
+	*os = OS(value)
+	return nil
+}
@@ -0,0 +1,101 @@
+package types_test
+
+import (
+	"encoding/json"
+	"github.com/dgruber/ubercluster/pkg/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Machine", func() {
+
+	Context("formatting", func() {
+
+		It("should format the memory with binary units", func() {
+			m := types.Machine{PhysicalMemory: 512, VirtualMemory: 2048}
+			Ω(m.PhysicalMemoryHuman()).Should(Equal("512 B"))
+			Ω(m.VirtualMemoryHuman()).Should(Equal("2.0 KiB"))
+			m.PhysicalMemory = 16 * 1024 * 1024 * 1024
+			Ω(m.PhysicalMemoryHuman()).Should(Equal("16.0 GiB"))
+			m.PhysicalMemory = 1536 * 1024
+			Ω(m.PhysicalMemoryHuman()).Should(Equal("1.5 MiB"))
+		})
+
+		It("should return the load per core", func() {
+			m := types.Machine{Sockets: 2, CoresPerSocket: 4, Load: 2.0}
+			Ω(m.Cores()).Should(Equal(int64(8)))
+			Ω(m.Utilization()).Should(Equal(0.25))
+			m.Sockets = 0
+			Ω(m.Utilization()).Should(Equal(2.0))
+		})
+
+		It("should distinguish draining and unavailable machines", func() {
+			m := types.Machine{Available: true}
+			Ω(m.Status()).Should(Equal("available"))
+			m.Draining = true
+			Ω(m.Status()).Should(Equal("draining"))
+			m = types.Machine{Available: false}
+			Ω(m.Status()).Should(Equal("unavailable"))
+			m.UnavailableReason = "maintenance"
+			Ω(m.Status()).Should(Equal("unavailable (maintenance)"))
+		})
+
+	})
+
+	Context("summaries", func() {
+
+		It("should summarize the machines of each cluster", func() {
+			machines := []types.Machine{
+				{Name: "a1", Cluster: "b", Available: true, Sockets: 1, CoresPerSocket: 4, PhysicalMemory: 1024},
+				{Name: "b1", Cluster: "a", Available: true, Sockets: 2, CoresPerSocket: 8, PhysicalMemory: 4096},
+				{Name: "a2", Cluster: "b", Available: false, Sockets: 1, CoresPerSocket: 4, PhysicalMemory: 1024},
+			}
+			summaries := types.SummarizeMachinesByCluster(machines)
+			Ω(summaries).Should(HaveLen(2))
+			Ω(summaries[0].Cluster).Should(Equal("a"))
+			Ω(summaries[0].Machines).Should(Equal(int64(1)))
+			Ω(summaries[0].Cores).Should(Equal(int64(16)))
+			Ω(summaries[1].Cluster).Should(Equal("b"))
+			Ω(summaries[1].Machines).Should(Equal(int64(2)))
+			Ω(summaries[1].Available).Should(Equal(int64(1)))
+			Ω(summaries[1].Cores).Should(Equal(int64(8)))
+			Ω(summaries[1].PhysicalMemoryHuman()).Should(Equal("2.0 KiB"))
+		})
+
+		It("should return an empty list for no machines", func() {
+			Ω(types.SummarizeMachinesByCluster(nil)).Should(BeEmpty())
+		})
+
+	})
+
+	Context("JSON", func() {
+
+		It("should encode architecture and OS by name", func() {
+			out, err := json.Marshal(types.Machine{Name: "m1", Architecture: types.X64, OS: types.Linux})
+			Ω(err).Should(BeNil())
+			Ω(string(out)).Should(ContainSubstring(`"architecture":"x64"`))
+			Ω(string(out)).Should(ContainSubstring(`"os":"Linux"`))
+
+			var m types.Machine
+			Ω(json.Unmarshal(out, &m)).Should(BeNil())
+			Ω(m.Architecture).Should(Equal(types.X64))
+			Ω(m.OS).Should(Equal(types.Linux))
+		})
+
+		It("should accept the integer form of older proxies", func() {
+			var m types.Machine
+			Ω(json.Unmarshal([]byte(`{"architecture":8,"os":3}`), &m)).Should(BeNil())
+			Ω(m.Architecture).Should(Equal(types.X64))
+			Ω(m.OS).Should(Equal(types.Linux))
+		})
+
+		It("should reject unknown names", func() {
+			var m types.Machine
+			Ω(json.Unmarshal([]byte(`{"architecture":"z80"}`), &m)).ShouldNot(BeNil())
+			Ω(json.Unmarshal([]byte(`{"os":"Plan9"}`), &m)).ShouldNot(BeNil())
+		})
+
+	})
+
+})
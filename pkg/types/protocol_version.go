@@ -0,0 +1,51 @@
+package types
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ProtocolVersion is a version of the ubercluster protocol which is
+// the first path element of all requests to a proxy (like "v1").
+type ProtocolVersion string
+
+const (
+	// ProtocolV1 is the first version of the ubercluster protocol.
+	ProtocolV1 ProtocolVersion = "v1"
+)
+
+// Number returns the number of the protocol version ("v2" is 2).
+func (v ProtocolVersion) Number() (int, error) {
+	s := strings.Trim(string(v), "/")
+	if !strings.HasPrefix(s, "v") {
+		return 0, fmt.Errorf("invalid protocol version: %s", v)
+	}
+	n, err := strconv.Atoi(s[1:])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid protocol version: %s", v)
+	}
+	return n, nil
+}
+
+// HighestCommonProtocolVersion returns the highest protocol version
+// which is part of both lists. Invalid versions are ignored. False is
+// returned when there is no common version.
+func HighestCommonProtocolVersion(a, b []ProtocolVersion) (ProtocolVersion, bool) {
+	supported := make(map[int]bool, len(b))
+	for _, v := range b {
+		if n, err := v.Number(); err == nil {
+			supported[n] = true
+		}
+	}
+	highest := 0
+	for _, v := range a {
+		if n, err := v.Number(); err == nil && supported[n] && n > highest {
+			highest = n
+		}
+	}
+	if highest == 0 {
+		return "", false
+	}
+	return ProtocolVersion(fmt.Sprintf("v%d", highest)), true
+}
@@ -0,0 +1,40 @@
+package types_test
+
+import (
+	"github.com/dgruber/ubercluster/pkg/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ProtocolVersion", func() {
+
+	It("should parse the number of a protocol version", func() {
+		n, err := types.ProtocolV1.Number()
+		Ω(err).Should(BeNil())
+		Ω(n).Should(Equal(1))
+		n, err = types.ProtocolVersion("v12").Number()
+		Ω(err).Should(BeNil())
+		Ω(n).Should(Equal(12))
+		_, err = types.ProtocolVersion("1").Number()
+		Ω(err).ShouldNot(BeNil())
+		_, err = types.ProtocolVersion("vx").Number()
+		Ω(err).ShouldNot(BeNil())
+	})
+
+	It("should select the highest version supported by both sides", func() {
+		v, ok := types.HighestCommonProtocolVersion(
+			[]types.ProtocolVersion{"v1", "v2", "v3"},
+			[]types.ProtocolVersion{"v2", "v1", "v10"})
+		Ω(ok).Should(BeTrue())
+		Ω(v).Should(Equal(types.ProtocolVersion("v2")))
+	})
+
+	It("should report when there is no common version", func() {
+		_, ok := types.HighestCommonProtocolVersion(
+			[]types.ProtocolVersion{"v1"},
+			[]types.ProtocolVersion{"v2", "invalid"})
+		Ω(ok).Should(BeFalse())
+	})
+
+})
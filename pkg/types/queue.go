@@ -0,0 +1,39 @@
+package types
+
+// Utilization returns the fraction of used slots of the queue, i.e. 1.0
+// means that all slots are occupied. When the amount of slots is not
+// known 0 is returned.
+func (q *Queue) Utilization() float64 {
+	if q.TotalSlots <= 0 {
+		return 0
+	}
+	return float64(q.UsedSlots) / float64(q.TotalSlots)
+}
+
+// FreeSlots returns the amount of unused slots of the queue. When the
+// amount of slots is not known 0 is returned.
+func (q *Queue) FreeSlots() int64 {
+	if q.TotalSlots <= q.UsedSlots {
+		return 0
+	}
+	return q.TotalSlots - q.UsedSlots
+}
+
+// QueueSlots is the slot availability of a queue which is used for
+// selecting the cluster a job is submitted to.
+type QueueSlots struct {
+	Name       string `xml:"name" json:"name"`
+	UsedSlots  int64  `xml:"usedSlots" json:"usedSlots"`
+	TotalSlots int64  `xml:"totalSlots" json:"totalSlots"`
+	FreeSlots  int64  `xml:"freeSlots" json:"freeSlots"`
+}
+
+// Slots returns the slot availability of the queue.
+func (q *Queue) Slots() QueueSlots {
+	return QueueSlots{
+		Name:       q.Name,
+		UsedSlots:  q.UsedSlots,
+		TotalSlots: q.TotalSlots,
+		FreeSlots:  q.FreeSlots(),
+	}
+}
@@ -0,0 +1,27 @@
+package types_test
+
+import (
+	"github.com/dgruber/ubercluster/pkg/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Queue", func() {
+
+	It("should return the fraction of used slots", func() {
+		q := types.Queue{Name: "all.q", UsedSlots: 3, TotalSlots: 12}
+		Ω(q.Utilization()).Should(Equal(0.25))
+		q.TotalSlots = 0
+		Ω(q.Utilization()).Should(Equal(0.0))
+	})
+
+	It("should return the free slots", func() {
+		q := types.Queue{Name: "all.q", UsedSlots: 3, TotalSlots: 12}
+		Ω(q.FreeSlots()).Should(Equal(int64(9)))
+		Ω(q.Slots()).Should(Equal(types.QueueSlots{Name: "all.q", UsedSlots: 3, TotalSlots: 12, FreeSlots: 9}))
+		q.TotalSlots = 0
+		Ω(q.FreeSlots()).Should(Equal(int64(0)))
+	})
+
+})
@@ -25,3 +25,15 @@ type RunLocalRequest struct {
 	Command string
 	Arg     string
 }
+
+// ArrayJobRequest describes a bulk job submission of a job template.
+// The tasks are created from Begin to End (inclusive) with the given
+// Step size. MaxParallel limits the amount of tasks running at the
+// same time (0 means unlimited).
+type ArrayJobRequest struct {
+	JobTemplate JobTemplate `json:"jobTemplate"`
+	Begin       int         `json:"begin"`
+	End         int         `json:"end"`
+	Step        int         `json:"step"`
+	MaxParallel int         `json:"maxParallel"`
+}